@@ -1,7 +1,8 @@
 package account
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -11,20 +12,13 @@ import (
 
 	"github.com/fewsats/fewsatscli/apikeys"
 	"github.com/fewsats/fewsatscli/client"
+	"github.com/fewsats/fewsatscli/fewsats"
 	"github.com/urfave/cli/v2"
 	"golang.org/x/term"
 )
 
-const (
-	// loginPath is the path to the login endpoint.
-	loginPath = "/v0/auth/login"
-)
-
 // LoginRequest is the request body for the login endpoint.
-type LoginRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
-}
+type LoginRequest = fewsats.LoginRequest
 
 var loginCommand = &cli.Command{
 	Name:   "login",
@@ -53,7 +47,7 @@ func LoginCLI(c *cli.Context) error {
 	fmt.Println()
 
 	// Perform the login using the email and password
-	_, err = Login(email, password)
+	_, err = Login(c.Context, email, password)
 	if err != nil {
 		return cli.Exit("Login failed: "+err.Error(), 1)
 	}
@@ -63,8 +57,9 @@ func LoginCLI(c *cli.Context) error {
 }
 
 // Login to the fewsats API and return the session cookie
-func Login(email, password string) (*http.Cookie, error) {
-	method := http.MethodPost
+func Login(ctx context.Context, email,
+	password string) (*http.Cookie, error) {
+
 	client, err := client.NewHTTPClient()
 	if err != nil {
 		slog.Debug(
@@ -75,54 +70,26 @@ func Login(email, password string) (*http.Cookie, error) {
 		return nil, cli.Exit("Failed to create http client.", 1)
 	}
 
-	loginReqBody, err := json.Marshal(&LoginRequest{
-		Email:    email,
-		Password: password,
-	})
-	if err != nil {
-		slog.Debug(
-			"Failed to marshal login request body.",
-			"error", err,
-		)
-
-		return nil, cli.Exit("Failed to marshal login request body.", 1)
-	}
-
-	resp, err := client.ExecuteRequest(method, loginPath, loginReqBody)
-	if err != nil {
-		slog.Debug(
-			"Failed to execute login request.",
-			"error", err,
-		)
-
-		return nil, cli.Exit("Failed to execute login request.", 1)
-	}
-	defer resp.Body.Close()
+	sessionCookie, err := client.API().Auth.Login(
+		ctx, email, password,
+	)
+	switch {
+	case errors.Is(err, fewsats.ErrNoSession):
+		slog.Debug("Session cookie not found.")
+		return nil, cli.Exit("Session cookie not found.", 1)
 
-	if resp.StatusCode != http.StatusOK {
+	case err != nil:
 		slog.Debug(
 			"Login request failed.",
-			"status_code", resp.StatusCode,
+			"error", err,
 		)
 
 		return nil, cli.Exit("Login request failed.", 1)
 	}
 
-	// Get the session cookie from the response
-	var sessionCookie *http.Cookie
-	for _, cookie := range resp.Cookies() {
-		if cookie.Name == "fewsats_session" {
-			sessionCookie = cookie
-			break
-		}
-	}
-
-	if sessionCookie == nil {
-		slog.Debug("Session cookie not found.")
-		return nil, cli.Exit("Session cookie not found.", 1)
-	}
-
-	apiKey, expiresAt, err := apikeys.CreateAPIKey(24*7*4*time.Hour, "default", sessionCookie)
+	apiKey, expiresAt, err := apikeys.CreateAPIKey(
+		ctx, 24*7*4*time.Hour, "default", sessionCookie,
+	)
 	if err != nil {
 		slog.Debug("Failed to create API key on login.", "error", err)
 		return nil, cli.Exit("Failed to create API key on login.", 1)
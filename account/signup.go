@@ -1,25 +1,18 @@
 package account
 
 import (
-	"encoding/json"
 	"fmt"
-	"io"
 	"log/slog"
-	"net/http"
 	"net/mail"
 	"strings"
 	"syscall"
 
 	"github.com/fewsats/fewsatscli/client"
+	"github.com/fewsats/fewsatscli/fewsats"
 	"github.com/urfave/cli/v2"
 	"golang.org/x/term"
 )
 
-const (
-	// signupPath is the path to the signup endpoint.
-	signupPath = "/v0/auth/signup"
-)
-
 var signUpCommand = &cli.Command{
 	Name:   "signup",
 	Usage:  "Create a new account.",
@@ -28,11 +21,7 @@ var signUpCommand = &cli.Command{
 }
 
 // SignupRequest is the request body for the signup endpoint.
-type SignupRequest struct {
-	Email                string `json:"email"`
-	Password             string `json:"password"`
-	PasswordConfirmation string `json:"password2"`
-}
+type SignupRequest = fewsats.SignupRequest
 
 // signup creates a new account.
 func signup(c *cli.Context) error {
@@ -77,16 +66,6 @@ func signup(c *cli.Context) error {
 		PasswordConfirmation: passwordConfirmation,
 	}
 
-	reqBody, err := json.Marshal(req)
-	if err != nil {
-		slog.Debug(
-			"Failed to marshal JSON body.",
-			"error", err,
-		)
-
-		return cli.Exit("Failed to marshal JSON body.", 1)
-	}
-
 	client, err := client.NewHTTPClient()
 	if err != nil {
 		slog.Debug(
@@ -97,25 +76,11 @@ func signup(c *cli.Context) error {
 		return cli.Exit("Failed to create HTTP client.", 1)
 	}
 
-	resp, err := client.ExecuteRequest(http.MethodPost, signupPath, reqBody)
+	err = client.API().Auth.Signup(c.Context, &req)
 	if err != nil {
-		return cli.Exit(err.Error(), 1)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusCreated {
-		bodyBytes, err := io.ReadAll(resp.Body)
-		if err != nil {
-			slog.Debug(
-				"Failed to read response body.",
-				"error", err,
-			)
-		}
-
 		slog.Debug(
 			"Failed to create account.",
-			"status_code", resp.StatusCode,
-			"body", string(bodyBytes),
+			"error", err,
 		)
 
 		return cli.Exit("Failed to create account.", 1)
@@ -1,23 +1,19 @@
 package apikeys
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"time"
 
 	"github.com/fewsats/fewsatscli/client"
+	"github.com/fewsats/fewsatscli/fewsats"
 	"github.com/fewsats/fewsatscli/store"
 
 	"github.com/urfave/cli/v2"
 )
 
-const (
-	// apikeysPath is the path to the create/list api keys endpoint.
-	apikeysPath = "/v0/auth/apikey"
-)
-
 var createCommand = &cli.Command{
 	Name:  "new",
 	Usage: "Create a new api key.",
@@ -38,26 +34,14 @@ var createCommand = &cli.Command{
 }
 
 // CreateAPIKeyRequest is the request body for the create api key endpoint.
-type CreateAPIKeyRequest struct {
-	Duration time.Duration `json:"duration"`
-	Name     string        `json:"name"`
-}
+type CreateAPIKeyRequest = fewsats.CreateAPIKeyRequest
 
 // CreateAPIKeyResponse is the response body for the create api key endpoint.
-type CreateAPIKeyResponse struct {
-	APIKey    string     `json:"apikey"`
-	ExpiresAt *time.Time `json:"expires_at"`
-	UserID    int64      `json:"user_id"`
-}
+type CreateAPIKeyResponse = fewsats.CreateAPIKeyResponse
 
 // CreateAPIKey is an exported function to create an API key.
-func CreateAPIKey(duration time.Duration, name string, sessionCookie *http.Cookie) (string, *time.Time, error) {
-	req := &CreateAPIKeyRequest{Duration: duration, Name: name}
-	reqBody, err := json.Marshal(req)
-	if err != nil {
-		slog.Debug("Failed to marshal JSON body.", "error", err)
-		return "", nil, err
-	}
+func CreateAPIKey(ctx context.Context, duration time.Duration, name string,
+	sessionCookie *http.Cookie) (string, *time.Time, error) {
 
 	client, err := client.NewHTTPClient()
 	if err != nil {
@@ -69,21 +53,11 @@ func CreateAPIKey(duration time.Duration, name string, sessionCookie *http.Cooki
 		client.SetSessionCookie(sessionCookie)
 	}
 
-	resp, err := client.ExecuteRequest(http.MethodPost, apikeysPath, reqBody)
-	if err != nil {
-		slog.Debug("Failed to execute request.", "error", err)
-		return "", nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusCreated {
-		return "", nil, fmt.Errorf("request failed with status code: %d", resp.StatusCode)
-	}
-
-	var respData CreateAPIKeyResponse
-	err = json.NewDecoder(resp.Body).Decode(&respData)
+	respData, err := client.API().APIKeys.Create(
+		ctx, &CreateAPIKeyRequest{Duration: duration, Name: name},
+	)
 	if err != nil {
-		slog.Debug("Failed to decode response body.", "error", err)
+		slog.Debug("Failed to create API key.", "error", err)
 		return "", nil, err
 	}
 
@@ -108,7 +82,7 @@ func newApiKey(c *cli.Context) error {
 	duration := c.Duration("duration")
 	name := c.String("name")
 
-	apiKey, expiresAt, err := CreateAPIKey(duration, name, nil)
+	apiKey, expiresAt, err := CreateAPIKey(c.Context, duration, name, nil)
 	if err != nil {
 		return cli.Exit(err.Error(), 1)
 	}
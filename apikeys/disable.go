@@ -2,7 +2,7 @@ package apikeys
 
 import (
 	"fmt"
-	"net/http"
+	"log/slog"
 
 	"github.com/fewsats/fewsatscli/client"
 	"github.com/urfave/cli/v2"
@@ -31,12 +31,11 @@ func disableAPIKey(c *cli.Context) error {
 		return cli.Exit("Failed to create HTTP client", 1)
 	}
 
-	endpoint := fmt.Sprintf("/v0/auth/apikeys/%s/disable", apiKeyID)
-	resp, err := client.ExecuteRequest(http.MethodPost, endpoint, nil)
+	err = client.API().APIKeys.Disable(c.Context, apiKeyID)
 	if err != nil {
-		return cli.Exit("Failed to disable API key", 1)
+		slog.Debug("Failed to disable API key.", "error", err)
+		return cli.Exit(fmt.Sprintf("Failed to disable API key: %v", err), 1)
 	}
-	defer resp.Body.Close()
 
 	fmt.Println("API key disabled successfully")
 	return nil
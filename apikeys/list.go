@@ -4,11 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
-	"net/http"
 
-	// Added to support time.Time in APIKey struct
 	"github.com/fewsats/fewsatscli/client"
-	"github.com/fewsats/fewsatscli/store"
+	"github.com/fewsats/fewsatscli/fewsats"
 	"github.com/urfave/cli/v2"
 )
 
@@ -31,24 +29,18 @@ func listAPIKeys(c *cli.Context) error {
 		return cli.Exit("Failed to create HTTP client.", 1)
 	}
 
-	// Try using existing API key
-	resp, err := client.ExecuteRequest(http.MethodGet, "/v0/auth/apikeys?limit=100", nil)
+	keys, err := client.API().APIKeys.List(
+		c.Context, fewsats.ListOptions{Limit: 100},
+	)
 	if err != nil {
-		slog.Debug("Failed to execute request with API key.", "error", err)
-		return cli.Exit("Failed to execute request.", 1)
+		slog.Debug("Failed to list API keys.", "error", err)
+		return cli.Exit(fmt.Sprintf("Failed to list API keys: %v", err), 1)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return cli.Exit(fmt.Sprintf("Failed request status code: %d", resp.StatusCode), 1)
-	}
-
-	var response struct {
-		Keys []store.APIKey `json:"keys"`
-	}
-	err = json.NewDecoder(resp.Body).Decode(&response)
-	if err != nil {
-		return cli.Exit("Failed to decode API keys.", 1)
+	response := struct {
+		Keys []fewsats.APIKey `json:"keys"`
+	}{
+		Keys: keys,
 	}
 
 	jsonOutput, err := json.MarshalIndent(response, "", "  ")
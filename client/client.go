@@ -2,28 +2,54 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
-	"strings"
 
-	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/fewsats/fewsatscli/config"
 	"github.com/fewsats/fewsatscli/credentials"
+	"github.com/fewsats/fewsatscli/fewsats"
+	"github.com/fewsats/fewsatscli/l402"
+	"github.com/fewsats/fewsatscli/payments"
+	"github.com/fewsats/fewsatscli/policy"
 	"github.com/fewsats/fewsatscli/store"
 	"github.com/fewsats/fewsatscli/wallets"
-	"github.com/lightningnetwork/lnd/zpay32"
 )
 
+// Store is the store of the credentials, wallets, spending policy and payments
+// used by the HTTP client.
+type Store interface {
+	credentials.Store
+	policy.Store
+	wallets.Store
+	payments.Ledger
+	payments.PendingStore
+
+	// GetAPIKey returns an enabled API key, empty if there is none.
+	GetAPIKey() (string, error)
+}
+
 // HttpClient is an HTTP client for interacting with the Fewsats API.
 type HttpClient struct {
 	// client is the HTTP client used to make requests.
 	client *http.Client
 
-	// wallet is the interface provider used to get the preimage of an invoice.
-	wallet wallets.PreimageProvider
+	// l402Client is the HTTP client used to make requests to L402
+	// paywalled resources, paying the invoices with the default wallet.
+	l402Client *http.Client
+
+	// l402Transport pays the L402 invoices of the l402Client.
+	l402Transport *l402.Transport
+
+	// policy is the spending policy engine that decides whether an invoice
+	// can be paid.
+	policy *policy.Engine
+
+	// approver checks the L402 payments against the spending policy.
+	approver *policy.Approver
 
 	// apiKey is the API key used for authentication in our platform.
 	apiKey        string
@@ -39,7 +65,15 @@ func NewHTTPClient() (*HttpClient, error) {
 		return nil, fmt.Errorf("unable to create http client: %w", err)
 	}
 
-	store := store.GetStore()
+	return NewHTTPClientWithStore(cfg, store.GetStore())
+}
+
+// NewHTTPClientWithStore creates a new HTTP client with the given
+// configuration and store, paying the L402 invoices with the default wallet
+// of the store.
+func NewHTTPClientWithStore(cfg *config.Config,
+	store Store) (*HttpClient, error) {
+
 	apiKey, err := store.GetAPIKey()
 	if err != nil {
 		return nil, fmt.Errorf("unable to get valid API key: %w", err)
@@ -53,24 +87,100 @@ func NewHTTPClient() (*HttpClient, error) {
 		return nil, fmt.Errorf("unable to get default wallet: %w", err)
 	}
 
+	// The wallet ID is only used to tag the ledger records.
+	walletID, _ := store.GetDefaultWallet()
+
+	engine := policy.NewEngine(store)
+	approver := policy.NewApprover(engine, policy.TerminalPrompt)
+
+	// The API responses are small, the whole request is bounded. The L402
+	// responses can be large downloads, only the wait for the response
+	// headers is bounded.
+	base := l402.NewBaseTransport(cfg.ConnectTimeout, cfg.RequestTimeout)
+	transport := &l402.Transport{
+		Base:           base,
+		Store:          store,
+		Wallet:         wallet,
+		Approver:       approver,
+		Ledger:         store,
+		WalletID:       walletID,
+		Network:        cfg.Network,
+		Pending:        store,
+		PaymentTimeout: cfg.PaymentTimeout,
+	}
+
 	return &HttpClient{
-		client:    &http.Client{},
-		wallet:    wallet,
-		apiKey:    apiKey,
-		domain:    cfg.Domain,
-		albyToken: cfg.AlbyToken,
+		client:        newAPIClient(cfg),
+		l402Client:    &http.Client{Transport: transport},
+		l402Transport: transport,
+		policy:        engine,
+		approver:      approver,
+		apiKey:        apiKey,
+		domain:        cfg.Domain,
+		albyToken:     cfg.AlbyToken,
 	}, nil
 }
 
+// SetMaxPrice sets the price ceiling (sats) for the L402 payments done by
+// this client. Invoices up to this price are paid without asking the user.
+func (c *HttpClient) SetMaxPrice(sats uint64) {
+	c.policy.SetMaxPrice(sats)
+}
+
+// SetSessionBudget caps the amount (sats) paid by this client during its
+// lifetime.
+func (c *HttpClient) SetSessionBudget(sats uint64) {
+	c.policy.SetSessionBudget(sats)
+}
+
+// SetProbe makes the client send the L402 requests without body first to get
+// the challenge, so a large body is only uploaded once.
+func (c *HttpClient) SetProbe(probe bool) {
+	c.l402Transport.Probe = probe
+}
+
+// SetAmountlessSats sets the amount (sats) paid for the amountless L402
+// invoices, they are refused if zero.
+func (c *HttpClient) SetAmountlessSats(sats uint64) {
+	c.l402Transport.AmountlessSats = sats
+}
+
+// DisablePrompt makes the client refuse the payments that need the user
+// approval instead of asking, used by long running commands like the proxy.
+func (c *HttpClient) DisablePrompt() {
+	c.approver.SetPrompt(nil)
+}
+
 func (c *HttpClient) SetSessionCookie(sessionCookie *http.Cookie) {
 	c.sessionCookie = sessionCookie
 }
 
+// API returns a Fewsats API client sharing the credentials and the HTTP
+// clients of this client. The L402 paywalled resources are paid with the
+// default wallet under the spending policy.
+func (c *HttpClient) API() *fewsats.Client {
+	api := fewsats.NewClient(c.domain, c.apiKey)
+	api.SessionCookie = c.sessionCookie
+	api.HTTPClient = c.client
+	api.L402Client = fewsats.DoerFunc(c.DoL402Request)
+
+	return api
+}
+
+// ExecuteRequest executes a request to the Fewsats API.
 func (c *HttpClient) ExecuteRequest(method, path string,
 	body []byte) (*http.Response, error) {
 
+	return c.ExecuteRequestContext(context.Background(), method, path, body)
+}
+
+// ExecuteRequestContext executes a request to the Fewsats API, cancelled
+// when the context is done.
+func (c *HttpClient) ExecuteRequestContext(ctx context.Context, method,
+	path string, body []byte) (*http.Response, error) {
+
 	url := fmt.Sprintf("%s%s", c.domain, path)
-	req, err := http.NewRequest(method, url, nil)
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("unable to create request: %w", err)
 	}
@@ -94,11 +204,24 @@ func (c *HttpClient) ExecuteRequest(method, path string,
 	return resp, nil
 }
 
+// ExecuteMultipartRequest executes a request to the Fewsats API with a body
+// of the given content type.
 func (c *HttpClient) ExecuteMultipartRequest(method, path string,
 	body []byte, contentType string) (*http.Response, error) {
 
+	return c.ExecuteMultipartRequestContext(
+		context.Background(), method, path, body, contentType,
+	)
+}
+
+// ExecuteMultipartRequestContext executes a request to the Fewsats API with a
+// body of the given content type, cancelled when the context is done.
+func (c *HttpClient) ExecuteMultipartRequestContext(ctx context.Context,
+	method, path string, body []byte,
+	contentType string) (*http.Response, error) {
+
 	url := fmt.Sprintf("%s%s", c.domain, path)
-	req, err := http.NewRequest(method, url, nil)
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("unable to create request: %w", err)
 	}
@@ -124,44 +247,25 @@ func (c *HttpClient) ExecuteMultipartRequest(method, path string,
 	return resp, nil
 }
 
-// getExternalID retrieves the external ID from the URL.
-func getExternalID(url string) string {
-	urlParts := strings.Split(url, "/")
-	return urlParts[len(urlParts)-1]
+// ExecuteL402Request executes an HTTP request with the given method, path, and body.
+// If the response status code is 402, the invoice is paid if the spending
+// policy allows it, asking the user when needed.
+func (c *HttpClient) ExecuteL402Request(method, url string,
+	body io.Reader, contentType *string) (*http.Response, error) {
 
+	return c.ExecuteL402RequestContext(
+		context.Background(), method, url, body, contentType,
+	)
 }
 
-// getL402Credentials retrieves the L402 credentials from the database.
-func getL402Credentials(externalID string) (*credentials.L402Credentials,
+// ExecuteL402RequestContext is like ExecuteL402Request but the request is
+// cancelled when the context is done. A payment already started is not
+// abandoned, the credentials are saved before returning.
+func (c *HttpClient) ExecuteL402RequestContext(ctx context.Context, method,
+	url string, body io.Reader, contentType *string) (*http.Response,
 	error) {
 
-	store := store.GetStore()
-	creds, err := store.GetL402Credentials(externalID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get L402 credentials from db: %w",
-			err)
-	}
-
-	return creds, nil
-}
-
-func saveL402Credentials(creds *credentials.L402Credentials) error {
-	store := store.GetStore()
-	err := store.InsertL402Credentials(creds)
-	if err != nil {
-		return fmt.Errorf("failed to insert credentials to db: %w", err)
-	}
-
-	return nil
-}
-
-// ExecuteL402Request executes an HTTP request with the given method, path, and body.
-// If the response status code is 402, it will show the user the price of the request
-// and ask if they would like to proceed.
-func (c *HttpClient) ExecuteL402Request(method, url string,
-	body io.Reader, contentType *string) (*http.Response, error) {
-
-	req, err := http.NewRequest(method, url, body)
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return nil, fmt.Errorf("unable to create request: %w", err)
 	}
@@ -170,106 +274,29 @@ func (c *HttpClient) ExecuteL402Request(method, url string,
 		req.Header.Set("Content-Type", *contentType)
 	}
 
-	// check if we already paid the invoice and it's in the DB
-	externalID := getExternalID(url)
-	creds, err := getL402Credentials(externalID)
-
-	switch {
-	case errors.Is(err, credentials.ErrNoCredentialsFound):
-		// No credentials found, continue without credentials.
-	case err != nil:
-		return nil, fmt.Errorf("unable to get L402 credentials: %w", err)
-	default:
-		slog.Debug(
-			"Using existing L402 credentials",
-			"macaroon", creds.Macaroon,
-			"preimage", creds.Preimage,
-		)
-
-		header, err := creds.AuthenticationHeader()
-		if err != nil {
-			return nil, fmt.Errorf("unable to generate L402 auth header: %w",
-				err)
-		}
-
-		req.Header.Set("Authorization", header)
-	}
+	return c.DoL402Request(req)
+}
 
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("unable to execute request: %w", err)
-	}
+// DoL402Request sends the given request handling any L402 challenge on the
+// way. Stored credentials for the resource are attached to the request and,
+// if the server answers with a 402, the invoice is paid with the configured
+// wallet and the request is sent again with the new credentials. The request
+// is cancelled when its context is done.
+func (c *HttpClient) DoL402Request(req *http.Request) (*http.Response,
+	error) {
 
+	resp, err := c.l402Client.Do(req)
 	switch {
-	case resp.StatusCode > 200 && resp.StatusCode < 300:
-		return resp, nil
-
-	case resp.StatusCode != http.StatusPaymentRequired:
-		return resp, nil
-	}
-
-	// Make sure we have a valid wallet to pay the invoice.
-	if c.wallet == nil {
+	case errors.Is(err, l402.ErrNoWallet):
 		fmt.Println()
 		fmt.Println("unable to access L402 paywalled content: no wallet configured")
 		fmt.Println("run `fewsatscli wallet connect` to connect your wallet")
 		fmt.Println()
 
-		return nil, fmt.Errorf("unable to access L402 paywalled content")
-	}
-
-	creds, err = credentials.ParseL402Challenge(externalID, resp)
-	if err != nil {
-		return nil, fmt.Errorf("unable to parse L402 challenge: %w", err)
-	}
-
-	invoicePrice, err := DecodePrice(creds.Invoice)
-	if err != nil {
-		return nil, fmt.Errorf("unable to decode invoice price: %w", err)
-	}
-
-	fmt.Printf("URL: %s\n", url)
-	fmt.Printf("Lightning invoice price: %d sats\n", invoicePrice)
-	fmt.Print("Do you want to continue? (y/N): ")
-
-	var input string
-	_, err = fmt.Scanln(&input)
-	if err != nil {
-		return nil, fmt.Errorf("unable to read user input: %w", err)
-	}
-
-	input = strings.TrimSpace(input)
-	if input != "Y" && input != "y" {
-		return nil, fmt.Errorf("user chose not to continue")
-	}
-
-	preimage, err := c.wallet.GetPreimage(creds.Invoice)
-	if err != nil {
-		return nil, fmt.Errorf("unable to pay invoice: %w", err)
-	}
-
-	creds.Preimage = preimage
-
-	slog.Debug(
-		"Paid invoice",
-		"macaroon", creds.Macaroon,
-		"invoice", creds.Invoice,
-		"preimage", creds.Preimage,
-	)
-
-	err = saveL402Credentials(creds)
-	if err != nil {
-		return nil, fmt.Errorf("unable to save L402 credentials: %w", err)
-	}
-
-	authHeader, err := creds.AuthenticationHeader()
-	if err != nil {
-		return nil, fmt.Errorf("unable to generate L402 auth header: %w", err)
-	}
+		return nil, fmt.Errorf("unable to access L402 paywalled content: %w",
+			err)
 
-	req.Header.Set("Authorization", authHeader)
-	resp, err = c.client.Do(req)
-	if err != nil {
+	case err != nil:
 		return nil, fmt.Errorf("unable to execute request: %w", err)
 	}
 
@@ -277,32 +304,10 @@ func (c *HttpClient) ExecuteL402Request(method, url string,
 }
 
 // DecodePrice decodes a price from a ln payment request.
+//
+// Deprecated: use l402.DecodePrice.
 func DecodePrice(invoice string) (uint64, error) {
-	if len(invoice) < 2 {
-		return 0, errors.New("bolt11 too short")
-	}
-
-	firstNumber := strings.IndexAny(invoice, "1234567890")
-	if firstNumber < 2 {
-		return 0, errors.New("invalid bolt11 invoice")
-	}
-
-	chainPrefix := strings.ToLower(invoice[2:firstNumber])
-	chain := &chaincfg.Params{
-		Bech32HRPSegwit: chainPrefix,
-	}
-
-	inv, err := zpay32.Decode(invoice, chain)
-	if err != nil {
-		return 0, fmt.Errorf("zpay32 decoding failed: %w", err)
-	}
-
-	var msat int64
-	if inv.MilliSat != nil {
-		msat = int64(*inv.MilliSat)
-	}
-
-	return uint64(msat / 1000), nil
+	return l402.DecodePrice(invoice)
 }
 
 // RequiresLogin checks for a valid session or API key.
@@ -319,7 +324,7 @@ func RequiresLogin() error {
 		return fmt.Errorf("failed to create request for /v0/auth/me: %w", err)
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := newAPIClient(cfg).Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to execute request for /v0/auth/me: %w", err)
 	}
@@ -376,7 +381,7 @@ func verifyAPIKey(key string) (*http.Response, error) {
 
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", key))
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := newAPIClient(cfg).Do(req)
 	if err != nil {
 		slog.Debug("Failed to execute authorize request", "error", err,
 			"key", key)
@@ -386,3 +391,13 @@ func verifyAPIKey(key string) (*http.Response, error) {
 
 	return resp, nil
 }
+
+// newAPIClient returns an HTTP client bounded by the configured timeouts.
+func newAPIClient(cfg *config.Config) *http.Client {
+	return &http.Client{
+		Transport: l402.NewBaseTransport(
+			cfg.ConnectTimeout, cfg.RequestTimeout,
+		),
+		Timeout: cfg.RequestTimeout,
+	}
+}
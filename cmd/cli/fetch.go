@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/fewsats/fewsatscli/client"
+	"github.com/fewsats/fewsatscli/config"
+	"github.com/fewsats/fewsatscli/l402"
+	"github.com/urfave/cli/v2"
+)
+
+// FetchSummary is the JSON summary printed by the fetch command when the
+// --json flag is set.
+type FetchSummary struct {
+	URL           string      `json:"url"`
+	Method        string      `json:"method"`
+	StatusCode    int         `json:"status_code"`
+	Status        string      `json:"status"`
+	Headers       http.Header `json:"headers"`
+	ContentLength int64       `json:"content_length"`
+	Output        string      `json:"output,omitempty"`
+	Body          string      `json:"body,omitempty"`
+}
+
+var fetchCommand = &cli.Command{
+	Name:      "fetch",
+	Usage:     "Fetch any L402 paywalled URL, paying the invoice if needed.",
+	ArgsUsage: "<url>",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:    "request",
+			Aliases: []string{"X"},
+			Value:   http.MethodGet,
+			Usage:   "HTTP method to use for the request (GET, POST, PUT, etc.)",
+		},
+		&cli.StringSliceFlag{
+			Name:    "header",
+			Aliases: []string{"H"},
+			Usage:   "Extra header to send, as 'Name: value' (can be used multiple times)",
+		},
+		&cli.StringFlag{
+			Name:    "data",
+			Aliases: []string{"d"},
+			Usage:   "Request body to send",
+		},
+		&cli.StringFlag{
+			Name:  "data-file",
+			Usage: "File with the request body to send, use '-' to read it from stdin",
+		},
+		&cli.StringFlag{
+			Name:    "output",
+			Aliases: []string{"o"},
+			Usage:   "Write the response body to the given file instead of stdout",
+		},
+		&cli.BoolFlag{
+			Name:    "include",
+			Aliases: []string{"i"},
+			Usage:   "Print the response status and headers before the body",
+		},
+		&cli.BoolFlag{
+			Name:  "json",
+			Usage: "Print a JSON summary of the response",
+		},
+		&cli.BoolFlag{
+			Name:    "fail",
+			Aliases: []string{"f"},
+			Usage:   "Exit with an error if the response status code is >= 400",
+		},
+		&cli.Uint64Flag{
+			Name:  "max-price",
+			Usage: "Pay invoices up to this price (sats) without asking, refuse above it",
+		},
+		&cli.BoolFlag{
+			Name:  "probe",
+			Usage: "Send the request without body first to get the L402 challenge, so a large body is only uploaded once",
+		},
+		&cli.Uint64Flag{
+			Name:  "amount",
+			Usage: "Amount (sats) to pay if the L402 invoice has no amount, amountless invoices are refused without it",
+		},
+	},
+	Action: fetch,
+}
+
+// fetch requests the given URL handling any L402 challenge with the stored
+// credentials and the default wallet.
+func fetch(c *cli.Context) error {
+	if c.Args().Len() < 1 {
+		return cli.Exit("missing <url> argument", 1)
+	}
+
+	targetURL := c.Args().Get(0)
+	_, err := url.ParseRequestURI(targetURL)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("invalid url: %s", targetURL), 1)
+	}
+
+	body, err := readRequestBody(c)
+	if err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+
+	method := strings.ToUpper(c.String("request"))
+	req, err := http.NewRequestWithContext(c.Context, method, targetURL, body)
+	if err != nil {
+		slog.Debug("Failed to create request.", "error", err)
+		return cli.Exit("failed to create request", 1)
+	}
+
+	for _, header := range c.StringSlice("header") {
+		name, value, found := strings.Cut(header, ":")
+		if !found || strings.TrimSpace(name) == "" {
+			return cli.Exit(fmt.Sprintf("invalid header format: %s", header), 1)
+		}
+
+		req.Header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+
+	store, ok := c.App.Metadata["store"].(client.Store)
+	if !ok {
+		return errors.New("failed to get store from context")
+	}
+
+	cfg, ok := c.App.Metadata["config"].(*config.Config)
+	if !ok {
+		return errors.New("failed to get config from context")
+	}
+
+	httpClient, err := client.NewHTTPClientWithStore(cfg, store)
+	if err != nil {
+		slog.Debug("Failed to create HTTP client.", "error", err)
+		return cli.Exit("failed to create HTTP client", 1)
+	}
+
+	if c.IsSet("max-price") {
+		httpClient.SetMaxPrice(c.Uint64("max-price"))
+	}
+	httpClient.SetAmountlessSats(c.Uint64("amount"))
+	httpClient.SetProbe(c.Bool("probe"))
+
+	resp, err := httpClient.DoL402Request(req)
+	switch {
+	// The client already told the user how to connect a wallet.
+	case errors.Is(err, l402.ErrNoWallet):
+		return cli.Exit("failed to execute request: no wallet configured "+
+			"to pay the L402 invoice", 1)
+
+	case err != nil:
+		slog.Debug("Failed to execute L402 request.", "error", err,
+			"method", method, "url", targetURL)
+		return cli.Exit(err.Error(), 1)
+	}
+	defer resp.Body.Close()
+
+	err = writeResponse(c, req, resp)
+	if err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+
+	if c.Bool("fail") && resp.StatusCode >= 400 {
+		return cli.Exit(fmt.Sprintf("request failed with status code: %d",
+			resp.StatusCode), 1)
+	}
+
+	return nil
+}
+
+// readRequestBody returns the request body set with the --data or
+// --data-file flags, or nil if none was given.
+func readRequestBody(c *cli.Context) (io.Reader, error) {
+	data := c.String("data")
+	dataFile := c.String("data-file")
+
+	switch {
+	case data != "" && dataFile != "":
+		return nil, fmt.Errorf("only one of --data and --data-file can be " +
+			"used")
+
+	case data != "":
+		return strings.NewReader(data), nil
+
+	// The bodies are streamed, the Transport rewinds the files or buffers
+	// the small bodies to send them again after paying.
+	case dataFile == "-":
+		return os.Stdin, nil
+
+	case dataFile != "":
+		file, err := os.Open(dataFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open body file: %w", err)
+		}
+
+		return file, nil
+	}
+
+	return nil, nil
+}
+
+// writeResponse prints the response according to the output flags.
+func writeResponse(c *cli.Context, req *http.Request,
+	resp *http.Response) error {
+
+	output := c.String("output")
+
+	var (
+		bodyWriter io.Writer = os.Stdout
+		bodyBuffer bytes.Buffer
+	)
+	switch {
+	case output != "":
+		outFile, err := os.Create(output)
+		if err != nil {
+			slog.Debug("Failed to create file", "file_name", output,
+				"error", err)
+			return fmt.Errorf("failed to create file")
+		}
+		defer outFile.Close()
+
+		bodyWriter = outFile
+
+	case c.Bool("json"):
+		bodyWriter = &bodyBuffer
+	}
+
+	if c.Bool("include") && !c.Bool("json") {
+		fmt.Printf("%s %s\n", resp.Proto, resp.Status)
+		printHeaders(resp.Header)
+		fmt.Println()
+	}
+
+	written, err := io.Copy(bodyWriter, resp.Body)
+	if err != nil {
+		slog.Debug("Failed to read response body", "error", err)
+		return fmt.Errorf("failed to read response body")
+	}
+
+	if !c.Bool("json") {
+		return nil
+	}
+
+	summary := FetchSummary{
+		URL:           req.URL.String(),
+		Method:        req.Method,
+		StatusCode:    resp.StatusCode,
+		Status:        resp.Status,
+		Headers:       resp.Header,
+		ContentLength: written,
+		Output:        output,
+		Body:          bodyBuffer.String(),
+	}
+
+	jsonOutput, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON")
+	}
+
+	fmt.Println(string(jsonOutput))
+
+	return nil
+}
+
+// printHeaders prints the headers sorted by name.
+func printHeaders(headers http.Header) {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, value := range headers[name] {
+			fmt.Printf("%s: %s\n", name, value)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/fewsats/fewsatscli/config"
+	"github.com/fewsats/fewsatscli/fewsatstest"
+	"github.com/fewsats/fewsatscli/store"
+	"github.com/fewsats/fewsatscli/wallets"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/macaroon.v2"
+)
+
+// l402Server is an L402 paywalled resource asking for an invoice of a fixed
+// amount.
+type l402Server struct {
+	*httptest.Server
+
+	// challenges is the number of requests answered with a challenge.
+	challenges atomic.Int32
+}
+
+// newL402Server returns an L402 paywalled resource asking for an invoice of
+// the given amount. Its preimage is written to the preimage dir of the dev
+// wallet.
+func newL402Server(t *testing.T, amountSats uint64,
+	preimageDir string) *l402Server {
+
+	t.Helper()
+
+	srv, err := fewsatstest.New(fewsatstest.Config{})
+	require.NoError(t, err)
+
+	invoice, preimage, err := srv.CreateInvoice(amountSats, "test")
+	require.NoError(t, err)
+
+	preimageBytes, err := hex.DecodeString(preimage)
+	require.NoError(t, err)
+	paymentHash := sha256.Sum256(preimageBytes)
+
+	err = os.WriteFile(
+		filepath.Join(preimageDir, hex.EncodeToString(paymentHash[:])),
+		[]byte(preimage), 0600,
+	)
+	require.NoError(t, err)
+
+	var id bytes.Buffer
+	require.NoError(t, binary.Write(&id, binary.BigEndian, uint16(0)))
+	id.Write(paymentHash[:])
+	id.Write(bytes.Repeat([]byte{1}, 32))
+
+	mac, err := macaroon.New(
+		[]byte("root-key"), id.Bytes(), "fewsats", macaroon.LatestVersion,
+	)
+	require.NoError(t, err)
+	macBytes, err := mac.MarshalBinary()
+	require.NoError(t, err)
+	encodedMac := base64.StdEncoding.EncodeToString(macBytes)
+
+	server := &l402Server{}
+	server.Server = httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			expected := fmt.Sprintf("L402 %s:%s", encodedMac, preimage)
+			if r.Header.Get("Authorization") == expected {
+				fmt.Fprint(w, "paid content")
+				return
+			}
+
+			server.challenges.Add(1)
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(
+				`L402 macaroon="%s", invoice="%s"`, encodedMac,
+				invoice,
+			))
+			w.WriteHeader(http.StatusPaymentRequired)
+		},
+	))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// newFetchApp returns an app running the l402 commands with a new store whose
+// default wallet is a dev wallet reading the preimages from the given dir.
+func newFetchApp(t *testing.T, preimageDir string) *cli.App {
+	t.Helper()
+
+	dir := t.TempDir()
+	st, err := store.NewStore(filepath.Join(dir, "test.db"))
+	require.NoError(t, err)
+	require.NoError(t, st.RunMigrations())
+
+	token, err := json.Marshal(&wallets.DevConfig{PreimageDir: preimageDir})
+	require.NoError(t, err)
+
+	id, err := st.InsertWallet(wallets.WalletTypeDev)
+	require.NoError(t, err)
+	require.NoError(t, st.InsertWalletToken(id, string(token)))
+	require.NoError(t, st.SetDefaultWallet(id))
+
+	return &cli.App{
+		Commands: []*cli.Command{l402Command()},
+		Metadata: map[string]any{
+			"store": st,
+			"config": &config.Config{
+				Network: "mainnet",
+			},
+		},
+		// The exit status is checked by the tests instead of exiting.
+		ExitErrHandler: func(*cli.Context, error) {},
+	}
+}
+
+func TestFetch(t *testing.T) {
+	preimageDir := t.TempDir()
+	server := newL402Server(t, 10, preimageDir)
+	app := newFetchApp(t, preimageDir)
+
+	// The invoice is paid and the request is sent again with the new
+	// credentials.
+	output := filepath.Join(t.TempDir(), "out")
+	err := app.RunContext(context.Background(), []string{
+		"fewsatscli", "l402", "fetch", "--max-price", "10",
+		"--output", output, server.URL,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int32(1), server.challenges.Load())
+
+	body, err := os.ReadFile(output)
+	require.NoError(t, err)
+	require.Equal(t, "paid content", string(body))
+
+	// The stored credentials are reused without paying again.
+	err = app.RunContext(context.Background(), []string{
+		"fewsatscli", "l402", "fetch", "--output", output, server.URL,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int32(1), server.challenges.Load())
+}
+
+func TestFetchRefused(t *testing.T) {
+	preimageDir := t.TempDir()
+	server := newL402Server(t, 10, preimageDir)
+	app := newFetchApp(t, preimageDir)
+
+	// An invoice above --max-price is refused and the command fails.
+	err := app.RunContext(context.Background(), []string{
+		"fewsatscli", "l402", "fetch", "--max-price", "5", server.URL,
+	})
+	require.ErrorContains(t, err, "exceeds --max-price 5 sats")
+
+	var exitErr cli.ExitCoder
+	require.ErrorAs(t, err, &exitErr)
+	require.Equal(t, 1, exitErr.ExitCode())
+}
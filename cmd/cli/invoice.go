@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/fewsats/fewsatscli/invoices"
+	"github.com/fewsats/fewsatscli/l402"
+	"github.com/urfave/cli/v2"
+)
+
+// invoiceCommand creates the invoice command with subcommands.
+func invoiceCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "invoice",
+		Usage: "Inspect lightning invoices.",
+		Subcommands: []*cli.Command{
+			decodeInvoiceCommand,
+		},
+	}
+}
+
+var decodeInvoiceCommand = &cli.Command{
+	Name: "decode",
+	Usage: "Decode a BOLT11 invoice, a lightning: URI or a " +
+		"WWW-Authenticate header.",
+	ArgsUsage: "<invoice>",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "json",
+			Usage: "Print the invoice as JSON instead of a table",
+		},
+	},
+	Action: decodeInvoice,
+}
+
+// decodeInvoice prints the details of the given invoice.
+func decodeInvoice(c *cli.Context) error {
+	if c.Args().Len() < 1 {
+		return cli.Exit("missing <invoice> argument", 1)
+	}
+
+	// A header may be given unquoted, split in several arguments.
+	input := strings.Join(c.Args().Slice(), " ")
+
+	invoice, err := l402.ExtractInvoice(input)
+	if err != nil {
+		slog.Debug("Failed to extract invoice.", "error", err)
+		return cli.Exit(fmt.Sprintf("no invoice found: %v", err), 1)
+	}
+
+	details, err := invoices.Parse(invoice)
+	if err != nil {
+		slog.Debug("Failed to decode invoice.", "error", err)
+		return cli.Exit(fmt.Sprintf("failed to decode invoice: %v", err), 1)
+	}
+
+	if c.Bool("json") {
+		jsonOutput, err := json.MarshalIndent(details, "", "  ")
+		if err != nil {
+			return cli.Exit("failed to marshal JSON", 1)
+		}
+
+		fmt.Println(string(jsonOutput))
+
+		return nil
+	}
+
+	err = printInvoice(os.Stdout, details, time.Now())
+	if err != nil {
+		return cli.Exit("failed to print invoice", 1)
+	}
+
+	return nil
+}
+
+// printInvoice writes the invoice details as a readable table.
+func printInvoice(w io.Writer, d *invoices.Details, now time.Time) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	network := d.Network
+	if network == "" {
+		network = "unknown"
+	}
+	fmt.Fprintf(tw, "Network:\t%s\n", network)
+
+	if d.Amountless {
+		fmt.Fprintf(tw, "Amount:\tnone (amountless)\n")
+	} else {
+		fmt.Fprintf(tw, "Amount:\t%d sats (%d msat)\n", d.AmountSats,
+			d.AmountMsat)
+	}
+
+	fmt.Fprintf(tw, "Payment hash:\t%s\n", d.PaymentHash)
+	fmt.Fprintf(tw, "Payee:\t%s\n", d.Payee)
+
+	switch {
+	case d.Description != "":
+		fmt.Fprintf(tw, "Description:\t%s\n", d.Description)
+
+	case d.DescriptionHash != "":
+		fmt.Fprintf(tw, "Description hash:\t%s\n", d.DescriptionHash)
+	}
+
+	fmt.Fprintf(tw, "Timestamp:\t%s\n", d.CreatedAt.Format(time.RFC3339))
+
+	expiry := fmt.Sprintf("%s (%s)", d.ExpiresAt.Format(time.RFC3339),
+		time.Duration(d.ExpirySeconds)*time.Second)
+	if !now.Before(d.ExpiresAt) {
+		expiry += ", expired"
+	}
+	fmt.Fprintf(tw, "Expires:\t%s\n", expiry)
+
+	fmt.Fprintf(tw, "Min final CLTV expiry:\t%d\n", d.MinFinalCLTVExpiry)
+
+	for i, route := range d.RouteHints {
+		for j, hop := range route {
+			label := ""
+			if j == 0 {
+				label = fmt.Sprintf("Route hint %d:", i+1)
+			}
+			fmt.Fprintf(tw, "%s\t%s via %s (fee %d msat + %d ppm, "+
+				"cltv delta %d)\n", label, hop.NodeID, hop.ChannelID,
+				hop.FeeBaseMsat, hop.FeeProportionalMillionths,
+				hop.CLTVExpiryDelta)
+		}
+	}
+
+	for i, feature := range d.Features {
+		label := ""
+		if i == 0 {
+			label = "Features:"
+		}
+
+		kind := "optional"
+		if feature.Required {
+			kind = "required"
+		}
+		fmt.Fprintf(tw, "%s\t%d %s (%s)\n", label, feature.Bit,
+			feature.Name, kind)
+	}
+
+	return tw.Flush()
+}
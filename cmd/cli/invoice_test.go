@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/fewsats/fewsatscli/fewsatstest"
+	"github.com/fewsats/fewsatscli/invoices"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrintInvoice(t *testing.T) {
+	srv, err := fewsatstest.New(fewsatstest.Config{
+		Network: invoices.NetworkRegtest,
+	})
+	require.NoError(t, err)
+
+	invoice, _, err := srv.CreateInvoice(10, "test invoice")
+	require.NoError(t, err)
+
+	details, err := invoices.Parse(invoice)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	err = printInvoice(&buf, details, details.ExpiresAt)
+	require.NoError(t, err)
+
+	out := buf.String()
+	require.Contains(t, out, "regtest")
+	require.Contains(t, out, "10 sats (10000 msat)")
+	require.Contains(t, out, details.PaymentHash)
+	require.Contains(t, out, details.Payee)
+	require.Contains(t, out, "test invoice")
+	require.Contains(t, out, "expired")
+
+	buf.Reset()
+	err = printInvoice(&buf, details, time.Now())
+	require.NoError(t, err)
+	require.NotContains(t, buf.String(), "expired")
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"github.com/urfave/cli/v2"
+)
+
+// l402Command creates the l402 command with subcommands. It is built on top of
+// the l402 package, which does not depend on the CLI.
+func l402Command() *cli.Command {
+	return &cli.Command{
+		Name:  "l402",
+		Usage: "Interact with any L402 paywalled service.",
+		Subcommands: []*cli.Command{
+			fetchCommand,
+		},
+	}
+}
@@ -1,19 +1,26 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
 	"log/slog"
 	"os"
+	"os/signal"
 	"path/filepath"
 
 	"github.com/fewsats/fewsatscli/account"
 	"github.com/fewsats/fewsatscli/apikeys"
 	"github.com/fewsats/fewsatscli/config"
+	"github.com/fewsats/fewsatscli/credentials"
+	"github.com/fewsats/fewsatscli/dev"
 	"github.com/fewsats/fewsatscli/gateway"
 	"github.com/fewsats/fewsatscli/macaroons"
+	"github.com/fewsats/fewsatscli/payments"
 	"github.com/fewsats/fewsatscli/payout"
+	"github.com/fewsats/fewsatscli/policy"
+	"github.com/fewsats/fewsatscli/proxy"
 	"github.com/fewsats/fewsatscli/storage"
 	"github.com/fewsats/fewsatscli/store"
 	"github.com/fewsats/fewsatscli/users"
@@ -76,19 +83,25 @@ func main() {
 				slog.SetLogLoggerLevel(slog.LevelError)
 			}
 
-			// Setup the store.
+			// Setup the store, its secrets are unlocked with the key
+			// file of the profile or a passphrase if encrypted.
+			keySource := store.NewKeySource(cfg.StoreKeyFile)
 			store, err := store.NewStore(cfg.DBFilePath)
 			if err != nil {
 				log.Fatal("Failed to create store:", err)
 			}
+			store.SetKeySource(keySource)
+			store.SetAPIURL(cfg.Domain)
 
 			// Run the migrations if needed.
 			if err = store.RunMigrations(); err != nil {
 				log.Fatal("Failed to run migrations:", err)
 			}
 
-			// Save the store in the App.Metadata field.
+			// Save the store and the config in the App.Metadata
+			// field.
 			c.App.Metadata["store"] = store
+			c.App.Metadata["config"] = cfg
 
 			return nil
 		},
@@ -101,10 +114,35 @@ func main() {
 			users.Command(),
 			gateway.Command(),
 			payout.Command(),
+			l402Command(),
+			invoiceCommand(),
+			credentials.Command(),
+			payments.Command(),
+			policy.Command(),
+			proxy.Command(),
+			dev.Command(),
+			store.Command(),
 		},
 	}
 
-	err = app.Run(os.Args)
+	// The first Ctrl-C cancels the running command cleanly, a payment in
+	// flight is finished and its credentials saved. A second one quits
+	// right away.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		signal.Stop(interrupt)
+
+		fmt.Fprintln(os.Stderr, "Interrupted, press Ctrl-C again to "+
+			"quit right away.")
+		cancel()
+	}()
+
+	err = app.RunContext(ctx, os.Args)
 	if err != nil {
 		fmt.Println(err)
 	}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"os/user"
 	"path/filepath"
+	"time"
 
 	"gopkg.in/ini.v1"
 )
@@ -12,6 +13,16 @@ import (
 const (
 	// baseURL is the base URL for the Fewsats API.
 	baseURL = "https://api.fewsats.com"
+
+	// defaultConnectTimeout is the default time to establish a connection.
+	defaultConnectTimeout = 10 * time.Second
+
+	// defaultRequestTimeout is the default time to wait for a response.
+	defaultRequestTimeout = 60 * time.Second
+
+	// defaultPaymentTimeout is the default time to wait for the wallet to
+	// pay an invoice.
+	defaultPaymentTimeout = 60 * time.Second
 )
 
 var (
@@ -25,8 +36,24 @@ type Config struct {
 	Domain     string
 	AlbyToken  string
 	LogLevel   string
+	Network    string
 	ConfigDir  string
 	DBFilePath string
+
+	// ConnectTimeout bounds the time to establish a connection.
+	ConnectTimeout time.Duration
+
+	// RequestTimeout bounds the time to wait for a response. The body of
+	// the L402 responses, like file downloads, is not bounded by it.
+	RequestTimeout time.Duration
+
+	// PaymentTimeout bounds the time to wait for the wallet to pay an
+	// invoice.
+	PaymentTimeout time.Duration
+
+	// StoreKeyFile is the key file unlocking the encrypted secrets of the
+	// store. If empty, a passphrase is asked for.
+	StoreKeyFile string
 }
 
 func getConfigSection(configFilePath, profile string) (*ini.Section, error) {
@@ -88,13 +115,28 @@ func GetConfig() (*Config, error) {
 	domain := section.Key("DOMAIN").MustString(baseURL)
 	albyToken := section.Key("ALBY_TOKEN").MustString("")
 	logLevel := section.Key("LOG_LEVEL").MustString("info")
+	network := section.Key("NETWORK").MustString("mainnet")
+	connectTimeout := section.Key("CONNECT_TIMEOUT").
+		MustDuration(defaultConnectTimeout)
+	requestTimeout := section.Key("REQUEST_TIMEOUT").
+		MustDuration(defaultRequestTimeout)
+	paymentTimeout := section.Key("PAYMENT_TIMEOUT").
+		MustDuration(defaultPaymentTimeout)
+	storeKeyFile := section.Key("STORE_KEY_FILE").MustString("")
 
 	loadedConfig = &Config{
 		Domain:     domain,
 		AlbyToken:  albyToken,
 		LogLevel:   logLevel,
+		Network:    network,
 		ConfigDir:  configDir,
 		DBFilePath: dbFilePath,
+
+		ConnectTimeout: connectTimeout,
+		RequestTimeout: requestTimeout,
+		PaymentTimeout: paymentTimeout,
+
+		StoreKeyFile: storeKeyFile,
 	}
 
 	return loadedConfig, nil
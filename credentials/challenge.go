@@ -0,0 +1,474 @@
+package credentials
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// SchemeL402 is the authentication scheme of the L402 challenges.
+	SchemeL402 = "L402"
+
+	// SchemeLSAT is the legacy name of the L402 authentication scheme.
+	SchemeLSAT = "LSAT"
+)
+
+var (
+	// ErrNoChallenge is the error returned when the WWW-Authenticate headers
+	// do not contain any L402 (or LSAT) challenge.
+	ErrNoChallenge = errors.New("no L402 challenge/empty header found")
+
+	// ErrMissingParams is the error returned when an L402 challenge lacks
+	// the macaroon or the invoice.
+	ErrMissingParams = errors.New("missing macaroon/invoice in challenge")
+
+	// ErrMalformedHeader is the error wrapped by ParseError.
+	ErrMalformedHeader = errors.New("malformed WWW-Authenticate header")
+)
+
+// ParseError is the error returned when a WWW-Authenticate header can not be
+// parsed.
+type ParseError struct {
+	// Header is the header value being parsed.
+	Header string
+
+	// Offset is the position of the header where the parser failed.
+	Offset int
+
+	// Reason describes the syntax error.
+	Reason string
+}
+
+// Error implements the error interface.
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%v at offset %d: %s", ErrMalformedHeader, e.Offset,
+		e.Reason)
+}
+
+// Unwrap returns ErrMalformedHeader so callers can use errors.Is.
+func (e *ParseError) Unwrap() error {
+	return ErrMalformedHeader
+}
+
+// Challenge is an authentication challenge as defined in RFC 7235, section
+// 2.1. It has either a token68 or a list of auth-params.
+type Challenge struct {
+	// Scheme is the authentication scheme as found in the header.
+	Scheme string
+
+	// Token68 is the token68 form of the challenge, if used.
+	Token68 string
+
+	// Params holds the auth-params by their lower cased name.
+	Params map[string]string
+}
+
+// L402Challenge is an L402 (or legacy LSAT) challenge.
+type L402Challenge struct {
+	// Scheme is the scheme of the challenge, L402 or LSAT.
+	Scheme string
+
+	// Macaroon is the base64 encoded macaroon.
+	Macaroon string
+
+	// Invoice is the LN invoice to pay for the macaroon.
+	Invoice string
+
+	// Version is the version of the L402 protocol announced by the server,
+	// empty if the server did not send it.
+	Version string
+
+	// Params holds all the auth-params of the challenge, including the
+	// ones above and any extension.
+	Params map[string]string
+}
+
+// FindL402Challenge returns the first L402 challenge found in the given
+// WWW-Authenticate header values. A legacy LSAT challenge is only returned if
+// no L402 challenge is found.
+func FindL402Challenge(values ...string) (*L402Challenge, error) {
+	challenges, err := ParseChallenges(values...)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		found   *L402Challenge
+		missing bool
+	)
+	for _, c := range challenges {
+		var scheme string
+		switch {
+		case strings.EqualFold(c.Scheme, SchemeL402):
+			scheme = SchemeL402
+
+		case strings.EqualFold(c.Scheme, SchemeLSAT):
+			scheme = SchemeLSAT
+
+		default:
+			continue
+		}
+
+		macaroon, invoice := c.Params["macaroon"], c.Params["invoice"]
+		if macaroon == "" || invoice == "" {
+			missing = true
+			continue
+		}
+
+		challenge := &L402Challenge{
+			Scheme:   scheme,
+			Macaroon: macaroon,
+			Invoice:  invoice,
+			Version:  c.Params["version"],
+			Params:   c.Params,
+		}
+
+		if scheme == SchemeL402 {
+			return challenge, nil
+		}
+
+		if found == nil {
+			found = challenge
+		}
+	}
+
+	switch {
+	case found != nil:
+		return found, nil
+
+	case missing:
+		return nil, fmt.Errorf("%w: %s", ErrMissingParams,
+			strings.Join(values, ", "))
+	}
+
+	return nil, ErrNoChallenge
+}
+
+// ParseChallenges parses the challenges of the given WWW-Authenticate header
+// values following RFC 7235. Each value may hold several challenges. Auth-
+// params separated only by whitespace are accepted too, as some servers send
+// them that way.
+//
+// Malformed challenges are skipped, a ParseError is only returned if no L402
+// (or LSAT) challenge could be parsed, so a broken challenge of another
+// scheme does not hide a valid L402 challenge.
+func ParseChallenges(values ...string) ([]Challenge, error) {
+	var (
+		challenges []Challenge
+		parseErr   error
+	)
+	for _, value := range values {
+		p := &challengeParser{header: value}
+
+		valueChallenges, err := p.parse()
+		if err != nil && parseErr == nil {
+			parseErr = err
+		}
+
+		challenges = append(challenges, valueChallenges...)
+	}
+
+	if parseErr != nil && !hasL402Challenge(challenges) {
+		return nil, parseErr
+	}
+
+	return challenges, nil
+}
+
+// hasL402Challenge returns true if any of the challenges has the L402 or the
+// LSAT scheme.
+func hasL402Challenge(challenges []Challenge) bool {
+	for _, c := range challenges {
+		if strings.EqualFold(c.Scheme, SchemeL402) ||
+			strings.EqualFold(c.Scheme, SchemeLSAT) {
+
+			return true
+		}
+	}
+
+	return false
+}
+
+// challengeParser parses a single WWW-Authenticate header value.
+type challengeParser struct {
+	header string
+	pos    int
+}
+
+// parse returns the well formed challenges of the header value. Malformed
+// challenges are dropped, the error of the first one is returned along with
+// the others.
+func (p *challengeParser) parse() ([]Challenge, error) {
+	var (
+		challenges []Challenge
+		firstErr   error
+
+		// open is true while the auth-params belong to the last
+		// challenge, it is false before the first challenge and after
+		// a malformed one was dropped.
+		open bool
+	)
+
+	// drop records the error, discards the challenge being parsed and
+	// skips the rest of the list element.
+	drop := func(err error) {
+		if firstErr == nil {
+			firstErr = err
+		}
+
+		if open {
+			challenges = challenges[:len(challenges)-1]
+			open = false
+		}
+
+		p.skipElement()
+	}
+
+	for {
+		p.skipSeparators()
+		if p.done() {
+			return challenges, firstErr
+		}
+
+		start := p.pos
+		name := p.token()
+		if name == "" {
+			drop(p.errorf("unexpected character %q", p.header[p.pos]))
+			continue
+		}
+
+		value, isParam, err := p.paramValue()
+		if err != nil {
+			drop(err)
+			continue
+		}
+
+		if !isParam {
+			challenges = append(challenges, Challenge{
+				Scheme:  name,
+				Token68: p.token68(),
+				Params:  make(map[string]string),
+			})
+			open = true
+
+			continue
+		}
+
+		if !open {
+			// The auth-params of a dropped challenge are skipped.
+			if firstErr == nil {
+				p.pos = start
+				drop(p.errorf("auth-param %q without a scheme",
+					name))
+			}
+
+			continue
+		}
+
+		challenge := &challenges[len(challenges)-1]
+		if challenge.Token68 != "" {
+			p.pos = start
+			drop(p.errorf("auth-param %q after a token68", name))
+
+			continue
+		}
+
+		key := strings.ToLower(name)
+		if _, ok := challenge.Params[key]; ok {
+			p.pos = start
+			drop(p.errorf("duplicated auth-param %q", name))
+
+			continue
+		}
+
+		challenge.Params[key] = value
+	}
+}
+
+// paramValue parses the `= value` part of an auth-param. False is returned,
+// without consuming anything, if no auth-param value follows.
+func (p *challengeParser) paramValue() (string, bool, error) {
+	start := p.pos
+
+	p.skipWhitespace()
+	if p.done() || p.header[p.pos] != '=' {
+		p.pos = start
+		return "", false, nil
+	}
+	p.pos++
+	p.skipWhitespace()
+
+	if !p.done() && p.header[p.pos] == '"' {
+		value, err := p.quotedString()
+		return value, true, err
+	}
+
+	value := p.unquotedValue()
+	if value == "" {
+		// A token followed by '=' and no value is the start of a token68
+		// or a scheme, not an auth-param.
+		p.pos = start
+		return "", false, nil
+	}
+
+	return value, true, nil
+}
+
+// unquotedValue parses an unquoted auth-param value. Besides tokens, the
+// token68 characters are accepted so unquoted base64 values with padding,
+// like some macaroons, are not cut short.
+func (p *challengeParser) unquotedValue() string {
+	start := p.pos
+	for !p.done() && (isTokenChar(p.header[p.pos]) ||
+		isToken68Char(p.header[p.pos])) {
+
+		p.pos++
+	}
+	for !p.done() && p.header[p.pos] == '=' {
+		p.pos++
+	}
+
+	return p.header[start:p.pos]
+}
+
+// token68 parses the token68 of a challenge, if any. Nothing is consumed if
+// what follows the scheme is not a token68.
+func (p *challengeParser) token68() string {
+	start := p.pos
+
+	p.skipWhitespace()
+	valueStart := p.pos
+	for !p.done() && isToken68Char(p.header[p.pos]) {
+		p.pos++
+	}
+	if p.pos == valueStart {
+		p.pos = start
+		return ""
+	}
+	for !p.done() && p.header[p.pos] == '=' {
+		p.pos++
+	}
+	value := p.header[valueStart:p.pos]
+
+	// A token68 is the only element of its challenge.
+	p.skipWhitespace()
+	if !p.done() && p.header[p.pos] != ',' {
+		p.pos = start
+		return ""
+	}
+
+	return value
+}
+
+// quotedString parses a quoted-string and returns its unescaped content.
+func (p *challengeParser) quotedString() (string, error) {
+	start := p.pos
+	p.pos++
+
+	var b strings.Builder
+	for !p.done() {
+		c := p.header[p.pos]
+		p.pos++
+
+		switch c {
+		case '"':
+			return b.String(), nil
+
+		case '\\':
+			if !p.done() {
+				b.WriteByte(p.header[p.pos])
+				p.pos++
+			}
+
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	p.pos = start
+	return "", p.errorf("unterminated quoted-string")
+}
+
+// token parses a token, an empty string is returned if there is none.
+func (p *challengeParser) token() string {
+	start := p.pos
+	for !p.done() && isTokenChar(p.header[p.pos]) {
+		p.pos++
+	}
+
+	return p.header[start:p.pos]
+}
+
+// skipElement skips the rest of the current list element, up to the next
+// comma outside of a quoted-string.
+func (p *challengeParser) skipElement() {
+	for !p.done() && p.header[p.pos] != ',' {
+		if p.header[p.pos] != '"' {
+			p.pos++
+			continue
+		}
+
+		if _, err := p.quotedString(); err != nil {
+			// An unterminated quoted-string runs to the end of the
+			// header.
+			p.pos = len(p.header)
+		}
+	}
+}
+
+// skipSeparators skips the whitespace and the commas between list elements.
+func (p *challengeParser) skipSeparators() {
+	for !p.done() && (isWhitespace(p.header[p.pos]) ||
+		p.header[p.pos] == ',') {
+
+		p.pos++
+	}
+}
+
+// skipWhitespace skips spaces and tabs.
+func (p *challengeParser) skipWhitespace() {
+	for !p.done() && isWhitespace(p.header[p.pos]) {
+		p.pos++
+	}
+}
+
+// done returns true if the whole header was consumed.
+func (p *challengeParser) done() bool {
+	return p.pos >= len(p.header)
+}
+
+// errorf returns a ParseError at the current position.
+func (p *challengeParser) errorf(format string, args ...any) error {
+	return &ParseError{
+		Header: p.header,
+		Offset: p.pos,
+		Reason: fmt.Sprintf(format, args...),
+	}
+}
+
+// isTokenChar returns true for the tchar characters of RFC 7230.
+func isTokenChar(c byte) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		return true
+	}
+
+	return strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+}
+
+// isToken68Char returns true for the token68 characters of RFC 7235, except
+// the trailing '=' padding.
+func isToken68Char(c byte) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		return true
+	}
+
+	return strings.IndexByte("-._~+/", c) >= 0
+}
+
+// isWhitespace returns true for the optional whitespace characters.
+func isWhitespace(c byte) bool {
+	return c == ' ' || c == '\t'
+}
@@ -0,0 +1,345 @@
+package credentials
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseChallenges(t *testing.T) {
+	tests := []struct {
+		name       string
+		values     []string
+		challenges []Challenge
+		errOffset  int
+		expectErr  string
+	}{
+		{
+			name:   "Empty header",
+			values: []string{""},
+		},
+		{
+			name:   "Scheme only",
+			values: []string{"Basic"},
+			challenges: []Challenge{
+				{Scheme: "Basic", Params: map[string]string{}},
+			},
+		},
+		{
+			name:   "Quoted params",
+			values: []string{`L402 macaroon="mac", invoice="lnbc1"`},
+			challenges: []Challenge{{
+				Scheme: "L402",
+				Params: map[string]string{
+					"macaroon": "mac",
+					"invoice":  "lnbc1",
+				},
+			}},
+		},
+		{
+			name:   "Unquoted tokens and extra params",
+			values: []string{`LSAT macaroon=mac, invoice=lnbc1, version=0`},
+			challenges: []Challenge{{
+				Scheme: "LSAT",
+				Params: map[string]string{
+					"macaroon": "mac",
+					"invoice":  "lnbc1",
+					"version":  "0",
+				},
+			}},
+		},
+		{
+			name:   "Whitespace separated params",
+			values: []string{`L402 macaroon=mac invoice=lnbc1`},
+			challenges: []Challenge{{
+				Scheme: "L402",
+				Params: map[string]string{
+					"macaroon": "mac",
+					"invoice":  "lnbc1",
+				},
+			}},
+		},
+		{
+			name:   "Unquoted base64 with padding",
+			values: []string{`L402 macaroon=AgE+/w==, invoice=lnbc1`},
+			challenges: []Challenge{{
+				Scheme: "L402",
+				Params: map[string]string{
+					"macaroon": "AgE+/w==",
+					"invoice":  "lnbc1",
+				},
+			}},
+		},
+		{
+			name:   "Case insensitive param names and bad whitespace",
+			values: []string{"L402  MacAroon = \"mac\" ,\tInvoice=\"lnbc1\""},
+			challenges: []Challenge{{
+				Scheme: "L402",
+				Params: map[string]string{
+					"macaroon": "mac",
+					"invoice":  "lnbc1",
+				},
+			}},
+		},
+		{
+			name:   "Escaped quoted string",
+			values: []string{`Basic realm="a \"quoted\", realm\\"`},
+			challenges: []Challenge{{
+				Scheme: "Basic",
+				Params: map[string]string{
+					"realm": `a "quoted", realm\`,
+				},
+			}},
+		},
+		{
+			name: "Several challenges in one header",
+			values: []string{
+				`Basic realm="simple", L402 macaroon="mac", ` +
+					`invoice="lnbc1", Newauth realm="apps", type=1`,
+			},
+			challenges: []Challenge{
+				{
+					Scheme: "Basic",
+					Params: map[string]string{"realm": "simple"},
+				},
+				{
+					Scheme: "L402",
+					Params: map[string]string{
+						"macaroon": "mac",
+						"invoice":  "lnbc1",
+					},
+				},
+				{
+					Scheme: "Newauth",
+					Params: map[string]string{
+						"realm": "apps",
+						"type":  "1",
+					},
+				},
+			},
+		},
+		{
+			name: "Several headers",
+			values: []string{
+				`LSAT macaroon="mac1", invoice="lnbc1"`,
+				`L402 macaroon="mac2", invoice="lnbc2"`,
+			},
+			challenges: []Challenge{
+				{
+					Scheme: "LSAT",
+					Params: map[string]string{
+						"macaroon": "mac1",
+						"invoice":  "lnbc1",
+					},
+				},
+				{
+					Scheme: "L402",
+					Params: map[string]string{
+						"macaroon": "mac2",
+						"invoice":  "lnbc2",
+					},
+				},
+			},
+		},
+		{
+			name:   "Token68 challenges",
+			values: []string{"Negotiate a87421000492aa874209af8bc028==, Bearer"},
+			challenges: []Challenge{
+				{
+					Scheme:  "Negotiate",
+					Token68: "a87421000492aa874209af8bc028==",
+					Params:  map[string]string{},
+				},
+				{Scheme: "Bearer", Params: map[string]string{}},
+			},
+		},
+		{
+			name:   "Empty list elements",
+			values: []string{`, ,L402 macaroon="mac",, invoice="lnbc1",`},
+			challenges: []Challenge{{
+				Scheme: "L402",
+				Params: map[string]string{
+					"macaroon": "mac",
+					"invoice":  "lnbc1",
+				},
+			}},
+		},
+		{
+			name: "Malformed challenges next to an L402 challenge",
+			values: []string{
+				`Bearer realm="a", realm="b", Basic (x), ` +
+					`L402 macaroon="mac", invoice="lnbc1"`,
+				`Negotiate abc==, realm="x"`,
+			},
+			challenges: []Challenge{{
+				Scheme: "L402",
+				Params: map[string]string{
+					"macaroon": "mac",
+					"invoice":  "lnbc1",
+				},
+			}},
+		},
+		{
+			name:      "Malformed challenge without an L402 challenge",
+			values:    []string{`Bearer realm="a", realm="b", Basic`},
+			errOffset: 18,
+			expectErr: `duplicated auth-param "realm"`,
+		},
+		{
+			name: "Malformed L402 challenge",
+			values: []string{
+				`L402 macaroon="a", macaroon="b", Basic realm="x"`,
+			},
+			errOffset: 19,
+			expectErr: `duplicated auth-param "macaroon"`,
+		},
+		{
+			name:      "Param without scheme",
+			values:    []string{`macaroon="mac"`},
+			errOffset: 0,
+			expectErr: `auth-param "macaroon" without a scheme`,
+		},
+		{
+			name:      "Unterminated quoted string",
+			values:    []string{`L402 macaroon="mac`},
+			errOffset: 14,
+			expectErr: "unterminated quoted-string",
+		},
+		{
+			name:      "Duplicated param",
+			values:    []string{`L402 macaroon="a", macaroon="b"`},
+			errOffset: 19,
+			expectErr: `duplicated auth-param "macaroon"`,
+		},
+		{
+			name:      "Param after token68",
+			values:    []string{`Negotiate abc==, realm="x"`},
+			errOffset: 17,
+			expectErr: `auth-param "realm" after a token68`,
+		},
+		{
+			name:      "Invalid character",
+			values:    []string{`L402 (macaroon)`},
+			errOffset: 5,
+			expectErr: "unexpected character '('",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			challenges, err := ParseChallenges(tc.values...)
+			if tc.expectErr != "" {
+				require.ErrorIs(t, err, ErrMalformedHeader)
+
+				var parseErr *ParseError
+				require.ErrorAs(t, err, &parseErr)
+				require.Equal(t, tc.errOffset, parseErr.Offset)
+				require.Contains(t, parseErr.Reason, tc.expectErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.challenges, challenges)
+		})
+	}
+}
+
+func TestFindL402Challenge(t *testing.T) {
+	tests := []struct {
+		name      string
+		values    []string
+		scheme    string
+		macaroon  string
+		invoice   string
+		version   string
+		expectErr error
+	}{
+		{
+			name:      "No header",
+			expectErr: ErrNoChallenge,
+		},
+		{
+			name:      "Other schemes only",
+			values:    []string{`Basic realm="x", Bearer`},
+			expectErr: ErrNoChallenge,
+		},
+		{
+			name:      "Missing invoice",
+			values:    []string{`L402 macaroon="mac"`},
+			expectErr: ErrMissingParams,
+		},
+		{
+			name:      "Malformed header",
+			values:    []string{`L402 macaroon="mac`},
+			expectErr: ErrMalformedHeader,
+		},
+		{
+			name: "Malformed Bearer challenge",
+			values: []string{
+				`Bearer error="invalid_token", error="x"`,
+				`L402 macaroon="mac", invoice="lnbc1"`,
+			},
+			scheme:   SchemeL402,
+			macaroon: "mac",
+			invoice:  "lnbc1",
+		},
+		{
+			name:     "L402 challenge",
+			values:   []string{`L402 macaroon="mac", invoice="lnbc1", version="0"`},
+			scheme:   SchemeL402,
+			macaroon: "mac",
+			invoice:  "lnbc1",
+			version:  "0",
+		},
+		{
+			name:     "Lower case scheme",
+			values:   []string{`l402 macaroon=mac, invoice=lnbc1`},
+			scheme:   SchemeL402,
+			macaroon: "mac",
+			invoice:  "lnbc1",
+		},
+		{
+			name:     "Legacy LSAT challenge",
+			values:   []string{`Basic realm="x", LSAT macaroon="mac", invoice="lnbc1"`},
+			scheme:   SchemeLSAT,
+			macaroon: "mac",
+			invoice:  "lnbc1",
+		},
+		{
+			name: "L402 preferred over LSAT",
+			values: []string{
+				`LSAT macaroon="mac1", invoice="lnbc1"`,
+				`L402 macaroon="mac2", invoice="lnbc2"`,
+			},
+			scheme:   SchemeL402,
+			macaroon: "mac2",
+			invoice:  "lnbc2",
+		},
+		{
+			name: "Incomplete challenge skipped",
+			values: []string{
+				`L402 macaroon="mac1", LSAT macaroon="mac2", invoice="lnbc2"`,
+			},
+			scheme:   SchemeLSAT,
+			macaroon: "mac2",
+			invoice:  "lnbc2",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			challenge, err := FindL402Challenge(tc.values...)
+			if tc.expectErr != nil {
+				require.ErrorIs(t, err, tc.expectErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.scheme, challenge.Scheme)
+			require.Equal(t, tc.macaroon, challenge.Macaroon)
+			require.Equal(t, tc.invoice, challenge.Invoice)
+			require.Equal(t, tc.version, challenge.Version)
+		})
+	}
+}
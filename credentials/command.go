@@ -0,0 +1,78 @@
+package credentials
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/fewsats/fewsatscli/invoices"
+	"github.com/urfave/cli/v2"
+)
+
+// Info is the summary of stored L402 credentials printed by the credentials
+// commands.
+type Info struct {
+	ID         int64      `json:"id"`
+	ExternalID string     `json:"external_id"`
+	URL        string     `json:"url,omitempty"`
+	Location   string     `json:"location,omitempty"`
+	AmountSats uint64     `json:"amount_sats"`
+	Caveats    []string   `json:"caveats"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	Stale      bool       `json:"stale"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Command creates the credentials command.
+func Command() *cli.Command {
+	return &cli.Command{
+		Name:  "credentials",
+		Usage: "Manage the stored L402 credentials.",
+		Subcommands: []*cli.Command{
+			listCommand,
+			showCommand,
+			deleteCommand,
+			pruneCommand,
+			exportCommand,
+			importCommand,
+		},
+	}
+}
+
+// newInfo returns the summary of the given credentials. The amount and the
+// caveats are left empty if the invoice or the macaroon can not be decoded.
+func newInfo(creds *L402Credentials) Info {
+	info := Info{
+		ID:         creds.ID,
+		ExternalID: creds.ExternalID,
+		Location:   creds.Location,
+		Caveats:    []string{},
+		ExpiresAt:  creds.ExpiresAt,
+		Stale:      creds.Stale,
+		CreatedAt:  creds.CreatedAt,
+	}
+
+	if !creds.IsLegacy() {
+		info.URL = creds.Scope().String()
+	}
+
+	if amount, err := invoices.DecodePrice(creds.Invoice); err == nil {
+		info.AmountSats = amount
+	}
+
+	if caveats, err := MacaroonCaveats(creds.Macaroon); err == nil {
+		info.Caveats = caveats
+	}
+
+	return info
+}
+
+// parseID parses a credentials ID given as argument.
+func parseID(arg string) (int64, error) {
+	id, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid credentials id: %s", arg)
+	}
+
+	return id, nil
+}
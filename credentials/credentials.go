@@ -3,7 +3,6 @@ package credentials
 import (
 	"fmt"
 	"net/http"
-	"regexp"
 	"time"
 )
 
@@ -20,9 +19,29 @@ type L402Credentials struct {
 	// database.
 	ID int64 `db:"id"`
 
-	// ExternalID is the unique identifier for the related resource.
+	// ExternalID is the last path segment of the related resource, the key
+	// used by older versions before the credentials were keyed by scope.
 	ExternalID string `db:"external_id"`
 
+	// Scheme is the URL scheme of the resource scope.
+	Scheme string `db:"scheme"`
+
+	// Host is the host of the resource scope. It is empty for the legacy
+	// credentials only keyed by their external ID.
+	Host string `db:"host"`
+
+	// PathPrefix is the path prefix of the resource scope, the credentials
+	// are used for any resource under it.
+	PathPrefix string `db:"path_prefix"`
+
+	// Location is the location of the macaroon, if any.
+	Location string `db:"location"`
+
+	// AuthScheme is the authentication scheme of the challenge, L402 or
+	// the legacy LSAT, sent back in the Authorization header. Empty means
+	// L402.
+	AuthScheme string `db:"auth_scheme"`
+
 	// Macaroon is the base64 encoded macaroon credentials.
 	Macaroon string `db:"macaroon"`
 
@@ -32,67 +51,91 @@ type L402Credentials struct {
 	// Invoice is the LN invoice linked to this L402 challenge.
 	Invoice string `db:"invoice"`
 
+	// ExpiresAt is the time after which the macaroon is no longer valid,
+	// taken from its time based caveats. Nil if it never expires.
+	ExpiresAt *time.Time `db:"expires_at"`
+
+	// Stale is set when the server rejected the credentials, they are not
+	// used anymore.
+	Stale bool `db:"stale"`
+
 	// CreatedAt is the time the L402 challenge stored in the database.
 	CreatedAt time.Time `db:"created_at"`
 }
 
-// AuthenticationHeader returns the L402 header used to authenticated a request for the
-// L402 credentials.
+// Valid returns true if the credentials can still be used at the given time:
+// they are not stale and not expired.
+func (l *L402Credentials) Valid(now time.Time) bool {
+	if l.Stale {
+		return false
+	}
+
+	return l.ExpiresAt == nil || now.Before(*l.ExpiresAt)
+}
+
+// AuthenticationHeader returns the L402 header used to authenticated a request
+// for the L402 credentials, with the scheme of the challenge they were paid
+// for.
 func (l *L402Credentials) AuthenticationHeader() (string, error) {
+	scheme := l.AuthScheme
+	if scheme == "" {
+		scheme = SchemeL402
+	}
+
 	// TODO(positiveblue): add credential validation.
-	return fmt.Sprintf("L402 %s:%s", l.Macaroon, l.Preimage), nil
+	return fmt.Sprintf("%s %s:%s", scheme, l.Macaroon, l.Preimage), nil
 }
 
-// ParseL402Challenge parses an L402 challenge from an HTTP request.
-func ParseL402Challenge(externalID string,
-	resp *http.Response) (*L402Credentials, error) {
+// PruneReason returns why the credentials can be pruned at the given time, or
+// an empty string if they are still usable.
+func (l *L402Credentials) PruneReason(now time.Time) string {
+	switch {
+	case l.Macaroon == "" || l.Preimage == "":
+		return "invalid (empty macaroon/preimage)"
 
-	challenge := resp.Header.Get("WWW-Authenticate")
+	case l.Stale:
+		return "rejected by the server"
 
-	macaroon, invoice, err := parseL402Challenge(challenge)
+	case l.ExpiresAt != nil && !now.Before(*l.ExpiresAt):
+		return fmt.Sprintf("expired at %s", l.ExpiresAt.Format(time.RFC3339))
+	}
+
+	return ""
+}
+
+// ParseL402Challenge parses an L402 challenge from an HTTP response to a
+// request for a resource in the given scope.
+func ParseL402Challenge(scope Scope,
+	resp *http.Response) (*L402Credentials, error) {
+
+	challenge, err := FindL402Challenge(resp.Header.Values("WWW-Authenticate")...)
 	if err != nil {
 		return nil, fmt.Errorf("invalid L402 challenge header: %w", err)
 	}
+	macaroon, invoice := challenge.Macaroon, challenge.Invoice
 
-	return &L402Credentials{
-		ExternalID: externalID,
+	creds := &L402Credentials{
 		Macaroon:   macaroon,
 		Invoice:    invoice,
-	}, nil
-}
-
-// Precompiled regular expressions for performance
-var (
-	macaroonRegex = regexp.MustCompile(`macaroon="([^"]+)"`)
-	invoiceRegex  = regexp.MustCompile(`invoice="([^"]+)"`)
-)
-
-// parseL402Challenge parses an L402 challenge and returns the macaroon and
-// invoice.
-func parseL402Challenge(challenge string) (string, string, error) {
-	if challenge == "" {
-		return "", "", fmt.Errorf("no L402 challenge/empty header found")
+		AuthScheme: challenge.Scheme,
 	}
 
-	macaroonMatches := macaroonRegex.FindStringSubmatch(challenge)
-	invoiceMatches := invoiceRegex.FindStringSubmatch(challenge)
+	scope.Location = MacaroonLocation(macaroon)
+	creds.SetScope(scope)
 
-	if macaroonMatches == nil || invoiceMatches == nil {
-		return "", "", fmt.Errorf("missing macaroon/invoice in challenge: %s", challenge)
+	if expiry, ok := MacaroonExpiry(macaroon); ok {
+		creds.ExpiresAt = &expiry
 	}
 
-	// Extracting the values from the regex matches
-	macaroon := macaroonMatches[1]
-	invoice := invoiceMatches[1]
-
-	return macaroon, invoice, nil
+	return creds, nil
 }
 
-// GetL402Credentials retrieves the L402 credentials from the database.
-func GetL402Credentials(store Store, externalID string) (*L402Credentials,
+// GetL402Credentials retrieves the most specific L402 credentials for the
+// given scope from the database.
+func GetL402Credentials(store Store, scope Scope) (*L402Credentials,
 	error) {
 
-	creds, err := store.GetL402Credentials(externalID)
+	creds, err := store.GetL402Credentials(scope)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get L402 credentials from db: %w",
 			err)
@@ -1,6 +1,7 @@
 package credentials
 
 import (
+	"net/http"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -12,6 +13,7 @@ func TestParseL402Challenge(t *testing.T) {
 		challenge string
 		macaroon  string
 		invoice   string
+		header    string
 		expectErr string
 	}{
 		{
@@ -33,6 +35,15 @@ func TestParseL402Challenge(t *testing.T) {
 			challenge: "L402 macaroon=1234 invoice=1234",
 			macaroon:  "1234",
 			invoice:   "1234",
+			header:    "L402 1234:abcd",
+			expectErr: "",
+		},
+		{
+			name:      "Valid LSAT challenge",
+			challenge: "LSAT macaroon=1234 invoice=1234",
+			macaroon:  "1234",
+			invoice:   "1234",
+			header:    "LSAT 1234:abcd",
 			expectErr: "",
 		},
 	}
@@ -41,7 +52,12 @@ func TestParseL402Challenge(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			tc := tc
 
-			macaroon, invoice, err := parseL402Challenge(tc.challenge)
+			resp := &http.Response{Header: http.Header{}}
+			if tc.challenge != "" {
+				resp.Header.Set("WWW-Authenticate", tc.challenge)
+			}
+
+			creds, err := ParseL402Challenge(Scope{}, resp)
 			if tc.expectErr != "" {
 				require.Error(t, err)
 				require.Contains(t, err.Error(), tc.expectErr)
@@ -49,8 +65,15 @@ func TestParseL402Challenge(t *testing.T) {
 			}
 
 			require.NoError(t, err)
-			require.Equal(t, tc.macaroon, macaroon)
-			require.Equal(t, tc.invoice, invoice)
+			require.Equal(t, tc.macaroon, creds.Macaroon)
+			require.Equal(t, tc.invoice, creds.Invoice)
+
+			// The credentials are sent back with the scheme of the
+			// challenge.
+			creds.Preimage = "abcd"
+			header, err := creds.AuthenticationHeader()
+			require.NoError(t, err)
+			require.Equal(t, tc.header, header)
 		})
 	}
 }
@@ -0,0 +1,47 @@
+package credentials
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/urfave/cli/v2"
+)
+
+var deleteCommand = &cli.Command{
+	Name:      "delete",
+	Usage:     "Delete the stored L402 credentials with the given ID.",
+	ArgsUsage: "<id>",
+	Action:    deleteCredentials,
+}
+
+// deleteCredentials deletes the credentials with the given ID.
+func deleteCredentials(c *cli.Context) error {
+	store, ok := c.App.Metadata["store"].(AdminStore)
+	if !ok {
+		return errors.New("failed to get store from context")
+	}
+
+	if c.Args().Len() < 1 {
+		return cli.Exit("missing <id> argument", 1)
+	}
+
+	id, err := parseID(c.Args().Get(0))
+	if err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+
+	err = store.DeleteL402Credentials(id)
+	switch {
+	case errors.Is(err, ErrNoCredentialsFound):
+		return cli.Exit(fmt.Sprintf("credentials %d not found", id), 1)
+
+	case err != nil:
+		slog.Debug("Failed to delete L402 credentials.", "error", err)
+		return cli.Exit("failed to delete credentials", 1)
+	}
+
+	fmt.Println("Credentials deleted successfully.")
+
+	return nil
+}
@@ -0,0 +1,298 @@
+package credentials
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+// ExportVersion is the version of the portable credentials format.
+const ExportVersion = 1
+
+// Export is the portable JSON format used to move L402 credentials between
+// machines or profiles.
+type Export struct {
+	// Version is the version of the format.
+	Version int `json:"version"`
+
+	// Credentials are the exported credentials.
+	Credentials []ExportedCredentials `json:"credentials"`
+}
+
+// ExportedCredentials are L402 credentials in the portable format.
+type ExportedCredentials struct {
+	// URL is the scope of the credentials (scheme, host and path prefix).
+	// It is empty for credentials stored by older versions, only keyed by
+	// their external ID.
+	URL string `json:"url,omitempty"`
+
+	// ExternalID is the last path segment of the resource.
+	ExternalID string `json:"external_id"`
+
+	// Location is the location of the macaroon, if any.
+	Location string `json:"location,omitempty"`
+
+	// AuthScheme is the authentication scheme of the challenge, L402 if
+	// empty.
+	AuthScheme string `json:"auth_scheme,omitempty"`
+
+	Macaroon  string     `json:"macaroon"`
+	Preimage  string     `json:"preimage"`
+	Invoice   string     `json:"invoice"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+
+	// Stale is set for credentials rejected by the server, they are
+	// imported as stale too.
+	Stale bool `json:"stale,omitempty"`
+}
+
+// NewExport returns the portable format of the given credentials.
+func NewExport(creds []*L402Credentials) *Export {
+	export := &Export{
+		Version:     ExportVersion,
+		Credentials: make([]ExportedCredentials, 0, len(creds)),
+	}
+
+	for _, c := range creds {
+		exported := ExportedCredentials{
+			ExternalID: c.ExternalID,
+			Location:   c.Location,
+			AuthScheme: c.AuthScheme,
+			Macaroon:   c.Macaroon,
+			Preimage:   c.Preimage,
+			Invoice:    c.Invoice,
+			ExpiresAt:  c.ExpiresAt,
+			CreatedAt:  c.CreatedAt,
+			Stale:      c.Stale,
+		}
+
+		if !c.IsLegacy() {
+			exported.URL = c.Scope().String()
+		}
+
+		export.Credentials = append(export.Credentials, exported)
+	}
+
+	return export
+}
+
+// L402Credentials validates the exported credentials and returns them ready
+// to be stored. The preimage must match the payment hash of the macaroon and
+// the expiry is taken from the macaroon caveats, not from the file.
+func (e *ExportedCredentials) L402Credentials() (*L402Credentials, error) {
+	if e.Macaroon == "" || e.Invoice == "" {
+		return nil, errors.New("missing macaroon/invoice")
+	}
+
+	preimage, err := hex.DecodeString(e.Preimage)
+	if err != nil || len(preimage) != 32 {
+		return nil, errors.New("invalid preimage")
+	}
+
+	creds := &L402Credentials{
+		Macaroon:   e.Macaroon,
+		Preimage:   e.Preimage,
+		Invoice:    e.Invoice,
+		AuthScheme: e.AuthScheme,
+		Stale:      e.Stale,
+	}
+
+	err = creds.VerifyPreimage(creds.Preimage)
+	if err != nil {
+		return nil, err
+	}
+
+	if expiry, ok := MacaroonExpiry(creds.Macaroon); ok {
+		creds.ExpiresAt = &expiry
+	}
+
+	if e.URL == "" {
+		if e.ExternalID == "" {
+			return nil, errors.New("missing url/external_id")
+		}
+
+		creds.ExternalID = e.ExternalID
+
+		return creds, nil
+	}
+
+	scope, err := ParseScope(e.URL)
+	if err != nil || scope.Scheme == "" || scope.Host == "" {
+		return nil, fmt.Errorf("invalid url: %s", e.URL)
+	}
+	scope.Location = e.Location
+	creds.SetScope(scope)
+
+	return creds, nil
+}
+
+var exportCommand = &cli.Command{
+	Name:      "export",
+	Usage:     "Export L402 credentials in a portable JSON format.",
+	ArgsUsage: "[id...]",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:    "output",
+			Aliases: []string{"o"},
+			Usage:   "Write the credentials to the given file instead of stdout",
+		},
+	},
+	Action: exportCredentials,
+}
+
+// exportCredentials writes the given credentials, or all of them, in the
+// portable format.
+func exportCredentials(c *cli.Context) error {
+	store, ok := c.App.Metadata["store"].(AdminStore)
+	if !ok {
+		return errors.New("failed to get store from context")
+	}
+
+	var creds []*L402Credentials
+	if c.Args().Len() == 0 {
+		var err error
+		creds, err = store.ListL402Credentials()
+		if err != nil {
+			slog.Debug("Failed to list L402 credentials.", "error", err)
+			return cli.Exit("failed to list credentials", 1)
+		}
+	}
+
+	for _, arg := range c.Args().Slice() {
+		id, err := parseID(arg)
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+
+		cred, err := store.GetL402CredentialsByID(id)
+		switch {
+		case errors.Is(err, ErrNoCredentialsFound):
+			return cli.Exit(fmt.Sprintf("credentials %d not found", id), 1)
+
+		case err != nil:
+			slog.Debug("Failed to get L402 credentials.", "error", err)
+			return cli.Exit("failed to get credentials", 1)
+		}
+
+		creds = append(creds, cred)
+	}
+
+	jsonOutput, err := json.MarshalIndent(NewExport(creds), "", "  ")
+	if err != nil {
+		return cli.Exit("failed to marshal JSON", 1)
+	}
+
+	output := c.String("output")
+	if output == "" {
+		fmt.Println(string(jsonOutput))
+		return nil
+	}
+
+	// The export holds the preimages, only the user can read it.
+	err = os.WriteFile(output, append(jsonOutput, '\n'), 0600)
+	if err != nil {
+		slog.Debug("Failed to write export file.", "error", err)
+		return cli.Exit("failed to write export file", 1)
+	}
+
+	fmt.Printf("%d credentials exported to %s.\n", len(creds), output)
+
+	return nil
+}
+
+var importCommand = &cli.Command{
+	Name:      "import",
+	Usage:     "Import L402 credentials exported with the export command.",
+	ArgsUsage: "<file>",
+	Action:    importCredentials,
+}
+
+// importCredentials stores the credentials of an export file, skipping the
+// ones already stored. All the credentials are validated before any of them
+// is stored, and they are stored at once.
+func importCredentials(c *cli.Context) error {
+	store, ok := c.App.Metadata["store"].(AdminStore)
+	if !ok {
+		return errors.New("failed to get store from context")
+	}
+
+	if c.Args().Len() < 1 {
+		return cli.Exit("missing <file> argument, use '-' to read from "+
+			"stdin", 1)
+	}
+
+	var (
+		content []byte
+		err     error
+	)
+	if path := c.Args().Get(0); path == "-" {
+		content, err = io.ReadAll(os.Stdin)
+	} else {
+		content, err = os.ReadFile(path)
+	}
+	if err != nil {
+		slog.Debug("Failed to read import file.", "error", err)
+		return cli.Exit("failed to read import file", 1)
+	}
+
+	var export Export
+	err = json.Unmarshal(content, &export)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("invalid import file: %v", err), 1)
+	}
+
+	if export.Version != ExportVersion {
+		return cli.Exit(fmt.Sprintf("unsupported import file version: %d",
+			export.Version), 1)
+	}
+
+	existing, err := store.ListL402Credentials()
+	if err != nil {
+		slog.Debug("Failed to list L402 credentials.", "error", err)
+		return cli.Exit("failed to list credentials", 1)
+	}
+
+	stored := make(map[string]bool, len(existing))
+	for _, cred := range existing {
+		stored[cred.Macaroon] = true
+	}
+
+	var (
+		imported []*L402Credentials
+		skipped  int
+	)
+	for i, exported := range export.Credentials {
+		creds, err := exported.L402Credentials()
+		if err != nil {
+			return cli.Exit(fmt.Sprintf("invalid credentials #%d: %v", i,
+				err), 1)
+		}
+
+		if stored[creds.Macaroon] {
+			skipped++
+			continue
+		}
+
+		stored[creds.Macaroon] = true
+		imported = append(imported, creds)
+	}
+
+	err = store.ImportL402Credentials(imported)
+	if err != nil {
+		slog.Debug("Failed to import L402 credentials.", "error", err)
+		return cli.Exit("failed to store credentials", 1)
+	}
+
+	fmt.Printf("%d credentials imported, %d already stored.\n",
+		len(imported), skipped)
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package credentials
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportRoundTrip(t *testing.T) {
+	expiry := time.Unix(1900000000, 0).UTC()
+
+	scoped, preimage := newTestCredentials(t, 0, "valid_until=1900000000")
+	scoped.Preimage = preimage
+	scoped.ExpiresAt = &expiry
+	scoped.SetScope(Scope{
+		Scheme:   "https",
+		Host:     "api.example.com:8443",
+		Path:     "/v1/data",
+		Location: "fewsats",
+	})
+
+	legacy, preimage := newTestCredentials(t, 0)
+	legacy.ExternalID = "data"
+	legacy.Preimage = preimage
+	legacy.Stale = true
+
+	content, err := json.Marshal(NewExport([]*L402Credentials{scoped, legacy}))
+	require.NoError(t, err)
+
+	var export Export
+	require.NoError(t, json.Unmarshal(content, &export))
+	require.Equal(t, ExportVersion, export.Version)
+	require.Len(t, export.Credentials, 2)
+	require.Equal(t, "https://api.example.com:8443/v1/data",
+		export.Credentials[0].URL)
+
+	// The expiry is taken from the macaroon, not from the file.
+	later := expiry.Add(time.Hour)
+	export.Credentials[0].ExpiresAt = &later
+	export.Credentials[1].ExpiresAt = &later
+
+	for i, expected := range []*L402Credentials{scoped, legacy} {
+		creds, err := export.Credentials[i].L402Credentials()
+		require.NoError(t, err)
+		require.Equal(t, expected, creds)
+	}
+}
+
+func TestExportedCredentialsValidation(t *testing.T) {
+	creds, preimage := newTestCredentials(t, 0)
+	mac, invoice := creds.Macaroon, creds.Invoice
+
+	other, otherPreimage := newTestCredentials(t, 0)
+
+	tests := []struct {
+		name      string
+		creds     ExportedCredentials
+		expectErr string
+	}{
+		{
+			name: "Missing macaroon",
+			creds: ExportedCredentials{
+				URL: "https://a.com", Preimage: preimage,
+				Invoice: invoice,
+			},
+			expectErr: "missing macaroon/invoice",
+		},
+		{
+			name: "Short preimage",
+			creds: ExportedCredentials{
+				URL: "https://a.com", Macaroon: mac, Preimage: "0101",
+				Invoice: invoice,
+			},
+			expectErr: "invalid preimage",
+		},
+		{
+			name: "Preimage of another invoice",
+			creds: ExportedCredentials{
+				URL: "https://a.com", Macaroon: mac,
+				Preimage: otherPreimage, Invoice: invoice,
+			},
+			expectErr: ErrPreimageMismatch.Error(),
+		},
+		{
+			name: "Macaroon of another invoice",
+			creds: ExportedCredentials{
+				URL: "https://a.com", Macaroon: other.Macaroon,
+				Preimage: preimage, Invoice: invoice,
+			},
+			expectErr: ErrPaymentHashMismatch.Error(),
+		},
+		{
+			name: "Missing scope",
+			creds: ExportedCredentials{
+				Macaroon: mac, Preimage: preimage, Invoice: invoice,
+			},
+			expectErr: "missing url/external_id",
+		},
+		{
+			name: "Relative url",
+			creds: ExportedCredentials{
+				URL: "/v1/data", Macaroon: mac, Preimage: preimage,
+				Invoice: invoice,
+			},
+			expectErr: "invalid url",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.creds.L402Credentials()
+			require.ErrorContains(t, err, tc.expectErr)
+		})
+	}
+}
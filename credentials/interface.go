@@ -3,11 +3,36 @@ package credentials
 // Store is the interface that defines the methods that a credentials store
 // should implement.
 type Store interface {
-	// GetL402Credentials retrieves the L402 credentials for a given service
-	// from the database.
-	GetL402Credentials(externalID string) (*L402Credentials, error)
+	// GetL402Credentials retrieves the most specific L402 credentials for
+	// the given resource scope from the database.
+	GetL402Credentials(scope Scope) (*L402Credentials, error)
 
 	// InsertL402Credentials inserts the L402 credentials for a given service
 	// into the database.
 	InsertL402Credentials(challenge *L402Credentials) error
+
+	// MarkL402CredentialsStale flags the L402 credentials as rejected by
+	// the server so they are not used anymore.
+	MarkL402CredentialsStale(id int64) error
+}
+
+// AdminStore is the store used by the credentials command to manage all the
+// stored L402 credentials.
+type AdminStore interface {
+	Store
+
+	// ListL402Credentials returns all the stored L402 credentials, newest
+	// first.
+	ListL402Credentials() ([]*L402Credentials, error)
+
+	// GetL402CredentialsByID retrieves the L402 credentials with the given
+	// ID.
+	GetL402CredentialsByID(id int64) (*L402Credentials, error)
+
+	// DeleteL402Credentials deletes the L402 credentials with the given ID.
+	DeleteL402Credentials(id int64) error
+
+	// ImportL402Credentials inserts all the given L402 credentials in a
+	// single transaction, none of them is stored if any insert fails.
+	ImportL402Credentials(creds []*L402Credentials) error
 }
@@ -0,0 +1,48 @@
+package credentials
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/urfave/cli/v2"
+)
+
+var listCommand = &cli.Command{
+	Name:   "list",
+	Usage:  "List the stored L402 credentials.",
+	Action: listCredentials,
+}
+
+// listCredentials prints a summary of all the stored credentials.
+func listCredentials(c *cli.Context) error {
+	store, ok := c.App.Metadata["store"].(AdminStore)
+	if !ok {
+		return errors.New("failed to get store from context")
+	}
+
+	creds, err := store.ListL402Credentials()
+	if err != nil {
+		slog.Debug("Failed to list L402 credentials.", "error", err)
+		return cli.Exit("failed to list credentials", 1)
+	}
+
+	response := struct {
+		Credentials []Info `json:"credentials"`
+	}{
+		Credentials: make([]Info, 0, len(creds)),
+	}
+	for _, cred := range creds {
+		response.Credentials = append(response.Credentials, newInfo(cred))
+	}
+
+	jsonOutput, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		return cli.Exit("failed to marshal JSON", 1)
+	}
+
+	fmt.Println(string(jsonOutput))
+
+	return nil
+}
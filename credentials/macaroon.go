@@ -0,0 +1,268 @@
+package credentials
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fewsats/fewsatscli/invoices"
+	"gopkg.in/macaroon.v2"
+)
+
+var (
+	// ErrPaymentHashMismatch is the error returned when the macaroon of an
+	// L402 challenge is not linked to the payment hash of its invoice.
+	ErrPaymentHashMismatch = errors.New("macaroon and invoice payment " +
+		"hashes do not match")
+
+	// ErrPreimageMismatch is the error returned when a preimage does not
+	// hash to the payment hash of the invoice.
+	ErrPreimageMismatch = errors.New("preimage does not match the " +
+		"payment hash")
+
+	// ErrUnsupportedIdentifierVersion is the error returned when the
+	// identifier of a macaroon has a version other than 0, its payment
+	// hash can not be read.
+	ErrUnsupportedIdentifierVersion = errors.New("unsupported macaroon " +
+		"identifier version")
+)
+
+// byteOrder is the byte order of the macaroon identifiers.
+var byteOrder = binary.BigEndian
+
+// expiryCaveats are the names of the first party caveats holding the time
+// after which a macaroon is no longer valid. Service prefixed caveats like
+// `<service>_valid_until` are also recognized.
+var expiryCaveats = []string{"valid_until", "expires_at"}
+
+// decodeMacaroon decodes a base64 encoded macaroon, both the standard and
+// the URL alphabets are accepted.
+func decodeMacaroon(encoded string) (*macaroon.Macaroon, error) {
+	encoded = strings.TrimRight(encoded, "=")
+
+	macBytes, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		macBytes, err = base64.RawURLEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	mac := &macaroon.Macaroon{}
+	if err := mac.UnmarshalBinary(macBytes); err != nil {
+		return nil, err
+	}
+
+	return mac, nil
+}
+
+// DecodeMacIdentifier decodes the macaroon identifier into its version,
+// payment hash and user ID.
+func DecodeMacIdentifier(id []byte) (uint16, [32]byte, [32]byte, error) {
+	r := bytes.NewReader(id)
+
+	var version uint16
+	if err := binary.Read(r, byteOrder, &version); err != nil {
+		return 0, [32]byte{}, [32]byte{}, err
+	}
+
+	switch version {
+	// A version 0 identifier consists of its linked payment hash, followed
+	// by the user ID.
+	case 0:
+		var paymentHash [32]byte
+		if _, err := io.ReadFull(r, paymentHash[:]); err != nil {
+			return 0, [32]byte{}, [32]byte{}, err
+		}
+		var tokenID [32]byte
+		if _, err := io.ReadFull(r, tokenID[:]); err != nil {
+			return 0, [32]byte{}, [32]byte{}, err
+		}
+
+		return version, paymentHash, tokenID, nil
+	}
+
+	return 0, [32]byte{}, [32]byte{}, fmt.Errorf("%w: %d",
+		ErrUnsupportedIdentifierVersion, version)
+}
+
+// MacaroonPaymentHash returns the payment hash linked to the base64 encoded
+// macaroon in its identifier.
+func MacaroonPaymentHash(encoded string) ([32]byte, error) {
+	mac, err := decodeMacaroon(encoded)
+	if err != nil {
+		return [32]byte{}, fmt.Errorf("unable to decode macaroon: %w", err)
+	}
+
+	_, paymentHash, _, err := DecodeMacIdentifier(mac.Id())
+	if err != nil {
+		return [32]byte{}, fmt.Errorf("unable to decode macaroon "+
+			"identifier: %w", err)
+	}
+
+	return paymentHash, nil
+}
+
+// VerifyChallenge checks that the macaroon and the invoice of the credentials
+// refer to each other: the macaroon identifier holds the payment hash of the
+// invoice.
+func (l *L402Credentials) VerifyChallenge() error {
+	inv, err := invoices.Decode(l.Invoice)
+	if err != nil {
+		return fmt.Errorf("unable to decode invoice: %w", err)
+	}
+
+	if inv.PaymentHash == nil {
+		return errors.New("invoice without payment hash")
+	}
+
+	macHash, err := MacaroonPaymentHash(l.Macaroon)
+	if err != nil {
+		return err
+	}
+
+	if macHash != *inv.PaymentHash {
+		return fmt.Errorf("%w: macaroon %x, invoice %x",
+			ErrPaymentHashMismatch, macHash, *inv.PaymentHash)
+	}
+
+	return nil
+}
+
+// VerifyPreimage checks that the preimage hashes to the payment hash of both
+// the invoice and the macaroon of the credentials.
+func (l *L402Credentials) VerifyPreimage(preimage string) error {
+	err := l.VerifyChallenge()
+	if err != nil {
+		return err
+	}
+
+	preimageBytes, err := hex.DecodeString(preimage)
+	if err != nil || len(preimageBytes) != 32 {
+		return fmt.Errorf("%w: invalid preimage %q", ErrPreimageMismatch,
+			preimage)
+	}
+
+	// The payment hashes of the macaroon and the invoice are the same after
+	// VerifyChallenge.
+	macHash, err := MacaroonPaymentHash(l.Macaroon)
+	if err != nil {
+		return err
+	}
+
+	hash := sha256.Sum256(preimageBytes)
+	if hash != macHash {
+		return fmt.Errorf("%w: sha256(preimage) %x, payment hash %x",
+			ErrPreimageMismatch, hash, macHash)
+	}
+
+	return nil
+}
+
+// MacaroonLocation returns the location of the base64 encoded macaroon, or
+// an empty string if it can not be decoded.
+func MacaroonLocation(encoded string) string {
+	mac, err := decodeMacaroon(encoded)
+	if err != nil {
+		return ""
+	}
+
+	return mac.Location()
+}
+
+// MacaroonCaveats returns the caveats of the base64 encoded macaroon. Third
+// party caveats are prefixed with their location.
+func MacaroonCaveats(encoded string) ([]string, error) {
+	mac, err := decodeMacaroon(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode macaroon: %w", err)
+	}
+
+	caveats := make([]string, 0, len(mac.Caveats()))
+	for _, caveat := range mac.Caveats() {
+		if caveat.VerificationId != nil {
+			caveats = append(caveats, fmt.Sprintf("%s: %s",
+				caveat.Location, caveat.Id))
+
+			continue
+		}
+
+		caveats = append(caveats, string(caveat.Id))
+	}
+
+	return caveats, nil
+}
+
+// MacaroonExpiry returns the earliest expiry found in the time based caveats
+// of the base64 encoded macaroon. False is returned if the macaroon can not
+// be decoded or it has no expiry.
+func MacaroonExpiry(encoded string) (time.Time, bool) {
+	mac, err := decodeMacaroon(encoded)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	var (
+		expiry time.Time
+		found  bool
+	)
+	for _, caveat := range mac.Caveats() {
+		// Only first party caveats can be checked locally.
+		if caveat.VerificationId != nil {
+			continue
+		}
+
+		t, ok := parseExpiryCaveat(string(caveat.Id))
+		if !ok {
+			continue
+		}
+
+		if !found || t.Before(expiry) {
+			expiry, found = t, true
+		}
+	}
+
+	return expiry, found
+}
+
+// parseExpiryCaveat parses a `name=value` caveat with an expiry given as a
+// unix timestamp or an RFC 3339 date.
+func parseExpiryCaveat(caveat string) (time.Time, bool) {
+	name, value, found := strings.Cut(caveat, "=")
+	if !found {
+		return time.Time{}, false
+	}
+
+	name = strings.ToLower(strings.TrimSpace(name))
+	value = strings.TrimSpace(value)
+
+	var isExpiry bool
+	for _, expiryCaveat := range expiryCaveats {
+		if name == expiryCaveat || strings.HasSuffix(name, "_"+expiryCaveat) {
+			isExpiry = true
+			break
+		}
+	}
+	if !isExpiry {
+		return time.Time{}, false
+	}
+
+	if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(unix, 0).UTC(), true
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return t.UTC(), true
+}
@@ -0,0 +1,178 @@
+package credentials
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/zpay32"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/macaroon.v2"
+)
+
+// newTestMacaroon returns a base64 encoded macaroon with the given first
+// party caveats.
+func newTestMacaroon(t *testing.T, caveats ...string) string {
+	t.Helper()
+
+	mac, err := macaroon.New(
+		[]byte("root-key"), []byte("id"), "fewsats", macaroon.LatestVersion,
+	)
+	require.NoError(t, err)
+
+	for _, caveat := range caveats {
+		require.NoError(t, mac.AddFirstPartyCaveat([]byte(caveat)))
+	}
+
+	macBytes, err := mac.MarshalBinary()
+	require.NoError(t, err)
+
+	return base64.StdEncoding.EncodeToString(macBytes)
+}
+
+// newTestCredentials returns credentials whose macaroon, with an identifier
+// of the given version and the given first party caveats, is linked to the
+// payment hash of its invoice. The preimage of the invoice is returned too.
+func newTestCredentials(t *testing.T, version uint16,
+	caveats ...string) (*L402Credentials, string) {
+
+	t.Helper()
+
+	var preimage [32]byte
+	_, err := rand.Read(preimage[:])
+	require.NoError(t, err)
+	paymentHash := sha256.Sum256(preimage[:])
+
+	var id bytes.Buffer
+	require.NoError(t, binary.Write(&id, byteOrder, version))
+	id.Write(paymentHash[:])
+	id.Write(make([]byte, 32))
+
+	mac, err := macaroon.New(
+		[]byte("root-key"), id.Bytes(), "fewsats", macaroon.LatestVersion,
+	)
+	require.NoError(t, err)
+
+	for _, caveat := range caveats {
+		require.NoError(t, mac.AddFirstPartyCaveat([]byte(caveat)))
+	}
+
+	macBytes, err := mac.MarshalBinary()
+	require.NoError(t, err)
+
+	inv, err := zpay32.NewInvoice(
+		&chaincfg.MainNetParams, paymentHash, time.Now(),
+		zpay32.Amount(lnwire.MilliSatoshi(10_000)),
+		zpay32.Description("test"),
+	)
+	require.NoError(t, err)
+
+	key, err := btcec.NewPrivateKey()
+	require.NoError(t, err)
+
+	invoice, err := inv.Encode(zpay32.MessageSigner{
+		SignCompact: func(msg []byte) ([]byte, error) {
+			return ecdsa.SignCompact(key, chainhash.HashB(msg), true)
+		},
+	})
+	require.NoError(t, err)
+
+	return &L402Credentials{
+		Macaroon: base64.StdEncoding.EncodeToString(macBytes),
+		Invoice:  invoice,
+	}, hex.EncodeToString(preimage[:])
+}
+
+func TestMacaroonExpiry(t *testing.T) {
+	tests := []struct {
+		name    string
+		caveats []string
+		expiry  time.Time
+		found   bool
+	}{
+		{
+			name:    "No caveats",
+			caveats: nil,
+		},
+		{
+			name:    "Unrelated caveats",
+			caveats: []string{"services=storage:0", "valid_until"},
+		},
+		{
+			name:    "Unix timestamp",
+			caveats: []string{"valid_until=1700000000"},
+			expiry:  time.Unix(1700000000, 0).UTC(),
+			found:   true,
+		},
+		{
+			name:    "RFC 3339 date",
+			caveats: []string{"expires_at=2024-01-02T03:04:05Z"},
+			expiry:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			found:   true,
+		},
+		{
+			name: "Earliest service expiry",
+			caveats: []string{
+				"storage_valid_until=1700000100",
+				"services=storage:0",
+				"gateway_valid_until = 1700000000",
+			},
+			expiry: time.Unix(1700000000, 0).UTC(),
+			found:  true,
+		},
+		{
+			name:    "Invalid value",
+			caveats: []string{"valid_until=tomorrow"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			expiry, found := MacaroonExpiry(
+				newTestMacaroon(t, tc.caveats...),
+			)
+			require.Equal(t, tc.found, found)
+			require.Equal(t, tc.expiry, expiry)
+		})
+	}
+
+	_, found := MacaroonExpiry("not a macaroon")
+	require.False(t, found)
+}
+
+func TestCredentialsValid(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-time.Minute), now.Add(time.Minute)
+
+	require.True(t, (&L402Credentials{}).Valid(now))
+	require.True(t, (&L402Credentials{ExpiresAt: &future}).Valid(now))
+	require.False(t, (&L402Credentials{ExpiresAt: &past}).Valid(now))
+	require.False(t, (&L402Credentials{Stale: true}).Valid(now))
+}
+
+func TestVerifyPreimage(t *testing.T) {
+	creds, preimage := newTestCredentials(t, 0)
+	require.NoError(t, creds.VerifyChallenge())
+	require.NoError(t, creds.VerifyPreimage(preimage))
+
+	err := creds.VerifyPreimage(hex.EncodeToString(make([]byte, 32)))
+	require.ErrorIs(t, err, ErrPreimageMismatch)
+
+	// The payment hash of identifiers with an unknown version can not be
+	// read.
+	creds, preimage = newTestCredentials(t, 1)
+	require.ErrorIs(t, creds.VerifyChallenge(),
+		ErrUnsupportedIdentifierVersion)
+	require.ErrorIs(t, creds.VerifyPreimage(preimage),
+		ErrUnsupportedIdentifierVersion)
+}
@@ -0,0 +1,69 @@
+package credentials
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+var pruneCommand = &cli.Command{
+	Name:  "prune",
+	Usage: "Delete the expired, rejected or invalid L402 credentials.",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "Only print the credentials that would be deleted",
+		},
+	},
+	Action: pruneCredentials,
+}
+
+// pruneCredentials deletes the credentials that can not be used anymore.
+func pruneCredentials(c *cli.Context) error {
+	store, ok := c.App.Metadata["store"].(AdminStore)
+	if !ok {
+		return errors.New("failed to get store from context")
+	}
+
+	creds, err := store.ListL402Credentials()
+	if err != nil {
+		slog.Debug("Failed to list L402 credentials.", "error", err)
+		return cli.Exit("failed to list credentials", 1)
+	}
+
+	var (
+		now    = time.Now()
+		dryRun = c.Bool("dry-run")
+		pruned int
+	)
+	for _, cred := range creds {
+		reason := cred.PruneReason(now)
+		if reason == "" {
+			continue
+		}
+
+		if !dryRun {
+			err := store.DeleteL402Credentials(cred.ID)
+			if err != nil {
+				slog.Debug("Failed to delete L402 credentials.",
+					"error", err)
+				return cli.Exit("failed to delete credentials", 1)
+			}
+		}
+
+		fmt.Printf("%d %s: %s\n", cred.ID, cred.ExternalID, reason)
+		pruned++
+	}
+
+	if dryRun {
+		fmt.Printf("%d credentials would be pruned.\n", pruned)
+		return nil
+	}
+
+	fmt.Printf("%d credentials pruned.\n", pruned)
+
+	return nil
+}
@@ -0,0 +1,228 @@
+package credentials
+
+import (
+	"net"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultLegacyOrigin is the origin of the Fewsats API. Older versions
+	// only recorded the last path segment of the resources they paid for,
+	// the Fewsats storage files and gateways, so their credentials are only
+	// used for the origin they were paid on. This one is used when neither
+	// the macaroon nor the configured API URL tell which one it was.
+	defaultLegacyOrigin = "https://api.fewsats.com"
+)
+
+// defaultPorts are the ports stripped from the host when normalizing a scope.
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// Scope is the normalized resource scope L402 credentials are keyed by. A
+// credential can be used for any request with the same scheme and host whose
+// path is within the credential path prefix.
+type Scope struct {
+	// Scheme is the lower cased URL scheme.
+	Scheme string
+
+	// Host is the lower cased host, including the port if it is not the
+	// default one for the scheme.
+	Host string
+
+	// Path is the cleaned URL path, without a trailing slash.
+	Path string
+
+	// Location is the location of the macaroon, optional. If set, only
+	// credentials with the same location (or none) match the scope.
+	Location string
+}
+
+// NewScope returns the normalized scope of the given URL.
+func NewScope(u *url.URL) Scope {
+	scheme := strings.ToLower(u.Scheme)
+
+	host := strings.ToLower(u.Host)
+	if h, port, err := net.SplitHostPort(host); err == nil &&
+		defaultPorts[scheme] == port {
+
+		host = h
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+	}
+
+	return Scope{
+		Scheme: scheme,
+		Host:   host,
+		Path:   cleanPath(u.Path),
+	}
+}
+
+// ParseScope parses the given URL and returns its normalized scope.
+func ParseScope(rawURL string) (Scope, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return Scope{}, err
+	}
+
+	return NewScope(u), nil
+}
+
+// String returns the scope as a URL without query or fragment.
+func (s Scope) String() string {
+	return s.Scheme + "://" + s.Host + s.Path
+}
+
+// ExternalID returns the last path segment of the scope, the key used for
+// the credentials stored by older versions.
+func (s Scope) ExternalID() string {
+	return path.Base(s.Path)
+}
+
+// Contains returns true if the given path is within the scope path prefix.
+func (s Scope) Contains(p string) bool {
+	p = cleanPath(p)
+
+	switch {
+	case s.Path == "/" || s.Path == p:
+		return true
+
+	case strings.HasPrefix(p, s.Path+"/"):
+		return true
+	}
+
+	return false
+}
+
+// Scope returns the scope the credentials were paid for.
+func (l *L402Credentials) Scope() Scope {
+	return Scope{
+		Scheme:   l.Scheme,
+		Host:     l.Host,
+		Path:     l.PathPrefix,
+		Location: l.Location,
+	}
+}
+
+// SetScope sets the scope the credentials can be used for.
+func (l *L402Credentials) SetScope(scope Scope) {
+	l.ExternalID = scope.ExternalID()
+	l.Scheme = scope.Scheme
+	l.Host = scope.Host
+	l.PathPrefix = scope.Path
+	l.Location = scope.Location
+}
+
+// IsLegacy returns true if the credentials were stored before they were keyed
+// by scope, only their external ID is known.
+func (l *L402Credentials) IsLegacy() bool {
+	return l.Host == ""
+}
+
+// LegacyOrigin returns the origin, a scope with only a scheme and a host, the
+// legacy credentials were paid on. It is taken from the macaroon location if
+// it is a URL, else from the given URL of the Fewsats API used by the older
+// versions, else the public Fewsats API is assumed.
+func (l *L402Credentials) LegacyOrigin(apiURL string) Scope {
+	candidates := []string{
+		l.Location, MacaroonLocation(l.Macaroon), apiURL,
+	}
+	for _, rawURL := range candidates {
+		origin, ok := parseOrigin(rawURL)
+		if ok {
+			return origin
+		}
+	}
+
+	origin, _ := parseOrigin(defaultLegacyOrigin)
+
+	return origin
+}
+
+// sameOrigin returns true if both scopes have the same scheme and host.
+func (s Scope) sameOrigin(other Scope) bool {
+	return s.Scheme == other.Scheme && s.Host == other.Host
+}
+
+// parseOrigin returns the origin of the given URL, false if it is not an
+// absolute URL.
+func parseOrigin(rawURL string) (Scope, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return Scope{}, false
+	}
+
+	scope := NewScope(u)
+
+	return Scope{Scheme: scope.Scheme, Host: scope.Host}, true
+}
+
+// MatchCredentials returns the most specific credentials for the scope: the
+// ones with the longest path prefix containing the scope path, with a
+// matching macaroon location if the scope has one. Legacy credentials whose
+// external ID matches a scope on their origin, see LegacyOrigin with the given
+// Fewsats API URL, are only used if nothing else matches, they never leave
+// that origin. The newest credentials win when several are equally specific.
+// Stale and expired credentials never match.
+func MatchCredentials(creds []*L402Credentials, scope Scope,
+	apiURL string) *L402Credentials {
+
+	var (
+		best      *L402Credentials
+		bestScore = -1
+		now       = time.Now()
+	)
+	for _, c := range creds {
+		var score int
+		switch {
+		case !c.Valid(now):
+			continue
+
+		case c.IsLegacy():
+			if !scope.sameOrigin(c.LegacyOrigin(apiURL)) ||
+				c.ExternalID != scope.ExternalID() {
+
+				continue
+			}
+
+		case c.Scheme != scope.Scheme || c.Host != scope.Host:
+			continue
+
+		case !c.Scope().Contains(scope.Path):
+			continue
+
+		case scope.Location != "" && c.Location != "" &&
+			c.Location != scope.Location:
+
+			continue
+
+		default:
+			// Any scoped match wins over the legacy ones.
+			score = len(c.PathPrefix) + 1
+		}
+
+		switch {
+		case score > bestScore:
+			best, bestScore = c, score
+
+		case score == bestScore && c.CreatedAt.After(best.CreatedAt):
+			best = c
+		}
+	}
+
+	return best
+}
+
+// cleanPath returns the cleaned absolute path without a trailing slash.
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+
+	return path.Clean("/" + p)
+}
@@ -0,0 +1,171 @@
+package credentials
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/macaroon.v2"
+)
+
+func TestParseScope(t *testing.T) {
+	tests := []struct {
+		url   string
+		scope Scope
+	}{
+		{
+			url:   "https://api.example.com/v1/data?x=1",
+			scope: Scope{Scheme: "https", Host: "api.example.com", Path: "/v1/data"},
+		},
+		{
+			url:   "HTTPS://API.Example.com:443/v1/data/",
+			scope: Scope{Scheme: "https", Host: "api.example.com", Path: "/v1/data"},
+		},
+		{
+			url:   "http://api.example.com:8080",
+			scope: Scope{Scheme: "http", Host: "api.example.com:8080", Path: "/"},
+		},
+		{
+			url:   "http://[::1]:80/a/../b",
+			scope: Scope{Scheme: "http", Host: "[::1]", Path: "/b"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.url, func(t *testing.T) {
+			scope, err := ParseScope(tc.url)
+			require.NoError(t, err)
+			require.Equal(t, tc.scope, scope)
+		})
+	}
+}
+
+func TestMatchCredentials(t *testing.T) {
+	now := time.Now()
+
+	scoped := func(id int64, rawURL, location string,
+		createdAt time.Time) *L402Credentials {
+
+		scope, err := ParseScope(rawURL)
+		require.NoError(t, err)
+		scope.Location = location
+
+		creds := &L402Credentials{ID: id, CreatedAt: createdAt}
+		creds.SetScope(scope)
+
+		return creds
+	}
+
+	// Legacy credentials whose macaroon location is the URL of the API
+	// they were paid on.
+	mac, err := macaroon.New(
+		[]byte("root-key"), []byte("id"), "https://files.example.com",
+		macaroon.LatestVersion,
+	)
+	require.NoError(t, err)
+	macBytes, err := mac.MarshalBinary()
+	require.NoError(t, err)
+
+	creds := []*L402Credentials{
+		{ID: 1, ExternalID: "data", CreatedAt: now},
+		{
+			ID:         7,
+			ExternalID: "file",
+			Macaroon:   base64.StdEncoding.EncodeToString(macBytes),
+			CreatedAt:  now,
+		},
+		scoped(2, "https://a.com/", "", now),
+		scoped(3, "https://a.com/v1/data", "", now),
+		scoped(4, "https://a.com/v1/data", "", now.Add(time.Second)),
+		scoped(5, "https://a.com/v2", "loc-a", now),
+		scoped(6, "https://b.com/v1", "", now),
+	}
+
+	tests := []struct {
+		name     string
+		url      string
+		location string
+		apiURL   string
+		id       int64
+	}{
+		{
+			name: "Newest of the most specific",
+			url:  "https://a.com/v1/data",
+			id:   4,
+		},
+		{
+			name: "Prefix on segment boundary",
+			url:  "https://a.com/v1/database",
+			id:   2,
+		},
+		{
+			name: "Longest prefix",
+			url:  "https://b.com/v1/x/data",
+			id:   6,
+		},
+		{
+			name:     "Matching location",
+			url:      "https://a.com/v2/x",
+			location: "loc-a",
+			id:       5,
+		},
+		{
+			name:     "Other location",
+			url:      "https://a.com/v2/x",
+			location: "loc-b",
+			id:       2,
+		},
+		{
+			name: "Legacy fallback",
+			url:  "https://api.fewsats.com/v0/storage/download/data",
+			id:   1,
+		},
+		{
+			name: "No legacy fallback on another origin",
+			url:  "https://c.com/v1/data",
+		},
+		{
+			name:   "Legacy fallback on the configured API",
+			url:    "http://localhost:8000/v0/storage/download/data",
+			apiURL: "http://localhost:8000",
+			id:     1,
+		},
+		{
+			name:   "No legacy fallback off the configured API",
+			url:    "https://api.fewsats.com/v0/storage/download/data",
+			apiURL: "http://localhost:8000",
+		},
+		{
+			name:   "Legacy fallback on the macaroon location",
+			url:    "https://files.example.com/v0/file",
+			apiURL: "http://localhost:8000",
+			id:     7,
+		},
+		{
+			name: "No legacy fallback off the macaroon location",
+			url:  "https://api.fewsats.com/v0/file",
+		},
+		{
+			name: "No match",
+			url:  "https://c.com/v1/other",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			scope, err := ParseScope(tc.url)
+			require.NoError(t, err)
+			scope.Location = tc.location
+
+			match := MatchCredentials(creds, scope, tc.apiURL)
+			if tc.id == 0 {
+				require.Nil(t, match)
+				return
+			}
+
+			require.NotNil(t, match)
+			require.Equal(t, tc.id, match.ID)
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package credentials
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/urfave/cli/v2"
+)
+
+var showCommand = &cli.Command{
+	Name:      "show",
+	Usage:     "Show the stored L402 credentials with the given ID.",
+	ArgsUsage: "<id>",
+	Action:    showCredentials,
+}
+
+// showCredentials prints the summary and the secrets of the credentials.
+func showCredentials(c *cli.Context) error {
+	store, ok := c.App.Metadata["store"].(AdminStore)
+	if !ok {
+		return errors.New("failed to get store from context")
+	}
+
+	if c.Args().Len() < 1 {
+		return cli.Exit("missing <id> argument", 1)
+	}
+
+	id, err := parseID(c.Args().Get(0))
+	if err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+
+	creds, err := store.GetL402CredentialsByID(id)
+	switch {
+	case errors.Is(err, ErrNoCredentialsFound):
+		return cli.Exit(fmt.Sprintf("credentials %d not found", id), 1)
+
+	case err != nil:
+		slog.Debug("Failed to get L402 credentials.", "error", err)
+		return cli.Exit("failed to get credentials", 1)
+	}
+
+	response := struct {
+		Info
+		Macaroon string `json:"macaroon"`
+		Preimage string `json:"preimage"`
+		Invoice  string `json:"invoice"`
+	}{
+		Info:     newInfo(creds),
+		Macaroon: creds.Macaroon,
+		Preimage: creds.Preimage,
+		Invoice:  creds.Invoice,
+	}
+
+	jsonOutput, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		return cli.Exit("failed to marshal JSON", 1)
+	}
+
+	fmt.Println(string(jsonOutput))
+
+	return nil
+}
@@ -0,0 +1,17 @@
+package dev
+
+import (
+	"github.com/urfave/cli/v2"
+)
+
+// Command creates the dev command, with the tools to develop and test
+// against a local stand-in for the Fewsats platform.
+func Command() *cli.Command {
+	return &cli.Command{
+		Name:  "dev",
+		Usage: "Tools for offline development and tests.",
+		Subcommands: []*cli.Command{
+			serverCommand,
+		},
+	}
+}
@@ -0,0 +1,156 @@
+package dev
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/fewsats/fewsatscli/config"
+	"github.com/fewsats/fewsatscli/fewsatstest"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	// defaultListenAddr is the default address the dev server listens on.
+	defaultListenAddr = "127.0.0.1:8081"
+
+	// shutdownTimeout is the time given to in flight requests to finish
+	// when the server is stopped.
+	shutdownTimeout = 5 * time.Second
+)
+
+var serverCommand = &cli.Command{
+	Name:  "server",
+	Usage: "Run an in-memory mock of the Fewsats API.",
+	Description: "Every command runs against the mock when the profile " +
+		"DOMAIN points at it. Its state is lost when it stops.",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "listen",
+			Value: defaultListenAddr,
+			Usage: "The address the server listens on",
+		},
+		&cli.StringFlag{
+			Name:  "network",
+			Usage: "The network of the invoices, the profile NETWORK by default",
+		},
+		&cli.StringFlag{
+			Name:  "email",
+			Value: fewsatstest.DefaultEmail,
+			Usage: "The email of the account created with the server",
+		},
+		&cli.StringFlag{
+			Name:  "password",
+			Value: fewsatstest.DefaultPassword,
+			Usage: "The password of the account created with the server",
+		},
+		&cli.StringFlag{
+			Name:  "api-key",
+			Value: fewsatstest.DefaultAPIKey,
+			Usage: "The API key of the account created with the server",
+		},
+		&cli.Uint64Flag{
+			Name:  "sats-per-cent",
+			Value: fewsatstest.DefaultSatsPerCent,
+			Usage: "The price in sats of a USD cent",
+		},
+		&cli.StringFlag{
+			Name:  "preimage-dir",
+			Usage: "Directory where the preimage of every invoice is written",
+		},
+	},
+	Action: runServer,
+}
+
+// runServer starts the mock server and blocks until it is interrupted.
+func runServer(c *cli.Context) error {
+	network := c.String("network")
+	if network == "" {
+		if cfg, err := config.GetConfig(); err == nil {
+			network = cfg.Network
+		}
+	}
+
+	if dir := c.String("preimage-dir"); dir != "" {
+		err := os.MkdirAll(dir, 0700)
+		if err != nil {
+			slog.Debug("Failed to create preimage dir.", "error", err)
+			return cli.Exit("failed to create preimage dir", 1)
+		}
+	}
+
+	mock, err := fewsatstest.New(fewsatstest.Config{
+		Network:     network,
+		Email:       c.String("email"),
+		Password:    c.String("password"),
+		APIKey:      c.String("api-key"),
+		SatsPerCent: c.Uint64("sats-per-cent"),
+		PreimageDir: c.String("preimage-dir"),
+	})
+	if err != nil {
+		slog.Debug("Failed to create dev server.", "error", err)
+		return cli.Exit(fmt.Sprintf("failed to create dev server: %v", err), 1)
+	}
+
+	listener, err := net.Listen("tcp", c.String("listen"))
+	if err != nil {
+		slog.Debug("Failed to listen.", "error", err)
+		return cli.Exit(fmt.Sprintf("failed to listen: %v", err), 1)
+	}
+
+	server := &http.Server{
+		Handler:           mock,
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(
+		c.Context, os.Interrupt, syscall.SIGTERM,
+	)
+	defer stop()
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.Serve(listener)
+	}()
+
+	cfg := mock.Config()
+	url := "http://" + listener.Addr().String()
+
+	fmt.Printf("Fewsats dev server listening on %s\n", url)
+	fmt.Printf("Set DOMAIN=%s and NETWORK=%s in your profile to use it.\n",
+		url, cfg.Network)
+	fmt.Printf("Account: %s / %s\n", cfg.Email, cfg.Password)
+	fmt.Printf("API key: %s\n", cfg.APIKey)
+	fmt.Printf("Pay the invoices with: POST %s/dev/pay {\"invoice\": ...}\n",
+		url)
+
+	select {
+	case err := <-errChan:
+		slog.Debug("Dev server stopped.", "error", err)
+		return cli.Exit(fmt.Sprintf("dev server stopped: %v", err), 1)
+
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(
+		context.Background(), shutdownTimeout,
+	)
+	defer cancel()
+
+	err = server.Shutdown(shutdownCtx)
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Debug("Failed to stop dev server.", "error", err)
+		return cli.Exit("failed to stop dev server", 1)
+	}
+
+	fmt.Println("Dev server stopped.")
+
+	return nil
+}
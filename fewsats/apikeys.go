@@ -0,0 +1,83 @@
+package fewsats
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+const (
+	// apiKeyPath is the path of the create API key endpoint.
+	apiKeyPath = "/v0/auth/apikey"
+
+	// apiKeysPath is the path of the API keys endpoints.
+	apiKeysPath = "/v0/auth/apikeys"
+)
+
+// APIKey is an API key of the user. The key itself is only known when it is
+// created, the API returns its hidden version afterwards.
+type APIKey struct {
+	ID        uint64     `json:"id"`
+	Key       string     `json:"key,omitempty"`
+	Name      string     `json:"name"`
+	HiddenKey string     `json:"hidden_key"`
+	UserID    int64      `json:"user_id"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	Enabled   bool       `json:"enabled"`
+}
+
+// CreateAPIKeyRequest is the request body of the create API key endpoint.
+type CreateAPIKeyRequest struct {
+	Duration time.Duration `json:"duration"`
+	Name     string        `json:"name"`
+}
+
+// CreateAPIKeyResponse is the response of the create API key endpoint.
+type CreateAPIKeyResponse struct {
+	APIKey    string     `json:"apikey"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	UserID    int64      `json:"user_id"`
+}
+
+// APIKeysService handles the API keys of the user.
+type APIKeysService struct {
+	client *Client
+}
+
+// Create creates an API key valid for the given duration.
+func (s *APIKeysService) Create(ctx context.Context,
+	r *CreateAPIKeyRequest) (*CreateAPIKeyResponse, error) {
+
+	var response CreateAPIKeyResponse
+	err := s.client.call(ctx, http.MethodPost, apiKeyPath, r, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// List returns the API keys of the user.
+func (s *APIKeysService) List(ctx context.Context,
+	opts ListOptions) ([]APIKey, error) {
+
+	var response struct {
+		Keys []APIKey `json:"keys"`
+	}
+	err := s.client.call(
+		ctx, http.MethodGet, apiKeysPath+opts.query(), nil, &response,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Keys, nil
+}
+
+// Disable disables the API key with the given ID.
+func (s *APIKeysService) Disable(ctx context.Context, id string) error {
+	return s.client.call(
+		ctx, http.MethodPost, itemPath(apiKeysPath, id)+"/disable", nil,
+		nil,
+	)
+}
@@ -0,0 +1,81 @@
+package fewsats
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+const (
+	// loginPath is the path of the login endpoint.
+	loginPath = "/v0/auth/login"
+
+	// signupPath is the path of the signup endpoint.
+	signupPath = "/v0/auth/signup"
+
+	// mePath is the path of the endpoint returning the authenticated user.
+	mePath = "/v0/auth/me"
+)
+
+// ErrNoSession is returned when the login response holds no session cookie.
+var ErrNoSession = errors.New("session cookie not found")
+
+// LoginRequest is the request body of the login endpoint.
+type LoginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// SignupRequest is the request body of the signup endpoint.
+type SignupRequest struct {
+	Email                string `json:"email"`
+	Password             string `json:"password"`
+	PasswordConfirmation string `json:"password2"`
+}
+
+// AuthService handles the accounts and sessions.
+type AuthService struct {
+	client *Client
+}
+
+// Signup creates an account.
+func (s *AuthService) Signup(ctx context.Context, r *SignupRequest) error {
+	return s.client.call(ctx, http.MethodPost, signupPath, r, nil)
+}
+
+// Login logs into the account and returns the session cookie. The cookie is
+// also set as the SessionCookie of the client.
+func (s *AuthService) Login(ctx context.Context, email,
+	password string) (*http.Cookie, error) {
+
+	req, err := s.client.newJSONRequest(
+		ctx, http.MethodPost, loginPath, &LoginRequest{
+			Email:    email,
+			Password: password,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := do(s.client.HTTPClient, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == sessionCookieName {
+			s.client.SessionCookie = cookie
+			return cookie, nil
+		}
+	}
+
+	return nil, ErrNoSession
+}
+
+// Verify checks that the client credentials are valid. An *APIError matching
+// ErrUnauthorized is returned if they are not.
+func (s *AuthService) Verify(ctx context.Context) error {
+	return s.client.call(ctx, http.MethodGet, mePath, nil, nil)
+}
@@ -0,0 +1,127 @@
+package fewsats
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+var (
+	// ErrBadRequest is matched by the API errors with a 400 status.
+	ErrBadRequest = errors.New("bad request")
+
+	// ErrUnauthorized is matched by the API errors with a 401 status, the
+	// API key or the session is missing, invalid or expired.
+	ErrUnauthorized = errors.New("unauthorized")
+
+	// ErrPaymentRequired is matched by the API errors with a 402 status,
+	// the L402 challenge was not paid.
+	ErrPaymentRequired = errors.New("payment required")
+
+	// ErrForbidden is matched by the API errors with a 403 status.
+	ErrForbidden = errors.New("forbidden")
+
+	// ErrNotFound is matched by the API errors with a 404 status.
+	ErrNotFound = errors.New("not found")
+
+	// ErrConflict is matched by the API errors with a 409 status.
+	ErrConflict = errors.New("conflict")
+
+	// ErrRateLimited is matched by the API errors with a 429 status.
+	ErrRateLimited = errors.New("rate limited")
+
+	// ErrServer is matched by the API errors with a 5xx status.
+	ErrServer = errors.New("server error")
+)
+
+// maxErrorBody is the maximum size of an error body kept in an APIError.
+const maxErrorBody = 64 * 1024
+
+// APIError is returned when the API answers with an error status.
+type APIError struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+
+	// Message is the error message sent by the API, empty if the body
+	// holds none.
+	Message string
+
+	// Body is the raw response body.
+	Body []byte
+}
+
+// Error returns the error message.
+func (e *APIError) Error() string {
+	status := fmt.Sprintf("%d %s", e.StatusCode,
+		http.StatusText(e.StatusCode))
+
+	if e.Message == "" {
+		return fmt.Sprintf("fewsats API error: %s", status)
+	}
+
+	return fmt.Sprintf("fewsats API error: %s: %s", status, e.Message)
+}
+
+// Is reports whether the error status matches the target sentinel error.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrPaymentRequired:
+		return e.StatusCode == http.StatusPaymentRequired
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrServer:
+		return e.StatusCode >= 500
+	}
+
+	return false
+}
+
+// newAPIError reads the error body of the response. The API sends the
+// message in the "error", "message" or "detail" field of a JSON object, any
+// other body is kept as the message if it is short text.
+func newAPIError(resp *http.Response) *APIError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		Body:       body,
+	}
+
+	var errorBody struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+		Detail  string `json:"detail"`
+	}
+	if json.Unmarshal(body, &errorBody) == nil {
+		switch {
+		case errorBody.Error != "":
+			apiErr.Message = errorBody.Error
+		case errorBody.Message != "":
+			apiErr.Message = errorBody.Message
+		case errorBody.Detail != "":
+			apiErr.Message = errorBody.Detail
+		}
+
+		return apiErr
+	}
+
+	message := strings.TrimSpace(string(body))
+	if len(message) <= 200 && !strings.ContainsRune(message, '<') {
+		apiErr.Message = message
+	}
+
+	return apiErr
+}
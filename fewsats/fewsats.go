@@ -0,0 +1,273 @@
+// Package fewsats is a Go client for the Fewsats API.
+//
+// A Client groups the API endpoints by resource:
+//
+//	c := fewsats.NewClient("https://api.fewsats.com", apiKey)
+//	files, err := c.Files.List(ctx, fewsats.ListOptions{Limit: 10})
+//
+// The requests fail with an *APIError when the API answers with an error
+// status, errors.Is can be used to check it against ErrNotFound,
+// ErrUnauthorized and the other sentinel errors.
+package fewsats
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// DefaultBaseURL is the URL of the Fewsats API.
+	DefaultBaseURL = "https://api.fewsats.com"
+
+	// sessionCookieName is the name of the cookie holding the session
+	// returned on login.
+	sessionCookieName = "fewsats_session"
+)
+
+// Doer sends HTTP requests, *http.Client implements it.
+type Doer interface {
+	// Do sends the request and returns its response.
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// DoerFunc is a function implementing the Doer interface.
+type DoerFunc func(req *http.Request) (*http.Response, error)
+
+// Do calls f(req).
+func (f DoerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Client is a client for the Fewsats API. The fields can be changed before
+// making the first request.
+type Client struct {
+	// BaseURL is the URL of the Fewsats API, DefaultBaseURL if empty.
+	BaseURL string
+
+	// APIKey authenticates the requests. If empty the SessionCookie is
+	// used instead.
+	APIKey string
+
+	// SessionCookie authenticates the requests when no API key is set, it
+	// is returned by Auth.Login.
+	SessionCookie *http.Cookie
+
+	// HTTPClient sends the API requests, http.DefaultClient if nil.
+	HTTPClient Doer
+
+	// L402Client sends the requests to the L402 paywalled resources, the
+	// file downloads and the gateway accesses. It should pay the 402
+	// challenges, for example an *http.Client using an l402.Transport.
+	// HTTPClient is used if nil.
+	L402Client Doer
+
+	// UploadClient sends the file contents to the presigned upload URLs,
+	// http.DefaultClient if nil. The uploads can be large, it should not
+	// bound the whole request with a timeout.
+	UploadClient Doer
+
+	// Auth handles the accounts and sessions.
+	Auth *AuthService
+
+	// Files handles the files in the storage service.
+	Files *FilesService
+
+	// Gateways handles the L402 gateways proxying other APIs.
+	Gateways *GatewaysService
+
+	// APIKeys handles the API keys of the user.
+	APIKeys *APIKeysService
+
+	// Users handles the user details and billing information.
+	Users *UsersService
+
+	// Payouts handles the payouts of the user.
+	Payouts *PayoutsService
+
+	// Macaroons mints and validates macaroons.
+	Macaroons *MacaroonsService
+}
+
+// NewClient creates a client for the Fewsats API at the given URL,
+// authenticated with the given API key.
+func NewClient(baseURL, apiKey string) *Client {
+	c := &Client{
+		BaseURL: baseURL,
+		APIKey:  apiKey,
+	}
+
+	c.Auth = &AuthService{client: c}
+	c.Files = &FilesService{client: c}
+	c.Gateways = &GatewaysService{client: c}
+	c.APIKeys = &APIKeysService{client: c}
+	c.Users = &UsersService{client: c}
+	c.Payouts = &PayoutsService{client: c}
+	c.Macaroons = &MacaroonsService{client: c}
+
+	return c
+}
+
+// ListOptions paginates the list and search requests.
+type ListOptions struct {
+	// Limit is the maximum number of results, the API default if zero.
+	Limit int
+
+	// Offset is the number of results to skip.
+	Offset int
+}
+
+// query returns the options as a query string, starting with "?" if not
+// empty.
+func (o ListOptions) query() string {
+	values := url.Values{}
+	if o.Limit > 0 {
+		values.Set("limit", fmt.Sprint(o.Limit))
+	}
+	if o.Offset > 0 {
+		values.Set("offset", fmt.Sprint(o.Offset))
+	}
+
+	if len(values) == 0 {
+		return ""
+	}
+
+	return "?" + values.Encode()
+}
+
+// URL returns the absolute URL of the given API path.
+func (c *Client) URL(path string) string {
+	baseURL := c.BaseURL
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+
+	return strings.TrimSuffix(baseURL, "/") + path
+}
+
+// itemPath returns the path of the item with the given ID under the given
+// path.
+func itemPath(path, id string) string {
+	return path + "/" + url.PathEscape(id)
+}
+
+// resourceURL returns the URL of the resource with the given ID under the
+// given path. The ID can already be a full URL, it is returned as is.
+func (c *Client) resourceURL(path, idOrURL string) string {
+	u, err := url.ParseRequestURI(idOrURL)
+	if err == nil && u.IsAbs() {
+		return idOrURL
+	}
+
+	return c.URL(itemPath(path, idOrURL))
+}
+
+// newRequest creates an authenticated request to the given API path.
+func (c *Client) newRequest(ctx context.Context, method, path string,
+	body io.Reader, contentType string) (*http.Request, error) {
+
+	req, err := http.NewRequestWithContext(ctx, method, c.URL(path), body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	switch {
+	case c.APIKey != "":
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	case c.SessionCookie != nil:
+		req.AddCookie(c.SessionCookie)
+	}
+
+	return req, nil
+}
+
+// do sends the request with the given client and checks the response
+// status. The caller must close the body of the returned response.
+func do(client Doer, req *http.Request) (*http.Response, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to execute request: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		return nil, newAPIError(resp)
+	}
+
+	return resp, nil
+}
+
+// call sends a request to the given API path with the given JSON body, nil
+// for none, and decodes the JSON response into out, nil to discard it.
+func (c *Client) call(ctx context.Context, method, path string, in,
+	out any) error {
+
+	req, err := c.newJSONRequest(ctx, method, path, in)
+	if err != nil {
+		return err
+	}
+
+	return c.send(req, out)
+}
+
+// newJSONRequest creates an authenticated request to the given API path with
+// the given JSON body, nil for none.
+func (c *Client) newJSONRequest(ctx context.Context, method, path string,
+	in any) (*http.Request, error) {
+
+	if in == nil {
+		return c.newRequest(ctx, method, path, nil, "")
+	}
+
+	data, err := json.Marshal(in)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode request: %w", err)
+	}
+
+	return c.newRequest(
+		ctx, method, path, bytes.NewReader(data), "application/json",
+	)
+}
+
+// send sends the request to the API and decodes the JSON response into out,
+// nil to discard it.
+func (c *Client) send(req *http.Request, out any) error {
+	resp, err := do(c.HTTPClient, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(out)
+	if err != nil {
+		return fmt.Errorf("unable to decode response: %w", err)
+	}
+
+	return nil
+}
+
+// l402Client returns the client used for the L402 paywalled resources.
+func (c *Client) l402Client() Doer {
+	if c.L402Client != nil {
+		return c.L402Client
+	}
+
+	return c.HTTPClient
+}
@@ -0,0 +1,221 @@
+package fewsats
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIError(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		body        string
+		wantMessage string
+		wantIs      error
+	}{
+		{
+			name:        "Error field",
+			status:      http.StatusNotFound,
+			body:        `{"error": "file not found"}`,
+			wantMessage: "file not found",
+			wantIs:      ErrNotFound,
+		},
+		{
+			name:        "Message field",
+			status:      http.StatusUnauthorized,
+			body:        `{"message": "invalid api key"}`,
+			wantMessage: "invalid api key",
+			wantIs:      ErrUnauthorized,
+		},
+		{
+			name:        "Plain text",
+			status:      http.StatusBadRequest,
+			body:        "price is required\n",
+			wantMessage: "price is required",
+			wantIs:      ErrBadRequest,
+		},
+		{
+			name:   "HTML page",
+			status: http.StatusBadGateway,
+			body:   "<html><body>Bad gateway</body></html>",
+			wantIs: ErrServer,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(tc.status)
+					io.WriteString(w, tc.body)
+				},
+			))
+			defer srv.Close()
+
+			c := NewClient(srv.URL, "key")
+			_, err := c.Files.Get(context.Background(), "id")
+
+			var apiErr *APIError
+			require.ErrorAs(t, err, &apiErr)
+			require.Equal(t, tc.status, apiErr.StatusCode)
+			require.Equal(t, tc.wantMessage, apiErr.Message)
+			require.Equal(t, tc.body, string(apiErr.Body))
+			require.ErrorIs(t, err, tc.wantIs)
+			require.NotErrorIs(t, err, ErrForbidden)
+		})
+	}
+}
+
+func TestClientRequests(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v0/storage", func(w http.ResponseWriter,
+		r *http.Request) {
+
+		require.Equal(t, "Bearer key", r.Header.Get("Authorization"))
+		require.Equal(t, "10", r.URL.Query().Get("limit"))
+		require.Equal(t, "5", r.URL.Query().Get("offset"))
+
+		io.WriteString(w, `{"files": [{"external_id": "f1", "name": "a.pdf",
+			"price_in_cents": 100}]}`)
+	})
+	mux.HandleFunc("POST /v0/gateway", func(w http.ResponseWriter,
+		r *http.Request) {
+
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		var req CreateGatewayRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		require.Equal(t, "https://example.com", req.TargetURL)
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(Gateway{
+			ExternalID: "g1",
+			TargetURL:  req.TargetURL,
+		})
+	})
+	mux.HandleFunc("GET /v0/gateway/g1/details", func(w http.ResponseWriter,
+		r *http.Request) {
+
+		io.WriteString(w, `{"gateway_visits": {"gateway_id": "g1",
+			"gateway_visits": [{"date": "2024-05-01", "visit_count": 3}]}}`)
+	})
+	mux.HandleFunc("POST /v0/auth/login", func(w http.ResponseWriter,
+		r *http.Request) {
+
+		require.Empty(t, r.Header.Get("Authorization"))
+
+		http.SetCookie(w, &http.Cookie{
+			Name:  sessionCookieName,
+			Value: "session",
+		})
+	})
+	mux.HandleFunc("GET /v0/auth/me", func(w http.ResponseWriter,
+		r *http.Request) {
+
+		cookie, err := r.Cookie(sessionCookieName)
+		if err != nil || cookie.Value != "session" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	})
+	mux.HandleFunc("POST /v0/storage/upload", func(w http.ResponseWriter,
+		r *http.Request) {
+
+		require.NoError(t, r.ParseMultipartForm(1<<20))
+		require.Equal(t, "a.pdf", r.FormValue("name"))
+		require.Equal(t, "1999", r.FormValue("price_in_cents"))
+		require.Equal(t, []string{"x", "y"}, r.MultipartForm.Value["tags"])
+
+		json.NewEncoder(w).Encode(UploadFileResponse{
+			FileID:       "f2",
+			PresignedURL: "http://" + r.Host + "/presigned/f2",
+		})
+	})
+	mux.HandleFunc("PUT /presigned/f2", func(w http.ResponseWriter,
+		r *http.Request) {
+
+		require.Empty(t, r.Header.Get("Authorization"))
+		require.EqualValues(t, 8, r.ContentLength)
+
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.Equal(t, "contents", string(body))
+	})
+	mux.HandleFunc("GET /v0/storage/download/f2", func(w http.ResponseWriter,
+		r *http.Request) {
+
+		w.Header().Set("file-name", "a.pdf")
+		io.WriteString(w, "contents")
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ctx := context.Background()
+	c := NewClient(srv.URL+"/", "key")
+
+	files, err := c.Files.List(ctx, ListOptions{Limit: 10, Offset: 5})
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.Equal(t, "f1", files[0].ExternalID)
+	require.EqualValues(t, 100, files[0].PriceInUsdCents)
+
+	gateway, err := c.Gateways.Create(ctx, &CreateGatewayRequest{
+		TargetURL: "https://example.com",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "g1", gateway.ExternalID)
+
+	visits, err := c.Gateways.Visits(ctx, "g1")
+	require.NoError(t, err)
+	require.Equal(t, "g1", visits.GatewayID)
+	require.Equal(t, 3, visits.GatewayVisits[0].VisitCount)
+
+	resp, err := c.Files.Upload(ctx, &UploadFileRequest{
+		Name:         "a.pdf",
+		PriceInCents: 1999,
+		Tags:         []string{"x", "y"},
+		File:         strings.NewReader("contents"),
+		FileSize:     8,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "f2", resp.FileID)
+
+	// The downloads go through the L402 client, by ID or by URL.
+	var l402Requests int
+	c.L402Client = DoerFunc(func(req *http.Request) (*http.Response,
+		error) {
+
+		l402Requests++
+		require.Empty(t, req.Header.Get("Authorization"))
+
+		return http.DefaultClient.Do(req)
+	})
+
+	for _, id := range []string{"f2", c.Files.DownloadURL("f2")} {
+		download, err := c.Files.Download(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, "a.pdf", download.Name)
+
+		body, err := io.ReadAll(download.Body)
+		require.NoError(t, err)
+		require.NoError(t, download.Body.Close())
+		require.Equal(t, "contents", string(body))
+	}
+	require.Equal(t, 2, l402Requests)
+
+	// Without an API key the session cookie returned on login is used.
+	anon := NewClient(srv.URL, "")
+	require.ErrorIs(t, anon.Auth.Verify(ctx), ErrUnauthorized)
+
+	cookie, err := anon.Auth.Login(ctx, "user@example.com", "secret")
+	require.NoError(t, err)
+	require.Equal(t, "session", cookie.Value)
+	require.NoError(t, anon.Auth.Verify(ctx))
+}
@@ -0,0 +1,271 @@
+package fewsats
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// storagePath is the path of the storage endpoints.
+	storagePath = "/v0/storage"
+
+	// downloadPath is the path of the L402 paywalled file downloads.
+	downloadPath = "/v0/storage/download"
+)
+
+// File is a file sold through the storage service.
+type File struct {
+	ExternalID      string    `json:"external_id"`
+	Name            string    `json:"name"`
+	Description     string    `json:"description"`
+	L402URL         string    `json:"l402_url"`
+	Size            uint64    `json:"size"`
+	Extension       string    `json:"extension"`
+	MimeType        string    `json:"mime_type"`
+	CoverURL        string    `json:"cover_url"`
+	PriceInUsdCents uint64    `json:"price_in_cents"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+	Tags            []string  `json:"tags"`
+	Status          string    `json:"status"`
+}
+
+// UploadFileRequest describes a file to upload.
+type UploadFileRequest struct {
+	// Name is the name of the file.
+	Name string
+
+	// Description is the description of the file contents.
+	Description string
+
+	// PriceInCents is the price of the file in USD cents.
+	PriceInCents uint64
+
+	// Tags are the tags associated with the file.
+	Tags []string
+
+	// Cover is the optional cover image, named CoverName.
+	Cover     io.Reader
+	CoverName string
+
+	// File is the file contents, sent to the presigned URL returned by the
+	// API. Its size is FileSize, or the size of the file if it is an
+	// *os.File and FileSize is zero.
+	File     io.Reader
+	FileSize int64
+}
+
+// UploadFileResponse is the response of the upload endpoint.
+type UploadFileResponse struct {
+	FileID       string `json:"file_id"`
+	PresignedURL string `json:"presigned_url"`
+}
+
+// FileDownload is a file being downloaded, the caller must close its Body.
+type FileDownload struct {
+	// Name is the name of the file.
+	Name string
+
+	// Size is the size of the file, -1 if unknown.
+	Size int64
+
+	// Body is the file contents.
+	Body io.ReadCloser
+}
+
+// FilesService handles the files in the storage service.
+type FilesService struct {
+	client *Client
+}
+
+// List returns the files of the user.
+func (s *FilesService) List(ctx context.Context,
+	opts ListOptions) ([]File, error) {
+
+	var response struct {
+		Files []File `json:"files"`
+	}
+	err := s.client.call(
+		ctx, http.MethodGet, storagePath+opts.query(), nil, &response,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Files, nil
+}
+
+// Search returns the public files.
+func (s *FilesService) Search(ctx context.Context,
+	opts ListOptions) ([]File, error) {
+
+	var response struct {
+		Files []File `json:"files"`
+	}
+	err := s.client.call(
+		ctx, http.MethodGet, storagePath+"/search"+opts.query(), nil,
+		&response,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Files, nil
+}
+
+// Get returns the file with the given ID.
+func (s *FilesService) Get(ctx context.Context, id string) (*File, error) {
+	var response struct {
+		File File `json:"file"`
+	}
+	err := s.client.call(
+		ctx, http.MethodGet, itemPath(storagePath, id), nil, &response,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response.File, nil
+}
+
+// Delete deletes the file with the given ID.
+func (s *FilesService) Delete(ctx context.Context, id string) error {
+	return s.client.call(ctx, http.MethodDelete, itemPath(storagePath, id), nil, nil)
+}
+
+// Upload creates the file and uploads its contents.
+func (s *FilesService) Upload(ctx context.Context,
+	r *UploadFileRequest) (*UploadFileResponse, error) {
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	fields := [][2]string{
+		{"name", r.Name},
+		{"file_name", r.Name},
+		{"description", r.Description},
+		{"price_in_cents", strconv.FormatUint(r.PriceInCents, 10)},
+	}
+	for _, tag := range r.Tags {
+		fields = append(fields, [2]string{"tags", tag})
+	}
+
+	for _, field := range fields {
+		err := writer.WriteField(field[0], field[1])
+		if err != nil {
+			return nil, fmt.Errorf("unable to write %s field: %w",
+				field[0], err)
+		}
+	}
+
+	if r.Cover != nil {
+		part, err := writer.CreateFormFile("cover", r.CoverName)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create cover form file: %w",
+				err)
+		}
+
+		_, err = io.Copy(part, r.Cover)
+		if err != nil {
+			return nil, fmt.Errorf("unable to write cover image: %w", err)
+		}
+	}
+
+	err := writer.Close()
+	if err != nil {
+		return nil, fmt.Errorf("unable to finalize multipart message: %w",
+			err)
+	}
+
+	req, err := s.client.newRequest(
+		ctx, http.MethodPost, storagePath+"/upload", body,
+		writer.FormDataContentType(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var response UploadFileResponse
+	err = s.client.send(req, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	// The presigned URL is empty when the file is not stored by us.
+	if response.PresignedURL != "" && r.File != nil {
+		err = s.uploadContents(ctx, response.PresignedURL, r)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &response, nil
+}
+
+// uploadContents sends the file contents to the presigned URL.
+func (s *FilesService) uploadContents(ctx context.Context,
+	presignedURL string, r *UploadFileRequest) error {
+
+	size := r.FileSize
+	if file, ok := r.File.(interface {
+		Stat() (fs.FileInfo, error)
+	}); ok && size == 0 {
+		info, err := file.Stat()
+		if err != nil {
+			return fmt.Errorf("unable to get file size: %w", err)
+		}
+		size = info.Size()
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodPut, presignedURL, r.File,
+	)
+	if err != nil {
+		return fmt.Errorf("unable to create upload request: %w", err)
+	}
+	req.ContentLength = size
+
+	resp, err := do(s.client.UploadClient, req)
+	if err != nil {
+		return fmt.Errorf("unable to upload file contents: %w", err)
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// DownloadURL returns the L402 URL where the file with the given ID is sold.
+func (s *FilesService) DownloadURL(id string) string {
+	return s.client.resourceURL(downloadPath, id)
+}
+
+// Download buys the file with the given ID or download URL through the
+// L402Client, which pays the invoice.
+func (s *FilesService) Download(ctx context.Context,
+	idOrURL string) (*FileDownload, error) {
+
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodGet, s.DownloadURL(idOrURL), nil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	resp, err := do(s.client.l402Client(), req)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileDownload{
+		Name: resp.Header.Get("file-name"),
+		Size: resp.ContentLength,
+		Body: resp.Body,
+	}, nil
+}
@@ -0,0 +1,222 @@
+package fewsats
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	// gatewayPath is the path of the gateway endpoints.
+	gatewayPath = "/v0/gateway"
+
+	// accessPath is the path of the L402 paywalled gateway accesses.
+	accessPath = "/v0/gateway/access"
+)
+
+// Gateway is an L402 gateway selling the access to another API.
+type Gateway struct {
+	ExternalID   string    `json:"external_id"`
+	Status       string    `json:"status"`
+	Name         string    `json:"name"`
+	TargetURL    string    `json:"target_url"`
+	Description  string    `json:"description"`
+	PriceInCents uint64    `json:"price_in_cents"`
+	Duration     string    `json:"duration"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// CreateGatewayRequest is the request body of the create gateway endpoint.
+type CreateGatewayRequest struct {
+	PriceInCents uint64 `json:"price_in_cents"`
+	TargetURL    string `json:"target_url"`
+	Duration     string `json:"duration"`
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+}
+
+// UpdateGatewayRequest is the request body of the update gateway endpoint.
+type UpdateGatewayRequest struct {
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	PriceInCents uint64 `json:"price_in_cents"`
+}
+
+// VisitData is the number of visits of a gateway in a day.
+type VisitData struct {
+	Date       string `json:"date"`
+	VisitCount int    `json:"visit_count"`
+}
+
+// GatewayVisits are the daily visits of a gateway.
+type GatewayVisits struct {
+	GatewayID     string      `json:"gateway_id"`
+	GatewayVisits []VisitData `json:"gateway_visits"`
+}
+
+// AccessRequest is a request to a gateway.
+type AccessRequest struct {
+	// Method is the HTTP method, GET if empty.
+	Method string
+
+	// Body is the request body, nil for none.
+	Body io.Reader
+
+	// ContentType is the content type of the body.
+	ContentType string
+
+	// Path is appended to the gateway URL to reach an endpoint of the
+	// proxied API.
+	Path string
+}
+
+// GatewaysService handles the L402 gateways proxying other APIs.
+type GatewaysService struct {
+	client *Client
+}
+
+// Create creates a gateway.
+func (s *GatewaysService) Create(ctx context.Context,
+	r *CreateGatewayRequest) (*Gateway, error) {
+
+	var gateway Gateway
+	err := s.client.call(ctx, http.MethodPost, gatewayPath, r, &gateway)
+	if err != nil {
+		return nil, err
+	}
+
+	return &gateway, nil
+}
+
+// Get returns the gateway with the given ID.
+func (s *GatewaysService) Get(ctx context.Context,
+	id string) (*Gateway, error) {
+
+	var response struct {
+		Gateway Gateway `json:"gateway"`
+	}
+	err := s.client.call(
+		ctx, http.MethodGet, itemPath(gatewayPath, id), nil, &response,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response.Gateway, nil
+}
+
+// List returns the gateways of the user.
+func (s *GatewaysService) List(ctx context.Context,
+	opts ListOptions) ([]Gateway, error) {
+
+	var response struct {
+		Gateways []Gateway `json:"gateways"`
+	}
+	err := s.client.call(
+		ctx, http.MethodGet, gatewayPath+opts.query(), nil, &response,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Gateways, nil
+}
+
+// Search returns the public gateways.
+func (s *GatewaysService) Search(ctx context.Context,
+	opts ListOptions) ([]Gateway, error) {
+
+	var response struct {
+		Gateways []Gateway `json:"gateways"`
+	}
+	err := s.client.call(
+		ctx, http.MethodGet, gatewayPath+"/search"+opts.query(), nil,
+		&response,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Gateways, nil
+}
+
+// Update updates the gateway with the given ID and returns the confirmation
+// message of the API.
+func (s *GatewaysService) Update(ctx context.Context, id string,
+	r *UpdateGatewayRequest) (string, error) {
+
+	var response struct {
+		Message string `json:"message"`
+	}
+	err := s.client.call(
+		ctx, http.MethodPatch, itemPath(gatewayPath, id), r, &response,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return response.Message, nil
+}
+
+// Delete deletes the gateway with the given ID.
+func (s *GatewaysService) Delete(ctx context.Context, id string) error {
+	return s.client.call(
+		ctx, http.MethodDelete, itemPath(gatewayPath, id), nil, nil,
+	)
+}
+
+// Visits returns the daily visits of the gateway with the given ID.
+func (s *GatewaysService) Visits(ctx context.Context,
+	id string) (*GatewayVisits, error) {
+
+	var response struct {
+		GatewayVisits GatewayVisits `json:"gateway_visits"`
+	}
+	err := s.client.call(
+		ctx, http.MethodGet, itemPath(gatewayPath, id)+"/details", nil,
+		&response,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response.GatewayVisits, nil
+}
+
+// AccessURL returns the L402 URL where the access to the gateway with the
+// given ID is sold.
+func (s *GatewaysService) AccessURL(id string) string {
+	return s.client.resourceURL(accessPath, id)
+}
+
+// Access sends a request to the gateway with the given ID or access URL
+// through the L402Client, which pays the invoice. The caller must close the
+// body of the returned response.
+func (s *GatewaysService) Access(ctx context.Context, idOrURL string,
+	r *AccessRequest) (*http.Response, error) {
+
+	if r == nil {
+		r = &AccessRequest{}
+	}
+
+	method := r.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx, method, s.AccessURL(idOrURL)+r.Path, r.Body,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	if r.ContentType != "" {
+		req.Header.Set("Content-Type", r.ContentType)
+	}
+
+	return do(s.client.l402Client(), req)
+}
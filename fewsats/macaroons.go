@@ -0,0 +1,64 @@
+package fewsats
+
+import (
+	"context"
+	"net/http"
+)
+
+// macaroonPath is the path of the macaroon endpoints.
+const macaroonPath = "/v0/macaroon"
+
+// MintMacaroonRequest is the request body of the mint macaroon endpoint.
+type MintMacaroonRequest struct {
+	Location string            `json:"location"`
+	Caveats  map[string]string `json:"caveats"`
+}
+
+// ValidateMacaroonRequest is the request body of the validate macaroon
+// endpoint.
+type ValidateMacaroonRequest struct {
+	Macaroon   string            `json:"macaroon"`
+	Conditions map[string]string `json:"conditions"`
+}
+
+// MacaroonsService mints and validates macaroons.
+type MacaroonsService struct {
+	client *Client
+}
+
+// Mint mints a macaroon for the given location with the given caveats and
+// returns it encoded.
+func (s *MacaroonsService) Mint(ctx context.Context,
+	r *MintMacaroonRequest) (string, error) {
+
+	var response struct {
+		Macaroon string `json:"macaroon"`
+	}
+	err := s.client.call(
+		ctx, http.MethodPost, macaroonPath+"/mint", r, &response,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return response.Macaroon, nil
+}
+
+// Validate checks the macaroon against the given conditions and returns the
+// confirmation message of the API. An *APIError holding the reason is
+// returned if the macaroon is not valid.
+func (s *MacaroonsService) Validate(ctx context.Context,
+	r *ValidateMacaroonRequest) (string, error) {
+
+	var response struct {
+		Message string `json:"message"`
+	}
+	err := s.client.call(
+		ctx, http.MethodPost, macaroonPath+"/validate", r, &response,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return response.Message, nil
+}
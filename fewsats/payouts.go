@@ -0,0 +1,36 @@
+package fewsats
+
+import (
+	"context"
+	"net/http"
+)
+
+// payoutsPath is the path of the payouts endpoint.
+const payoutsPath = "/v0/payouts"
+
+// Payout is a payment of the user earnings.
+type Payout struct {
+	ID          uint64 `json:"id"`
+	TotalAmount uint64 `json:"total_amount"`
+	Currency    string `json:"currency"`
+	Status      string `json:"status"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// PayoutsService handles the payouts of the user.
+type PayoutsService struct {
+	client *Client
+}
+
+// List returns the payouts of the user.
+func (s *PayoutsService) List(ctx context.Context) ([]Payout, error) {
+	var response struct {
+		Payouts []Payout `json:"payouts"`
+	}
+	err := s.client.call(ctx, http.MethodGet, payoutsPath, nil, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Payouts, nil
+}
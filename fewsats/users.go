@@ -0,0 +1,152 @@
+package fewsats
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+)
+
+const (
+	// userDetailsPath is the path of the user details endpoints.
+	userDetailsPath = "/v0/users/details"
+
+	// billingPath is the path of the billing information endpoints.
+	billingPath = "/v0/users/billing"
+)
+
+// User represents a platform user.
+type User struct {
+	// Email is the email address of the user. It is used as the unique
+	// identifier for the user.
+	Email string `json:"email"`
+
+	// Username is the username of the user.
+	Username string `json:"username"`
+
+	// ProfileImageURL is the URL of the profile image of the user.
+	ProfileImageURL string `json:"profile_image_url"`
+}
+
+// BillingInformation represents a user's billing information.
+type BillingInformation struct {
+	// FirstName is the first name of the user.
+	FirstName string `json:"first_name"`
+
+	// LastName is the last name of the user.
+	LastName string `json:"last_name"`
+
+	// AccountType is the type of account the user has.
+	AccountType string `json:"account_type"`
+
+	// CompanyName is the name of the user's company.
+	CompanyName string `json:"company_name"`
+
+	// Currency is the currency the user pays in.
+	Currency string `json:"currency"`
+
+	// Address is the street address of the billing address.
+	Address string `json:"address"`
+
+	// City is the city of the billing address.
+	City string `json:"city"`
+
+	// State is the state of the billing address.
+	State string `json:"state"`
+
+	// Country is the country of the billing address.
+	Country string `json:"country"`
+
+	// PostalCode is the postal code of the billing address.
+	PostalCode string `json:"postal_code"`
+
+	// VatNumber is the VAT number of the user.
+	VatNumber string `json:"vat_number"`
+
+	// TaxID is the tax ID of the user.
+	TaxID string `json:"tax_id"`
+}
+
+// UpdateUserRequest holds the new details of the user.
+type UpdateUserRequest struct {
+	// Username is the new username.
+	Username string
+
+	// ProfileImage is the contents of the new profile image.
+	ProfileImage []byte
+}
+
+// UsersService handles the user details and billing information.
+type UsersService struct {
+	client *Client
+}
+
+// Get returns the details of the user.
+func (s *UsersService) Get(ctx context.Context) (*User, error) {
+	var user User
+	err := s.client.call(ctx, http.MethodGet, userDetailsPath, nil, &user)
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// Update updates the details of the user.
+func (s *UsersService) Update(ctx context.Context,
+	r *UpdateUserRequest) error {
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	err := writer.WriteField("username", r.Username)
+	if err != nil {
+		return fmt.Errorf("unable to write username field: %w", err)
+	}
+
+	// The API takes the profile image base64 encoded in a form field.
+	err = writer.WriteField(
+		"profile_image",
+		base64.StdEncoding.EncodeToString(r.ProfileImage),
+	)
+	if err != nil {
+		return fmt.Errorf("unable to write profile_image field: %w", err)
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return fmt.Errorf("unable to finalize multipart message: %w", err)
+	}
+
+	req, err := s.client.newRequest(
+		ctx, http.MethodPut, userDetailsPath, body,
+		writer.FormDataContentType(),
+	)
+	if err != nil {
+		return err
+	}
+
+	return s.client.send(req, nil)
+}
+
+// GetBilling returns the billing information of the user.
+func (s *UsersService) GetBilling(
+	ctx context.Context) (*BillingInformation, error) {
+
+	var billing BillingInformation
+	err := s.client.call(ctx, http.MethodGet, billingPath, nil, &billing)
+	if err != nil {
+		return nil, err
+	}
+
+	return &billing, nil
+}
+
+// UpdateBilling replaces the billing information of the user.
+func (s *UsersService) UpdateBilling(ctx context.Context,
+	billing *BillingInformation) error {
+
+	return s.client.call(ctx, http.MethodPut, billingPath, billing, nil)
+}
@@ -0,0 +1,151 @@
+package fewsatstest
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/fewsats/fewsatscli/fewsats"
+)
+
+func (s *Server) handleSignup(w http.ResponseWriter, r *http.Request) {
+	var req fewsats.SignupRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	switch {
+	case req.Email == "" || req.Password == "":
+		writeError(w, http.StatusBadRequest, "email and password are required")
+		return
+
+	case req.Password != req.PasswordConfirmation:
+		writeError(w, http.StatusBadRequest, "passwords do not match")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.email == req.Email {
+			writeError(w, http.StatusConflict, "email already registered")
+			return
+		}
+	}
+
+	s.addUser(req.Email, req.Password)
+
+	writeJSON(w, http.StatusCreated, map[string]string{
+		"message": "account created",
+	})
+}
+
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	var req fewsats.LoginRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.email != req.Email || u.password != req.Password {
+			continue
+		}
+
+		session := randomHex(32)
+		s.sessions[session] = u.id
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     sessionCookieName,
+			Value:    session,
+			Path:     "/",
+			HttpOnly: true,
+		})
+		writeJSON(w, http.StatusOK, map[string]string{
+			"message": "logged in",
+		})
+
+		return
+	}
+
+	writeError(w, http.StatusUnauthorized, "invalid email or password")
+}
+
+func (s *Server) handleMe(w http.ResponseWriter, _ *http.Request, u *user) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, u.details)
+}
+
+func (s *Server) handleCreateAPIKey(w http.ResponseWriter, r *http.Request,
+	u *user) {
+
+	var req fewsats.CreateAPIKeyRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if req.Duration <= 0 {
+		req.Duration = 24 * 7 * 4 * time.Hour
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := randomHex(16)
+	k := s.addAPIKey(u.id, key, req.Name, req.Duration)
+
+	writeJSON(w, http.StatusCreated, fewsats.CreateAPIKeyResponse{
+		APIKey:    key,
+		ExpiresAt: k.ExpiresAt,
+		UserID:    u.id,
+	})
+}
+
+func (s *Server) handleListAPIKeys(w http.ResponseWriter, r *http.Request,
+	u *user) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := []fewsats.APIKey{}
+	for _, k := range s.apiKeys {
+		if k.userID == u.id {
+			keys = append(keys, k.APIKey)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].ID < keys[j].ID
+	})
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": paginate(r, keys),
+	})
+}
+
+func (s *Server) handleDisableAPIKey(w http.ResponseWriter, r *http.Request,
+	u *user) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := r.PathValue("id")
+	for _, k := range s.apiKeys {
+		if k.userID != u.id || formatID(k.ID) != id {
+			continue
+		}
+
+		k.Enabled = false
+		writeJSON(w, http.StatusOK, map[string]string{
+			"message": "api key disabled",
+		})
+
+		return
+	}
+
+	writeError(w, http.StatusNotFound, "api key not found")
+}
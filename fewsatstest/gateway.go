@@ -0,0 +1,268 @@
+package fewsatstest
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fewsats/fewsatscli/fewsats"
+)
+
+// accessPath is the path of the L402 paywalled gateway accesses.
+const accessPath = "/v0/gateway/access"
+
+// sortedGateways returns the gateways selected by the filter, the newest
+// first. The lock must be held.
+func (s *Server) sortedGateways(
+	filter func(g *gateway) bool) []fewsats.Gateway {
+
+	gateways := []fewsats.Gateway{}
+	for _, g := range s.gateways {
+		if filter(g) {
+			gateways = append(gateways, g.Gateway)
+		}
+	}
+	sort.Slice(gateways, func(i, j int) bool {
+		if gateways[i].CreatedAt.Equal(gateways[j].CreatedAt) {
+			return gateways[i].ExternalID < gateways[j].ExternalID
+		}
+		return gateways[i].CreatedAt.After(gateways[j].CreatedAt)
+	})
+
+	return gateways
+}
+
+// ownGateway returns the gateway with the ID of the request path if it is
+// owned by the account, writing a 404 response otherwise. The lock must be
+// held.
+func (s *Server) ownGateway(w http.ResponseWriter, r *http.Request,
+	u *user) (*gateway, bool) {
+
+	g, ok := s.gateways[r.PathValue("id")]
+	if !ok || g.userID != u.id {
+		writeError(w, http.StatusNotFound, "gateway not found")
+		return nil, false
+	}
+
+	return g, true
+}
+
+func (s *Server) handleCreateGateway(w http.ResponseWriter, r *http.Request,
+	u *user) {
+
+	var req fewsats.CreateGatewayRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	target, err := url.Parse(req.TargetURL)
+	switch {
+	case req.Name == "":
+		writeError(w, http.StatusBadRequest, "name is required")
+		return
+
+	case err != nil || !target.IsAbs():
+		writeError(w, http.StatusBadRequest, "invalid target_url")
+		return
+	}
+
+	now := time.Now().UTC()
+	g := &gateway{
+		Gateway: fewsats.Gateway{
+			ExternalID:   newUUID(),
+			Status:       "active",
+			Name:         req.Name,
+			TargetURL:    req.TargetURL,
+			Description:  req.Description,
+			PriceInCents: req.PriceInCents,
+			Duration:     req.Duration,
+			CreatedAt:    now,
+			UpdatedAt:    now,
+		},
+		userID: u.id,
+		visits: make(map[string]int),
+	}
+
+	s.mu.Lock()
+	s.gateways[g.ExternalID] = g
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusCreated, g.Gateway)
+}
+
+func (s *Server) handleListGateways(w http.ResponseWriter, r *http.Request,
+	u *user) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	gateways := s.sortedGateways(func(g *gateway) bool {
+		return g.userID == u.id
+	})
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"gateways": paginate(r, gateways),
+	})
+}
+
+func (s *Server) handleSearchGateways(w http.ResponseWriter,
+	r *http.Request) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	gateways := s.sortedGateways(func(g *gateway) bool {
+		return g.Status == "active"
+	})
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"gateways": paginate(r, gateways),
+	})
+}
+
+func (s *Server) handleGetGateway(w http.ResponseWriter, r *http.Request,
+	u *user) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g, ok := s.ownGateway(w, r, u)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"gateway": g.Gateway})
+}
+
+func (s *Server) handleUpdateGateway(w http.ResponseWriter, r *http.Request,
+	u *user) {
+
+	var req fewsats.UpdateGatewayRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g, ok := s.ownGateway(w, r, u)
+	if !ok {
+		return
+	}
+
+	// Like the API, the empty fields are left unchanged.
+	if req.Name != "" {
+		g.Name = req.Name
+	}
+	if req.Description != "" {
+		g.Description = req.Description
+	}
+	if req.PriceInCents != 0 {
+		g.PriceInCents = req.PriceInCents
+	}
+	g.UpdatedAt = time.Now().UTC()
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": "gateway updated",
+	})
+}
+
+func (s *Server) handleDeleteGateway(w http.ResponseWriter, r *http.Request,
+	u *user) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g, ok := s.ownGateway(w, r, u)
+	if !ok {
+		return
+	}
+
+	delete(s.gateways, g.ExternalID)
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": "gateway deleted",
+	})
+}
+
+func (s *Server) handleGatewayVisits(w http.ResponseWriter, r *http.Request,
+	u *user) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g, ok := s.ownGateway(w, r, u)
+	if !ok {
+		return
+	}
+
+	visits := fewsats.GatewayVisits{
+		GatewayID:     g.ExternalID,
+		GatewayVisits: []fewsats.VisitData{},
+	}
+	for date, count := range g.visits {
+		visits.GatewayVisits = append(visits.GatewayVisits,
+			fewsats.VisitData{Date: date, VisitCount: count})
+	}
+	sort.Slice(visits.GatewayVisits, func(i, j int) bool {
+		return visits.GatewayVisits[i].Date < visits.GatewayVisits[j].Date
+	})
+
+	writeJSON(w, http.StatusOK, map[string]any{"gateway_visits": visits})
+}
+
+// handleAccess proxies the paid requests to the gateway target URL. The path
+// after the gateway ID is appended to the target URL.
+func (s *Server) handleAccess(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, accessPath+"/")
+	id, path, _ := strings.Cut(rest, "/")
+
+	s.mu.Lock()
+	g, ok := s.gateways[id]
+	var (
+		target      string
+		price       uint64
+		description string
+	)
+	if ok {
+		target, price, description = g.TargetURL, g.PriceInCents, g.Name
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "gateway not found")
+		return
+	}
+
+	if !s.checkL402(w, r, "gateway_id", id, price, description) {
+		return
+	}
+
+	targetURL, err := url.Parse(target)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "invalid target_url")
+		return
+	}
+
+	s.mu.Lock()
+	if g, ok := s.gateways[id]; ok {
+		g.visits[time.Now().UTC().Format(time.DateOnly)]++
+	}
+	s.mu.Unlock()
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.Out.URL.Path = "/" + path
+			pr.Out.URL.RawPath = ""
+			pr.SetURL(targetURL)
+
+			// The L402 credentials are for the gateway, not the
+			// target.
+			pr.Out.Header.Del("Authorization")
+		},
+	}
+	proxy.ServeHTTP(w, r)
+}
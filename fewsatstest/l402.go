@@ -0,0 +1,361 @@
+package fewsatstest
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/fewsats/fewsatscli/credentials"
+	"github.com/fewsats/fewsatscli/invoices"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/zpay32"
+	"gopkg.in/macaroon.v2"
+)
+
+const (
+	// macaroonLocation is the location of the minted macaroons.
+	macaroonLocation = "fewsatstest"
+
+	// expiryCaveat is the caveat holding the expiry of the L402
+	// credentials.
+	expiryCaveat = "expires_at"
+)
+
+var (
+	// ErrUnknownInvoice is returned when paying an invoice not issued by
+	// the server.
+	ErrUnknownInvoice = errors.New("unknown invoice")
+
+	// ErrInvoiceExpired is returned when paying an expired invoice.
+	ErrInvoiceExpired = errors.New("invoice expired")
+)
+
+// invoice is an invoice issued by the server.
+type invoice struct {
+	preimage   string
+	amountSats uint64
+	expiresAt  time.Time
+	paid       bool
+}
+
+// checkL402 checks the L402 credentials of the request for the resource
+// identified by the caveat with the given name and value. If they are not
+// valid, a 402 response with a new challenge is written and false is
+// returned. The free resources need no credentials.
+func (s *Server) checkL402(w http.ResponseWriter, r *http.Request,
+	caveat, resourceID string, priceCents uint64, description string) bool {
+
+	if priceCents == 0 {
+		return true
+	}
+
+	err := s.verifyL402(r.Header.Get("Authorization"), caveat, resourceID)
+	if err == nil {
+		return true
+	}
+
+	mac, paymentRequest, err := s.newChallenge(
+		caveat, resourceID, priceCents*s.cfg.SatsPerCent, description,
+	)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return false
+	}
+
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(
+		`L402 macaroon="%s", invoice="%s"`, mac, paymentRequest,
+	))
+	writeError(w, http.StatusPaymentRequired, "payment required")
+
+	return false
+}
+
+// verifyL402 checks that the authorization header holds a macaroon minted
+// by the server for the given resource, not expired, and the preimage of
+// its payment hash.
+func (s *Server) verifyL402(authorization, caveat, resourceID string) error {
+	scheme, token, found := strings.Cut(authorization, " ")
+	if !found || (!strings.EqualFold(scheme, "L402") &&
+		!strings.EqualFold(scheme, "LSAT")) {
+
+		return errors.New("no L402 credentials")
+	}
+
+	encodedMac, preimage, found := strings.Cut(strings.TrimSpace(token), ":")
+	if !found {
+		return errors.New("malformed L402 credentials")
+	}
+
+	mac, err := decodeMacaroon(encodedMac)
+	if err != nil {
+		return err
+	}
+
+	err = mac.Verify(s.rootKey, func(c string) error {
+		name, value, _ := strings.Cut(c, "=")
+		switch name {
+		case caveat:
+			if value != resourceID {
+				return fmt.Errorf("macaroon not valid for %s", resourceID)
+			}
+
+		case expiryCaveat:
+			expiresAt, err := time.Parse(time.RFC3339, value)
+			if err != nil || time.Now().After(expiresAt) {
+				return errors.New("macaroon expired")
+			}
+
+		default:
+			return fmt.Errorf("unknown caveat %q", c)
+		}
+
+		return nil
+	}, nil)
+	if err != nil {
+		return err
+	}
+
+	_, paymentHash, _, err := credentials.DecodeMacIdentifier(mac.Id())
+	if err != nil {
+		return err
+	}
+
+	preimageBytes, err := hex.DecodeString(preimage)
+	if err != nil {
+		return fmt.Errorf("invalid preimage: %w", err)
+	}
+
+	if sha256.Sum256(preimageBytes) != paymentHash {
+		return credentials.ErrPreimageMismatch
+	}
+
+	return nil
+}
+
+// newChallenge mints a macaroon for the resource, linked to a new invoice of
+// the given amount.
+func (s *Server) newChallenge(caveat, resourceID string, amountSats uint64,
+	description string) (string, string, error) {
+
+	preimage := make([]byte, 32)
+	if _, err := rand.Read(preimage); err != nil {
+		return "", "", fmt.Errorf("unable to create preimage: %w", err)
+	}
+	paymentHash := sha256.Sum256(preimage)
+
+	expiresAt := time.Now().Add(s.cfg.CredentialsDuration).UTC()
+	mac, err := s.newMacaroon(paymentHash, []string{
+		caveat + "=" + resourceID,
+		expiryCaveat + "=" + expiresAt.Format(time.RFC3339),
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	paymentRequest, err := s.newInvoice(
+		preimage, amountSats, description,
+	)
+	if err != nil {
+		return "", "", err
+	}
+
+	return mac, paymentRequest, nil
+}
+
+// newMacaroon mints a base64 encoded macaroon with the given first party
+// caveats. Its identifier is linked to the payment hash.
+func (s *Server) newMacaroon(paymentHash [32]byte,
+	caveats []string) (string, error) {
+
+	tokenID := make([]byte, 32)
+	if _, err := rand.Read(tokenID); err != nil {
+		return "", fmt.Errorf("unable to create token ID: %w", err)
+	}
+
+	var id bytes.Buffer
+	binary.Write(&id, binary.BigEndian, uint16(0))
+	id.Write(paymentHash[:])
+	id.Write(tokenID)
+
+	mac, err := macaroon.New(
+		s.rootKey, id.Bytes(), macaroonLocation, macaroon.LatestVersion,
+	)
+	if err != nil {
+		return "", fmt.Errorf("unable to mint macaroon: %w", err)
+	}
+
+	for _, caveat := range caveats {
+		err = mac.AddFirstPartyCaveat([]byte(caveat))
+		if err != nil {
+			return "", fmt.Errorf("unable to add caveat: %w", err)
+		}
+	}
+
+	macBytes, err := mac.MarshalBinary()
+	if err != nil {
+		return "", fmt.Errorf("unable to encode macaroon: %w", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(macBytes), nil
+}
+
+// newInvoice issues an invoice for the preimage, signed by the node key.
+func (s *Server) newInvoice(preimage []byte, amountSats uint64,
+	description string) (string, error) {
+
+	params, err := invoices.NetworkParams(s.cfg.Network)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	paymentHash := sha256.Sum256(preimage)
+
+	inv, err := zpay32.NewInvoice(
+		params, paymentHash, now,
+		zpay32.Amount(lnwire.MilliSatoshi(amountSats*1000)),
+		zpay32.Description(description),
+		zpay32.Expiry(s.cfg.InvoiceExpiry),
+	)
+	if err != nil {
+		return "", fmt.Errorf("unable to create invoice: %w", err)
+	}
+
+	paymentRequest, err := inv.Encode(zpay32.MessageSigner{
+		SignCompact: func(msg []byte) ([]byte, error) {
+			return ecdsa.SignCompact(
+				s.nodeKey, chainhash.HashB(msg), true,
+			)
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to sign invoice: %w", err)
+	}
+
+	hash := hex.EncodeToString(paymentHash[:])
+	preimageHex := hex.EncodeToString(preimage)
+
+	if s.cfg.PreimageDir != "" {
+		err = os.WriteFile(
+			filepath.Join(s.cfg.PreimageDir, hash), []byte(preimageHex),
+			0600,
+		)
+		if err != nil {
+			return "", fmt.Errorf("unable to write preimage: %w", err)
+		}
+	}
+
+	s.mu.Lock()
+	s.invoices[hash] = &invoice{
+		preimage:   preimageHex,
+		amountSats: amountSats,
+		expiresAt:  now.Add(s.cfg.InvoiceExpiry),
+	}
+	s.mu.Unlock()
+
+	return paymentRequest, nil
+}
+
+// CreateInvoice issues an invoice of the given amount, not linked to any
+// resource, to be paid with PayInvoice. It returns the invoice and its hex
+// encoded preimage.
+func (s *Server) CreateInvoice(amountSats uint64,
+	description string) (string, string, error) {
+
+	preimage := make([]byte, 32)
+	if _, err := rand.Read(preimage); err != nil {
+		return "", "", fmt.Errorf("unable to create preimage: %w", err)
+	}
+
+	paymentRequest, err := s.newInvoice(preimage, amountSats, description)
+	if err != nil {
+		return "", "", err
+	}
+
+	return paymentRequest, hex.EncodeToString(preimage), nil
+}
+
+// PayInvoice settles an invoice issued by the server and returns its hex
+// encoded preimage.
+func (s *Server) PayInvoice(paymentRequest string) (string, error) {
+	details, err := invoices.Parse(paymentRequest)
+	if err != nil {
+		return "", fmt.Errorf("unable to decode invoice: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	inv, ok := s.invoices[details.PaymentHash]
+	switch {
+	case !ok:
+		return "", ErrUnknownInvoice
+
+	case !inv.paid && time.Now().After(inv.expiresAt):
+		return "", ErrInvoiceExpired
+	}
+
+	inv.paid = true
+
+	return inv.preimage, nil
+}
+
+// handlePay settles an invoice, it stands for a wallet paying it.
+func (s *Server) handlePay(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Invoice string `json:"invoice"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	preimage, err := s.PayInvoice(req.Invoice)
+	switch {
+	case errors.Is(err, ErrUnknownInvoice):
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+
+	case errors.Is(err, ErrInvoiceExpired):
+		writeError(w, http.StatusGone, err.Error())
+		return
+
+	case err != nil:
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"preimage": preimage})
+}
+
+// decodeMacaroon decodes a base64 encoded macaroon, both the standard and
+// the URL alphabets are accepted.
+func decodeMacaroon(encoded string) (*macaroon.Macaroon, error) {
+	encoded = strings.TrimRight(encoded, "=")
+
+	macBytes, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		macBytes, err = base64.RawURLEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid macaroon encoding: %w", err)
+		}
+	}
+
+	mac := &macaroon.Macaroon{}
+	if err := mac.UnmarshalBinary(macBytes); err != nil {
+		return nil, fmt.Errorf("invalid macaroon: %w", err)
+	}
+
+	return mac, nil
+}
@@ -0,0 +1,83 @@
+package fewsatstest
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/fewsats/fewsatscli/fewsats"
+)
+
+func (s *Server) handleMint(w http.ResponseWriter, r *http.Request, _ *user) {
+	var req fewsats.MintMacaroonRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if req.Location == "" {
+		writeError(w, http.StatusBadRequest, "location is required")
+		return
+	}
+
+	names := make([]string, 0, len(req.Caveats))
+	for name := range req.Caveats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	caveats := make([]string, 0, len(names))
+	for _, name := range names {
+		caveats = append(caveats, name+"="+req.Caveats[name])
+	}
+
+	// The minted macaroons are not linked to a payment, the hash of the
+	// location stands for the payment hash.
+	mac, err := s.newMacaroon(sha256.Sum256([]byte(req.Location)), caveats)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"macaroon": mac})
+}
+
+func (s *Server) handleValidate(w http.ResponseWriter, r *http.Request,
+	_ *user) {
+
+	var req fewsats.ValidateMacaroonRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	mac, err := decodeMacaroon(req.Macaroon)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Every caveat must be satisfied by the condition of the same name.
+	err = mac.Verify(s.rootKey, func(caveat string) error {
+		name, value, _ := strings.Cut(caveat, "=")
+
+		condition, ok := req.Conditions[name]
+		switch {
+		case !ok:
+			return fmt.Errorf("no condition for caveat %s", name)
+
+		case condition != value:
+			return fmt.Errorf("caveat %s not satisfied", name)
+		}
+
+		return nil
+	}, nil)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": "macaroon is valid",
+	})
+}
@@ -0,0 +1,455 @@
+// Package fewsatstest implements an in-memory stand-in for the Fewsats API,
+// for offline development and tests.
+//
+// The server implements the auth, storage, gateway, macaroon, users and
+// payouts endpoints used by the CLI. The file downloads and the gateway
+// accesses are sold with real L402 challenges: the macaroons are minted with
+// a local root key and the invoices are signed by a local node key. Nothing
+// is paid on the Lightning network, the invoices are settled with
+// PayInvoice or its /dev/pay endpoint.
+//
+//	handler, err := fewsatstest.New(fewsatstest.Config{})
+//	...
+//	srv := httptest.NewServer(handler)
+//	api := fewsats.NewClient(srv.URL, fewsatstest.DefaultAPIKey)
+package fewsatstest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/fewsats/fewsatscli/fewsats"
+	"github.com/fewsats/fewsatscli/invoices"
+)
+
+const (
+	// DefaultEmail is the email of the account created with the server.
+	DefaultEmail = "dev@fewsats.test"
+
+	// DefaultPassword is the password of the account created with the
+	// server.
+	DefaultPassword = "password"
+
+	// DefaultAPIKey is the API key of the account created with the server.
+	DefaultAPIKey = "fewsats-dev-api-key"
+
+	// DefaultSatsPerCent is the exchange rate used to price the invoices.
+	DefaultSatsPerCent = 10
+
+	// DefaultInvoiceExpiry is the expiry of the invoices.
+	DefaultInvoiceExpiry = 10 * time.Minute
+
+	// DefaultCredentialsDuration is the time the L402 credentials are
+	// valid for.
+	DefaultCredentialsDuration = 24 * time.Hour
+
+	// sessionCookieName is the name of the session cookie set on login.
+	sessionCookieName = "fewsats_session"
+)
+
+// Config configures the server, the zero value is a valid configuration.
+type Config struct {
+	// Network is the network of the invoices, mainnet if empty. It must
+	// match the NETWORK setting of the clients.
+	Network string
+
+	// Email, Password and APIKey are the credentials of the account
+	// created with the server, DefaultEmail, DefaultPassword and
+	// DefaultAPIKey if empty.
+	Email    string
+	Password string
+	APIKey   string
+
+	// SatsPerCent is the price in sats of a USD cent, DefaultSatsPerCent if
+	// zero.
+	SatsPerCent uint64
+
+	// InvoiceExpiry is the expiry of the invoices, DefaultInvoiceExpiry if
+	// zero.
+	InvoiceExpiry time.Duration
+
+	// CredentialsDuration is the time the L402 credentials are valid for,
+	// DefaultCredentialsDuration if zero.
+	CredentialsDuration time.Duration
+
+	// PreimageDir is a directory where the preimage of every invoice is
+	// written, in a file named after its hex encoded payment hash. Wallets
+	// sharing the directory can pay the invoices without calling the
+	// server. Nothing is written if empty.
+	PreimageDir string
+}
+
+// user is an account of the server.
+type user struct {
+	id       int64
+	email    string
+	password string
+	details  fewsats.User
+	billing  fewsats.BillingInformation
+	payouts  []fewsats.Payout
+}
+
+// apiKey is an API key of an account.
+type apiKey struct {
+	fewsats.APIKey
+
+	userID int64
+}
+
+// file is a file sold by an account.
+type file struct {
+	fewsats.File
+
+	userID   int64
+	contents []byte
+	cover    []byte
+}
+
+// gateway is a gateway owned by an account.
+type gateway struct {
+	fewsats.Gateway
+
+	userID int64
+	visits map[string]int
+}
+
+// Server is an in-memory stand-in for the Fewsats API. It implements
+// http.Handler.
+type Server struct {
+	cfg Config
+
+	mux *http.ServeMux
+
+	// rootKey is the root key of the minted macaroons.
+	rootKey []byte
+
+	// nodeKey signs the invoices.
+	nodeKey *btcec.PrivateKey
+
+	mu       sync.Mutex
+	nextID   int64
+	users    map[int64]*user
+	sessions map[string]int64
+	apiKeys  map[string]*apiKey
+	files    map[string]*file
+	gateways map[string]*gateway
+
+	// invoices maps the hex encoded payment hashes of the issued invoices
+	// to their state.
+	invoices map[string]*invoice
+}
+
+// New creates a server with a single account, holding the credentials of
+// the configuration.
+func New(cfg Config) (*Server, error) {
+	if cfg.Network == "" {
+		cfg.Network = invoices.NetworkMainnet
+	}
+	if _, err := invoices.NetworkParams(cfg.Network); err != nil {
+		return nil, err
+	}
+	if cfg.Email == "" {
+		cfg.Email = DefaultEmail
+	}
+	if cfg.Password == "" {
+		cfg.Password = DefaultPassword
+	}
+	if cfg.APIKey == "" {
+		cfg.APIKey = DefaultAPIKey
+	}
+	if cfg.SatsPerCent == 0 {
+		cfg.SatsPerCent = DefaultSatsPerCent
+	}
+	if cfg.InvoiceExpiry == 0 {
+		cfg.InvoiceExpiry = DefaultInvoiceExpiry
+	}
+	if cfg.CredentialsDuration == 0 {
+		cfg.CredentialsDuration = DefaultCredentialsDuration
+	}
+
+	rootKey := make([]byte, 32)
+	if _, err := rand.Read(rootKey); err != nil {
+		return nil, fmt.Errorf("unable to create root key: %w", err)
+	}
+
+	nodeKey, err := btcec.NewPrivateKey()
+	if err != nil {
+		return nil, fmt.Errorf("unable to create node key: %w", err)
+	}
+
+	s := &Server{
+		cfg:      cfg,
+		mux:      http.NewServeMux(),
+		rootKey:  rootKey,
+		nodeKey:  nodeKey,
+		users:    make(map[int64]*user),
+		sessions: make(map[string]int64),
+		apiKeys:  make(map[string]*apiKey),
+		files:    make(map[string]*file),
+		gateways: make(map[string]*gateway),
+		invoices: make(map[string]*invoice),
+	}
+
+	u := s.addUser(cfg.Email, cfg.Password)
+	s.addAPIKey(u.id, cfg.APIKey, "default", 24*7*4*time.Hour)
+
+	s.routes()
+
+	return s, nil
+}
+
+// Config returns the configuration of the server, with the defaults set.
+func (s *Server) Config() Config {
+	return s.cfg
+}
+
+// ServeHTTP serves the API requests.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// The gateway accesses are matched by prefix, their paths would
+	// conflict with the other gateway routes.
+	if strings.HasPrefix(r.URL.Path, accessPath+"/") {
+		s.handleAccess(w, r)
+		return
+	}
+
+	s.mux.ServeHTTP(w, r)
+}
+
+// routes registers the API endpoints.
+func (s *Server) routes() {
+	s.mux.HandleFunc("POST /v0/auth/signup", s.handleSignup)
+	s.mux.HandleFunc("POST /v0/auth/login", s.handleLogin)
+	s.mux.HandleFunc("GET /v0/auth/me", s.authed(s.handleMe))
+	s.mux.HandleFunc("POST /v0/auth/apikey", s.authed(s.handleCreateAPIKey))
+	s.mux.HandleFunc("GET /v0/auth/apikeys", s.authed(s.handleListAPIKeys))
+	s.mux.HandleFunc(
+		"POST /v0/auth/apikeys/{id}/disable",
+		s.authed(s.handleDisableAPIKey),
+	)
+
+	s.mux.HandleFunc("GET /v0/storage", s.authed(s.handleListFiles))
+	s.mux.HandleFunc("GET /v0/storage/search", s.handleSearchFiles)
+	s.mux.HandleFunc("GET /v0/storage/{id}", s.handleGetFile)
+	s.mux.HandleFunc("DELETE /v0/storage/{id}", s.authed(s.handleDeleteFile))
+	s.mux.HandleFunc("POST /v0/storage/upload", s.authed(s.handleUpload))
+	s.mux.HandleFunc("PUT /dev/upload/{id}", s.handleUploadContents)
+	s.mux.HandleFunc("GET /v0/storage/download/{id}", s.handleDownload)
+	s.mux.HandleFunc("GET /dev/covers/{id}", s.handleCover)
+
+	s.mux.HandleFunc("POST /v0/gateway", s.authed(s.handleCreateGateway))
+	s.mux.HandleFunc("GET /v0/gateway", s.authed(s.handleListGateways))
+	s.mux.HandleFunc("GET /v0/gateway/search", s.handleSearchGateways)
+	s.mux.HandleFunc("GET /v0/gateway/{id}", s.authed(s.handleGetGateway))
+	s.mux.HandleFunc(
+		"PATCH /v0/gateway/{id}", s.authed(s.handleUpdateGateway),
+	)
+	s.mux.HandleFunc(
+		"DELETE /v0/gateway/{id}", s.authed(s.handleDeleteGateway),
+	)
+	s.mux.HandleFunc(
+		"GET /v0/gateway/{id}/details", s.authed(s.handleGatewayVisits),
+	)
+
+	s.mux.HandleFunc("POST /v0/macaroon/mint", s.authed(s.handleMint))
+	s.mux.HandleFunc("POST /v0/macaroon/validate", s.authed(s.handleValidate))
+
+	s.mux.HandleFunc("GET /v0/users/details", s.authed(s.handleGetUser))
+	s.mux.HandleFunc("PUT /v0/users/details", s.authed(s.handleUpdateUser))
+	s.mux.HandleFunc("GET /v0/users/billing", s.authed(s.handleGetBilling))
+	s.mux.HandleFunc(
+		"PUT /v0/users/billing", s.authed(s.handleUpdateBilling),
+	)
+	s.mux.HandleFunc("GET /v0/payouts", s.authed(s.handleListPayouts))
+
+	s.mux.HandleFunc("POST /dev/pay", s.handlePay)
+}
+
+// authedHandler is a handler of the endpoints needing an account.
+type authedHandler func(w http.ResponseWriter, r *http.Request, u *user)
+
+// authed wraps the handler to authenticate the request with an API key or a
+// session cookie.
+func (s *Server) authed(h authedHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u := s.authenticate(r)
+		if u == nil {
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		h(w, r, u)
+	}
+}
+
+// authenticate returns the account of the request credentials, nil if there
+// are none or they are not valid.
+func (s *Server) authenticate(r *http.Request) *user {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if key, found := strings.CutPrefix(
+		r.Header.Get("Authorization"), "Bearer ",
+	); found {
+		k, ok := s.apiKeys[key]
+		if !ok || !k.Enabled || time.Now().After(*k.ExpiresAt) {
+			return nil
+		}
+
+		return s.users[k.userID]
+	}
+
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil {
+		return nil
+	}
+
+	userID, ok := s.sessions[cookie.Value]
+	if !ok {
+		return nil
+	}
+
+	return s.users[userID]
+}
+
+// addUser creates an account, the lock must be held or the server not
+// serving yet.
+func (s *Server) addUser(email, password string) *user {
+	s.nextID++
+	u := &user{
+		id:       s.nextID,
+		email:    email,
+		password: password,
+		details: fewsats.User{
+			Email:    email,
+			Username: strings.Split(email, "@")[0],
+		},
+	}
+	s.users[u.id] = u
+
+	return u
+}
+
+// addAPIKey creates an API key, the lock must be held or the server not
+// serving yet.
+func (s *Server) addAPIKey(userID int64, key, name string,
+	duration time.Duration) *apiKey {
+
+	s.nextID++
+	expiresAt := time.Now().Add(duration).UTC().Truncate(time.Second)
+
+	hiddenKey := key
+	if len(key) > 4 {
+		hiddenKey = strings.Repeat("*", len(key)-4) + key[len(key)-4:]
+	}
+
+	k := &apiKey{
+		APIKey: fewsats.APIKey{
+			ID:        uint64(s.nextID),
+			Name:      name,
+			HiddenKey: hiddenKey,
+			UserID:    userID,
+			ExpiresAt: &expiresAt,
+			Enabled:   true,
+		},
+		userID: userID,
+	}
+	s.apiKeys[key] = k
+
+	return k
+}
+
+// AddPayout adds a payout to the account with the given email.
+func (s *Server) AddPayout(email string, payout fewsats.Payout) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.email == email {
+			u.payouts = append(u.payouts, payout)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("no account for %s", email)
+}
+
+// baseURL returns the URL the request was sent to, used to build the URLs
+// returned to the client.
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	return scheme + "://" + r.Host
+}
+
+// randomHex returns n random bytes hex encoded.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("unable to read random bytes: %v", err))
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// listOptions returns the limit and offset query parameters, the limit is
+// zero if not set.
+func listOptions(r *http.Request) (int, int) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+
+	return max(limit, 0), max(offset, 0)
+}
+
+// paginate returns the page of the items selected by the request.
+func paginate[T any](r *http.Request, items []T) []T {
+	limit, offset := listOptions(r)
+
+	if offset >= len(items) {
+		return []T{}
+	}
+	items = items[offset:]
+
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+
+	return items
+}
+
+// decodeJSON decodes the JSON request body into v, answering with a 400
+// status if it fails.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+
+			err.Error())
+
+		return false
+	}
+
+	return true
+}
+
+// writeJSON writes v as the JSON response body with the given status.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error response, with the message in the "error"
+// field like the Fewsats API.
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,252 @@
+package fewsatstest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fewsats/fewsatscli/fewsats"
+	"github.com/fewsats/fewsatscli/invoices"
+	"github.com/fewsats/fewsatscli/l402"
+	"github.com/stretchr/testify/require"
+)
+
+// serverWallet pays the invoices of the server.
+type serverWallet struct {
+	server *Server
+	calls  atomic.Int32
+}
+
+func (w *serverWallet) GetPreimage(invoice string) (string, error) {
+	w.calls.Add(1)
+	return w.server.PayInvoice(invoice)
+}
+
+// newTestClient starts the server and returns an API client for it paying
+// the L402 invoices with the server wallet.
+func newTestClient(t *testing.T, cfg Config) (*Server, *fewsats.Client,
+	*serverWallet) {
+
+	srv, err := New(cfg)
+	require.NoError(t, err)
+
+	httpServer := httptest.NewServer(srv)
+	t.Cleanup(httpServer.Close)
+
+	wallet := &serverWallet{server: srv}
+	api := fewsats.NewClient(httpServer.URL, srv.Config().APIKey)
+	api.L402Client = &http.Client{
+		Transport: &l402.Transport{
+			Wallet: wallet,
+			Approver: l402.ApproveFunc(func(*http.Request,
+				*l402.Payment) error {
+
+				return nil
+			}),
+		},
+	}
+
+	return srv, api, wallet
+}
+
+func TestServerStorage(t *testing.T) {
+	ctx := context.Background()
+	_, api, wallet := newTestClient(t, Config{})
+
+	upload, err := api.Files.Upload(ctx, &fewsats.UploadFileRequest{
+		Name:         "report.txt",
+		Description:  "A report",
+		PriceInCents: 5,
+		File:         strings.NewReader("the report"),
+		FileSize:     int64(len("the report")),
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, upload.FileID)
+
+	file, err := api.Files.Get(ctx, upload.FileID)
+	require.NoError(t, err)
+	require.Equal(t, "report.txt", file.Name)
+	require.Equal(t, "uploaded", file.Status)
+
+	files, err := api.Files.List(ctx, fewsats.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	// The first download pays the invoice, the second one reuses the
+	// credentials.
+	for i := 0; i < 2; i++ {
+		download, err := api.Files.Download(ctx, upload.FileID)
+		require.NoError(t, err)
+
+		contents, err := io.ReadAll(download.Body)
+		require.NoError(t, err)
+		require.NoError(t, download.Body.Close())
+
+		require.Equal(t, "the report", string(contents))
+		require.Equal(t, "report.txt", download.Name)
+	}
+	require.EqualValues(t, 1, wallet.calls.Load())
+
+	// Without a wallet the download asks for a payment.
+	unpaid := fewsats.NewClient(api.BaseURL, "")
+	_, err = unpaid.Files.Download(ctx, upload.FileID)
+	require.ErrorIs(t, err, fewsats.ErrPaymentRequired)
+
+	require.NoError(t, api.Files.Delete(ctx, upload.FileID))
+	_, err = api.Files.Get(ctx, upload.FileID)
+	require.ErrorIs(t, err, fewsats.ErrNotFound)
+}
+
+func TestServerGateway(t *testing.T) {
+	ctx := context.Background()
+	_, api, wallet := newTestClient(t, Config{})
+
+	target := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			require.Empty(t, r.Header.Get("Authorization"))
+			io.WriteString(w, r.Method+" "+r.URL.Path)
+		},
+	))
+	defer target.Close()
+
+	gateway, err := api.Gateways.Create(ctx, &fewsats.CreateGatewayRequest{
+		Name:         "echo",
+		TargetURL:    target.URL,
+		PriceInCents: 1,
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		resp, err := api.Gateways.Access(ctx, gateway.ExternalID,
+			&fewsats.AccessRequest{Path: "/hello"})
+		require.NoError(t, err)
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+
+		require.Equal(t, "GET /hello", string(body))
+	}
+	require.EqualValues(t, 1, wallet.calls.Load())
+
+	visits, err := api.Gateways.Visits(ctx, gateway.ExternalID)
+	require.NoError(t, err)
+	require.Len(t, visits.GatewayVisits, 1)
+	require.Equal(t, 2, visits.GatewayVisits[0].VisitCount)
+
+	_, err = api.Gateways.Update(ctx, gateway.ExternalID,
+		&fewsats.UpdateGatewayRequest{Name: "renamed"})
+	require.NoError(t, err)
+
+	gateway, err = api.Gateways.Get(ctx, gateway.ExternalID)
+	require.NoError(t, err)
+	require.Equal(t, "renamed", gateway.Name)
+	require.Equal(t, target.URL, gateway.TargetURL)
+}
+
+func TestServerPayments(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	srv, api, _ := newTestClient(t, Config{
+		Network:       invoices.NetworkRegtest,
+		InvoiceExpiry: time.Minute,
+		PreimageDir:   dir,
+	})
+
+	gateway, err := api.Gateways.Create(ctx, &fewsats.CreateGatewayRequest{
+		Name:         "paid",
+		TargetURL:    "http://127.0.0.1:1",
+		PriceInCents: 2,
+	})
+	require.NoError(t, err)
+
+	resp, err := http.Get(api.Gateways.AccessURL(gateway.ExternalID))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusPaymentRequired, resp.StatusCode)
+
+	invoice, err := l402.ExtractInvoice(resp.Header.Get("WWW-Authenticate"))
+	require.NoError(t, err)
+
+	details, err := invoices.Parse(invoice)
+	require.NoError(t, err)
+	require.EqualValues(t, 2*DefaultSatsPerCent, details.AmountSats)
+
+	// The preimage is shared with the wallets through the directory.
+	shared, err := os.ReadFile(filepath.Join(dir, details.PaymentHash))
+	require.NoError(t, err)
+
+	preimage, err := srv.PayInvoice(invoice)
+	require.NoError(t, err)
+	require.Equal(t, string(shared), preimage)
+
+	_, err = srv.PayInvoice(strings.Replace(invoice, "lnbcrt", "lnbc", 1))
+	require.Error(t, err)
+}
+
+func TestServerAccount(t *testing.T) {
+	ctx := context.Background()
+	_, api, _ := newTestClient(t, Config{})
+
+	key, err := api.APIKeys.Create(ctx, &fewsats.CreateAPIKeyRequest{
+		Name:     "ci",
+		Duration: time.Hour,
+	})
+	require.NoError(t, err)
+
+	keyClient := fewsats.NewClient(api.BaseURL, key.APIKey)
+	user, err := keyClient.Users.Get(ctx)
+	require.NoError(t, err)
+	require.Equal(t, DefaultEmail, user.Email)
+
+	keys, err := api.APIKeys.List(ctx, fewsats.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+
+	for _, k := range keys {
+		if k.Name == "ci" {
+			require.NoError(t, api.APIKeys.Disable(ctx,
+				strconv.FormatUint(k.ID, 10)))
+		}
+	}
+	_, err = keyClient.Users.Get(ctx)
+	require.ErrorIs(t, err, fewsats.ErrUnauthorized)
+
+	// The session cookie authenticates the requests after a login.
+	session := fewsats.NewClient(api.BaseURL, "")
+	_, err = session.Auth.Login(ctx, DefaultEmail, "wrong")
+	require.ErrorIs(t, err, fewsats.ErrUnauthorized)
+
+	_, err = session.Auth.Login(ctx, DefaultEmail, DefaultPassword)
+	require.NoError(t, err)
+	require.NoError(t, session.Auth.Verify(ctx))
+
+	mac, err := session.Macaroons.Mint(ctx, &fewsats.MintMacaroonRequest{
+		Location: "test",
+		Caveats:  map[string]string{"user": "alice"},
+	})
+	require.NoError(t, err)
+
+	_, err = session.Macaroons.Validate(ctx, &fewsats.ValidateMacaroonRequest{
+		Macaroon:   mac,
+		Conditions: map[string]string{"user": "alice"},
+	})
+	require.NoError(t, err)
+
+	_, err = session.Macaroons.Validate(ctx, &fewsats.ValidateMacaroonRequest{
+		Macaroon:   mac,
+		Conditions: map[string]string{"user": "bob"},
+	})
+	var apiErr *fewsats.APIError
+	require.True(t, errors.As(err, &apiErr))
+	require.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+}
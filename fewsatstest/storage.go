@@ -0,0 +1,273 @@
+package fewsatstest
+
+import (
+	"encoding/hex"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fewsats/fewsatscli/fewsats"
+)
+
+const (
+	// downloadPath is the path of the L402 paywalled file downloads.
+	downloadPath = "/v0/storage/download"
+
+	// maxUploadSize is the maximum size of the uploaded files and forms.
+	maxUploadSize = 32 << 20
+)
+
+// formatID formats a numeric ID as sent in the URLs.
+func formatID(id uint64) string {
+	return strconv.FormatUint(id, 10)
+}
+
+// sortedFiles returns the files selected by the filter, the newest first.
+// The lock must be held.
+func (s *Server) sortedFiles(filter func(f *file) bool) []fewsats.File {
+	files := []fewsats.File{}
+	for _, f := range s.files {
+		if filter(f) {
+			files = append(files, f.File)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].CreatedAt.Equal(files[j].CreatedAt) {
+			return files[i].ExternalID < files[j].ExternalID
+		}
+		return files[i].CreatedAt.After(files[j].CreatedAt)
+	})
+
+	return files
+}
+
+func (s *Server) handleListFiles(w http.ResponseWriter, r *http.Request,
+	u *user) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	files := s.sortedFiles(func(f *file) bool {
+		return f.userID == u.id
+	})
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"files": paginate(r, files),
+	})
+}
+
+func (s *Server) handleSearchFiles(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	files := s.sortedFiles(func(f *file) bool {
+		return f.Status == "uploaded"
+	})
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"files": paginate(r, files),
+	})
+}
+
+func (s *Server) handleGetFile(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.files[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "file not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"file": f.File})
+}
+
+func (s *Server) handleDeleteFile(w http.ResponseWriter, r *http.Request,
+	u *user) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := r.PathValue("id")
+	f, ok := s.files[id]
+	if !ok || f.userID != u.id {
+		writeError(w, http.StatusNotFound, "file not found")
+		return
+	}
+
+	delete(s.files, id)
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": "file deleted",
+	})
+}
+
+func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request,
+	u *user) {
+
+	err := r.ParseMultipartForm(maxUploadSize)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid multipart form: "+
+			err.Error())
+
+		return
+	}
+
+	name := r.FormValue("name")
+	if name == "" {
+		name = r.FormValue("file_name")
+	}
+
+	price, err := strconv.ParseUint(r.FormValue("price_in_cents"), 10, 64)
+	switch {
+	case name == "":
+		writeError(w, http.StatusBadRequest, "name is required")
+		return
+
+	case err != nil:
+		writeError(w, http.StatusBadRequest, "invalid price_in_cents")
+		return
+	}
+
+	id := newUUID()
+	now := time.Now().UTC()
+	ext := filepath.Ext(name)
+
+	f := &file{
+		File: fewsats.File{
+			ExternalID:      id,
+			Name:            name,
+			Description:     r.FormValue("description"),
+			L402URL:         baseURL(r) + downloadPath + "/" + id,
+			Extension:       strings.TrimPrefix(ext, "."),
+			MimeType:        mime.TypeByExtension(ext),
+			PriceInUsdCents: price,
+			CreatedAt:       now,
+			UpdatedAt:       now,
+			Tags:            r.MultipartForm.Value["tags"],
+			Status:          "pending",
+		},
+		userID: u.id,
+	}
+	if f.Tags == nil {
+		f.Tags = []string{}
+	}
+
+	if cover, _, err := r.FormFile("cover"); err == nil {
+		f.cover, err = io.ReadAll(cover)
+		cover.Close()
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "unable to read the cover")
+			return
+		}
+
+		f.CoverURL = baseURL(r) + "/dev/covers/" + id
+	}
+
+	s.mu.Lock()
+	s.files[id] = f
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, fewsats.UploadFileResponse{
+		FileID:       id,
+		PresignedURL: baseURL(r) + "/dev/upload/" + id,
+	})
+}
+
+// handleUploadContents stores the contents of a file, it stands for the
+// presigned URL of the storage bucket.
+func (s *Server) handleUploadContents(w http.ResponseWriter,
+	r *http.Request) {
+
+	contents, err := io.ReadAll(io.LimitReader(r.Body, maxUploadSize+1))
+	switch {
+	case err != nil:
+		writeError(w, http.StatusBadRequest, "unable to read the file")
+		return
+
+	case len(contents) > maxUploadSize:
+		writeError(w, http.StatusRequestEntityTooLarge, "file too large")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.files[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "file not found")
+		return
+	}
+
+	f.contents = contents
+	f.Size = uint64(len(contents))
+	f.Status = "uploaded"
+	f.UpdatedAt = time.Now().UTC()
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) handleCover(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	f, ok := s.files[r.PathValue("id")]
+	var cover []byte
+	if ok {
+		cover = f.cover
+	}
+	s.mu.Unlock()
+
+	if cover == nil {
+		writeError(w, http.StatusNotFound, "cover not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", http.DetectContentType(cover))
+	w.Write(cover)
+}
+
+func (s *Server) handleDownload(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	s.mu.Lock()
+	f, ok := s.files[id]
+	var (
+		name     string
+		price    uint64
+		contents []byte
+	)
+	if ok {
+		name, price, contents = f.Name, f.PriceInUsdCents, f.contents
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "file not found")
+		return
+	}
+
+	if !s.checkL402(w, r, "file_id", id, price, name) {
+		return
+	}
+
+	w.Header().Set("file-name", name)
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.Itoa(len(contents)))
+	w.Write(contents)
+}
+
+// newUUID returns a random version 4 UUID.
+func newUUID() string {
+	b, _ := hex.DecodeString(randomHex(16))
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	h := hex.EncodeToString(b)
+
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" +
+		h[20:]
+}
@@ -0,0 +1,82 @@
+package fewsatstest
+
+import (
+	"net/http"
+
+	"github.com/fewsats/fewsatscli/fewsats"
+)
+
+func (s *Server) handleGetUser(w http.ResponseWriter, _ *http.Request,
+	u *user) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, u.details)
+}
+
+func (s *Server) handleUpdateUser(w http.ResponseWriter, r *http.Request,
+	u *user) {
+
+	err := r.ParseMultipartForm(maxUploadSize)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid multipart form: "+
+			err.Error())
+
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if username := r.FormValue("username"); username != "" {
+		u.details.Username = username
+	}
+
+	// The profile image is sent base64 encoded, it is kept as a data URL.
+	if image := r.FormValue("profile_image"); image != "" {
+		u.details.ProfileImageURL = "data:;base64," + image
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": "user details updated",
+	})
+}
+
+func (s *Server) handleGetBilling(w http.ResponseWriter, _ *http.Request,
+	u *user) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, u.billing)
+}
+
+func (s *Server) handleUpdateBilling(w http.ResponseWriter, r *http.Request,
+	u *user) {
+
+	var billing fewsats.BillingInformation
+	if !decodeJSON(w, r, &billing) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u.billing = billing
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": "billing information updated",
+	})
+}
+
+func (s *Server) handleListPayouts(w http.ResponseWriter, _ *http.Request,
+	u *user) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	payouts := append([]fewsats.Payout{}, u.payouts...)
+
+	writeJSON(w, http.StatusOK, map[string]any{"payouts": payouts})
+}
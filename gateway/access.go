@@ -4,19 +4,13 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
-	"net/http"
-	"net/url"
 	"strings"
 
 	"github.com/fewsats/fewsatscli/client"
-	"github.com/fewsats/fewsatscli/config"
+	"github.com/fewsats/fewsatscli/fewsats"
 	"github.com/urfave/cli/v2"
 )
 
-const (
-	accessGatewayPath = "/v0/gateway/access"
-)
-
 var accessCommand = &cli.Command{
 	Name:      "access",
 	Usage:     "Access a gateway endpoint.",
@@ -38,28 +32,21 @@ var accessCommand = &cli.Command{
 			Value: "application/json",
 			Usage: "Content-Type header for the request",
 		},
+		&cli.Uint64Flag{
+			Name:  "max-price",
+			Usage: "Pay invoices up to this price (sats) without asking, refuse above it",
+		},
 	},
 	Action: accessGateway,
 }
 
 func accessGateway(c *cli.Context) error {
-	cfg, err := config.GetConfig()
-	if err != nil {
-		return cli.Exit("failed to get config", 1)
-	}
-
 	if c.Args().Len() < 1 {
 		return cli.Exit("missing <gateway_id> argument", 1)
 	}
 
-	gatewayURL := c.Args().Get(0)
-	// Check if the gatewayURL is a valid URL
-	// If not, it means that the user used the gateway_id, prepend the domain to
-	// create a valid URL
-	_, err = url.ParseRequestURI(gatewayURL)
-	if err != nil {
-		gatewayURL = cfg.Domain + accessGatewayPath + "/" + gatewayURL
-	}
+	// The gateway can be given by its ID or its access URL.
+	gatewayID := c.Args().Get(0)
 
 	httpClient, err := client.NewHTTPClient()
 	if err != nil {
@@ -70,34 +57,30 @@ func accessGateway(c *cli.Context) error {
 		return cli.Exit("failed to create http client", 1)
 	}
 
-	method := c.String("method")
-	body := c.String("body")
-	contentType := c.String("content-type")
+	if c.IsSet("max-price") {
+		httpClient.SetMaxPrice(c.Uint64("max-price"))
+	}
+
+	req := &fewsats.AccessRequest{
+		Method:      c.String("method"),
+		ContentType: c.String("content-type"),
+	}
 
-	var bodyReader io.Reader
-	if body != "" {
-		bodyReader = strings.NewReader(body)
+	if body := c.String("body"); body != "" {
+		req.Body = strings.NewReader(body)
 	}
 
-	resp, err := httpClient.ExecuteL402Request(method, gatewayURL, bodyReader, &contentType)
+	resp, err := httpClient.API().Gateways.Access(c.Context, gatewayID, req)
 	if err != nil {
 		slog.Debug(
-			"Failed to execute L402 request.",
+			"Failed to access gateway.",
 			"error", err,
-			"method", method,
+			"method", req.Method,
 		)
-		return cli.Exit("failed to execute request", 1)
+		return cli.Exit(fmt.Sprintf("failed to access gateway: %v", err), 1)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		slog.Debug(
-			"Request failed",
-			"status_code", resp.StatusCode,
-		)
-		return cli.Exit(fmt.Sprintf("failed to access gateway. Status code: %d", resp.StatusCode), 1)
-	}
-
 	// Read the response body
 	bodyResp, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -3,18 +3,13 @@ package gateway
 import (
 	"encoding/json"
 	"fmt"
-	"io"
 	"log/slog"
-	"net/http"
 
 	"github.com/fewsats/fewsatscli/client"
+	"github.com/fewsats/fewsatscli/fewsats"
 	"github.com/urfave/cli/v2"
 )
 
-const (
-	createGatewayPath = "/v0/gateway"
-)
-
 var createCommand = &cli.Command{
 	Name:  "create",
 	Usage: "Create a new gateway.",
@@ -49,21 +44,11 @@ func createGateway(c *cli.Context) error {
 		return cli.Exit("You need to log in to run this command.", 1)
 	}
 
-	priceInCents := c.Uint64("price")
-	targetURL := c.String("target-url")
-	name := c.String("name")
-	description := c.String("description")
-
 	req := CreateGatewayRequest{
-		PriceInCents: priceInCents,
-		TargetURL:    targetURL,
-		Name:         name,
-		Description:  description,
-	}
-
-	jsonData, err := json.Marshal(req)
-	if err != nil {
-		return cli.Exit(fmt.Sprintf("Failed to marshal request: %v", err), 1)
+		PriceInCents: c.Uint64("price"),
+		TargetURL:    c.String("target-url"),
+		Name:         c.String("name"),
+		Description:  c.String("description"),
 	}
 
 	client, err := client.NewHTTPClient()
@@ -72,34 +57,15 @@ func createGateway(c *cli.Context) error {
 		return cli.Exit("Failed to create HTTP client.", 1)
 	}
 
-	resp, err := client.ExecuteRequest(http.MethodPost, createGatewayPath, jsonData)
+	gateway, err := client.API().Gateways.Create(c.Context, &req)
 	if err != nil {
-		slog.Debug("Failed to execute request.", "error", err)
-		return cli.Exit("Failed to execute request.", 1)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusCreated {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return cli.Exit(fmt.Sprintf("Failed to create gateway. Status code: %d, Body: %s", resp.StatusCode, string(bodyBytes)), 1)
-	}
-
-	// Read the response body
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return cli.Exit(fmt.Sprintf("Failed to read response body: %v", err), 1)
-	}
-
-	// Unmarshal the response into a Gateway struct
-	var gateway Gateway
-	err = json.Unmarshal(bodyBytes, &gateway)
-	if err != nil {
-		return cli.Exit(fmt.Sprintf("Failed to decode response: %v. Raw response: %s", err, string(bodyBytes)), 1)
+		slog.Debug("Failed to create gateway.", "error", err)
+		return cli.Exit(fmt.Sprintf("Failed to create gateway: %v", err), 1)
 	}
 
 	// Create a response struct to match the format of other commands
 	response := struct {
-		Gateway Gateway `json:"gateway"`
+		Gateway *Gateway `json:"gateway"`
 	}{
 		Gateway: gateway,
 	}
@@ -115,10 +81,5 @@ func createGateway(c *cli.Context) error {
 	return nil
 }
 
-type CreateGatewayRequest struct {
-	PriceInCents uint64 `json:"price_in_cents"`
-	TargetURL    string `json:"target_url"`
-	Duration     string `json:"duration"`
-	Name         string `json:"name"`
-	Description  string `json:"description"`
-}
+// CreateGatewayRequest is the request body for the create gateway endpoint.
+type CreateGatewayRequest = fewsats.CreateGatewayRequest
@@ -3,7 +3,6 @@ package gateway
 import (
 	"fmt"
 	"log/slog"
-	"net/http"
 
 	"github.com/fewsats/fewsatscli/client"
 	"github.com/urfave/cli/v2"
@@ -34,15 +33,10 @@ func deleteGateway(c *cli.Context) error {
 		return cli.Exit("Failed to create HTTP client.", 1)
 	}
 
-	resp, err := client.ExecuteRequest(http.MethodDelete, fmt.Sprintf("/v0/gateway/%s", gatewayID), nil)
+	err = client.API().Gateways.Delete(c.Context, gatewayID)
 	if err != nil {
-		slog.Debug("Failed to execute request.", "error", err)
-		return cli.Exit("Failed to execute request.", 1)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return cli.Exit(fmt.Sprintf("Failed to delete gateway. Status code: %d", resp.StatusCode), 1)
+		slog.Debug("Failed to delete gateway.", "error", err)
+		return cli.Exit(fmt.Sprintf("Failed to delete gateway: %v", err), 1)
 	}
 
 	fmt.Println("Gateway deleted successfully.")
@@ -1,22 +1,12 @@
 package gateway
 
 import (
-	"time"
-
+	"github.com/fewsats/fewsatscli/fewsats"
 	"github.com/urfave/cli/v2"
 )
 
-type Gateway struct {
-	ExternalID   string    `json:"external_id"`
-	Status       string    `json:"status"`
-	Name         string    `json:"name"`
-	TargetURL    string    `json:"target_url"`
-	Description  string    `json:"description"`
-	PriceInCents uint64    `json:"price_in_cents"`
-	Duration     string    `json:"duration"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
-}
+// Gateway is an L402 gateway selling the access to another API.
+type Gateway = fewsats.Gateway
 
 func Command() *cli.Command {
 	return &cli.Command{
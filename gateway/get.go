@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
-	"net/http"
 
 	"github.com/fewsats/fewsatscli/client"
 	"github.com/urfave/cli/v2"
@@ -35,23 +34,16 @@ func getGateway(c *cli.Context) error {
 		return cli.Exit("Failed to create HTTP client.", 1)
 	}
 
-	resp, err := client.ExecuteRequest(http.MethodGet, fmt.Sprintf("/v0/gateway/%s", gatewayID), nil)
+	gateway, err := client.API().Gateways.Get(c.Context, gatewayID)
 	if err != nil {
-		slog.Debug("Failed to execute request.", "error", err)
-		return cli.Exit("Failed to execute request.", 1)
+		slog.Debug("Failed to get gateway.", "error", err)
+		return cli.Exit(fmt.Sprintf("Failed to get gateway: %v", err), 1)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return cli.Exit(fmt.Sprintf("Failed request status code: %d", resp.StatusCode), 1)
-	}
-
-	var response struct {
-		Gateway Gateway `json:"gateway"`
-	}
-	err = json.NewDecoder(resp.Body).Decode(&response)
-	if err != nil {
-		return cli.Exit("Failed to decode gateway.", 1)
+	response := struct {
+		Gateway *Gateway `json:"gateway"`
+	}{
+		Gateway: gateway,
 	}
 
 	jsonOutput, err := json.MarshalIndent(response, "", "  ")
@@ -4,16 +4,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
-	"net/http"
 
 	"github.com/fewsats/fewsatscli/client"
+	"github.com/fewsats/fewsatscli/fewsats"
 	"github.com/urfave/cli/v2"
 )
 
-const (
-	searchGatewaysPath = "/v0/gateway/search"
-)
-
 var searchCommand = &cli.Command{
 	Name:   "search",
 	Usage:  "Search gateways.",
@@ -44,27 +40,21 @@ func searchGateways(c *cli.Context) error {
 		return cli.Exit("Failed to create HTTP client.", 1)
 	}
 
-	limit := c.Int("limit")
-	offset := c.Int("offset")
-
-	url := fmt.Sprintf("%s?limit=%d&offset=%d", searchGatewaysPath, limit, offset)
-	resp, err := client.ExecuteRequest(http.MethodGet, url, nil)
+	gateways, err := client.API().Gateways.Search(
+		c.Context, fewsats.ListOptions{
+			Limit:  c.Int("limit"),
+			Offset: c.Int("offset"),
+		},
+	)
 	if err != nil {
-		slog.Debug("Failed to execute request.", "error", err)
-		return cli.Exit("Failed to execute request.", 1)
+		slog.Debug("Failed to search gateways.", "error", err)
+		return cli.Exit(fmt.Sprintf("Failed to search gateways: %v", err), 1)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return cli.Exit(fmt.Sprintf("Failed request status code: %d", resp.StatusCode), 1)
-	}
-
-	var response struct {
+	response := struct {
 		Gateways []Gateway `json:"gateways"`
-	}
-	err = json.NewDecoder(resp.Body).Decode(&response)
-	if err != nil {
-		return cli.Exit("Failed to decode response.", 1)
+	}{
+		Gateways: gateways,
 	}
 
 	// Marshal the response struct to JSON
@@ -1,13 +1,11 @@
 package gateway
 
 import (
-	"encoding/json"
 	"fmt"
-	"io"
 	"log/slog"
-	"net/http"
 
 	"github.com/fewsats/fewsatscli/client"
+	"github.com/fewsats/fewsatscli/fewsats"
 	"github.com/urfave/cli/v2"
 )
 
@@ -53,49 +51,22 @@ func updateGateway(c *cli.Context) error {
 		PriceInCents: c.Uint64("price"),
 	}
 
-	jsonData, err := json.Marshal(req)
-	if err != nil {
-		return cli.Exit(fmt.Sprintf("Failed to marshal request: %v", err), 1)
-	}
-
 	client, err := client.NewHTTPClient()
 	if err != nil {
 		slog.Debug("Failed to create HTTP client.", "error", err)
 		return cli.Exit("Failed to create HTTP client.", 1)
 	}
 
-	resp, err := client.ExecuteRequest(http.MethodPatch, fmt.Sprintf("/v0/gateway/%s", id), jsonData)
+	message, err := client.API().Gateways.Update(c.Context, id, &req)
 	if err != nil {
-		slog.Debug("Failed to execute request.", "error", err)
-		return cli.Exit("Failed to execute request.", 1)
+		slog.Debug("Failed to update gateway.", "error", err)
+		return cli.Exit(fmt.Sprintf("Failed to update gateway: %v", err), 1)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return cli.Exit(fmt.Sprintf("Failed to update gateway. Status code: %d, Body: %s", resp.StatusCode, string(bodyBytes)), 1)
-	}
-
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return cli.Exit(fmt.Sprintf("Failed to read response body: %v", err), 1)
-	}
-
-	var response struct {
-		Message string `json:"message"`
-	}
-	err = json.Unmarshal(bodyBytes, &response)
-	if err != nil {
-		return cli.Exit(fmt.Sprintf("Failed to decode response: %v. Raw response: %s", err, string(bodyBytes)), 1)
-	}
-
-	fmt.Println(response.Message)
+	fmt.Println(message)
 
 	return nil
 }
 
-type UpdateGatewayRequest struct {
-	Name         string `json:"name"`
-	Description  string `json:"description"`
-	PriceInCents uint64 `json:"price_in_cents"`
-}
+// UpdateGatewayRequest is the request body for the update gateway endpoint.
+type UpdateGatewayRequest = fewsats.UpdateGatewayRequest
@@ -4,9 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
-	"net/http"
 
 	"github.com/fewsats/fewsatscli/client"
+	"github.com/fewsats/fewsatscli/fewsats"
 	"github.com/urfave/cli/v2"
 )
 
@@ -17,16 +17,11 @@ var visitsCommand = &cli.Command{
 	Action:    getGatewayVisits,
 }
 
-type VisitData struct {
-	Date       string `json:"date"`
-	VisitCount int    `json:"visit_count"`
-}
+// VisitData is the number of visits of a gateway in a day.
+type VisitData = fewsats.VisitData
 
 type GatewayVisitsResponse struct {
-	GatewayVisits struct {
-		GatewayID     string      `json:"gateway_id"`
-		GatewayVisits []VisitData `json:"gateway_visits"`
-	} `json:"gateway_visits"`
+	GatewayVisits *fewsats.GatewayVisits `json:"gateway_visits"`
 }
 
 func getGatewayVisits(c *cli.Context) error {
@@ -47,22 +42,15 @@ func getGatewayVisits(c *cli.Context) error {
 		return cli.Exit("Failed to create HTTP client.", 1)
 	}
 
-	resp, err := client.ExecuteRequest(http.MethodGet, fmt.Sprintf("/v0/gateway/%s/details", gatewayID), nil)
+	visits, err := client.API().Gateways.Visits(c.Context, gatewayID)
 	if err != nil {
-		slog.Debug("Failed to execute request.", "error", err)
-		return cli.Exit("Failed to execute request.", 1)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return cli.Exit(fmt.Sprintf("Failed request status code: %d", resp.StatusCode), 1)
+		slog.Debug("Failed to get gateway visits.", "error", err)
+		return cli.Exit(
+			fmt.Sprintf("Failed to get gateway visits: %v", err), 1,
+		)
 	}
 
-	var response GatewayVisitsResponse
-	err = json.NewDecoder(resp.Body).Decode(&response)
-	if err != nil {
-		return cli.Exit("Failed to decode gateway visits.", 1)
-	}
+	response := GatewayVisitsResponse{GatewayVisits: visits}
 
 	jsonOutput, err := json.MarshalIndent(response, "", "  ")
 	if err != nil {
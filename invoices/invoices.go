@@ -0,0 +1,314 @@
+package invoices
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/zpay32"
+)
+
+const (
+	// NetworkMainnet is the Bitcoin main network.
+	NetworkMainnet = "mainnet"
+
+	// NetworkTestnet is the Bitcoin test network (testnet3).
+	NetworkTestnet = "testnet"
+
+	// NetworkSignet is the Bitcoin signet network.
+	NetworkSignet = "signet"
+
+	// NetworkRegtest is the Bitcoin regression test network.
+	NetworkRegtest = "regtest"
+)
+
+var (
+	// ErrExpired is the error returned when an invoice is expired.
+	ErrExpired = errors.New("invoice expired")
+
+	// ErrWrongNetwork is the error returned when an invoice is not for the
+	// expected network.
+	ErrWrongNetwork = errors.New("invoice for another network")
+
+	// ErrAmountless is the error returned when an invoice has no amount and
+	// no explicit amount was given to pay it.
+	ErrAmountless = errors.New("amountless invoice")
+)
+
+// networkParams are the chain params of the supported networks.
+var networkParams = map[string]*chaincfg.Params{
+	NetworkMainnet: &chaincfg.MainNetParams,
+	NetworkTestnet: &chaincfg.TestNet3Params,
+	NetworkSignet:  &chaincfg.SigNetParams,
+	NetworkRegtest: &chaincfg.RegressionNetParams,
+}
+
+// networkPrefixes maps the BOLT11 currency prefixes to their network, longer
+// prefixes first so they win over the shorter ones they start with.
+var networkPrefixes = []struct {
+	prefix  string
+	network string
+}{
+	{"lnbcrt", NetworkRegtest},
+	{"lnbc", NetworkMainnet},
+	{"lntbs", NetworkSignet},
+	{"lntb", NetworkTestnet},
+}
+
+// Details is the decoded information of an invoice.
+type Details struct {
+	// Network is the network of the invoice, empty if it is not one of
+	// the supported networks.
+	Network string `json:"network"`
+
+	// AmountSats is the amount of the invoice, zero if it is amountless.
+	AmountSats uint64 `json:"amount_sats"`
+
+	// AmountMsat is the amount of the invoice in millisatoshis.
+	AmountMsat uint64 `json:"amount_msat"`
+
+	// Amountless is set for the invoices that let the payer choose the
+	// amount.
+	Amountless bool `json:"amountless"`
+
+	// PaymentHash is the hex encoded payment hash.
+	PaymentHash string `json:"payment_hash"`
+
+	// Payee is the hex encoded public key of the payee node.
+	Payee string `json:"payee"`
+
+	// Description is the description of the invoice, if any.
+	Description string `json:"description,omitempty"`
+
+	// DescriptionHash is the hex encoded hash of the description, used by
+	// the invoices whose description is too long to be included.
+	DescriptionHash string `json:"description_hash,omitempty"`
+
+	// CreatedAt is the creation time of the invoice.
+	CreatedAt time.Time `json:"created_at"`
+
+	// ExpirySeconds is the validity of the invoice from its creation.
+	ExpirySeconds int64 `json:"expiry_seconds"`
+
+	// ExpiresAt is the time after which the invoice can not be paid.
+	ExpiresAt time.Time `json:"expires_at"`
+
+	// MinFinalCLTVExpiry is the minimum CLTV delta of the final hop.
+	MinFinalCLTVExpiry uint64 `json:"min_final_cltv_expiry"`
+
+	// RouteHints are the private routes to reach the payee, each one a
+	// list of hops.
+	RouteHints [][]HopHint `json:"route_hints"`
+
+	// Features are the feature bits set in the invoice.
+	Features []Feature `json:"features"`
+}
+
+// HopHint is a hop of an invoice route hint.
+type HopHint struct {
+	// NodeID is the hex encoded public key of the hop node.
+	NodeID string `json:"node_id"`
+
+	// ChannelID is the short channel ID as block:tx:output.
+	ChannelID string `json:"channel_id"`
+
+	FeeBaseMsat               uint32 `json:"fee_base_msat"`
+	FeeProportionalMillionths uint32 `json:"fee_proportional_millionths"`
+	CLTVExpiryDelta           uint16 `json:"cltv_expiry_delta"`
+}
+
+// Feature is a feature bit set in an invoice.
+type Feature struct {
+	Bit      uint16 `json:"bit"`
+	Name     string `json:"name"`
+	Required bool   `json:"required"`
+	Known    bool   `json:"known"`
+}
+
+// NetworkOf returns the network of the invoice from its prefix.
+func NetworkOf(invoice string) (string, error) {
+	invoice = strings.ToLower(invoice)
+	for _, p := range networkPrefixes {
+		if strings.HasPrefix(invoice, p.prefix) {
+			return p.network, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown network for invoice prefix")
+}
+
+// Decode decodes a BOLT11 ln payment request. The network is taken from the
+// invoice prefix so invoices of any network can be decoded.
+func Decode(invoice string) (*zpay32.Invoice, error) {
+	if len(invoice) < 2 {
+		return nil, errors.New("bolt11 too short")
+	}
+
+	chain, err := chainParams(invoice)
+	if err != nil {
+		return nil, err
+	}
+
+	inv, err := zpay32.Decode(invoice, chain)
+	if err != nil {
+		return nil, fmt.Errorf("zpay32 decoding failed: %w", err)
+	}
+
+	return inv, nil
+}
+
+// Parse decodes a BOLT11 ln payment request and returns its details.
+func Parse(invoice string) (*Details, error) {
+	inv, err := Decode(invoice)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unknown networks are reported with an empty network.
+	network, _ := NetworkOf(invoice)
+
+	details := &Details{
+		Network:    network,
+		Amountless: inv.MilliSat == nil,
+		CreatedAt:  inv.Timestamp.UTC(),
+		ExpiresAt:  inv.Timestamp.Add(inv.Expiry()).UTC(),
+
+		ExpirySeconds:      int64(inv.Expiry() / time.Second),
+		MinFinalCLTVExpiry: inv.MinFinalCLTVExpiry(),
+		RouteHints:         [][]HopHint{},
+		Features:           []Feature{},
+	}
+
+	if inv.MilliSat != nil {
+		details.AmountMsat = uint64(*inv.MilliSat)
+		details.AmountSats = details.AmountMsat / 1000
+	}
+
+	if inv.PaymentHash != nil {
+		details.PaymentHash = hex.EncodeToString(inv.PaymentHash[:])
+	}
+
+	if inv.Destination != nil {
+		details.Payee = hex.EncodeToString(
+			inv.Destination.SerializeCompressed(),
+		)
+	}
+
+	if inv.Description != nil {
+		details.Description = *inv.Description
+	}
+
+	if inv.DescriptionHash != nil {
+		details.DescriptionHash = hex.EncodeToString(
+			inv.DescriptionHash[:],
+		)
+	}
+
+	for _, route := range inv.RouteHints {
+		hops := make([]HopHint, 0, len(route))
+		for _, hop := range route {
+			hops = append(hops, HopHint{
+				NodeID: hex.EncodeToString(
+					hop.NodeID.SerializeCompressed(),
+				),
+				ChannelID: lnwire.NewShortChanIDFromInt(
+					hop.ChannelID,
+				).String(),
+				FeeBaseMsat:               hop.FeeBaseMSat,
+				FeeProportionalMillionths: hop.FeeProportionalMillionths,
+				CLTVExpiryDelta:           hop.CLTVExpiryDelta,
+			})
+		}
+
+		details.RouteHints = append(details.RouteHints, hops)
+	}
+
+	if inv.Features != nil {
+		for bit := range inv.Features.Features() {
+			details.Features = append(details.Features, Feature{
+				Bit:      uint16(bit),
+				Name:     inv.Features.Name(bit),
+				Required: bit%2 == 0,
+				Known:    inv.Features.IsKnown(bit),
+			})
+		}
+
+		sort.Slice(details.Features, func(i, j int) bool {
+			return details.Features[i].Bit < details.Features[j].Bit
+		})
+	}
+
+	return details, nil
+}
+
+// Check runs the pre-flight checks of an invoice before paying it: it must
+// not be expired at the given time and it must be for the expected network,
+// if any.
+func (d *Details) Check(network string, now time.Time) error {
+	if network != "" {
+		if _, ok := networkParams[network]; !ok {
+			return fmt.Errorf("unknown expected network %q", network)
+		}
+
+		if d.Network != network {
+			return fmt.Errorf("%w: %s invoice, expected %s",
+				ErrWrongNetwork, d.Network, network)
+		}
+	}
+
+	if !now.Before(d.ExpiresAt) {
+		return fmt.Errorf("%w at %s", ErrExpired,
+			d.ExpiresAt.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// DecodePrice decodes a price from a ln payment request.
+func DecodePrice(invoice string) (uint64, error) {
+	inv, err := Decode(invoice)
+	if err != nil {
+		return 0, err
+	}
+
+	var msat int64
+	if inv.MilliSat != nil {
+		msat = int64(*inv.MilliSat)
+	}
+
+	return uint64(msat / 1000), nil
+}
+
+// NetworkParams returns the chain params of the given network.
+func NetworkParams(network string) (*chaincfg.Params, error) {
+	params, ok := networkParams[network]
+	if !ok {
+		return nil, fmt.Errorf("unknown network %q", network)
+	}
+
+	return params, nil
+}
+
+// chainParams returns the chain params of the invoice network. The params of
+// unknown networks are guessed from the invoice prefix.
+func chainParams(invoice string) (*chaincfg.Params, error) {
+	if network, err := NetworkOf(invoice); err == nil {
+		return networkParams[network], nil
+	}
+
+	firstNumber := strings.IndexAny(invoice, "1234567890")
+	if firstNumber < 2 {
+		return nil, errors.New("invalid bolt11 invoice")
+	}
+
+	chainPrefix := strings.ToLower(invoice[2:firstNumber])
+
+	return &chaincfg.Params{
+		Bech32HRPSegwit: chainPrefix,
+	}, nil
+}
@@ -0,0 +1,225 @@
+package invoices
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/zpay32"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestInvoice returns a signed invoice for the given network and the key
+// that signed it.
+func newTestInvoice(t *testing.T, params *chaincfg.Params,
+	timestamp time.Time, options ...func(*zpay32.Invoice)) (string,
+	*btcec.PrivateKey) {
+
+	t.Helper()
+
+	invoice, err := zpay32.NewInvoice(
+		params, sha256.Sum256([]byte("preimage")), timestamp,
+		append(options, zpay32.Expiry(time.Hour))...,
+	)
+	require.NoError(t, err)
+
+	key, err := btcec.NewPrivateKey()
+	require.NoError(t, err)
+
+	encoded, err := invoice.Encode(zpay32.MessageSigner{
+		SignCompact: func(msg []byte) ([]byte, error) {
+			return ecdsa.SignCompact(key, chainhash.HashB(msg), true)
+		},
+	})
+	require.NoError(t, err)
+
+	return encoded, key
+}
+
+func TestParse(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	descHash := sha256.Sum256([]byte("a long description"))
+
+	tests := []struct {
+		name       string
+		params     *chaincfg.Params
+		options    []func(*zpay32.Invoice)
+		network    string
+		amountSats uint64
+		amountless bool
+		desc       string
+		descHash   string
+	}{
+		{
+			name:    "Mainnet",
+			params:  &chaincfg.MainNetParams,
+			network: NetworkMainnet,
+			options: []func(*zpay32.Invoice){
+				zpay32.Amount(lnwire.MilliSatoshi(21000)),
+				zpay32.Description("coffee"),
+			},
+			amountSats: 21,
+			desc:       "coffee",
+		},
+		{
+			name:    "Testnet",
+			params:  &chaincfg.TestNet3Params,
+			network: NetworkTestnet,
+			options: []func(*zpay32.Invoice){
+				zpay32.Amount(lnwire.MilliSatoshi(1000)),
+				zpay32.Description("test"),
+			},
+			amountSats: 1,
+			desc:       "test",
+		},
+		{
+			name:    "Signet with description hash",
+			params:  &chaincfg.SigNetParams,
+			network: NetworkSignet,
+			options: []func(*zpay32.Invoice){
+				zpay32.Amount(lnwire.MilliSatoshi(5000)),
+				zpay32.DescriptionHash(descHash),
+			},
+			amountSats: 5,
+			descHash:   hex.EncodeToString(descHash[:]),
+		},
+		{
+			name:    "Regtest amountless",
+			params:  &chaincfg.RegressionNetParams,
+			network: NetworkRegtest,
+			options: []func(*zpay32.Invoice){
+				zpay32.Description("tip"),
+			},
+			amountless: true,
+			desc:       "tip",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			invoice, key := newTestInvoice(t, tc.params, now, tc.options...)
+
+			network, err := NetworkOf(invoice)
+			require.NoError(t, err)
+			require.Equal(t, tc.network, network)
+
+			details, err := Parse(invoice)
+			require.NoError(t, err)
+
+			hash := sha256.Sum256([]byte("preimage"))
+			require.Equal(t, tc.network, details.Network)
+			require.Equal(t, tc.amountSats, details.AmountSats)
+			require.Equal(t, tc.amountless, details.Amountless)
+			require.Equal(t, tc.desc, details.Description)
+			require.Equal(t, tc.descHash, details.DescriptionHash)
+			require.Equal(t, hex.EncodeToString(hash[:]), details.PaymentHash)
+			require.Equal(t,
+				hex.EncodeToString(key.PubKey().SerializeCompressed()),
+				details.Payee)
+			require.True(t, now.Equal(details.CreatedAt))
+			require.True(t, now.Add(time.Hour).Equal(details.ExpiresAt))
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	now := time.Now()
+	invoice, _ := newTestInvoice(
+		t, &chaincfg.RegressionNetParams, now,
+		zpay32.Amount(lnwire.MilliSatoshi(1000)), zpay32.Description("x"),
+	)
+
+	details, err := Parse(invoice)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		network   string
+		now       time.Time
+		expectErr error
+	}{
+		{
+			name: "Any network",
+			now:  now,
+		},
+		{
+			name:    "Expected network",
+			network: NetworkRegtest,
+			now:     now,
+		},
+		{
+			name:      "Wrong network",
+			network:   NetworkMainnet,
+			now:       now,
+			expectErr: ErrWrongNetwork,
+		},
+		{
+			name:      "Expired",
+			now:       now.Add(time.Hour),
+			expectErr: ErrExpired,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := details.Check(tc.network, tc.now)
+			require.ErrorIs(t, err, tc.expectErr)
+		})
+	}
+
+	err = details.Check("bitcoin", now)
+	require.ErrorContains(t, err, "unknown expected network")
+}
+
+func TestParseRouteHintsAndFeatures(t *testing.T) {
+	hopKey, err := btcec.NewPrivateKey()
+	require.NoError(t, err)
+
+	chanID := lnwire.ShortChannelID{
+		BlockHeight: 800000, TxIndex: 12, TxPosition: 1,
+	}
+	features := lnwire.NewFeatureVector(
+		lnwire.NewRawFeatureVector(
+			lnwire.TLVOnionPayloadRequired,
+			lnwire.PaymentAddrRequired,
+		),
+		lnwire.Features,
+	)
+
+	invoice, _ := newTestInvoice(
+		t, &chaincfg.MainNetParams, time.Now(),
+		zpay32.Amount(lnwire.MilliSatoshi(1000)), zpay32.Description("x"),
+		zpay32.PaymentAddr([32]byte{1}), zpay32.Features(features),
+		zpay32.RouteHint([]zpay32.HopHint{{
+			NodeID:                    hopKey.PubKey(),
+			ChannelID:                 chanID.ToUint64(),
+			FeeBaseMSat:               1000,
+			FeeProportionalMillionths: 100,
+			CLTVExpiryDelta:           40,
+		}}),
+	)
+
+	details, err := Parse(invoice)
+	require.NoError(t, err)
+
+	require.Equal(t, [][]HopHint{{{
+		NodeID: hex.EncodeToString(
+			hopKey.PubKey().SerializeCompressed(),
+		),
+		ChannelID:                 "800000:12:1",
+		FeeBaseMsat:               1000,
+		FeeProportionalMillionths: 100,
+		CLTVExpiryDelta:           40,
+	}}}, details.RouteHints)
+
+	require.Equal(t, []Feature{
+		{Bit: 8, Name: "tlv-onion", Required: true, Known: true},
+		{Bit: 14, Name: "payment-addr", Required: true, Known: true},
+	}, details.Features)
+}
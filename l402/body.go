@@ -0,0 +1,219 @@
+package l402
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultMaxBufferedBody is the size of the largest request body buffered in
+// memory to be replayed, used if Transport.MaxBufferedBody is zero.
+const DefaultMaxBufferedBody = 1 << 20
+
+// replayableRequest returns a copy of the request whose body can be sent
+// again after paying an invoice. Bodies with a GetBody function are used as
+// they are, seekable bodies (like files) are read again from their start and
+// other bodies up to MaxBufferedBody bytes are buffered in memory. Larger
+// bodies can only be sent once. The returned function closes the original
+// body and must be called once the request is done.
+func (t *Transport) replayableRequest(req *http.Request) (*http.Request,
+	func(), error) {
+
+	noop := func() {}
+	if req.Body == nil || req.Body == http.NoBody || req.GetBody != nil {
+		return req, noop, nil
+	}
+
+	r := req.Clone(req.Context())
+
+	if seeker, ok := req.Body.(io.Seeker); ok {
+		getBody, size, err := seekableBody(req.Body, seeker)
+		if err == nil {
+			if r.ContentLength <= 0 {
+				r.ContentLength = size
+			}
+
+			// The body is wrapped so the http.Transport does not close
+			// it between the attempts.
+			r.GetBody = getBody
+			r.Body, err = getBody()
+			if err != nil {
+				req.Body.Close()
+				return nil, noop, err
+			}
+
+			return r, func() { req.Body.Close() }, nil
+		}
+	}
+
+	maxBuffered := t.MaxBufferedBody
+	if maxBuffered == 0 {
+		maxBuffered = DefaultMaxBufferedBody
+	}
+	if maxBuffered < 0 {
+		r.Body = &onceBody{Reader: req.Body, Closer: req.Body}
+		return r, noop, nil
+	}
+
+	buf, err := io.ReadAll(io.LimitReader(req.Body, maxBuffered+1))
+	if err != nil {
+		req.Body.Close()
+		return nil, noop, fmt.Errorf("unable to read request body: %w", err)
+	}
+
+	if int64(len(buf)) > maxBuffered {
+		// Too large to be buffered, the body can only be sent once.
+		r.Body = &onceBody{
+			Reader: io.MultiReader(bytes.NewReader(buf), req.Body),
+			Closer: req.Body,
+		}
+
+		return r, noop, nil
+	}
+
+	req.Body.Close()
+
+	r.ContentLength = int64(len(buf))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(buf)), nil
+	}
+	r.Body = io.NopCloser(bytes.NewReader(buf))
+
+	return r, noop, nil
+}
+
+// seekableBody returns a GetBody function returning the body from its
+// current position, and the size of the body from there. The http.Transport
+// may still be writing the body of an attempt after returning its response,
+// so bodies implementing io.ReaderAt, like files, get their own reader per
+// attempt and the others are only rewound once the body of the previous
+// attempt is closed.
+func seekableBody(body io.Reader,
+	seeker io.Seeker) (func() (io.ReadCloser, error), int64, error) {
+
+	start, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	end, err := seeker.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	_, err = seeker.Seek(start, io.SeekStart)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	size := end - start
+	if readerAt, ok := body.(io.ReaderAt); ok {
+		getBody := func() (io.ReadCloser, error) {
+			return io.NopCloser(
+				io.NewSectionReader(readerAt, start, size),
+			), nil
+		}
+
+		return getBody, size, nil
+	}
+
+	var (
+		mu   sync.Mutex
+		prev *rewoundBody
+	)
+	getBody := func() (io.ReadCloser, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if prev != nil {
+			prev.wait()
+		}
+
+		_, err := seeker.Seek(start, io.SeekStart)
+		if err != nil {
+			return nil, fmt.Errorf("unable to rewind request body: %w",
+				err)
+		}
+
+		prev = &rewoundBody{Reader: body, closed: make(chan struct{})}
+
+		return prev, nil
+	}
+
+	return getBody, size, nil
+}
+
+// rewoundBody is the body of an attempt sharing a seekable request body with
+// the other attempts.
+type rewoundBody struct {
+	io.Reader
+
+	read      atomic.Bool
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// Read implements the io.Reader interface.
+func (b *rewoundBody) Read(p []byte) (int, error) {
+	b.read.Store(true)
+	return b.Reader.Read(p)
+}
+
+// Close implements the io.Closer interface, the shared body is left open.
+func (b *rewoundBody) Close() error {
+	b.closeOnce.Do(func() {
+		close(b.closed)
+	})
+
+	return nil
+}
+
+// wait blocks until the body is closed, unless nothing was read from it.
+func (b *rewoundBody) wait() {
+	if b.read.Load() {
+		<-b.closed
+	}
+}
+
+// onceBody is a request body that can only be sent once. It can still be
+// sent after paying if nothing was read from it, like when a probe got the
+// L402 challenge.
+type onceBody struct {
+	io.Reader
+	io.Closer
+
+	read atomic.Bool
+}
+
+// Read implements the io.Reader interface.
+func (b *onceBody) Read(p []byte) (int, error) {
+	b.read.Store(true)
+	return b.Reader.Read(p)
+}
+
+// unread returns true if the body is a onceBody not read yet.
+func unread(body io.ReadCloser) bool {
+	b, ok := body.(*onceBody)
+	return ok && !b.read.Load()
+}
+
+// probeRequest returns a copy of the request without body, sent to get the
+// L402 challenge before uploading the body.
+func probeRequest(req *http.Request) *http.Request {
+	probe := req.Clone(req.Context())
+	probe.Body = http.NoBody
+	probe.GetBody = func() (io.ReadCloser, error) {
+		return http.NoBody, nil
+	}
+	probe.ContentLength = 0
+
+	return probe
+}
+
+// hasBody returns true if the request has a body to send.
+func hasBody(req *http.Request) bool {
+	return req.Body != nil && req.Body != http.NoBody
+}
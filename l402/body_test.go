@@ -0,0 +1,316 @@
+package l402
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newBodyTestServer returns a server asking for an L402 payment unless the
+// request carries the expected credentials. It echoes the body of the paid
+// requests and keeps the bodies received.
+func newBodyTestServer(t *testing.T) (*httptest.Server, func() []string) {
+	t.Helper()
+
+	macaroon := newTestMacaroon(t, testPreimage, 1)
+	invoice := newTestInvoice(t, testPreimage, 10)
+	expected := fmt.Sprintf("L402 %s:%s", macaroon, testPreimage)
+
+	var (
+		mu     sync.Mutex
+		bodies []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+
+			mu.Lock()
+			bodies = append(bodies, string(body))
+			mu.Unlock()
+
+			if r.Header.Get("Authorization") != expected {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(
+					`L402 macaroon="%s", invoice="%s"`, macaroon,
+					invoice,
+				))
+				w.WriteHeader(http.StatusPaymentRequired)
+
+				return
+			}
+
+			fmt.Fprintf(w, "paid %s", body)
+		},
+	))
+
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return append([]string(nil), bodies...)
+	}
+}
+
+func TestTransportReplayableBodies(t *testing.T) {
+	t.Parallel()
+
+	payload := strings.Repeat("x", 64)
+
+	// The file is rewound instead of buffered.
+	path := filepath.Join(t.TempDir(), "payload")
+	require.NoError(t, os.WriteFile(path, []byte(payload), 0600))
+
+	tests := []struct {
+		name      string
+		body      func() io.Reader
+		maxBuffer int64
+		probe     bool
+		bodies    []string
+		expectErr error
+	}{
+		{
+			name: "Small body buffered",
+			body: func() io.Reader {
+				// Hide the type so http.NewRequest sets no GetBody.
+				return io.MultiReader(strings.NewReader(payload))
+			},
+			bodies: []string{payload, payload},
+		},
+		{
+			name: "Seekable body rewound",
+			body: func() io.Reader {
+				file, err := os.Open(path)
+				require.NoError(t, err)
+
+				return file
+			},
+			maxBuffer: -1,
+			bodies:    []string{payload, payload},
+		},
+		{
+			name: "Seekable body without ReaderAt rewound",
+			body: func() io.Reader {
+				return seekOnlyBody{strings.NewReader(payload)}
+			},
+			maxBuffer: -1,
+			bodies:    []string{payload, payload},
+		},
+		{
+			name: "Large body sent once",
+			body: func() io.Reader {
+				return io.MultiReader(strings.NewReader(payload))
+			},
+			maxBuffer: 16,
+			bodies:    []string{payload},
+			expectErr: ErrBodyNotReplayable,
+		},
+		{
+			name: "Large body uploaded once after a probe",
+			body: func() io.Reader {
+				return io.MultiReader(strings.NewReader(payload))
+			},
+			maxBuffer: 16,
+			probe:     true,
+			bodies:    []string{"", payload},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			server, bodies := newBodyTestServer(t)
+			defer server.Close()
+
+			client := &http.Client{
+				Transport: &Transport{
+					Wallet: &testWallet{preimage: testPreimage},
+					Approver: ApproveFunc(
+						func(*http.Request, *Payment) error {
+							return nil
+						},
+					),
+					MaxBufferedBody: tc.maxBuffer,
+					Probe:           tc.probe,
+				},
+			}
+
+			resp, err := client.Post(
+				server.URL+"/resource", "text/plain", tc.body(),
+			)
+			require.Equal(t, tc.bodies, bodies())
+			if tc.expectErr != nil {
+				require.ErrorIs(t, err, tc.expectErr)
+				return
+			}
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, "paid "+payload, string(body))
+		})
+	}
+}
+
+func TestTransportUnreadSeekableBody(t *testing.T) {
+	t.Parallel()
+
+	macaroon := newTestMacaroon(t, testPreimage, 1)
+	invoice := newTestInvoice(t, testPreimage, 10)
+	expected := fmt.Sprintf("L402 %s:%s", macaroon, testPreimage)
+
+	// The challenge is answered without reading the request body, which is
+	// larger than what the server discards before closing the connection.
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != expected {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(
+					`L402 macaroon="%s", invoice="%s"`, macaroon,
+					invoice,
+				))
+				w.WriteHeader(http.StatusPaymentRequired)
+
+				return
+			}
+
+			body, _ := io.ReadAll(r.Body)
+			fmt.Fprintf(w, "paid %d", len(body))
+		},
+	))
+	defer server.Close()
+
+	client := &http.Client{
+		Transport: &Transport{
+			Wallet: &testWallet{preimage: testPreimage},
+			Approver: ApproveFunc(
+				func(*http.Request, *Payment) error {
+					return nil
+				},
+			),
+			MaxBufferedBody: -1,
+		},
+	}
+
+	payload := strings.Repeat("x", 4<<20)
+	type result struct {
+		resp *http.Response
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		resp, err := client.Post(
+			server.URL+"/resource", "text/plain",
+			seekOnlyBody{strings.NewReader(payload)},
+		)
+		done <- result{resp: resp, err: err}
+	}()
+
+	var res result
+	select {
+	case res = <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("request blocked on the unread body of the challenge")
+	}
+	require.NoError(t, res.err)
+	defer res.resp.Body.Close()
+
+	body, err := io.ReadAll(res.resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprintf("paid %d", len(payload)), string(body))
+}
+
+// seekOnlyBody is a seekable request body not implementing io.ReaderAt.
+type seekOnlyBody struct {
+	io.ReadSeeker
+}
+
+// Close implements the io.Closer interface.
+func (seekOnlyBody) Close() error {
+	return nil
+}
+
+func TestSeekableBody(t *testing.T) {
+	t.Parallel()
+
+	payload := "0123456789"
+
+	t.Run("Readers at an offset are independent", func(t *testing.T) {
+		body := strings.NewReader(payload)
+		_, err := body.Seek(2, io.SeekStart)
+		require.NoError(t, err)
+
+		getBody, size, err := seekableBody(body, body)
+		require.NoError(t, err)
+		require.EqualValues(t, 8, size)
+
+		first, err := getBody()
+		require.NoError(t, err)
+		buf := make([]byte, 3)
+		_, err = io.ReadFull(first, buf)
+		require.NoError(t, err)
+
+		// The previous attempt is still open and partly read.
+		second, err := getBody()
+		require.NoError(t, err)
+
+		rest, err := io.ReadAll(first)
+		require.NoError(t, err)
+		require.Equal(t, "23456789", string(buf)+string(rest))
+
+		all, err := io.ReadAll(second)
+		require.NoError(t, err)
+		require.Equal(t, "23456789", string(all))
+	})
+
+	t.Run("Rewound after the previous body is closed", func(t *testing.T) {
+		body := seekOnlyBody{strings.NewReader(payload)}
+
+		getBody, size, err := seekableBody(body, body)
+		require.NoError(t, err)
+		require.EqualValues(t, 10, size)
+
+		// An unread body does not hold back the next attempt.
+		_, err = getBody()
+		require.NoError(t, err)
+
+		first, err := getBody()
+		require.NoError(t, err)
+		buf := make([]byte, 3)
+		_, err = io.ReadFull(first, buf)
+		require.NoError(t, err)
+
+		next := make(chan io.ReadCloser)
+		go func() {
+			second, err := getBody()
+			if err != nil {
+				close(next)
+				return
+			}
+			next <- second
+		}()
+
+		select {
+		case <-next:
+			t.Fatal("body rewound while the previous one is open")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		rest, err := io.ReadAll(first)
+		require.NoError(t, err)
+		require.Equal(t, payload, string(buf)+string(rest))
+		require.NoError(t, first.Close())
+
+		second, ok := <-next
+		require.True(t, ok)
+		all, err := io.ReadAll(second)
+		require.NoError(t, err)
+		require.Equal(t, payload, string(all))
+	})
+}
@@ -0,0 +1,68 @@
+package l402
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/fewsats/fewsatscli/credentials"
+	"github.com/fewsats/fewsatscli/invoices"
+)
+
+// DecodePrice decodes a price from a ln payment request.
+func DecodePrice(invoice string) (uint64, error) {
+	return invoices.DecodePrice(invoice)
+}
+
+// ExtractInvoice returns the BOLT11 invoice found in the input: a bare
+// invoice, a `lightning:` URI, a BIP21 `bitcoin:` URI with a lightning
+// parameter or a WWW-Authenticate header with an L402 challenge, with or
+// without the header name.
+func ExtractInvoice(input string) (string, error) {
+	input = strings.TrimSpace(input)
+
+	name, value, found := strings.Cut(input, ":")
+	if found && strings.EqualFold(name, "WWW-Authenticate") {
+		return invoiceFromHeader(value)
+	}
+
+	lower := strings.ToLower(input)
+	switch {
+	case strings.HasPrefix(lower, "lightning:"):
+		invoice := strings.TrimPrefix(input[len("lightning:"):], "//")
+		invoice, _, _ = strings.Cut(invoice, "?")
+		return invoice, nil
+
+	case strings.HasPrefix(lower, "bitcoin:"):
+		_, query, _ := strings.Cut(input, "?")
+		params, err := url.ParseQuery(query)
+		if err != nil {
+			return "", fmt.Errorf("invalid bitcoin URI: %w", err)
+		}
+
+		for key, values := range params {
+			if strings.EqualFold(key, "lightning") && values[0] != "" {
+				return values[0], nil
+			}
+		}
+
+		return "", errors.New("no lightning invoice in bitcoin URI")
+
+	case strings.HasPrefix(lower, "ln") && !strings.ContainsAny(input, " ="):
+		return input, nil
+	}
+
+	return invoiceFromHeader(input)
+}
+
+// invoiceFromHeader returns the invoice of the L402 challenge of a
+// WWW-Authenticate header value.
+func invoiceFromHeader(value string) (string, error) {
+	challenge, err := credentials.FindL402Challenge(value)
+	if err != nil {
+		return "", err
+	}
+
+	return challenge.Invoice, nil
+}
@@ -0,0 +1,62 @@
+package l402
+
+import (
+	"testing"
+
+	"github.com/fewsats/fewsatscli/credentials"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractInvoice(t *testing.T) {
+	const invoice = "lnbcrt100n1pjtest"
+
+	tests := []struct {
+		name      string
+		input     string
+		expectErr error
+	}{
+		{
+			name:  "Bare invoice",
+			input: "  " + invoice + "\n",
+		},
+		{
+			name:  "Lightning URI",
+			input: "LIGHTNING:" + invoice,
+		},
+		{
+			name:  "Lightning URI with slashes",
+			input: "lightning://" + invoice,
+		},
+		{
+			name:  "BIP21 URI",
+			input: "bitcoin:bcrt1qaddress?amount=0.0001&lightning=" + invoice,
+		},
+		{
+			name: "WWW-Authenticate header",
+			input: `WWW-Authenticate: L402 macaroon="mac", invoice="` +
+				invoice + `"`,
+		},
+		{
+			name:  "Header value",
+			input: `LSAT macaroon=mac invoice=` + invoice,
+		},
+		{
+			name:      "Header without challenge",
+			input:     `WWW-Authenticate: Basic realm="x"`,
+			expectErr: credentials.ErrNoChallenge,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ExtractInvoice(tc.input)
+			if tc.expectErr != nil {
+				require.ErrorIs(t, err, tc.expectErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, invoice, got)
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package l402
+
+import (
+	"sync"
+	"time"
+
+	"github.com/fewsats/fewsatscli/credentials"
+)
+
+// MemoryStore is an in memory credentials.Store, useful for programs that do
+// not need to keep the L402 credentials across restarts.
+type MemoryStore struct {
+	mu     sync.Mutex
+	nextID int64
+	creds  []*credentials.L402Credentials
+}
+
+// A compile time check to ensure MemoryStore implements credentials.Store.
+var _ credentials.Store = (*MemoryStore)(nil)
+
+// NewMemoryStore creates a new empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// GetL402Credentials retrieves the most specific L402 credentials for the
+// given scope.
+func (m *MemoryStore) GetL402Credentials(
+	scope credentials.Scope) (*credentials.L402Credentials, error) {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	creds := credentials.MatchCredentials(m.creds, scope, "")
+	if creds == nil {
+		return nil, credentials.ErrNoCredentialsFound
+	}
+
+	credsCopy := *creds
+
+	return &credsCopy, nil
+}
+
+// InsertL402Credentials stores the L402 credentials.
+func (m *MemoryStore) InsertL402Credentials(
+	creds *credentials.L402Credentials) error {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextID++
+	creds.ID = m.nextID
+	creds.CreatedAt = time.Now().UTC()
+
+	credsCopy := *creds
+	m.creds = append(m.creds, &credsCopy)
+
+	return nil
+}
+
+// MarkL402CredentialsStale flags the L402 credentials as rejected by the
+// server so they are not used anymore.
+func (m *MemoryStore) MarkL402CredentialsStale(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, creds := range m.creds {
+		if creds.ID == id {
+			creds.Stale = true
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,29 @@
+package l402
+
+import "context"
+
+// paymentInfoKey is the context key used to store the PaymentInfo of a
+// request.
+type paymentInfoKey struct{}
+
+// PaymentInfo reports the L402 payment done while executing a request.
+type PaymentInfo struct {
+	// AmountSats is the price of the invoice found in the L402 challenge.
+	AmountSats uint64
+
+	// Paid is true if the invoice was paid while executing the request.
+	Paid bool
+}
+
+// WithPaymentInfo returns a copy of the context that makes the Transport
+// report the payment done for the request in the given info.
+func WithPaymentInfo(ctx context.Context, info *PaymentInfo) context.Context {
+	return context.WithValue(ctx, paymentInfoKey{}, info)
+}
+
+// paymentInfoFromContext returns the PaymentInfo stored in the context, if
+// any.
+func paymentInfoFromContext(ctx context.Context) *PaymentInfo {
+	info, _ := ctx.Value(paymentInfoKey{}).(*PaymentInfo)
+	return info
+}
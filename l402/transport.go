@@ -0,0 +1,1032 @@
+package l402
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fewsats/fewsatscli/credentials"
+	"github.com/fewsats/fewsatscli/invoices"
+	"github.com/fewsats/fewsatscli/payments"
+	"github.com/fewsats/fewsatscli/wallets"
+)
+
+var (
+	// ErrNoWallet is the error returned when a resource requires an L402
+	// payment but the Transport has no wallet to pay it.
+	ErrNoWallet = errors.New("no wallet configured to pay L402 invoices")
+
+	// ErrPaymentNotApproved is the error returned when the Transport has no
+	// Approver, no invoice is paid without one.
+	ErrPaymentNotApproved = errors.New("L402 payment not approved")
+
+	// ErrBodyNotReplayable is the error returned when a request has to be
+	// sent again after paying the invoice but its body can not be read a
+	// second time.
+	ErrBodyNotReplayable = errors.New("request body can not be replayed, " +
+		"set http.Request.GetBody")
+)
+
+const (
+	// DefaultMaxRetries is the number of retries of the requests failing
+	// with a transient error, used if Transport.MaxRetries is zero.
+	DefaultMaxRetries = 3
+
+	// DefaultRetryBackoff is the wait before the first retry, used if
+	// Transport.RetryBackoff is zero.
+	DefaultRetryBackoff = 250 * time.Millisecond
+
+	// DefaultPaymentTimeout is the time the wallet is given to pay an
+	// invoice, used if Transport.PaymentTimeout is zero.
+	DefaultPaymentTimeout = 60 * time.Second
+)
+
+// Payment describes an L402 invoice the Transport is about to pay.
+type Payment struct {
+	// URL is the URL of the L402 resource.
+	URL string
+
+	// Invoice is the BOLT11 invoice found in the L402 challenge.
+	Invoice string
+
+	// PaymentHash is the hex encoded payment hash of the invoice.
+	PaymentHash string
+
+	// Macaroon is the base64 encoded macaroon found in the L402 challenge.
+	Macaroon string
+
+	// AuthScheme is the authentication scheme of the challenge, L402 or
+	// the legacy LSAT.
+	AuthScheme string
+
+	// AmountSats is the price of the invoice, or the amount paid for an
+	// amountless invoice.
+	AmountSats uint64
+
+	// Details holds the decoded invoice: payee, description and expiry.
+	Details *invoices.Details
+
+	// DecisionID identifies the decision of the Approver approving the
+	// payment, 0 if none. It is stored with the pending payment so the
+	// decision is settled even if the payment is recovered by another
+	// process.
+	DecisionID int64
+}
+
+// Approver decides whether the L402 invoices are paid.
+type Approver interface {
+	// Approve is called before paying an invoice. Returning an error
+	// refuses the payment and the error is returned to the caller.
+	Approve(req *http.Request, payment *Payment) error
+
+	// Settle is called with the result of the payment attempt of an
+	// approved payment, err is nil if the invoice was paid.
+	Settle(payment *Payment, err error)
+}
+
+// ApproveFunc is an adapter to use an ordinary function as an Approver.
+type ApproveFunc func(req *http.Request, payment *Payment) error
+
+// Approve calls f(req, payment).
+func (f ApproveFunc) Approve(req *http.Request, payment *Payment) error {
+	return f(req, payment)
+}
+
+// Settle is a no-op.
+func (f ApproveFunc) Settle(*Payment, error) {}
+
+// Transport is an http.RoundTripper that handles L402 challenges. Stored
+// credentials are attached to the requests and, when a server answers with a
+// 402, the invoice is paid with the wallet (if the Approver allows it) and
+// the request is sent again with the new credentials.
+//
+// A Transport is safe for concurrent use and concurrent requests for the
+// same resource only pay once.
+type Transport struct {
+	// Base is the RoundTripper used to send the requests. If nil,
+	// http.DefaultTransport is used.
+	Base http.RoundTripper
+
+	// Store persists the L402 credentials. If nil, the credentials are
+	// only kept in memory for the lifetime of the Transport.
+	Store credentials.Store
+
+	// Wallet pays the L402 invoices.
+	Wallet wallets.PreimageProvider
+
+	// Approver decides whether an invoice is paid. If nil, every payment
+	// is refused with ErrPaymentNotApproved.
+	Approver Approver
+
+	// Ledger records every payment attempt. If nil, nothing is recorded.
+	Ledger payments.Ledger
+
+	// WalletID identifies the Wallet in the Ledger records.
+	WalletID uint64
+
+	// Pending persists the payments before the wallet is called, so a
+	// payment interrupted after the invoice was paid is recovered with the
+	// wallet (if it implements wallets.PaymentLookup) instead of paid
+	// twice. If nil, interrupted payments are lost.
+	Pending payments.PendingStore
+
+	// MaxRetries is the number of times a request is sent again after a
+	// transient failure: a network error or a 502, 503 or 504 response.
+	// Only the idempotent requests (GET, HEAD, OPTIONS, TRACE or any
+	// request with an Idempotency-Key header) are retried after those,
+	// the others only when the connection could not be opened. Requests
+	// whose body can not be replayed are never retried. If zero,
+	// DefaultMaxRetries is used, a negative value disables the retries.
+	MaxRetries int
+
+	// RetryBackoff is the wait before the first retry, doubled after each
+	// one. If zero, DefaultRetryBackoff is used.
+	RetryBackoff time.Duration
+
+	// MaxBufferedBody is the size of the largest request body buffered in
+	// memory to be sent again after paying. Seekable bodies, like files,
+	// and bodies with http.Request.GetBody are never buffered. If zero,
+	// DefaultMaxBufferedBody is used, a negative value disables the
+	// buffering.
+	MaxBufferedBody int64
+
+	// Probe makes the requests with a body and no stored credentials send
+	// a copy with an empty body first. If the probe gets a 402, the
+	// invoice is paid and the body is only uploaded once, with the
+	// credentials. Any other answer to the probe is discarded and the
+	// request is sent as usual, so the probe must be harmless for the
+	// server.
+	Probe bool
+
+	// PaymentTimeout bounds the wallet calls. A payment is not cancelled
+	// with the request context once started, the request waits for its
+	// outcome (up to PaymentTimeout) so the credentials are saved. If
+	// zero, DefaultPaymentTimeout is used.
+	PaymentTimeout time.Duration
+
+	// Network is the network the invoices must be for: mainnet, testnet,
+	// signet or regtest. If empty, invoices of any network are paid.
+	Network string
+
+	// AmountlessSats is the amount paid for the invoices without an
+	// amount. If zero, amountless invoices are refused with
+	// invoices.ErrAmountless. The Wallet must implement
+	// wallets.AmountPreimageProvider to pay them.
+	AmountlessSats uint64
+
+	// locks holds a lock per resource so concurrent requests for the same
+	// resource only pay once. A lock is removed once its last holder
+	// releases it.
+	locks   map[string]*resourceLock
+	locksMu sync.Mutex
+
+	// unresolved holds the approved payments whose outcome is unknown, by
+	// payment hash. They are settled with the Approver once
+	// recoverPending resolves them, so their budget stays reserved
+	// meanwhile.
+	unresolved   map[string]*Payment
+	unresolvedMu sync.Mutex
+
+	memStore     *MemoryStore
+	memStoreOnce sync.Once
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req, done, err := t.replayableRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	store := t.store()
+	scope := credentials.NewScope(req.URL)
+
+	// Check if we already paid for the resource.
+	creds, err := store.GetL402Credentials(scope)
+	switch {
+	case errors.Is(err, credentials.ErrNoCredentialsFound):
+		creds = nil
+
+	case err != nil:
+		closeBody(req)
+		return nil, fmt.Errorf("unable to get L402 credentials: %w", err)
+
+	default:
+		slog.Debug(
+			"Using existing L402 credentials",
+			"macaroon", creds.Macaroon,
+		)
+	}
+
+	resp, err := t.sendFirst(req, creds)
+	if err != nil {
+		return nil, err
+	}
+
+	rejected := resp.StatusCode == http.StatusPaymentRequired ||
+		resp.StatusCode == http.StatusUnauthorized
+	if creds != nil && rejected {
+		resp, err = t.discardCredentials(req, resp, creds)
+		if err != nil {
+			return nil, err
+		}
+
+		creds = nil
+	}
+
+	if resp.StatusCode != http.StatusPaymentRequired {
+		return resp, nil
+	}
+
+	if t.Wallet == nil {
+		resp.Body.Close()
+		return nil, ErrNoWallet
+	}
+
+	challenge, err := credentials.ParseL402Challenge(scope, resp)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse L402 challenge: %w", err)
+	}
+
+	// Only one request pays for a given resource at a time. Requests that
+	// waited for the lock reuse the credentials paid by the first one.
+	unlock := t.lock(scope.String())
+	defer unlock()
+
+	scope.Location = challenge.Location
+	paidCreds, err := store.GetL402Credentials(scope)
+	switch {
+	case errors.Is(err, credentials.ErrNoCredentialsFound):
+		// Nobody paid for the resource in the meantime.
+
+	case err != nil:
+		return nil, fmt.Errorf("unable to get L402 credentials: %w", err)
+
+	case creds == nil || paidCreds.Macaroon != creds.Macaroon:
+		slog.Debug(
+			"Using L402 credentials paid by a concurrent request",
+			"macaroon", paidCreds.Macaroon,
+		)
+
+		return t.sendRetry(req, paidCreds, true)
+	}
+
+	// A previous request for the resource may have been interrupted after
+	// paying, its credentials are used instead of paying again.
+	recovered, err := t.recoverPending(req, scope)
+	if err != nil {
+		return nil, err
+	}
+	if recovered != nil {
+		return t.sendPaid(req, recovered)
+	}
+
+	// Never pay for a macaroon that is not linked to the invoice, the
+	// preimage would not unlock it.
+	err = challenge.VerifyChallenge()
+	if err != nil {
+		return nil, fmt.Errorf("invalid L402 challenge: %w", err)
+	}
+
+	details, err := invoices.Parse(challenge.Invoice)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode invoice: %w", err)
+	}
+
+	amount, err := t.preflight(details)
+	if err != nil {
+		return nil, fmt.Errorf("invoice pre-flight check failed: %w", err)
+	}
+
+	info := paymentInfoFromContext(req.Context())
+	if info != nil {
+		info.AmountSats = amount
+	}
+
+	payment := &Payment{
+		URL:         req.URL.String(),
+		Invoice:     challenge.Invoice,
+		PaymentHash: details.PaymentHash,
+		Macaroon:    challenge.Macaroon,
+		AuthScheme:  challenge.AuthScheme,
+		AmountSats:  amount,
+		Details:     details,
+	}
+
+	approver := t.Approver
+	if approver == nil {
+		approver = ApproveFunc(func(*http.Request, *Payment) error {
+			return ErrPaymentNotApproved
+		})
+	}
+
+	err = approver.Approve(req, payment)
+	if err != nil {
+		return nil, err
+	}
+
+	// The approval may have waited for the user, do not start paying if
+	// the request was cancelled meanwhile. The approved payment is settled
+	// so its budget is released.
+	err = req.Context().Err()
+	if err != nil {
+		approver.Settle(payment, err)
+		return nil, err
+	}
+
+	start := time.Now()
+	paid, unresolved, err := t.payOnce(req.Context(), payment)
+	if unresolved {
+		// The payments whose outcome is unknown are settled and
+		// recorded once recovered.
+		t.holdUnresolved(payment)
+	} else {
+		approver.Settle(payment, err)
+	}
+	if err != nil {
+		if !unresolved {
+			t.record(req, payment, nil, start, err)
+		}
+
+		return nil, fmt.Errorf("unable to pay invoice: %w", err)
+	}
+
+	// Do not trust the wallet blindly, a wrong preimage would be stored
+	// and sent forever.
+	preimage := strings.ToLower(paid.Preimage)
+	err = challenge.VerifyPreimage(preimage)
+	t.record(req, payment, paid, start, err)
+	if err != nil {
+		t.forget(payment.PaymentHash)
+		return nil, fmt.Errorf("wallet returned an invalid preimage: %w",
+			err)
+	}
+
+	if info != nil {
+		info.Paid = true
+	}
+
+	challenge.Preimage = preimage
+
+	slog.Debug(
+		"Paid invoice",
+		"macaroon", challenge.Macaroon,
+		"invoice", challenge.Invoice,
+		"preimage", challenge.Preimage,
+	)
+
+	// The credentials are saved before sending the request again so a
+	// failure from now on never loses the payment. Until they are saved
+	// the payment stays pending.
+	err = store.InsertL402Credentials(challenge)
+	if err != nil {
+		return nil, fmt.Errorf("unable to save L402 credentials: %w", err)
+	}
+	t.forget(payment.PaymentHash)
+
+	return t.sendPaid(req, challenge)
+}
+
+// payOnce pays the invoice of the payment at most once. The payment is stored
+// as pending before calling the wallet and, if the wallet fails, it is looked
+// up in the wallet in case the invoice was paid anyway. True is returned if
+// the outcome is unknown, the payment is then kept pending to be recovered
+// later.
+func (t *Transport) payOnce(ctx context.Context,
+	payment *Payment) (*wallets.PaymentResult, bool, error) {
+
+	// Once started, a payment is never abandoned halfway because the
+	// request was cancelled, only the payment timeout stops waiting.
+	ctx, cancel := context.WithTimeout(
+		context.WithoutCancel(ctx), t.paymentTimeout(),
+	)
+	defer cancel()
+
+	if t.Pending == nil {
+		paid, err := t.pay(ctx, payment)
+		return paid, false, err
+	}
+
+	err := t.Pending.InsertPendingPayment(&payments.Pending{
+		PaymentHash: payment.PaymentHash,
+		URL:         payment.URL,
+		Macaroon:    payment.Macaroon,
+		AuthScheme:  payment.AuthScheme,
+		Invoice:     payment.Invoice,
+		AmountSats:  payment.AmountSats,
+		WalletID:    t.WalletID,
+		DecisionID:  payment.DecisionID,
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("unable to store pending payment: "+
+			"%w", err)
+	}
+
+	paid, payErr := t.pay(ctx, payment)
+	if payErr == nil {
+		return paid, false, nil
+	}
+
+	// Without a lookup, only the errors of a payment the wallet never
+	// sent resolve it, the others leave it pending.
+	_, canLookup := t.Wallet.(wallets.PaymentLookup)
+	if !canLookup && notSent(payErr) {
+		t.forget(payment.PaymentHash)
+		return nil, false, payErr
+	}
+
+	// The payment context may be done already if the wallet timed out.
+	lookupCtx, cancelLookup := context.WithTimeout(
+		context.WithoutCancel(ctx), t.paymentTimeout(),
+	)
+	defer cancelLookup()
+
+	preimage, err := payments.Resolve(
+		lookupCtx, t.Wallet, payment.PaymentHash,
+	)
+	switch {
+	case err == nil:
+		slog.Debug(
+			"Recovered the preimage of a payment that failed",
+			"payment_hash", payment.PaymentHash,
+			"error", payErr,
+		)
+
+		return &wallets.PaymentResult{Preimage: preimage}, false, nil
+
+	case errors.Is(err, payments.ErrPaymentNotSent):
+		t.forget(payment.PaymentHash)
+		return nil, false, payErr
+
+	case errors.Is(err, payments.ErrPaymentInFlight):
+		return nil, true, fmt.Errorf("%w: %v", err, payErr)
+	}
+
+	slog.Debug(
+		"Unable to look up the failed payment, keeping it pending",
+		"payment_hash", payment.PaymentHash,
+		"error", err,
+	)
+
+	return nil, true, payErr
+}
+
+// recoverPending looks up the pending payments left for the resource by
+// interrupted requests. The credentials of the first one paid are saved and
+// returned. Payments still in flight, or that can not be looked up, fail the
+// request, paying a new invoice could pay the resource twice.
+func (t *Transport) recoverPending(req *http.Request,
+	scope credentials.Scope) (*credentials.L402Credentials, error) {
+
+	if t.Pending == nil {
+		return nil, nil
+	}
+
+	pending, err := t.Pending.ListPendingPayments()
+	if err != nil {
+		return nil, fmt.Errorf("unable to list pending payments: %w", err)
+	}
+
+	scope.Location = ""
+	for _, p := range pending {
+		pendingScope, err := p.Scope()
+		if err != nil || pendingScope != scope {
+			continue
+		}
+
+		payment := &Payment{
+			URL:         p.URL,
+			Invoice:     p.Invoice,
+			PaymentHash: p.PaymentHash,
+			Macaroon:    p.Macaroon,
+			AmountSats:  p.AmountSats,
+		}
+
+		preimage, err := payments.Resolve(
+			req.Context(), t.Wallet, p.PaymentHash,
+		)
+		switch {
+		case errors.Is(err, payments.ErrPaymentNotSent):
+			t.settleUnresolved(p.PaymentHash, err)
+			t.record(req, payment, nil, p.CreatedAt, err)
+			t.forget(p.PaymentHash)
+			continue
+
+		case errors.Is(err, payments.ErrPaymentInFlight):
+			return nil, fmt.Errorf("a previous payment for %s is %w",
+				scope, err)
+
+		case err != nil:
+			return nil, fmt.Errorf("a previous payment for %s can not "+
+				"be looked up, resolve it with `fewsatscli payments "+
+				"recover`: %w", scope, err)
+		}
+
+		paid := &wallets.PaymentResult{Preimage: preimage}
+
+		creds, err := p.Credentials(preimage)
+		if errors.Is(err, credentials.ErrPreimageMismatch) {
+			// The invoice is paid, the preimage will never unlock
+			// the macaroon.
+			t.settleUnresolved(p.PaymentHash, nil)
+			t.record(req, payment, paid, p.CreatedAt, err)
+			t.settlePending(p.PaymentHash)
+			continue
+		}
+		if err != nil {
+			slog.Debug("Unable to recover pending payment.", "error", err,
+				"payment_hash", p.PaymentHash)
+			continue
+		}
+
+		err = t.store().InsertL402Credentials(creds)
+		if err != nil {
+			return nil, fmt.Errorf("unable to save L402 credentials: %w",
+				err)
+		}
+
+		paid.Preimage = creds.Preimage
+		t.settleUnresolved(p.PaymentHash, nil)
+		t.record(req, payment, paid, p.CreatedAt, nil)
+		t.settlePending(p.PaymentHash)
+
+		slog.Debug(
+			"Recovered L402 credentials of a pending payment",
+			"macaroon", creds.Macaroon,
+			"payment_hash", p.PaymentHash,
+		)
+
+		return creds, nil
+	}
+
+	return nil, nil
+}
+
+// holdUnresolved keeps the approved payment whose outcome is unknown until
+// it is resolved.
+func (t *Transport) holdUnresolved(payment *Payment) {
+	t.unresolvedMu.Lock()
+	defer t.unresolvedMu.Unlock()
+
+	if t.unresolved == nil {
+		t.unresolved = make(map[string]*Payment)
+	}
+	t.unresolved[payment.PaymentHash] = payment
+}
+
+// settleUnresolved settles the approved payment with the given payment hash
+// with the Approver, now that its outcome is known. err is nil if the invoice
+// was paid.
+func (t *Transport) settleUnresolved(paymentHash string, err error) {
+	t.unresolvedMu.Lock()
+	payment, ok := t.unresolved[paymentHash]
+	delete(t.unresolved, paymentHash)
+	t.unresolvedMu.Unlock()
+
+	if ok && t.Approver != nil {
+		t.Approver.Settle(payment, err)
+	}
+}
+
+// notSent returns true for the wallet errors meaning the payment was never
+// sent or definitely failed. Timeouts and other errors leave the outcome
+// unknown.
+func notSent(err error) bool {
+	return errors.Is(err, wallets.ErrPaymentFailed) ||
+		errors.Is(err, wallets.ErrUnauthorized) ||
+		errors.Is(err, wallets.ErrInsufficientBalance) ||
+		errors.Is(err, wallets.ErrDevPaymentFailed)
+}
+
+// forget removes the payment from the pending payments once its outcome is
+// known.
+func (t *Transport) forget(paymentHash string) {
+	if t.Pending == nil {
+		return
+	}
+
+	err := t.Pending.DeletePendingPayment(paymentHash)
+	if err != nil && !errors.Is(err, payments.ErrNoPendingPayment) {
+		slog.Debug("Failed to delete pending payment.", "error", err,
+			"payment_hash", paymentHash)
+	}
+}
+
+// settlePending removes the recovered payment from the pending payments once
+// its invoice is known to be paid, its spending decision is marked as paid.
+func (t *Transport) settlePending(paymentHash string) {
+	err := t.Pending.SettlePendingPayment(paymentHash)
+	if err != nil && !errors.Is(err, payments.ErrNoPendingPayment) {
+		slog.Debug("Failed to settle pending payment.", "error", err,
+			"payment_hash", paymentHash)
+	}
+}
+
+// sendFirst sends the request with the stored credentials, if any. Without
+// credentials and with Probe set, a probe without body is sent first and its
+// response is returned if it is an L402 challenge.
+func (t *Transport) sendFirst(req *http.Request,
+	creds *credentials.L402Credentials) (*http.Response, error) {
+
+	if creds != nil || !t.Probe || !hasBody(req) {
+		return t.sendRetry(req, creds, false)
+	}
+
+	resp, err := t.sendRetry(probeRequest(req), nil, false)
+	switch {
+	case err != nil:
+		slog.Debug("L402 probe failed.", "error", err)
+
+	case resp.StatusCode == http.StatusPaymentRequired:
+		return resp, nil
+
+	default:
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	return t.sendRetry(req, nil, false)
+}
+
+// sendPaid sends the request with the credentials just paid for. They are
+// already saved, a failure here does not lose the payment.
+func (t *Transport) sendPaid(req *http.Request,
+	creds *credentials.L402Credentials) (*http.Response, error) {
+
+	resp, err := t.sendRetry(req, creds, true)
+	if err != nil {
+		return nil, fmt.Errorf("unable to send the request after paying, "+
+			"the L402 credentials are saved: %w", err)
+	}
+
+	return resp, nil
+}
+
+// preflight checks the invoice can be paid: it is not expired, it is for the
+// expected network and, if it is amountless, an amount was given and the
+// wallet can pay it. The amount to pay is returned.
+func (t *Transport) preflight(details *invoices.Details) (uint64, error) {
+	err := details.Check(t.Network, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	if !details.Amountless {
+		return details.AmountSats, nil
+	}
+
+	if t.AmountlessSats == 0 {
+		return 0, fmt.Errorf("%w: an explicit amount is required",
+			invoices.ErrAmountless)
+	}
+
+	if _, ok := t.Wallet.(wallets.AmountPreimageProvider); !ok {
+		return 0, fmt.Errorf("%w: the wallet can not pay amountless "+
+			"invoices", invoices.ErrAmountless)
+	}
+
+	return t.AmountlessSats, nil
+}
+
+// pay pays the invoice of the payment with the wallet and returns the
+// preimage and the fee paid.
+func (t *Transport) pay(ctx context.Context,
+	payment *Payment) (*wallets.PaymentResult, error) {
+
+	var amountSats uint64
+	if payment.Details.Amountless {
+		amountSats = payment.AmountSats
+	}
+
+	return wallets.Pay(ctx, t.Wallet, payment.Invoice, amountSats)
+}
+
+// paymentTimeout returns the time the wallet is given to pay an invoice.
+func (t *Transport) paymentTimeout() time.Duration {
+	if t.PaymentTimeout == 0 {
+		return DefaultPaymentTimeout
+	}
+
+	return t.PaymentTimeout
+}
+
+// record adds the payment attempt to the ledger. paid is the outcome of the
+// payment, nil if the wallet failed to pay, and payErr is the payment or the
+// preimage verification error. A paid invoice with an invalid preimage is
+// recorded as such, its amount is spent. A failure to record does not fail
+// the request, the invoice is already paid.
+func (t *Transport) record(req *http.Request, payment *Payment,
+	paid *wallets.PaymentResult, start time.Time, payErr error) {
+
+	if t.Ledger == nil {
+		return
+	}
+
+	record := &payments.Payment{
+		URL:         payment.URL,
+		Host:        strings.ToLower(req.URL.Hostname()),
+		AmountSats:  payment.AmountSats,
+		PaymentHash: payment.PaymentHash,
+		WalletID:    t.WalletID,
+		Status:      payments.StatusSucceeded,
+		DurationMs:  time.Since(start).Milliseconds(),
+		CreatedAt:   start.UTC(),
+	}
+
+	if paid != nil {
+		record.Preimage = strings.ToLower(paid.Preimage)
+		record.FeeSats = paid.FeeSats
+	}
+
+	switch {
+	case payErr != nil && paid != nil:
+		record.Status = payments.StatusPaidInvalidPreimage
+		record.Error = payErr.Error()
+
+	case payErr != nil:
+		record.Status = payments.StatusFailed
+		record.Error = payErr.Error()
+	}
+
+	err := t.Ledger.InsertPayment(record)
+	if err != nil {
+		slog.Debug("Failed to record payment.", "error", err,
+			"payment_hash", payment.PaymentHash)
+	}
+}
+
+// discardCredentials marks the credentials rejected by the server as stale
+// and returns the response to go through the L402 challenge once more. A 401
+// response carries no challenge so the request is sent again without
+// credentials to get one.
+func (t *Transport) discardCredentials(req *http.Request, resp *http.Response,
+	creds *credentials.L402Credentials) (*http.Response, error) {
+
+	slog.Debug(
+		"Stored L402 credentials rejected",
+		"macaroon", creds.Macaroon,
+		"status", resp.StatusCode,
+	)
+
+	err := t.store().MarkL402CredentialsStale(creds.ID)
+	if err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unable to mark L402 credentials as stale: "+
+			"%w", err)
+	}
+
+	if resp.StatusCode == http.StatusPaymentRequired {
+		return resp, nil
+	}
+
+	resp.Body.Close()
+
+	return t.send(req, nil, true)
+}
+
+// send sends a copy of the request with the given L402 credentials, if any.
+// Replayed requests get a fresh body from req.GetBody.
+func (t *Transport) send(req *http.Request,
+	creds *credentials.L402Credentials, replay bool) (*http.Response,
+	error) {
+
+	r := req.Clone(req.Context())
+
+	// A body that can only be sent once is still sent if it was never
+	// read, like after a probe.
+	if replay && hasBody(req) && !unread(req.Body) {
+		if req.GetBody == nil {
+			return nil, ErrBodyNotReplayable
+		}
+
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("unable to get request body: %w", err)
+		}
+		r.Body = body
+	}
+
+	if creds != nil {
+		header, err := creds.AuthenticationHeader()
+		if err != nil {
+			closeBody(r)
+			return nil, fmt.Errorf("unable to generate L402 auth "+
+				"header: %w", err)
+		}
+
+		r.Header.Set("Authorization", header)
+	}
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return base.RoundTrip(r)
+}
+
+// sendRetry sends the request like send, retrying with an exponential
+// backoff while it fails with a transient error and its body can be replayed.
+func (t *Transport) sendRetry(req *http.Request,
+	creds *credentials.L402Credentials, replay bool) (*http.Response,
+	error) {
+
+	maxRetries := t.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = DefaultMaxRetries
+	}
+
+	backoff := t.RetryBackoff
+	if backoff == 0 {
+		backoff = DefaultRetryBackoff
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := t.send(req, creds, replay)
+
+		retry := attempt < maxRetries && canReplay(req) &&
+			req.Context().Err() == nil && isTransient(req, resp, err)
+		if !retry {
+			return resp, err
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		slog.Debug(
+			"Retrying request after a transient failure",
+			"url", req.URL.String(),
+			"attempt", attempt+1,
+			"backoff", backoff,
+			"error", err,
+		)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+
+		backoff *= 2
+		replay = true
+	}
+}
+
+// canReplay returns true if the request can be sent again.
+func canReplay(req *http.Request) bool {
+	return !hasBody(req) || req.GetBody != nil || unread(req.Body)
+}
+
+// isTransient returns true for the failures worth retrying: network errors
+// and the 502, 503 and 504 responses. The server may have processed a
+// request that failed that way, so only idempotent requests are retried
+// after them, the others only if the connection could not be opened.
+func isTransient(req *http.Request, resp *http.Response, err error) bool {
+	if err != nil && !idempotent(req) {
+		return isDialError(err)
+	}
+
+	if err != nil {
+		var netErr net.Error
+		return errors.As(err, &netErr) || errors.Is(err, io.EOF) ||
+			errors.Is(err, io.ErrUnexpectedEOF) ||
+			errors.Is(err, syscall.ECONNRESET) ||
+			errors.Is(err, syscall.ECONNREFUSED)
+	}
+
+	if !idempotent(req) {
+		return false
+	}
+
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+
+		return true
+	}
+
+	return false
+}
+
+// idempotent returns true if sending the request twice has the same effect
+// as sending it once: the GET, HEAD, OPTIONS and TRACE requests, and any
+// request with an Idempotency-Key header.
+func idempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions,
+		http.MethodTrace:
+
+		return true
+	}
+
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+// isDialError returns true if the request failed before being sent because
+// the connection could not be opened.
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// store returns the credentials store used by the Transport.
+func (t *Transport) store() credentials.Store {
+	if t.Store != nil {
+		return t.Store
+	}
+
+	t.memStoreOnce.Do(func() {
+		t.memStore = NewMemoryStore()
+	})
+
+	return t.memStore
+}
+
+// resourceLock is the lock of a resource and the number of requests holding
+// or waiting for it.
+type resourceLock struct {
+	sync.Mutex
+
+	refs int
+}
+
+// lock locks the payments for the given resource and returns the function
+// that unlocks them.
+func (t *Transport) lock(key string) func() {
+	t.locksMu.Lock()
+	if t.locks == nil {
+		t.locks = make(map[string]*resourceLock)
+	}
+
+	lock, ok := t.locks[key]
+	if !ok {
+		lock = &resourceLock{}
+		t.locks[key] = lock
+	}
+	lock.refs++
+	t.locksMu.Unlock()
+
+	lock.Lock()
+
+	return func() {
+		lock.Unlock()
+
+		t.locksMu.Lock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(t.locks, key)
+		}
+		t.locksMu.Unlock()
+	}
+}
+
+// closeBody closes the request body, a RoundTripper must always close it.
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
+
+// NewBaseTransport returns a copy of http.DefaultTransport bounding the time
+// to connect, including the TLS handshake, and to receive the response
+// headers. The body of the responses is not bounded, so large downloads are
+// not cut short. Zero timeouts keep the defaults.
+func NewBaseTransport(connectTimeout,
+	responseTimeout time.Duration) *http.Transport {
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if connectTimeout > 0 {
+		dialer := &net.Dialer{
+			Timeout:   connectTimeout,
+			KeepAlive: 30 * time.Second,
+		}
+		transport.DialContext = dialer.DialContext
+		transport.TLSHandshakeTimeout = connectTimeout
+	}
+
+	if responseTimeout > 0 {
+		transport.ResponseHeaderTimeout = responseTimeout
+	}
+
+	return transport
+}
@@ -0,0 +1,1077 @@
+package l402
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/fewsats/fewsatscli/credentials"
+	"github.com/fewsats/fewsatscli/invoices"
+	"github.com/fewsats/fewsatscli/payments"
+	"github.com/fewsats/fewsatscli/wallets"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/zpay32"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/macaroon.v2"
+)
+
+const (
+	testPreimage = "0101010101010101010101010101010101010101010101010101010101010101"
+)
+
+// newTestMacaroon returns a base64 encoded macaroon linked to the payment
+// hash of the given preimage.
+func newTestMacaroon(t *testing.T, preimage string, tokenID byte) string {
+	t.Helper()
+
+	preimageBytes, err := hex.DecodeString(preimage)
+	require.NoError(t, err)
+	paymentHash := sha256.Sum256(preimageBytes)
+
+	var id bytes.Buffer
+	require.NoError(t, binary.Write(&id, binary.BigEndian, uint16(0)))
+	id.Write(paymentHash[:])
+	id.Write(bytes.Repeat([]byte{tokenID}, 32))
+
+	mac, err := macaroon.New(
+		[]byte("root-key"), id.Bytes(), "fewsats", macaroon.LatestVersion,
+	)
+	require.NoError(t, err)
+
+	macBytes, err := mac.MarshalBinary()
+	require.NoError(t, err)
+
+	return base64.StdEncoding.EncodeToString(macBytes)
+}
+
+// newTestInvoice returns a signed regtest invoice for the given preimage and
+// amount.
+func newTestInvoice(t *testing.T, preimage string, amountSats uint64) string {
+	t.Helper()
+
+	preimageBytes, err := hex.DecodeString(preimage)
+	require.NoError(t, err)
+
+	invoice, err := zpay32.NewInvoice(
+		&chaincfg.RegressionNetParams, sha256.Sum256(preimageBytes),
+		time.Now(),
+		zpay32.Amount(lnwire.MilliSatoshi(amountSats*1000)),
+		zpay32.Description("test invoice"),
+	)
+	require.NoError(t, err)
+
+	return signTestInvoice(t, invoice)
+}
+
+// signTestInvoice signs and encodes the invoice with a random key.
+func signTestInvoice(t *testing.T, invoice *zpay32.Invoice) string {
+	t.Helper()
+
+	key, err := btcec.NewPrivateKey()
+	require.NoError(t, err)
+
+	encoded, err := invoice.Encode(zpay32.MessageSigner{
+		SignCompact: func(msg []byte) ([]byte, error) {
+			return ecdsa.SignCompact(key, chainhash.HashB(msg), true)
+		},
+	})
+	require.NoError(t, err)
+
+	return encoded
+}
+
+// testWallet is a wallet that always returns the same preimage.
+type testWallet struct {
+	preimage string
+	calls    atomic.Int32
+}
+
+func (w *testWallet) GetPreimage(string) (string, error) {
+	w.calls.Add(1)
+	return w.preimage, nil
+}
+
+// newTestServer returns a server that asks for an L402 payment unless the
+// request carries the expected credentials.
+func newTestServer(t *testing.T, macaroon,
+	invoice string) *httptest.Server {
+
+	t.Helper()
+
+	return newSchemeTestServer(t, credentials.SchemeL402, macaroon, invoice)
+}
+
+// newSchemeTestServer returns a server that asks for a payment with a
+// challenge of the given authentication scheme unless the request carries
+// the expected credentials with the same scheme.
+func newSchemeTestServer(t *testing.T, scheme, macaroon,
+	invoice string) *httptest.Server {
+
+	t.Helper()
+
+	expected := fmt.Sprintf("%s %s:%s", scheme, macaroon, testPreimage)
+
+	return httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != expected {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(
+					`%s macaroon="%s", invoice="%s"`, scheme,
+					macaroon, invoice,
+				))
+				w.WriteHeader(http.StatusPaymentRequired)
+
+				return
+			}
+
+			body, _ := io.ReadAll(r.Body)
+			fmt.Fprintf(w, "paid %s", body)
+		},
+	))
+}
+
+func TestTransport(t *testing.T) {
+	t.Parallel()
+
+	invoice := newTestInvoice(t, testPreimage, 10)
+	server := newTestServer(t, newTestMacaroon(t, testPreimage, 1), invoice)
+	defer server.Close()
+
+	wallet := &testWallet{preimage: testPreimage}
+
+	var approved []*Payment
+	client := &http.Client{
+		Transport: &Transport{
+			Wallet: wallet,
+			Approver: ApproveFunc(func(_ *http.Request, p *Payment) error {
+				approved = append(approved, p)
+				return nil
+			}),
+		},
+	}
+
+	// The invoice is paid and the request is replayed with its body.
+	resp, err := client.Post(
+		server.URL+"/resource", "text/plain", strings.NewReader("body"),
+	)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "paid body", string(body))
+
+	require.Len(t, approved, 1)
+	require.Equal(t, uint64(10), approved[0].AmountSats)
+	require.Equal(t, invoice, approved[0].Invoice)
+
+	// The stored credentials are reused for the next request.
+	resp, err = client.Get(server.URL + "/resource")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, int32(1), wallet.calls.Load())
+}
+
+func TestTransportLSATChallenge(t *testing.T) {
+	t.Parallel()
+
+	server := newSchemeTestServer(
+		t, credentials.SchemeLSAT, newTestMacaroon(t, testPreimage, 1),
+		newTestInvoice(t, testPreimage, 10),
+	)
+	defer server.Close()
+
+	store := NewMemoryStore()
+	wallet := &testWallet{preimage: testPreimage}
+	client := &http.Client{
+		Transport: &Transport{
+			Store:  store,
+			Wallet: wallet,
+			Approver: ApproveFunc(func(*http.Request, *Payment) error {
+				return nil
+			}),
+		},
+	}
+
+	// The credentials paid for an LSAT only server are sent back with
+	// the LSAT scheme, and stored with it for the next requests.
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(server.URL + "/resource")
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+	require.Equal(t, int32(1), wallet.calls.Load())
+
+	scope, err := credentials.ParseScope(server.URL + "/resource")
+	require.NoError(t, err)
+	creds, err := store.GetL402Credentials(scope)
+	require.NoError(t, err)
+	require.Equal(t, credentials.SchemeLSAT, creds.AuthScheme)
+}
+
+func TestTransportConcurrentPayments(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(
+		t, newTestMacaroon(t, testPreimage, 1),
+		newTestInvoice(t, testPreimage, 10),
+	)
+	defer server.Close()
+
+	wallet := &testWallet{preimage: testPreimage}
+	transport := &Transport{
+		Wallet: wallet,
+		Approver: ApproveFunc(func(*http.Request, *Payment) error {
+			return nil
+		}),
+	}
+	client := &http.Client{Transport: transport}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			resp, err := client.Get(server.URL + "/resource")
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, int32(1), wallet.calls.Load())
+
+	// The locks are removed once released.
+	transport.locksMu.Lock()
+	defer transport.locksMu.Unlock()
+	require.Empty(t, transport.locks)
+}
+
+func TestTransportRefused(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(
+		t, newTestMacaroon(t, testPreimage, 1),
+		newTestInvoice(t, testPreimage, 10),
+	)
+	defer server.Close()
+
+	wallet := &testWallet{preimage: testPreimage}
+
+	// Without an Approver nothing is paid.
+	client := &http.Client{Transport: &Transport{Wallet: wallet}}
+	_, err := client.Get(server.URL + "/resource")
+	require.ErrorIs(t, err, ErrPaymentNotApproved)
+
+	// Without a wallet nothing can be paid.
+	client = &http.Client{Transport: &Transport{}}
+	_, err = client.Get(server.URL + "/resource")
+	require.ErrorIs(t, err, ErrNoWallet)
+
+	require.Zero(t, wallet.calls.Load())
+}
+
+func TestTransportStaleCredentials(t *testing.T) {
+	t.Parallel()
+
+	invoice := newTestInvoice(t, testPreimage, 10)
+
+	var (
+		mu sync.Mutex
+
+		// macaroon is the only macaroon the server accepts, rotating it
+		// revokes the credentials paid for the previous one.
+		macaroon = newTestMacaroon(t, testPreimage, 1)
+
+		// rejectStatus is the status used to reject revoked credentials.
+		rejectStatus = http.StatusPaymentRequired
+	)
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			auth := r.Header.Get("Authorization")
+			switch {
+			case auth == fmt.Sprintf("L402 %s:%s", macaroon, testPreimage):
+				fmt.Fprint(w, "ok")
+
+			case auth != "" && rejectStatus == http.StatusUnauthorized:
+				w.WriteHeader(http.StatusUnauthorized)
+
+			default:
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(
+					`L402 macaroon="%s", invoice="%s"`, macaroon,
+					invoice,
+				))
+				w.WriteHeader(http.StatusPaymentRequired)
+			}
+		},
+	))
+	defer server.Close()
+
+	rotate := func(newMacaroon string, status int) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		macaroon, rejectStatus = newMacaroon, status
+	}
+
+	wallet := &testWallet{preimage: testPreimage}
+	client := &http.Client{
+		Transport: &Transport{
+			Wallet: wallet,
+			Approver: ApproveFunc(func(*http.Request, *Payment) error {
+				return nil
+			}),
+		},
+	}
+
+	get := func() {
+		t.Helper()
+
+		resp, err := client.Get(server.URL + "/resource")
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	get()
+	require.Equal(t, int32(1), wallet.calls.Load())
+
+	// Credentials rejected with a new challenge are paid again.
+	rotate(newTestMacaroon(t, testPreimage, 2), http.StatusPaymentRequired)
+	get()
+	require.Equal(t, int32(2), wallet.calls.Load())
+
+	// Credentials rejected with a 401 are dropped and the challenge is
+	// requested again.
+	rotate(newTestMacaroon(t, testPreimage, 3), http.StatusUnauthorized)
+	get()
+	require.Equal(t, int32(3), wallet.calls.Load())
+
+	// The new credentials are reused.
+	get()
+	require.Equal(t, int32(3), wallet.calls.Load())
+}
+
+// testLedger is an in memory payments ledger.
+type testLedger struct {
+	mu       sync.Mutex
+	payments []*payments.Payment
+}
+
+func (l *testLedger) InsertPayment(payment *payments.Payment) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.payments = append(l.payments, payment)
+
+	return nil
+}
+
+// feeWallet is a test wallet reporting the fee of its payments.
+type feeWallet struct {
+	testWallet
+	feeSats uint64
+}
+
+func (w *feeWallet) PayInvoice(context.Context, string,
+	uint64) (*wallets.PaymentResult, error) {
+
+	w.calls.Add(1)
+
+	return &wallets.PaymentResult{
+		Preimage: w.preimage,
+		FeeSats:  w.feeSats,
+	}, nil
+}
+
+func TestTransportLedger(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(
+		t, newTestMacaroon(t, testPreimage, 1),
+		newTestInvoice(t, testPreimage, 10),
+	)
+	defer server.Close()
+
+	ledger := &testLedger{}
+	client := &http.Client{
+		Transport: &Transport{
+			Wallet: &feeWallet{
+				testWallet: testWallet{preimage: testPreimage},
+				feeSats:    2,
+			},
+			Approver: ApproveFunc(func(*http.Request, *Payment) error {
+				return nil
+			}),
+			Ledger:   ledger,
+			WalletID: 7,
+		},
+	}
+
+	resp, err := client.Get(server.URL + "/resource")
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	preimage, err := hex.DecodeString(testPreimage)
+	require.NoError(t, err)
+	paymentHash := sha256.Sum256(preimage)
+
+	require.Len(t, ledger.payments, 1)
+	payment := ledger.payments[0]
+	require.Equal(t, server.URL+"/resource", payment.URL)
+	require.Equal(t, "127.0.0.1", payment.Host)
+	require.Equal(t, uint64(10), payment.AmountSats)
+	require.Equal(t, uint64(2), payment.FeeSats)
+	require.Equal(t, hex.EncodeToString(paymentHash[:]), payment.PaymentHash)
+	require.Equal(t, testPreimage, payment.Preimage)
+	require.Equal(t, uint64(7), payment.WalletID)
+	require.Equal(t, payments.StatusSucceeded, payment.Status)
+}
+
+func TestTransportVerification(t *testing.T) {
+	t.Parallel()
+
+	otherPreimage := strings.Repeat("02", 32)
+	approve := ApproveFunc(func(*http.Request, *Payment) error {
+		return nil
+	})
+
+	// A macaroon not linked to the invoice is never paid.
+	server := newTestServer(
+		t, newTestMacaroon(t, otherPreimage, 1),
+		newTestInvoice(t, testPreimage, 10),
+	)
+	defer server.Close()
+
+	wallet := &testWallet{preimage: testPreimage}
+	client := &http.Client{
+		Transport: &Transport{Wallet: wallet, Approver: approve},
+	}
+
+	_, err := client.Get(server.URL + "/resource")
+	require.ErrorIs(t, err, credentials.ErrPaymentHashMismatch)
+	require.Zero(t, wallet.calls.Load())
+
+	// A wrong preimage returned by the wallet is never stored.
+	server = newTestServer(
+		t, newTestMacaroon(t, testPreimage, 1),
+		newTestInvoice(t, testPreimage, 10),
+	)
+	defer server.Close()
+
+	store := NewMemoryStore()
+	ledger := &testLedger{}
+	wallet = &testWallet{preimage: otherPreimage}
+	client = &http.Client{
+		Transport: &Transport{
+			Store:    store,
+			Wallet:   wallet,
+			Approver: approve,
+			Ledger:   ledger,
+		},
+	}
+
+	_, err = client.Get(server.URL + "/resource")
+	require.ErrorIs(t, err, credentials.ErrPreimageMismatch)
+	require.Equal(t, int32(1), wallet.calls.Load())
+	require.Empty(t, store.creds)
+
+	// The invoice is paid anyway, its amount is spent.
+	require.Len(t, ledger.payments, 1)
+	require.Equal(t, payments.StatusPaidInvalidPreimage,
+		ledger.payments[0].Status)
+	require.Equal(t, otherPreimage, ledger.payments[0].Preimage)
+}
+
+// amountWallet is a test wallet able to pay amountless invoices.
+type amountWallet struct {
+	testWallet
+	amount uint64
+}
+
+func (w *amountWallet) GetPreimageForAmount(_ context.Context, _ string,
+	amountSats uint64) (string, error) {
+
+	w.amount = amountSats
+	return w.GetPreimage("")
+}
+
+func TestTransportPreflight(t *testing.T) {
+	t.Parallel()
+
+	approve := ApproveFunc(func(*http.Request, *Payment) error {
+		return nil
+	})
+	macaroon := newTestMacaroon(t, testPreimage, 1)
+
+	// Invoices for another network are never paid.
+	server := newTestServer(t, macaroon, newTestInvoice(t, testPreimage, 10))
+	defer server.Close()
+
+	wallet := &amountWallet{testWallet: testWallet{preimage: testPreimage}}
+	client := &http.Client{
+		Transport: &Transport{
+			Wallet:   wallet,
+			Approver: approve,
+			Network:  invoices.NetworkMainnet,
+		},
+	}
+
+	_, err := client.Get(server.URL + "/resource")
+	require.ErrorIs(t, err, invoices.ErrWrongNetwork)
+
+	// Expired invoices are never paid.
+	preimageHash := sha256.Sum256(mustDecodeHex(t, testPreimage))
+	expired, err := zpay32.NewInvoice(
+		&chaincfg.RegressionNetParams, preimageHash,
+		time.Now().Add(-2*time.Hour), zpay32.Amount(10000),
+		zpay32.Description("expired"), zpay32.Expiry(time.Hour),
+	)
+	require.NoError(t, err)
+
+	server = newTestServer(t, macaroon, signTestInvoice(t, expired))
+	defer server.Close()
+
+	client.Transport = &Transport{Wallet: wallet, Approver: approve}
+	_, err = client.Get(server.URL + "/resource")
+	require.ErrorIs(t, err, invoices.ErrExpired)
+	require.Zero(t, wallet.calls.Load())
+
+	// Amountless invoices are only paid with an explicit amount.
+	amountless, err := zpay32.NewInvoice(
+		&chaincfg.RegressionNetParams, preimageHash, time.Now(),
+		zpay32.Description("amountless"),
+	)
+	require.NoError(t, err)
+
+	server = newTestServer(t, macaroon, signTestInvoice(t, amountless))
+	defer server.Close()
+
+	_, err = client.Get(server.URL + "/resource")
+	require.ErrorIs(t, err, invoices.ErrAmountless)
+
+	var paid *Payment
+	client.Transport = &Transport{
+		Wallet: wallet,
+		Approver: ApproveFunc(func(_ *http.Request, p *Payment) error {
+			paid = p
+			return nil
+		}),
+		AmountlessSats: 21,
+	}
+
+	resp, err := client.Get(server.URL + "/resource")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	require.Equal(t, uint64(21), wallet.amount)
+	require.Equal(t, uint64(21), paid.AmountSats)
+	require.True(t, paid.Details.Amountless)
+	require.Equal(t, "amountless", paid.Details.Description)
+
+	// Wallets unable to pay amountless invoices are not asked to.
+	plainWallet := &testWallet{preimage: testPreimage}
+	client.Transport = &Transport{
+		Wallet:         plainWallet,
+		Approver:       approve,
+		AmountlessSats: 21,
+	}
+
+	_, err = client.Get(server.URL + "/resource")
+	require.ErrorIs(t, err, invoices.ErrAmountless)
+	require.Zero(t, plainWallet.calls.Load())
+}
+
+// mustDecodeHex decodes the hex string or fails the test.
+func mustDecodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+
+	b, err := hex.DecodeString(s)
+	require.NoError(t, err)
+
+	return b
+}
+
+// testPending is an in memory pending payments store.
+type testPending struct {
+	mu      sync.Mutex
+	pending map[string]*payments.Pending
+}
+
+func (p *testPending) InsertPendingPayment(pending *payments.Pending) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.pending == nil {
+		p.pending = make(map[string]*payments.Pending)
+	}
+	if _, ok := p.pending[pending.PaymentHash]; !ok {
+		p.pending[pending.PaymentHash] = pending
+	}
+
+	return nil
+}
+
+func (p *testPending) ListPendingPayments() ([]*payments.Pending, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	result := make([]*payments.Pending, 0, len(p.pending))
+	for _, pending := range p.pending {
+		result = append(result, pending)
+	}
+
+	return result, nil
+}
+
+func (p *testPending) DeletePendingPayment(paymentHash string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.pending[paymentHash]; !ok {
+		return payments.ErrNoPendingPayment
+	}
+	delete(p.pending, paymentHash)
+
+	return nil
+}
+
+func (p *testPending) SettlePendingPayment(paymentHash string) error {
+	return p.DeletePendingPayment(paymentHash)
+}
+
+// lookupWallet is a test wallet failing to pay that can look up the status of
+// its payments.
+type lookupWallet struct {
+	testWallet
+	status *wallets.PaymentStatus
+}
+
+func (w *lookupWallet) GetPreimage(string) (string, error) {
+	w.calls.Add(1)
+	return "", errors.New("timeout")
+}
+
+func (w *lookupWallet) LookupPayment(context.Context,
+	string) (*wallets.PaymentStatus, error) {
+	if w.status == nil {
+		return nil, wallets.ErrPaymentNotFound
+	}
+
+	return w.status, nil
+}
+
+// settleApprover approves every payment and records how they are settled.
+type settleApprover struct {
+	mu      sync.Mutex
+	settled []error
+}
+
+func (a *settleApprover) Approve(*http.Request, *Payment) error {
+	return nil
+}
+
+func (a *settleApprover) Settle(_ *Payment, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.settled = append(a.settled, err)
+}
+
+func TestTransportPendingPayments(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(
+		t, newTestMacaroon(t, testPreimage, 1),
+		newTestInvoice(t, testPreimage, 10),
+	)
+	defer server.Close()
+
+	approve := &settleApprover{}
+
+	get := func(transport *Transport) error {
+		client := &http.Client{Transport: transport}
+
+		resp, err := client.Get(server.URL + "/resource")
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		return nil
+	}
+
+	// A wallet error for a payment the wallet never sent is returned and
+	// nothing is left pending.
+	pending := &testPending{}
+	ledger := &testLedger{}
+	wallet := &lookupWallet{}
+	transport := &Transport{
+		Wallet:   wallet,
+		Approver: approve,
+		Ledger:   ledger,
+		Pending:  pending,
+	}
+
+	err := get(transport)
+	require.ErrorContains(t, err, "timeout")
+	require.Empty(t, pending.pending)
+	require.Len(t, ledger.payments, 1)
+	require.Equal(t, payments.StatusFailed, ledger.payments[0].Status)
+	require.Len(t, approve.settled, 1)
+	require.Error(t, approve.settled[0])
+
+	// A payment still in flight is kept pending and not recorded yet, its
+	// budget stays reserved.
+	ledger.payments = nil
+	approve.settled = nil
+	wallet.status = &wallets.PaymentStatus{State: wallets.PaymentInFlight}
+
+	err = get(transport)
+	require.ErrorIs(t, err, payments.ErrPaymentInFlight)
+	require.Len(t, pending.pending, 1)
+	require.Empty(t, ledger.payments)
+	require.Empty(t, approve.settled)
+	require.Equal(t, int32(2), wallet.calls.Load())
+
+	// Nothing is paid while the previous payment is in flight.
+	err = get(transport)
+	require.ErrorIs(t, err, payments.ErrPaymentInFlight)
+	require.Equal(t, int32(2), wallet.calls.Load())
+
+	// Once settled, the payment is recovered instead of paid again.
+	wallet.status = &wallets.PaymentStatus{
+		State:    wallets.PaymentSucceeded,
+		Preimage: testPreimage,
+	}
+
+	err = get(transport)
+	require.NoError(t, err)
+	require.Equal(t, int32(2), wallet.calls.Load())
+	require.Empty(t, pending.pending)
+	require.Len(t, ledger.payments, 1)
+	require.Equal(t, payments.StatusSucceeded, ledger.payments[0].Status)
+	require.Equal(t, []error{nil}, approve.settled)
+
+	// A wallet error for an invoice paid anyway is recovered right away.
+	store := NewMemoryStore()
+	ledger.payments = nil
+	transport = &Transport{
+		Store:    store,
+		Wallet:   wallet,
+		Approver: approve,
+		Ledger:   ledger,
+		Pending:  pending,
+	}
+
+	err = get(transport)
+	require.NoError(t, err)
+	require.Equal(t, int32(3), wallet.calls.Load())
+	require.Len(t, store.creds, 1)
+	require.Empty(t, pending.pending)
+	require.Equal(t, payments.StatusSucceeded, ledger.payments[0].Status)
+}
+
+// errWallet is a test wallet failing with the given error, it can not look
+// up its payments.
+type errWallet struct {
+	testWallet
+	err error
+}
+
+func (w *errWallet) GetPreimage(string) (string, error) {
+	w.calls.Add(1)
+	return "", w.err
+}
+
+func TestTransportPendingWithoutLookup(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(
+		t, newTestMacaroon(t, testPreimage, 1),
+		newTestInvoice(t, testPreimage, 10),
+	)
+	defer server.Close()
+
+	tests := []struct {
+		name string
+		err  error
+
+		// sent is set if the payment may have been sent.
+		sent bool
+	}{
+		{
+			name: "Payment failed",
+			err:  fmt.Errorf("no route: %w", wallets.ErrPaymentFailed),
+		},
+		{
+			name: "Unauthorized",
+			err:  wallets.ErrUnauthorized,
+		},
+		{
+			name: "Insufficient balance",
+			err:  wallets.ErrInsufficientBalance,
+		},
+		{
+			name: "Dev payment failed",
+			err:  wallets.ErrDevPaymentFailed,
+		},
+		{
+			name: "Timeout",
+			err:  context.DeadlineExceeded,
+			sent: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pending := &testPending{}
+			ledger := &testLedger{}
+			approver := &settleApprover{}
+			client := &http.Client{
+				Transport: &Transport{
+					Wallet:   &errWallet{err: tc.err},
+					Approver: approver,
+					Ledger:   ledger,
+					Pending:  pending,
+				},
+			}
+
+			_, err := client.Get(server.URL + "/resource")
+			require.ErrorIs(t, err, tc.err)
+
+			// An ambiguous error keeps the payment pending and its
+			// budget reserved until it is resolved.
+			if tc.sent {
+				require.Len(t, pending.pending, 1)
+				require.Empty(t, ledger.payments)
+				require.Empty(t, approver.settled)
+
+				// The next request does not pay again while
+				// the payment can not be looked up.
+				wallet := &testWallet{preimage: testPreimage}
+				client.Transport = &Transport{
+					Wallet:   wallet,
+					Approver: approver,
+					Ledger:   ledger,
+					Pending:  pending,
+				}
+
+				_, err = client.Get(server.URL + "/resource")
+				require.ErrorContains(t, err, "payments recover")
+				require.Zero(t, wallet.calls.Load())
+				require.Len(t, pending.pending, 1)
+
+				return
+			}
+
+			require.Empty(t, pending.pending)
+			require.Len(t, ledger.payments, 1)
+			require.Equal(t, payments.StatusFailed,
+				ledger.payments[0].Status)
+			require.Len(t, approver.settled, 1)
+			require.ErrorIs(t, approver.settled[0], tc.err)
+		})
+	}
+}
+
+func TestTransportRetries(t *testing.T) {
+	t.Parallel()
+
+	macaroon := newTestMacaroon(t, testPreimage, 1)
+	invoice := newTestInvoice(t, testPreimage, 10)
+	expected := fmt.Sprintf("L402 %s:%s", macaroon, testPreimage)
+
+	// The server fails twice with a 503 once paid.
+	var failures atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != expected {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(
+					`L402 macaroon="%s", invoice="%s"`, macaroon,
+					invoice,
+				))
+				w.WriteHeader(http.StatusPaymentRequired)
+
+				return
+			}
+
+			if failures.Add(1) <= 2 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+
+			body, _ := io.ReadAll(r.Body)
+			fmt.Fprintf(w, "paid %s", body)
+		},
+	))
+	defer server.Close()
+
+	store := NewMemoryStore()
+	wallet := &testWallet{preimage: testPreimage}
+	client := &http.Client{
+		Transport: &Transport{
+			Store:  store,
+			Wallet: wallet,
+			Approver: ApproveFunc(func(*http.Request, *Payment) error {
+				return nil
+			}),
+			RetryBackoff: time.Millisecond,
+		},
+	}
+
+	// A request with an idempotency key is retried.
+	req, err := http.NewRequest(
+		http.MethodPut, server.URL+"/resource",
+		bytes.NewReader([]byte("body")),
+	)
+	require.NoError(t, err)
+	req.Header.Set("Idempotency-Key", "key")
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "paid body", string(body))
+	require.Equal(t, int32(3), failures.Load())
+	require.Equal(t, int32(1), wallet.calls.Load())
+
+	// A POST is never retried after a 503, the server may have processed
+	// it.
+	failures.Store(0)
+	resp, err = client.Post(
+		server.URL+"/resource", "text/plain",
+		bytes.NewReader([]byte("body")),
+	)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	require.Equal(t, int32(1), failures.Load())
+
+	// Without retries the failure is returned but the credentials are
+	// saved, the next request does not pay again.
+	failures.Store(0)
+	client.Transport = &Transport{
+		Store:      store,
+		Wallet:     wallet,
+		MaxRetries: -1,
+	}
+
+	resp, err = client.Get(server.URL + "/resource")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	require.Len(t, store.creds, 1)
+	require.Equal(t, int32(1), wallet.calls.Load())
+}
+
+// slowWallet is a test wallet whose payments take the given delay.
+type slowWallet struct {
+	testWallet
+	delay   time.Duration
+	started chan struct{}
+}
+
+func (w *slowWallet) GetPreimageContext(ctx context.Context,
+	invoice string) (string, error) {
+
+	close(w.started)
+
+	select {
+	case <-time.After(w.delay):
+		return w.GetPreimage(invoice)
+
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func TestTransportCancellation(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(
+		t, newTestMacaroon(t, testPreimage, 1),
+		newTestInvoice(t, testPreimage, 10),
+	)
+	defer server.Close()
+
+	approve := ApproveFunc(func(*http.Request, *Payment) error {
+		return nil
+	})
+
+	// Cancelling the request does not abandon the payment in flight, its
+	// credentials are saved.
+	store := NewMemoryStore()
+	wallet := &slowWallet{
+		testWallet: testWallet{preimage: testPreimage},
+		delay:      50 * time.Millisecond,
+		started:    make(chan struct{}),
+	}
+	client := &http.Client{
+		Transport: &Transport{
+			Store:    store,
+			Wallet:   wallet,
+			Approver: approve,
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-wallet.started
+		cancel()
+	}()
+
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodGet, server.URL+"/resource", nil,
+	)
+	require.NoError(t, err)
+
+	_, err = client.Do(req)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, int32(1), wallet.calls.Load())
+	require.Len(t, store.creds, 1)
+
+	// The payment timeout bounds the wallet.
+	wallet = &slowWallet{
+		testWallet: testWallet{preimage: testPreimage},
+		delay:      time.Minute,
+		started:    make(chan struct{}),
+	}
+	client.Transport = &Transport{
+		Wallet:         wallet,
+		Approver:       approve,
+		PaymentTimeout: 10 * time.Millisecond,
+	}
+
+	_, err = client.Get(server.URL + "/resource")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Zero(t, wallet.calls.Load())
+}
@@ -1,12 +1,11 @@
 package macaroons
 
 import (
-	"bytes"
 	"encoding/base64"
-	"encoding/binary"
 	"encoding/json"
 	"fmt"
 
+	"github.com/fewsats/fewsatscli/credentials"
 	"github.com/urfave/cli/v2"
 	"gopkg.in/macaroon.v2"
 )
@@ -20,8 +19,6 @@ type Macaroon struct {
 	Caveats     []string `json:"caveats"`
 }
 
-var byteOrder = binary.BigEndian
-
 var decodeCommand = &cli.Command{
 	Name:      "decode",
 	Usage:     "Decode a macaroon token",
@@ -32,30 +29,7 @@ var decodeCommand = &cli.Command{
 // DecodeMacIdentifier decodes the macaroon identifier into its version,
 // payment hash and user ID.
 func DecodeMacIdentifier(id []byte) (uint16, [32]byte, [32]byte, error) {
-	r := bytes.NewReader(id)
-
-	var version uint16
-	if err := binary.Read(r, byteOrder, &version); err != nil {
-		return 0, [32]byte{}, [32]byte{}, err
-	}
-
-	switch version {
-	// A version 0 identifier consists of its linked payment hash, followed
-	// by the user ID.
-	case 0:
-		var paymentHash [32]byte
-		if _, err := r.Read(paymentHash[:]); err != nil {
-			return 0, [32]byte{}, [32]byte{}, err
-		}
-		var tokenID [32]byte
-		if _, err := r.Read(tokenID[:]); err != nil {
-			return 0, [32]byte{}, [32]byte{}, err
-		}
-
-		return version, paymentHash, tokenID, nil
-	}
-
-	return 0, [32]byte{}, [32]byte{}, fmt.Errorf("unkown version: %d", version)
+	return credentials.DecodeMacIdentifier(id)
 }
 
 func decode(c *cli.Context) error {
@@ -3,19 +3,16 @@ package macaroons
 import (
 	"encoding/json"
 	"fmt"
-	"io"
 	"log/slog"
-	"net/http"
 	"strings"
 
 	"github.com/fewsats/fewsatscli/client"
+	"github.com/fewsats/fewsatscli/fewsats"
 	"github.com/urfave/cli/v2"
 )
 
-type MintMacaroonRequest struct {
-	Location string            `json:"location"`
-	Caveats  map[string]string `json:"caveats"`
-}
+// MintMacaroonRequest is the request body for the mint macaroon endpoint.
+type MintMacaroonRequest = fewsats.MintMacaroonRequest
 
 var mintCommand = &cli.Command{
 	Name:   "mint",
@@ -67,31 +64,13 @@ func mintMacaroon(c *cli.Context) error {
 		return cli.Exit("Failed to create HTTP client.", 1)
 	}
 
-	reqBody, err := json.Marshal(req)
+	mac, err := client.API().Macaroons.Mint(c.Context, &req)
 	if err != nil {
-		return cli.Exit(fmt.Sprintf("Failed to marshal request: %v", err), 1)
+		slog.Debug("Failed to mint macaroon.", "error", err)
+		return cli.Exit(fmt.Sprintf("Failed to mint macaroon: %v", err), 1)
 	}
 
-	resp, err := client.ExecuteRequest(http.MethodPost, "/v0/macaroon/mint", reqBody)
-	if err != nil {
-		slog.Debug("Failed to execute request.", "error", err)
-		return cli.Exit("Failed to execute request.", 1)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return cli.Exit(fmt.Sprintf("Failed to mint macaroon. Status code: %d", resp.StatusCode), 1)
-	}
-
-	// Read the entire response body
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return cli.Exit(fmt.Sprintf("Failed to read response body: %v", err), 1)
-	}
-	var response MacaroonResponse
-	if err := json.Unmarshal(bodyBytes, &response); err != nil {
-		return cli.Exit(fmt.Sprintf("Failed to decode response: %v", err), 1)
-	}
+	response := MacaroonResponse{Macaroon: mac}
 
 	jsonOutput, err := json.MarshalIndent(response, "", "  ")
 	if err != nil {
@@ -1,20 +1,19 @@
 package macaroons
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
-	"net/http"
 	"strings"
 
 	"github.com/fewsats/fewsatscli/client"
+	"github.com/fewsats/fewsatscli/fewsats"
 	"github.com/urfave/cli/v2"
 )
 
-type ValidateMacaroonRequest struct {
-	Macaroon   string            `json:"macaroon"`
-	Conditions map[string]string `json:"conditions"`
-}
+// ValidateMacaroonRequest is the request body for the validate macaroon
+// endpoint.
+type ValidateMacaroonRequest = fewsats.ValidateMacaroonRequest
 
 var validateCommand = &cli.Command{
 	Name:   "validate",
@@ -62,35 +61,20 @@ func validateMacaroon(c *cli.Context) error {
 		return cli.Exit("Failed to create HTTP client.", 1)
 	}
 
-	reqBody, err := json.Marshal(req)
-	if err != nil {
-		return cli.Exit(fmt.Sprintf("Failed to marshal request: %v", err), 1)
-	}
-
-	resp, err := client.ExecuteRequest(http.MethodPost, "/v0/macaroon/validate", reqBody)
-	if err != nil {
-		slog.Debug("Failed to execute request.", "error", err)
-		return cli.Exit("Failed to execute request.", 1)
-	}
-	defer resp.Body.Close()
+	message, err := client.API().Macaroons.Validate(c.Context, &req)
 
-	if resp.StatusCode != http.StatusOK {
-		var errorResponse struct {
-			Error string `json:"error"`
-		}
-		if err := json.NewDecoder(resp.Body).Decode(&errorResponse); err != nil {
-			return cli.Exit(fmt.Sprintf("Failed to decode error response: %v", err), 1)
-		}
-		return cli.Exit(fmt.Sprintf("Failed to validate macaroon. %s", errorResponse.Error), 1)
-	}
+	var apiErr *fewsats.APIError
+	switch {
+	case errors.As(err, &apiErr) && apiErr.StatusCode < 500:
+		return cli.Exit(
+			fmt.Sprintf("Failed to validate macaroon. %s", apiErr.Message), 1,
+		)
 
-	var response struct {
-		Message string `json:"message"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return cli.Exit(fmt.Sprintf("Failed to decode response: %v", err), 1)
+	case err != nil:
+		slog.Debug("Failed to validate macaroon.", "error", err)
+		return cli.Exit(fmt.Sprintf("Failed to validate macaroon: %v", err), 1)
 	}
 
-	fmt.Println("Validation successful:", response.Message)
+	fmt.Println("Validation successful:", message)
 	return nil
 }
@@ -0,0 +1,143 @@
+package payments
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+// csvHeader is the header of the CSV export.
+var csvHeader = []string{
+	"id", "created_at", "url", "host", "amount_sats", "fee_sats",
+	"payment_hash", "preimage", "wallet_id", "status", "error",
+	"duration_ms",
+}
+
+var exportCommand = &cli.Command{
+	Name:  "export",
+	Usage: "Export the L402 payments as JSON or CSV.",
+	Flags: []cli.Flag{
+		sinceFlag,
+		&cli.BoolFlag{
+			Name:  "csv",
+			Usage: "Export the payments as CSV instead of JSON",
+		},
+		&cli.StringFlag{
+			Name:    "output",
+			Aliases: []string{"o"},
+			Usage:   "Write the payments to the given file instead of stdout",
+		},
+	},
+	Action: exportPayments,
+}
+
+// exportPayments writes all the payments since the --since flag, oldest
+// first.
+func exportPayments(c *cli.Context) error {
+	store, ok := c.App.Metadata["store"].(Store)
+	if !ok {
+		return errors.New("failed to get store from context")
+	}
+
+	since, err := parseSince(c.String("since"), time.Now().UTC())
+	if err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+
+	payments, err := store.ListPayments(since, 0)
+	if err != nil {
+		slog.Debug("Failed to list payments.", "error", err)
+		return cli.Exit("failed to list payments", 1)
+	}
+
+	// Exports read better in chronological order.
+	for i, j := 0, len(payments)-1; i < j; i, j = i+1, j-1 {
+		payments[i], payments[j] = payments[j], payments[i]
+	}
+
+	var w io.Writer = os.Stdout
+	if output := c.String("output"); output != "" {
+		f, err := os.OpenFile(
+			output, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600,
+		)
+		if err != nil {
+			slog.Debug("Failed to create export file.", "error", err)
+			return cli.Exit("failed to create export file", 1)
+		}
+		defer f.Close()
+
+		w = f
+	}
+
+	if c.Bool("csv") {
+		err = WriteCSV(w, payments)
+	} else {
+		err = writeJSON(w, payments)
+	}
+	if err != nil {
+		slog.Debug("Failed to export payments.", "error", err)
+		return cli.Exit("failed to export payments", 1)
+	}
+
+	return nil
+}
+
+// WriteCSV writes the payments as CSV, with a header row.
+func WriteCSV(w io.Writer, payments []*Payment) error {
+	csvWriter := csv.NewWriter(w)
+
+	err := csvWriter.Write(csvHeader)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range payments {
+		err := csvWriter.Write([]string{
+			strconv.FormatInt(p.ID, 10),
+			p.CreatedAt.UTC().Format(time.RFC3339),
+			p.URL,
+			p.Host,
+			strconv.FormatUint(p.AmountSats, 10),
+			strconv.FormatUint(p.FeeSats, 10),
+			p.PaymentHash,
+			p.Preimage,
+			strconv.FormatUint(p.WalletID, 10),
+			string(p.Status),
+			p.Error,
+			strconv.FormatInt(p.DurationMs, 10),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	csvWriter.Flush()
+
+	return csvWriter.Error()
+}
+
+// writeJSON writes the payments as an indented JSON document.
+func writeJSON(w io.Writer, payments []*Payment) error {
+	response := struct {
+		Payments []*Payment `json:"payments"`
+	}{
+		Payments: payments,
+	}
+
+	jsonOutput, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	_, err = fmt.Fprintln(w, string(jsonOutput))
+
+	return err
+}
@@ -0,0 +1,59 @@
+package payments
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+var listCommand = &cli.Command{
+	Name:  "list",
+	Usage: "List the latest L402 payments.",
+	Flags: []cli.Flag{
+		sinceFlag,
+		&cli.IntFlag{
+			Name:  "limit",
+			Usage: "Limit the number of results",
+			Value: 20,
+		},
+	},
+	Action: listPayments,
+}
+
+// listPayments prints the latest payments.
+func listPayments(c *cli.Context) error {
+	store, ok := c.App.Metadata["store"].(Store)
+	if !ok {
+		return errors.New("failed to get store from context")
+	}
+
+	since, err := parseSince(c.String("since"), time.Now().UTC())
+	if err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+
+	payments, err := store.ListPayments(since, c.Int("limit"))
+	if err != nil {
+		slog.Debug("Failed to list payments.", "error", err)
+		return cli.Exit("failed to list payments", 1)
+	}
+
+	response := struct {
+		Payments []*Payment `json:"payments"`
+	}{
+		Payments: payments,
+	}
+
+	jsonOutput, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		return cli.Exit("failed to marshal JSON", 1)
+	}
+
+	fmt.Println(string(jsonOutput))
+
+	return nil
+}
@@ -0,0 +1,182 @@
+package payments
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Status is the outcome of a payment attempt.
+type Status string
+
+const (
+	// StatusSucceeded is the status of the paid invoices.
+	StatusSucceeded Status = "succeeded"
+
+	// StatusFailed is the status of the invoices the wallet failed to pay.
+	StatusFailed Status = "failed"
+
+	// StatusPaidInvalidPreimage is the status of the invoices the wallet
+	// paid but whose preimage does not match their payment hash. The
+	// money is spent even if the credentials can not be used.
+	StatusPaidInvalidPreimage Status = "paid_invalid_preimage"
+)
+
+const (
+	// GroupByHost groups the payments by the host of the L402 resource.
+	GroupByHost = "host"
+
+	// GroupByDay groups the payments by their UTC day.
+	GroupByDay = "day"
+
+	// GroupByWallet groups the payments by the wallet used to pay.
+	GroupByWallet = "wallet"
+)
+
+// Payment is an L402 invoice payment attempt.
+type Payment struct {
+	ID          int64     `db:"id" json:"id"`
+	URL         string    `db:"url" json:"url"`
+	Host        string    `db:"host" json:"host"`
+	AmountSats  uint64    `db:"amount_sats" json:"amount_sats"`
+	FeeSats     uint64    `db:"fee_sats" json:"fee_sats"`
+	PaymentHash string    `db:"payment_hash" json:"payment_hash"`
+	Preimage    string    `db:"preimage" json:"preimage,omitempty"`
+	WalletID    uint64    `db:"wallet_id" json:"wallet_id"`
+	Status      Status    `db:"status" json:"status"`
+	Error       string    `db:"error" json:"error,omitempty"`
+	DurationMs  int64     `db:"duration_ms" json:"duration_ms"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+}
+
+// Ledger records the payment attempts.
+type Ledger interface {
+	// InsertPayment records a payment attempt.
+	InsertPayment(payment *Payment) error
+}
+
+// Store is the interface that defines the methods that a payments store
+// should implement.
+type Store interface {
+	Ledger
+
+	// ListPayments returns the payments attempted since the given time,
+	// newest first. A zero limit returns all of them.
+	ListPayments(since time.Time, limit int) ([]*Payment, error)
+}
+
+// Group is the spend of a group of payments.
+type Group struct {
+	Key             string `json:"key"`
+	Payments        int    `json:"payments"`
+	Failed          int    `json:"failed"`
+	InvalidPreimage int    `json:"invalid_preimage"`
+	AmountSats      uint64 `json:"amount_sats"`
+	FeeSats         uint64 `json:"fee_sats"`
+}
+
+// Paid returns true if the payment spent its amount, even if the preimage
+// returned by the wallet was invalid.
+func (p *Payment) Paid() bool {
+	return p.Status == StatusSucceeded ||
+		p.Status == StatusPaidInvalidPreimage
+}
+
+// Command creates the payments command.
+func Command() *cli.Command {
+	return &cli.Command{
+		Name:  "payments",
+		Usage: "Inspect the ledger of L402 payments.",
+		Subcommands: []*cli.Command{
+			listCommand,
+			summaryCommand,
+			exportCommand,
+			pendingCommand,
+			recoverCommand,
+		},
+	}
+}
+
+// Summarize groups the payments by host, day or wallet. Only the paid
+// payments, including the ones with an invalid preimage, count towards the
+// amount and the fees, failed ones are counted apart. Groups are sorted by
+// key.
+func Summarize(payments []*Payment, by string) ([]Group, error) {
+	groups := make(map[string]*Group)
+	for _, p := range payments {
+		var key string
+		switch by {
+		case GroupByHost:
+			key = p.Host
+
+		case GroupByDay:
+			key = p.CreatedAt.UTC().Format(time.DateOnly)
+
+		case GroupByWallet:
+			key = strconv.FormatUint(p.WalletID, 10)
+
+		default:
+			return nil, fmt.Errorf("unknown group %q, use one of: %s, %s, "+
+				"%s", by, GroupByHost, GroupByDay, GroupByWallet)
+		}
+
+		group, ok := groups[key]
+		if !ok {
+			group = &Group{Key: key}
+			groups[key] = group
+		}
+
+		if !p.Paid() {
+			group.Failed++
+			continue
+		}
+
+		if p.Status == StatusPaidInvalidPreimage {
+			group.InvalidPreimage++
+		}
+
+		group.Payments++
+		group.AmountSats += p.AmountSats
+		group.FeeSats += p.FeeSats
+	}
+
+	result := make([]Group, 0, len(groups))
+	for _, group := range groups {
+		result = append(result, *group)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Key < result[j].Key
+	})
+
+	return result, nil
+}
+
+// parseSince parses the --since flag, either a duration back from now (like
+// 24h) or a date (like 2024-01-31). An empty value means the beginning of
+// times.
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --since %q, use a duration "+
+			"(720h) or a date (2006-01-02)", value)
+	}
+
+	return t, nil
+}
+
+// sinceFlag is the flag used to filter the payments by date.
+var sinceFlag = &cli.StringFlag{
+	Name:  "since",
+	Usage: "Only payments since this duration ago (720h) or date (2006-01-02)",
+}
@@ -0,0 +1,177 @@
+package payments
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fewsats/fewsatscli/wallets"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummarize(t *testing.T) {
+	day1 := time.Date(2024, 1, 1, 23, 0, 0, 0, time.UTC)
+	day2 := time.Date(2024, 1, 2, 1, 0, 0, 0, time.UTC)
+
+	payments := []*Payment{
+		{Host: "a.com", AmountSats: 10, WalletID: 1, Status: StatusSucceeded, CreatedAt: day1},
+		{Host: "a.com", AmountSats: 20, WalletID: 2, Status: StatusSucceeded, CreatedAt: day2},
+		{Host: "b.com", AmountSats: 5, WalletID: 1, Status: StatusSucceeded, CreatedAt: day2},
+		{Host: "b.com", AmountSats: 100, WalletID: 1, Status: StatusFailed, CreatedAt: day2},
+		{Host: "b.com", AmountSats: 7, FeeSats: 1, WalletID: 2, Status: StatusPaidInvalidPreimage, CreatedAt: day2},
+		{Host: "a.com", AmountSats: 1, FeeSats: 2, WalletID: 1, Status: StatusSucceeded, CreatedAt: day1},
+	}
+
+	tests := []struct {
+		by     string
+		groups []Group
+	}{
+		{
+			by: GroupByHost,
+			groups: []Group{
+				{Key: "a.com", Payments: 3, AmountSats: 31, FeeSats: 2},
+				{Key: "b.com", Payments: 2, Failed: 1, InvalidPreimage: 1, AmountSats: 12, FeeSats: 1},
+			},
+		},
+		{
+			by: GroupByDay,
+			groups: []Group{
+				{Key: "2024-01-01", Payments: 2, AmountSats: 11, FeeSats: 2},
+				{Key: "2024-01-02", Payments: 3, Failed: 1, InvalidPreimage: 1, AmountSats: 32, FeeSats: 1},
+			},
+		},
+		{
+			by: GroupByWallet,
+			groups: []Group{
+				{Key: "1", Payments: 3, Failed: 1, AmountSats: 16, FeeSats: 2},
+				{Key: "2", Payments: 2, InvalidPreimage: 1, AmountSats: 27, FeeSats: 1},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.by, func(t *testing.T) {
+			groups, err := Summarize(payments, tc.by)
+			require.NoError(t, err)
+			require.Equal(t, tc.groups, groups)
+		})
+	}
+
+	_, err := Summarize(payments, "month")
+	require.ErrorContains(t, err, "unknown group")
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC)
+
+	since, err := parseSince("", now)
+	require.NoError(t, err)
+	require.True(t, since.IsZero())
+
+	since, err = parseSince("24h", now)
+	require.NoError(t, err)
+	require.Equal(t, now.Add(-24*time.Hour), since)
+
+	since, err = parseSince("2024-01-15", now)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), since)
+
+	_, err = parseSince("last week", now)
+	require.ErrorContains(t, err, "invalid --since")
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteCSV(&buf, []*Payment{{
+		ID:          1,
+		URL:         "https://a.com/x?a=1,2",
+		Host:        "a.com",
+		AmountSats:  10,
+		FeeSats:     1,
+		PaymentHash: "hash",
+		Preimage:    "preimage",
+		WalletID:    2,
+		Status:      StatusSucceeded,
+		DurationMs:  150,
+		CreatedAt:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}})
+	require.NoError(t, err)
+
+	require.Equal(t, "id,created_at,url,host,amount_sats,fee_sats,"+
+		"payment_hash,preimage,wallet_id,status,error,duration_ms\n"+
+		"1,2024-01-01T00:00:00Z,\"https://a.com/x?a=1,2\",a.com,10,1,"+
+		"hash,preimage,2,succeeded,,150\n", buf.String())
+}
+
+// lookupWallet is a wallet returning a fixed payment status.
+type lookupWallet struct {
+	status *wallets.PaymentStatus
+	err    error
+}
+
+func (w *lookupWallet) GetPreimage(string) (string, error) {
+	return "", errors.New("not implemented")
+}
+
+func (w *lookupWallet) LookupPayment(context.Context,
+	string) (*wallets.PaymentStatus, error) {
+	return w.status, w.err
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name      string
+		wallet    wallets.PreimageProvider
+		preimage  string
+		expectErr error
+	}{
+		{
+			name: "Succeeded",
+			wallet: &lookupWallet{status: &wallets.PaymentStatus{
+				State:    wallets.PaymentSucceeded,
+				Preimage: "preimage",
+			}},
+			preimage: "preimage",
+		},
+		{
+			name: "In flight",
+			wallet: &lookupWallet{status: &wallets.PaymentStatus{
+				State: wallets.PaymentInFlight,
+			}},
+			expectErr: ErrPaymentInFlight,
+		},
+		{
+			name: "Failed",
+			wallet: &lookupWallet{status: &wallets.PaymentStatus{
+				State: wallets.PaymentFailed,
+			}},
+			expectErr: ErrPaymentNotSent,
+		},
+		{
+			name:      "Never sent",
+			wallet:    &lookupWallet{err: wallets.ErrPaymentNotFound},
+			expectErr: ErrPaymentNotSent,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			preimage, err := Resolve(context.Background(), tc.wallet, "hash")
+			if tc.expectErr != nil {
+				require.ErrorIs(t, err, tc.expectErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.preimage, preimage)
+		})
+	}
+
+	// Wallets unable to look up payments can not resolve them.
+	_, err := Resolve(
+		context.Background(), &wallets.DevWallet{}, "hash",
+	)
+	require.ErrorContains(t, err, "can not look up payments")
+}
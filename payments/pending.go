@@ -0,0 +1,140 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/fewsats/fewsatscli/credentials"
+	"github.com/fewsats/fewsatscli/wallets"
+)
+
+var (
+	// ErrNoPendingPayment is the error returned when there is no pending
+	// payment for a payment hash.
+	ErrNoPendingPayment = errors.New("no pending payment found")
+
+	// ErrPaymentInFlight is the error returned when a pending payment is
+	// not settled yet, paying again could pay twice.
+	ErrPaymentInFlight = errors.New("payment still in flight")
+
+	// ErrPaymentNotSent is the error returned when a pending payment
+	// failed or was never sent by the wallet, it is safe to pay again.
+	ErrPaymentNotSent = errors.New("payment failed or never sent")
+)
+
+// Pending is a payment started but not known to be settled. It is stored
+// before calling the wallet so a payment interrupted after the wallet paid
+// the invoice is recovered instead of paid a second time.
+type Pending struct {
+	PaymentHash string    `db:"payment_hash" json:"payment_hash"`
+	URL         string    `db:"url" json:"url"`
+	Macaroon    string    `db:"macaroon" json:"macaroon"`
+	AuthScheme  string    `db:"auth_scheme" json:"auth_scheme"`
+	Invoice     string    `db:"invoice" json:"invoice"`
+	AmountSats  uint64    `db:"amount_sats" json:"amount_sats"`
+	WalletID    uint64    `db:"wallet_id" json:"wallet_id"`
+	DecisionID  int64     `db:"decision_id" json:"decision_id,omitempty"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+
+	// WalletPaymentID is the ID the wallet returned when paying, set by
+	// the wallets looking up the payments by their own ID.
+	WalletPaymentID string `db:"wallet_payment_id" json:"wallet_payment_id"`
+}
+
+// PendingStore persists the pending payments.
+type PendingStore interface {
+	// InsertPendingPayment stores the pending payment, keeping the
+	// existing one if its payment hash is already pending.
+	InsertPendingPayment(pending *Pending) error
+
+	// ListPendingPayments returns all the pending payments, oldest first.
+	ListPendingPayments() ([]*Pending, error)
+
+	// DeletePendingPayment removes the pending payment with the given
+	// payment hash.
+	DeletePendingPayment(paymentHash string) error
+
+	// SettlePendingPayment removes the pending payment with the given
+	// payment hash once its invoice is known to be paid, and marks its
+	// spending decision as paid so it counts towards the budgets.
+	SettlePendingPayment(paymentHash string) error
+}
+
+// Scope returns the scope of the resource the payment is for.
+func (p *Pending) Scope() (credentials.Scope, error) {
+	u, err := url.Parse(p.URL)
+	if err != nil {
+		return credentials.Scope{}, fmt.Errorf("invalid pending payment "+
+			"url: %w", err)
+	}
+
+	return credentials.NewScope(u), nil
+}
+
+// Credentials returns the L402 credentials bought by the payment, once the
+// preimage is known. The preimage is verified against the macaroon.
+func (p *Pending) Credentials(
+	preimage string) (*credentials.L402Credentials, error) {
+
+	scope, err := p.Scope()
+	if err != nil {
+		return nil, err
+	}
+
+	creds := &credentials.L402Credentials{
+		Macaroon:   p.Macaroon,
+		Invoice:    p.Invoice,
+		Preimage:   strings.ToLower(preimage),
+		AuthScheme: p.AuthScheme,
+	}
+
+	scope.Location = credentials.MacaroonLocation(p.Macaroon)
+	creds.SetScope(scope)
+
+	if expiry, ok := credentials.MacaroonExpiry(p.Macaroon); ok {
+		creds.ExpiresAt = &expiry
+	}
+
+	err = creds.VerifyPreimage(creds.Preimage)
+	if err != nil {
+		return nil, err
+	}
+
+	return creds, nil
+}
+
+// Resolve looks up the pending payment with the wallet and returns its
+// preimage if the invoice was paid. ErrPaymentInFlight is returned if the
+// payment is not settled yet and ErrPaymentNotSent if it failed or was never
+// sent. The wallet must implement wallets.PaymentLookup.
+func Resolve(ctx context.Context, wallet wallets.PreimageProvider,
+	paymentHash string) (string, error) {
+
+	lookup, ok := wallet.(wallets.PaymentLookup)
+	if !ok {
+		return "", errors.New("the wallet can not look up payments")
+	}
+
+	status, err := lookup.LookupPayment(ctx, paymentHash)
+	switch {
+	case errors.Is(err, wallets.ErrPaymentNotFound):
+		return "", ErrPaymentNotSent
+
+	case err != nil:
+		return "", fmt.Errorf("unable to look up payment: %w", err)
+	}
+
+	switch status.State {
+	case wallets.PaymentSucceeded:
+		return status.Preimage, nil
+
+	case wallets.PaymentFailed:
+		return "", ErrPaymentNotSent
+	}
+
+	return "", ErrPaymentInFlight
+}
@@ -0,0 +1,268 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+
+	"github.com/fewsats/fewsatscli/credentials"
+	"github.com/fewsats/fewsatscli/wallets"
+	"github.com/urfave/cli/v2"
+)
+
+// recoverStore is the store needed by the recover command.
+type recoverStore interface {
+	Ledger
+	PendingStore
+	credentials.Store
+	wallets.Store
+}
+
+// Recovery is the outcome of the recovery of a pending payment.
+type Recovery struct {
+	PaymentHash string `json:"payment_hash"`
+	URL         string `json:"url"`
+	Outcome     string `json:"outcome"`
+	Error       string `json:"error,omitempty"`
+}
+
+var pendingCommand = &cli.Command{
+	Name:   "pending",
+	Usage:  "List the payments interrupted before their outcome was known.",
+	Action: listPending,
+}
+
+var recoverCommand = &cli.Command{
+	Name: "recover",
+	Usage: "Look up the pending payments in the wallet and save the " +
+		"credentials of the paid ones.",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "payment-hash",
+			Usage: "Only recover the pending payment with this payment hash",
+		},
+		&cli.StringFlag{
+			Name: "preimage",
+			Usage: "The preimage of the payment, as shown by the wallet, " +
+				"for the wallets that can not look up their payments. " +
+				"Requires --payment-hash",
+		},
+		&cli.BoolFlag{
+			Name: "failed",
+			Usage: "Drop the payment as not paid, for the wallets that " +
+				"can not look up their payments. Requires --payment-hash",
+		},
+	},
+	Action: recoverPending,
+}
+
+// resolveFunc returns the preimage of the pending payment if it was paid,
+// like Resolve.
+type resolveFunc func(ctx context.Context, p *Pending) (string, error)
+
+// listPending prints the pending payments.
+func listPending(c *cli.Context) error {
+	store, ok := c.App.Metadata["store"].(PendingStore)
+	if !ok {
+		return errors.New("failed to get store from context")
+	}
+
+	pending, err := store.ListPendingPayments()
+	if err != nil {
+		slog.Debug("Failed to list pending payments.", "error", err)
+		return cli.Exit("failed to list pending payments", 1)
+	}
+
+	response := struct {
+		Pending []*Pending `json:"pending"`
+	}{
+		Pending: pending,
+	}
+
+	jsonOutput, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		return cli.Exit("failed to marshal JSON", 1)
+	}
+
+	fmt.Println(string(jsonOutput))
+
+	return nil
+}
+
+// recoverPending resolves every pending payment with the wallet used to pay
+// it and prints the outcomes.
+func recoverPending(c *cli.Context) error {
+	store, ok := c.App.Metadata["store"].(recoverStore)
+	if !ok {
+		return errors.New("failed to get store from context")
+	}
+
+	paymentHash := strings.ToLower(c.String("payment-hash"))
+	preimage := c.String("preimage")
+
+	// By default the payments are looked up in the wallet used to pay
+	// them, the user can resolve them otherwise.
+	resolve := func(ctx context.Context, p *Pending) (string, error) {
+		wallet, err := pendingWallet(store, p)
+		if err != nil {
+			return "", fmt.Errorf("unable to get wallet: %w", err)
+		}
+
+		return Resolve(ctx, wallet, p.PaymentHash)
+	}
+	switch {
+	case (preimage != "" || c.Bool("failed")) && paymentHash == "":
+		return cli.Exit("--preimage and --failed require --payment-hash", 1)
+
+	case preimage != "" && c.Bool("failed"):
+		return cli.Exit("only one of --preimage and --failed can be used", 1)
+
+	case preimage != "":
+		resolve = func(context.Context, *Pending) (string, error) {
+			return preimage, nil
+		}
+
+	case c.Bool("failed"):
+		resolve = func(context.Context, *Pending) (string, error) {
+			return "", fmt.Errorf("%w: dropped by the user",
+				ErrPaymentNotSent)
+		}
+	}
+
+	pending, err := store.ListPendingPayments()
+	if err != nil {
+		slog.Debug("Failed to list pending payments.", "error", err)
+		return cli.Exit("failed to list pending payments", 1)
+	}
+
+	recoveries := make([]Recovery, 0, len(pending))
+	for _, p := range pending {
+		if paymentHash != "" && p.PaymentHash != paymentHash {
+			continue
+		}
+
+		recoveries = append(
+			recoveries, recoverPayment(c.Context, store, p, resolve),
+		)
+	}
+
+	if paymentHash != "" && len(recoveries) == 0 {
+		return cli.Exit(fmt.Sprintf("no pending payment with payment "+
+			"hash %s", paymentHash), 1)
+	}
+
+	response := struct {
+		Recovered []Recovery `json:"recovered"`
+	}{
+		Recovered: recoveries,
+	}
+
+	jsonOutput, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		return cli.Exit("failed to marshal JSON", 1)
+	}
+
+	fmt.Println(string(jsonOutput))
+
+	return nil
+}
+
+// pendingWallet returns the wallet used to pay the pending payment, the
+// default wallet if it is unknown.
+func pendingWallet(store wallets.Store,
+	p *Pending) (wallets.PreimageProvider, error) {
+
+	if p.WalletID != 0 {
+		return wallets.GetWallet(store, p.WalletID)
+	}
+
+	return wallets.GetDefaultWallet(store)
+}
+
+// recoverPayment resolves the pending payment. If the invoice was paid the
+// credentials are saved, the payments that failed or were never sent are
+// dropped, both are recorded in the ledger. A paid invoice whose preimage
+// does not unlock the macaroon is recorded as paid with an invalid preimage.
+// Payments still in flight, or that can not be looked up, are kept pending.
+func recoverPayment(ctx context.Context, store recoverStore, p *Pending,
+	resolve resolveFunc) Recovery {
+
+	recovery := Recovery{
+		PaymentHash: p.PaymentHash,
+		URL:         p.URL,
+		Outcome:     "pending",
+	}
+
+	record := &Payment{
+		URL:         p.URL,
+		AmountSats:  p.AmountSats,
+		PaymentHash: p.PaymentHash,
+		WalletID:    p.WalletID,
+		CreatedAt:   p.CreatedAt,
+	}
+	if u, err := url.Parse(p.URL); err == nil {
+		record.Host = strings.ToLower(u.Hostname())
+	}
+
+	preimage, err := resolve(ctx, p)
+	switch {
+	case errors.Is(err, ErrPaymentNotSent):
+		recovery.Outcome = string(StatusFailed)
+		record.Status = StatusFailed
+		record.Error = err.Error()
+
+	case err != nil:
+		recovery.Error = err.Error()
+		return recovery
+
+	default:
+		creds, err := p.Credentials(preimage)
+		if errors.Is(err, credentials.ErrPreimageMismatch) {
+			recovery.Outcome = string(StatusPaidInvalidPreimage)
+			recovery.Error = err.Error()
+			record.Status = StatusPaidInvalidPreimage
+			record.Preimage = strings.ToLower(preimage)
+			record.Error = err.Error()
+
+			break
+		}
+		if err != nil {
+			recovery.Error = err.Error()
+			return recovery
+		}
+
+		err = store.InsertL402Credentials(creds)
+		if err != nil {
+			recovery.Error = fmt.Sprintf("unable to save L402 "+
+				"credentials: %v", err)
+			return recovery
+		}
+
+		recovery.Outcome = string(StatusSucceeded)
+		record.Status = StatusSucceeded
+		record.Preimage = creds.Preimage
+	}
+
+	err = store.InsertPayment(record)
+	if err != nil {
+		slog.Debug("Failed to record payment.", "error", err,
+			"payment_hash", p.PaymentHash)
+	}
+
+	// The paid invoices count towards the budgets.
+	if record.Paid() {
+		err = store.SettlePendingPayment(p.PaymentHash)
+	} else {
+		err = store.DeletePendingPayment(p.PaymentHash)
+	}
+	if err != nil {
+		slog.Debug("Failed to delete pending payment.", "error", err,
+			"payment_hash", p.PaymentHash)
+	}
+
+	return recovery
+}
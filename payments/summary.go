@@ -0,0 +1,79 @@
+package payments
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+var summaryCommand = &cli.Command{
+	Name:  "summary",
+	Usage: "Summarize the L402 spend by host, day or wallet.",
+	Flags: []cli.Flag{
+		sinceFlag,
+		&cli.StringFlag{
+			Name: "by",
+			Usage: fmt.Sprintf("Group the payments by: {%s, %s, %s}",
+				GroupByHost, GroupByDay, GroupByWallet),
+			Value: GroupByHost,
+		},
+	},
+	Action: summarizePayments,
+}
+
+// summarizePayments prints the spend grouped by the --by flag.
+func summarizePayments(c *cli.Context) error {
+	store, ok := c.App.Metadata["store"].(Store)
+	if !ok {
+		return errors.New("failed to get store from context")
+	}
+
+	since, err := parseSince(c.String("since"), time.Now().UTC())
+	if err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+
+	payments, err := store.ListPayments(since, 0)
+	if err != nil {
+		slog.Debug("Failed to list payments.", "error", err)
+		return cli.Exit("failed to list payments", 1)
+	}
+
+	groups, err := Summarize(payments, c.String("by"))
+	if err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+
+	response := struct {
+		By              string  `json:"by"`
+		Payments        int     `json:"payments"`
+		Failed          int     `json:"failed"`
+		InvalidPreimage int     `json:"invalid_preimage"`
+		AmountSats      uint64  `json:"amount_sats"`
+		FeeSats         uint64  `json:"fee_sats"`
+		Groups          []Group `json:"groups"`
+	}{
+		By:     c.String("by"),
+		Groups: groups,
+	}
+	for _, group := range groups {
+		response.Payments += group.Payments
+		response.Failed += group.Failed
+		response.InvalidPreimage += group.InvalidPreimage
+		response.AmountSats += group.AmountSats
+		response.FeeSats += group.FeeSats
+	}
+
+	jsonOutput, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		return cli.Exit("failed to marshal JSON", 1)
+	}
+
+	fmt.Println(string(jsonOutput))
+
+	return nil
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
-	"net/http"
 
 	"github.com/fewsats/fewsatscli/client"
 	"github.com/urfave/cli/v2"
@@ -28,23 +27,16 @@ func listPayouts(c *cli.Context) error {
 		return cli.Exit("Failed to create HTTP client.", 1)
 	}
 
-	resp, err := client.ExecuteRequest(http.MethodGet, "/v0/payouts", nil)
+	payouts, err := client.API().Payouts.List(c.Context)
 	if err != nil {
-		slog.Debug("Failed to execute request.", "error", err)
-		return cli.Exit("Failed to execute request.", 1)
+		slog.Debug("Failed to list payouts.", "error", err)
+		return cli.Exit(fmt.Sprintf("Failed to list payouts: %v", err), 1)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return cli.Exit(fmt.Sprintf("Failed request status code: %d", resp.StatusCode), 1)
-	}
-
-	var response struct {
+	response := struct {
 		Payouts []Payout `json:"payouts"`
-	}
-	err = json.NewDecoder(resp.Body).Decode(&response)
-	if err != nil {
-		return cli.Exit("Failed to decode payouts.", 1)
+	}{
+		Payouts: payouts,
 	}
 
 	jsonOutput, err := json.MarshalIndent(response, "", "  ")
@@ -1,16 +1,12 @@
 package payout
 
 import (
+	"github.com/fewsats/fewsatscli/fewsats"
 	"github.com/urfave/cli/v2"
 )
 
-type Payout struct {
-	ID          uint64 `json:"id"`
-	TotalAmount uint64 `json:"total_amount"`
-	Currency    string `json:"currency"`
-	Status      string `json:"status"`
-	CreatedAt   string `json:"created_at"`
-}
+// Payout is a payment of the user earnings.
+type Payout = fewsats.Payout
 
 func Command() *cli.Command {
 	return &cli.Command{
@@ -0,0 +1,84 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+var allowCommand = &cli.Command{
+	Name:      "allow",
+	Usage:     "Pay invoices from a host automatically up to a ceiling.",
+	ArgsUsage: "<host>",
+	Flags: []cli.Flag{
+		&cli.Uint64Flag{
+			Name:     "max-price",
+			Usage:    "The ceiling (sats) for a single payment to the host",
+			Required: true,
+		},
+	},
+	Action: allowHost,
+}
+
+var disallowCommand = &cli.Command{
+	Name:      "disallow",
+	Usage:     "Remove a host from the allowlist.",
+	ArgsUsage: "<host>",
+	Action:    disallowHost,
+}
+
+// allowHost adds or updates a host in the allowlist.
+func allowHost(c *cli.Context) error {
+	store, ok := c.App.Metadata["store"].(Store)
+	if !ok {
+		return errors.New("failed to get store from context")
+	}
+
+	if c.Args().Len() < 1 {
+		return cli.Exit("missing <host> argument", 1)
+	}
+
+	rule := &HostRule{
+		Host:         strings.ToLower(c.Args().Get(0)),
+		MaxPriceSats: c.Uint64("max-price"),
+		CreatedAt:    time.Now().UTC(),
+	}
+
+	err := store.UpsertHostRule(rule)
+	if err != nil {
+		slog.Debug("Failed to allowlist host.", "error", err)
+		return cli.Exit("failed to allowlist host", 1)
+	}
+
+	fmt.Printf("Host %s allowlisted up to %d sats.\n", rule.Host,
+		rule.MaxPriceSats)
+
+	return nil
+}
+
+// disallowHost removes a host from the allowlist.
+func disallowHost(c *cli.Context) error {
+	store, ok := c.App.Metadata["store"].(Store)
+	if !ok {
+		return errors.New("failed to get store from context")
+	}
+
+	if c.Args().Len() < 1 {
+		return cli.Exit("missing <host> argument", 1)
+	}
+
+	host := strings.ToLower(c.Args().Get(0))
+	err := store.DeleteHostRule(host)
+	if err != nil {
+		slog.Debug("Failed to remove host from allowlist.", "error", err)
+		return cli.Exit("failed to remove host from allowlist", 1)
+	}
+
+	fmt.Printf("Host %s removed from the allowlist.\n", host)
+
+	return nil
+}
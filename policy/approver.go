@@ -0,0 +1,181 @@
+package policy
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fewsats/fewsatscli/l402"
+	"golang.org/x/term"
+)
+
+// PromptFunc asks the user to approve a payment the spending policy could
+// not decide on its own. It returns the final action and its reason.
+type PromptFunc func(payment *l402.Payment) (Action, string, error)
+
+// Approver is an l402.Approver that checks the payments against a spending
+// policy engine, asking the user when the policy requires it. Every decision
+// is recorded in the engine store.
+type Approver struct {
+	engine *Engine
+
+	// prompt asks the user to approve a payment, if nil the payments that
+	// need approval are refused.
+	prompt PromptFunc
+
+	// decisions holds the decisions of the payments in flight.
+	decisions map[*l402.Payment]*Decision
+	mu        sync.Mutex
+}
+
+// A compile time check to ensure Approver implements l402.Approver.
+var _ l402.Approver = (*Approver)(nil)
+
+// NewApprover creates a new Approver backed by the given spending policy
+// engine. A nil prompt refuses the payments that need approval.
+func NewApprover(engine *Engine, prompt PromptFunc) *Approver {
+	return &Approver{
+		engine:    engine,
+		prompt:    prompt,
+		decisions: make(map[*l402.Payment]*Decision),
+	}
+}
+
+// SetPrompt replaces the function used to ask the user, a nil prompt refuses
+// the payments that need approval.
+func (a *Approver) SetPrompt(prompt PromptFunc) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.prompt = prompt
+}
+
+// Approve checks the payment against the spending policy and records the
+// final decision. An error wrapping ErrPaymentRefused is returned if
+// the invoice must not be paid.
+func (a *Approver) Approve(_ *http.Request, payment *l402.Payment) error {
+	decision, err := a.engine.Evaluate(payment.URL, payment.AmountSats)
+	if err != nil {
+		return fmt.Errorf("unable to evaluate spending policy: %w", err)
+	}
+
+	a.mu.Lock()
+	prompt := a.prompt
+	a.mu.Unlock()
+
+	switch {
+	case decision.Action == ActionPrompt && prompt == nil:
+		decision.Action = ActionRefuse
+		decision.Reason = "approval required but prompts are disabled"
+
+	case decision.Action == ActionPrompt:
+		decision.Action, decision.Reason, err = prompt(payment)
+		if err != nil {
+			a.engine.Release(decision)
+			return err
+		}
+	}
+
+	slog.Debug(
+		"Spending policy decision",
+		"url", payment.URL,
+		"amount", payment.AmountSats,
+		"action", decision.Action,
+		"reason", decision.Reason,
+	)
+
+	err = a.engine.Record(decision)
+	if err != nil {
+		a.engine.Release(decision)
+		return err
+	}
+
+	if decision.Action != ActionPay {
+		a.engine.Release(decision)
+		return fmt.Errorf("%w: %s", ErrPaymentRefused,
+			decision.Reason)
+	}
+
+	a.mu.Lock()
+	a.decisions[payment] = decision
+	a.mu.Unlock()
+
+	// The decision is settled from the pending payment if the payment is
+	// recovered later.
+	payment.DecisionID = decision.ID
+
+	return nil
+}
+
+// Settle marks the decision as paid or releases its reserved budget.
+func (a *Approver) Settle(payment *l402.Payment, err error) {
+	a.mu.Lock()
+	decision, ok := a.decisions[payment]
+	delete(a.decisions, payment)
+	a.mu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	if err != nil {
+		a.engine.Release(decision)
+		return
+	}
+
+	err = a.engine.MarkPaid(decision)
+	if err != nil {
+		slog.Debug("Failed to mark spending decision as paid.",
+			"error", err)
+	}
+}
+
+// TerminalPrompt asks the user to approve the payment in the terminal. The
+// payment is refused without asking if stdin is not a terminal so scripts
+// never hang.
+func TerminalPrompt(payment *l402.Payment) (Action, string, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return ActionRefuse, "approval required but stdin is not " +
+			"a terminal", nil
+	}
+
+	fmt.Printf("URL: %s\n", payment.URL)
+	fmt.Printf("Lightning invoice price: %d sats\n", payment.AmountSats)
+	if d := payment.Details; d != nil {
+		if d.Amountless {
+			fmt.Println("The invoice has no amount, the price above " +
+				"is chosen by you")
+		}
+		fmt.Printf("Payee node: %s\n", d.Payee)
+		switch {
+		case d.Description != "":
+			fmt.Printf("Description: %s\n", d.Description)
+
+		case d.DescriptionHash != "":
+			fmt.Printf("Description hash: %s\n", d.DescriptionHash)
+		}
+		fmt.Printf("Expires: %s (in %s)\n",
+			d.ExpiresAt.Local().Format(time.RFC1123),
+			time.Until(d.ExpiresAt).Round(time.Second))
+	}
+	fmt.Print("Do you want to continue? (y/N): ")
+
+	input, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", "", fmt.Errorf("unable to read user input: %w", err)
+	}
+
+	input = strings.TrimSpace(input)
+	if input != "Y" && input != "y" {
+		return ActionRefuse, "rejected by the user", nil
+	}
+
+	return ActionPay, "approved by the user", nil
+}
@@ -0,0 +1,251 @@
+package policy
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fewsats/fewsatscli/fewsatstest"
+	"github.com/fewsats/fewsatscli/l402"
+	"github.com/fewsats/fewsatscli/payments"
+	"github.com/fewsats/fewsatscli/wallets"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/macaroon.v2"
+)
+
+// payingWallet pays every invoice with a fixed preimage.
+type payingWallet struct {
+	preimage string
+}
+
+func (w *payingWallet) GetPreimage(string) (string, error) {
+	return w.preimage, nil
+}
+
+// lookupWallet fails to pay every invoice and looks up the payments with the
+// given status.
+type lookupWallet struct {
+	status wallets.PaymentStatus
+}
+
+func (w *lookupWallet) GetPreimage(string) (string, error) {
+	return "", errors.New("timeout")
+}
+
+func (w *lookupWallet) LookupPayment(context.Context,
+	string) (*wallets.PaymentStatus, error) {
+
+	status := w.status
+	return &status, nil
+}
+
+// memPending is an in-memory pending payments store marking the decisions of
+// the settled payments as paid in the given spending policy store.
+type memPending struct {
+	store   *memStore
+	pending map[string]*payments.Pending
+	mu      sync.Mutex
+}
+
+func (m *memPending) InsertPendingPayment(pending *payments.Pending) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.pending == nil {
+		m.pending = make(map[string]*payments.Pending)
+	}
+	m.pending[pending.PaymentHash] = pending
+
+	return nil
+}
+
+func (m *memPending) ListPendingPayments() ([]*payments.Pending, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make([]*payments.Pending, 0, len(m.pending))
+	for _, pending := range m.pending {
+		result = append(result, pending)
+	}
+
+	return result, nil
+}
+
+func (m *memPending) DeletePendingPayment(paymentHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.pending[paymentHash]; !ok {
+		return payments.ErrNoPendingPayment
+	}
+	delete(m.pending, paymentHash)
+
+	return nil
+}
+
+func (m *memPending) SettlePendingPayment(paymentHash string) error {
+	m.mu.Lock()
+	pending, ok := m.pending[paymentHash]
+	delete(m.pending, paymentHash)
+	m.mu.Unlock()
+
+	switch {
+	case !ok:
+		return payments.ErrNoPendingPayment
+
+	case pending.DecisionID == 0:
+		return nil
+	}
+
+	return m.store.MarkSpendingDecisionPaid(pending.DecisionID)
+}
+
+// newL402Server returns a server asking for an L402 payment of the given
+// amount on every request without credentials. The preimage of the invoice
+// is returned with the server.
+func newL402Server(t *testing.T, amountSats uint64) (*httptest.Server,
+	string) {
+
+	t.Helper()
+
+	srv, err := fewsatstest.New(fewsatstest.Config{})
+	require.NoError(t, err)
+
+	invoice, preimage, err := srv.CreateInvoice(amountSats, "test")
+	require.NoError(t, err)
+
+	preimageBytes, err := hex.DecodeString(preimage)
+	require.NoError(t, err)
+	paymentHash := sha256.Sum256(preimageBytes)
+
+	var id bytes.Buffer
+	require.NoError(t, binary.Write(&id, binary.BigEndian, uint16(0)))
+	id.Write(paymentHash[:])
+	id.Write(bytes.Repeat([]byte{1}, 32))
+
+	mac, err := macaroon.New(
+		[]byte("root-key"), id.Bytes(), "fewsats", macaroon.LatestVersion,
+	)
+	require.NoError(t, err)
+	macBytes, err := mac.MarshalBinary()
+	require.NoError(t, err)
+
+	challenge := fmt.Sprintf(`L402 macaroon="%s", invoice="%s"`,
+		base64.StdEncoding.EncodeToString(macBytes), invoice)
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "" {
+				return
+			}
+
+			w.Header().Set("WWW-Authenticate", challenge)
+			w.WriteHeader(http.StatusPaymentRequired)
+		},
+	))
+	t.Cleanup(server.Close)
+
+	return server, preimage
+}
+
+func TestApproverCancelledAfterApproval(t *testing.T) {
+	server, _ := newL402Server(t, 10)
+
+	engine := NewEngine(&memStore{})
+	engine.SetSessionBudget(100)
+
+	// The request is cancelled while the user approves the payment.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	approver := NewApprover(engine, func(*l402.Payment) (Action, string,
+		error) {
+
+		cancel()
+		return ActionPay, "approved by the user", nil
+	})
+
+	client := &http.Client{
+		Transport: &l402.Transport{
+			Wallet:   &payingWallet{preimage: "00"},
+			Approver: approver,
+		},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		server.URL, nil)
+	require.NoError(t, err)
+
+	_, err = client.Do(req)
+	require.ErrorIs(t, err, context.Canceled)
+
+	// Nothing was paid, the reservation is released.
+	require.Zero(t, engine.inFlightSats)
+	require.Zero(t, engine.sessionSpentSats)
+	require.Empty(t, approver.decisions)
+}
+
+func TestApproverRecoveredPayment(t *testing.T) {
+	server, preimage := newL402Server(t, 10)
+
+	store := &memStore{}
+	pending := &memPending{store: store}
+	approve := func(*l402.Payment) (Action, string, error) {
+		return ActionPay, "approved by the user", nil
+	}
+
+	get := func(transport *l402.Transport) error {
+		client := &http.Client{Transport: transport}
+
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		return nil
+	}
+
+	// The payment is still in flight when the first process gives up, it
+	// is left pending.
+	engine := NewEngine(store)
+	err := get(&l402.Transport{
+		Wallet: &lookupWallet{
+			status: wallets.PaymentStatus{State: wallets.PaymentInFlight},
+		},
+		Approver: NewApprover(engine, approve),
+		Pending:  pending,
+	})
+	require.ErrorIs(t, err, payments.ErrPaymentInFlight)
+	require.Len(t, pending.pending, 1)
+
+	// A later process recovers the paid invoice, its decision counts
+	// towards the budgets.
+	engine = NewEngine(store)
+	err = get(&l402.Transport{
+		Wallet: &lookupWallet{
+			status: wallets.PaymentStatus{
+				State:    wallets.PaymentSucceeded,
+				Preimage: preimage,
+			},
+		},
+		Approver: NewApprover(engine, approve),
+		Pending:  pending,
+	})
+	require.NoError(t, err)
+	require.Empty(t, pending.pending)
+
+	spent, err := engine.GetSpent(time.Now())
+	require.NoError(t, err)
+	require.EqualValues(t, 10, spent.Daily)
+}
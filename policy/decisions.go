@@ -0,0 +1,52 @@
+package policy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/urfave/cli/v2"
+)
+
+var decisionsCommand = &cli.Command{
+	Name:  "decisions",
+	Usage: "List the latest spending policy decisions.",
+	Flags: []cli.Flag{
+		&cli.IntFlag{
+			Name:  "limit",
+			Usage: "Limit the number of results",
+			Value: 20,
+		},
+	},
+	Action: listDecisions,
+}
+
+// listDecisions prints the latest recorded spending decisions.
+func listDecisions(c *cli.Context) error {
+	store, ok := c.App.Metadata["store"].(Store)
+	if !ok {
+		return errors.New("failed to get store from context")
+	}
+
+	decisions, err := store.ListSpendingDecisions(c.Int("limit"))
+	if err != nil {
+		slog.Debug("Failed to list spending decisions.", "error", err)
+		return cli.Exit("failed to list spending decisions", 1)
+	}
+
+	response := struct {
+		Decisions []Decision `json:"decisions"`
+	}{
+		Decisions: decisions,
+	}
+
+	jsonOutput, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		return cli.Exit("failed to marshal JSON", 1)
+	}
+
+	fmt.Println(string(jsonOutput))
+
+	return nil
+}
@@ -0,0 +1,317 @@
+package policy
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// dayWindow is the rolling window used for the daily budget.
+	dayWindow = 24 * time.Hour
+
+	// weekWindow is the rolling window used for the weekly budget.
+	weekWindow = 7 * dayWindow
+
+	// monthWindow is the rolling window used for the monthly budget.
+	monthWindow = 30 * dayWindow
+)
+
+// Spent holds the amount paid in each of the rolling budget windows.
+type Spent struct {
+	Daily   uint64 `json:"daily"`
+	Weekly  uint64 `json:"weekly"`
+	Monthly uint64 `json:"monthly"`
+}
+
+// Engine evaluates L402 payments against the spending policy.
+type Engine struct {
+	store Store
+
+	// maxPriceSats is the price ceiling for the current call, usually set
+	// with the --max-price flag. Invoices up to this price are paid without
+	// asking the user.
+	maxPriceSats uint64
+
+	// sessionBudgetSats caps the amount paid through this engine, used by
+	// long running processes like the proxy. Zero means no cap.
+	sessionBudgetSats uint64
+
+	// sessionSpentSats is the amount paid or reserved by in flight payments
+	// through this engine.
+	sessionSpentSats uint64
+
+	// inFlightSats is the amount reserved by the payments in flight, not
+	// counted by the store yet. It is added to the rolling budgets.
+	inFlightSats uint64
+
+	mu sync.Mutex
+}
+
+// NewEngine creates a new spending policy engine backed by the given store.
+func NewEngine(store Store) *Engine {
+	return &Engine{
+		store: store,
+	}
+}
+
+// SetMaxPrice sets the price ceiling for the current call.
+func (e *Engine) SetMaxPrice(sats uint64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.maxPriceSats = sats
+}
+
+// SetSessionBudget caps the amount paid through this engine.
+func (e *Engine) SetSessionBudget(sats uint64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.sessionBudgetSats = sats
+}
+
+// GetSpent returns the amount paid in each rolling budget window.
+func (e *Engine) GetSpent(now time.Time) (*Spent, error) {
+	var (
+		spent Spent
+		err   error
+	)
+
+	spent.Daily, err = e.store.GetSpentSince(now.Add(-dayWindow))
+	if err != nil {
+		return nil, fmt.Errorf("unable to get daily spent: %w", err)
+	}
+
+	spent.Weekly, err = e.store.GetSpentSince(now.Add(-weekWindow))
+	if err != nil {
+		return nil, fmt.Errorf("unable to get weekly spent: %w", err)
+	}
+
+	spent.Monthly, err = e.store.GetSpentSince(now.Add(-monthWindow))
+	if err != nil {
+		return nil, fmt.Errorf("unable to get monthly spent: %w", err)
+	}
+
+	return &spent, nil
+}
+
+// Evaluate decides whether an invoice of the given amount for the given
+// resource can be paid. The returned decision is not recorded, callers must
+// call Record once the final action is known.
+func (e *Engine) Evaluate(resourceURL string, amountSats uint64) (*Decision,
+	error) {
+
+	u, err := url.Parse(resourceURL)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse url: %w", err)
+	}
+	host := strings.ToLower(u.Hostname())
+
+	policy, err := e.store.GetSpendingPolicy()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get spending policy: %w", err)
+	}
+
+	rules, err := e.store.ListHostRules()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get allowlisted hosts: %w", err)
+	}
+
+	// The spent amounts are read and the decision reserved under the lock
+	// so concurrent payments can not exceed the budgets together.
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := time.Now().UTC()
+	spent, err := e.GetSpent(now)
+	if err != nil {
+		return nil, err
+	}
+
+	// The payments in flight are not counted by the store yet.
+	spent.Daily += e.inFlightSats
+	spent.Weekly += e.inFlightSats
+	spent.Monthly += e.inFlightSats
+
+	rule := matchHostRule(rules, host)
+	action, reason := evaluate(
+		policy, rule, e.maxPriceSats, *spent, amountSats,
+	)
+
+	decision := &Decision{
+		URL:        resourceURL,
+		Host:       host,
+		AmountSats: amountSats,
+		Action:     action,
+		Reason:     reason,
+		CreatedAt:  now,
+	}
+
+	if action != ActionRefuse {
+		e.reserve(decision)
+	}
+
+	return decision, nil
+}
+
+// reserve sets aside the decision amount from the budgets so concurrent
+// payments can not exceed them. The decision is refused if the session budget
+// is exhausted. The caller must hold e.mu.
+func (e *Engine) reserve(decision *Decision) {
+	if e.sessionBudgetSats != 0 &&
+		e.sessionSpentSats+decision.AmountSats > e.sessionBudgetSats {
+
+		decision.Action = ActionRefuse
+		decision.Reason = fmt.Sprintf("price %d sats exceeds the session "+
+			"budget (%d of %d sats spent)", decision.AmountSats,
+			e.sessionSpentSats, e.sessionBudgetSats)
+
+		return
+	}
+
+	e.sessionSpentSats += decision.AmountSats
+	e.inFlightSats += decision.AmountSats
+	decision.reserved = true
+}
+
+// Release gives back the amount reserved by a decision that did not end up
+// being paid.
+func (e *Engine) Release(decision *Decision) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !decision.reserved || decision.Paid {
+		return
+	}
+
+	e.sessionSpentSats -= decision.AmountSats
+	e.inFlightSats -= decision.AmountSats
+	decision.reserved = false
+}
+
+// Record stores the decision in the database.
+func (e *Engine) Record(decision *Decision) error {
+	err := e.store.InsertSpendingDecision(decision)
+	if err != nil {
+		return fmt.Errorf("unable to record spending decision: %w", err)
+	}
+
+	return nil
+}
+
+// MarkPaid flags the decision as paid so it counts towards the budgets.
+func (e *Engine) MarkPaid(decision *Decision) error {
+	err := e.store.MarkSpendingDecisionPaid(decision.ID)
+	if err != nil {
+		return fmt.Errorf("unable to mark spending decision as paid: %w",
+			err)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	// The store counts the amount from now on, it stays reserved from
+	// the session budget.
+	if decision.reserved && !decision.Paid {
+		e.inFlightSats -= decision.AmountSats
+	}
+	decision.Paid = true
+
+	return nil
+}
+
+// evaluate applies the spending rules in order of precedence: the per call
+// ceiling, the rolling budgets, the global max price, the host allowlist and
+// finally the auto approve threshold. Anything else needs the user approval.
+// The ceiling of an allowlisted host never raises the global max price.
+func evaluate(policy *Policy, rule *HostRule, maxPriceSats uint64,
+	spent Spent, amountSats uint64) (Action, string) {
+
+	if maxPriceSats != 0 && amountSats > maxPriceSats {
+		return ActionRefuse, fmt.Sprintf("price %d sats exceeds "+
+			"--max-price %d sats", amountSats, maxPriceSats)
+	}
+
+	budgets := []struct {
+		name   string
+		budget uint64
+		spent  uint64
+	}{
+		{"daily", policy.DailyBudgetSats, spent.Daily},
+		{"weekly", policy.WeeklyBudgetSats, spent.Weekly},
+		{"monthly", policy.MonthlyBudgetSats, spent.Monthly},
+	}
+	for _, b := range budgets {
+		if b.budget != 0 && b.spent+amountSats > b.budget {
+			return ActionRefuse, fmt.Sprintf("price %d sats exceeds "+
+				"the %s budget (%d of %d sats spent)", amountSats,
+				b.name, b.spent, b.budget)
+		}
+	}
+
+	if policy.MaxPriceSats != 0 && amountSats > policy.MaxPriceSats {
+		return ActionRefuse, fmt.Sprintf("price %d sats exceeds the max "+
+			"price of %d sats", amountSats, policy.MaxPriceSats)
+	}
+
+	if rule != nil {
+		if amountSats > rule.MaxPriceSats {
+			return ActionRefuse, fmt.Sprintf("price %d sats exceeds "+
+				"the %s ceiling of %d sats", amountSats, rule.Host,
+				rule.MaxPriceSats)
+		}
+
+		return ActionPay, fmt.Sprintf("host %s allowlisted up to %d sats",
+			rule.Host, rule.MaxPriceSats)
+	}
+
+	if maxPriceSats != 0 {
+		return ActionPay, fmt.Sprintf("price within --max-price %d sats",
+			maxPriceSats)
+	}
+
+	if amountSats <= policy.AutoApproveSats {
+		return ActionPay, fmt.Sprintf("price within the auto approve "+
+			"threshold of %d sats", policy.AutoApproveSats)
+	}
+
+	return ActionPrompt, "price above the auto approve threshold"
+}
+
+// matchHostRule returns the most specific rule matching the host: an exact
+// match wins over wildcards and longer wildcards win over shorter ones.
+func matchHostRule(rules []HostRule, host string) *HostRule {
+	var (
+		best      *HostRule
+		bestScore int
+	)
+	for i := range rules {
+		pattern := strings.ToLower(rules[i].Host)
+
+		var score int
+		switch {
+		case pattern == host:
+			// Exact matches always win over wildcards.
+			score = len(pattern) + 1<<16
+
+		case strings.HasPrefix(pattern, "*."):
+			suffix := pattern[1:]
+			if !strings.HasSuffix(host, suffix) {
+				continue
+			}
+			score = len(suffix)
+
+		default:
+			continue
+		}
+
+		if best == nil || score > bestScore {
+			best, bestScore = &rules[i], score
+		}
+	}
+
+	return best
+}
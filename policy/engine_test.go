@@ -0,0 +1,300 @@
+package policy
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// memStore is an in-memory spending policy store for tests.
+type memStore struct {
+	policy    Policy
+	decisions []*Decision
+	mu        sync.Mutex
+}
+
+func (m *memStore) GetSpendingPolicy() (*Policy, error) {
+	p := m.policy
+	return &p, nil
+}
+
+func (m *memStore) SetSpendingPolicy(policy *Policy) error {
+	m.policy = *policy
+	return nil
+}
+
+func (m *memStore) ListHostRules() ([]HostRule, error) {
+	return nil, nil
+}
+
+func (m *memStore) UpsertHostRule(*HostRule) error {
+	return nil
+}
+
+func (m *memStore) DeleteHostRule(string) error {
+	return nil
+}
+
+func (m *memStore) InsertSpendingDecision(decision *Decision) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	decision.ID = int64(len(m.decisions) + 1)
+	stored := *decision
+	m.decisions = append(m.decisions, &stored)
+
+	return nil
+}
+
+func (m *memStore) MarkSpendingDecisionPaid(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.decisions[id-1].Paid = true
+
+	return nil
+}
+
+func (m *memStore) ListSpendingDecisions(int) ([]Decision, error) {
+	return nil, nil
+}
+
+func (m *memStore) GetSpentSince(since time.Time) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var spent uint64
+	for _, d := range m.decisions {
+		if d.Paid && !d.CreatedAt.Before(since) {
+			spent += d.AmountSats
+		}
+	}
+
+	return spent, nil
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   Policy
+		rule     *HostRule
+		maxPrice uint64
+		spent    Spent
+		amount   uint64
+		action   Action
+		reason   string
+	}{
+		{
+			name:   "Empty policy prompts",
+			amount: 10,
+			action: ActionPrompt,
+		},
+		{
+			name:   "Within auto approve threshold",
+			policy: Policy{AutoApproveSats: 10},
+			amount: 10,
+			action: ActionPay,
+			reason: "auto approve",
+		},
+		{
+			name:   "Above auto approve threshold",
+			policy: Policy{AutoApproveSats: 10},
+			amount: 11,
+			action: ActionPrompt,
+		},
+		{
+			name:   "Above global max price",
+			policy: Policy{AutoApproveSats: 10, MaxPriceSats: 100},
+			amount: 101,
+			action: ActionRefuse,
+			reason: "max price",
+		},
+		{
+			name:     "Within per call max price",
+			maxPrice: 50,
+			amount:   50,
+			action:   ActionPay,
+			reason:   "--max-price",
+		},
+		{
+			name:     "Above per call max price",
+			policy:   Policy{AutoApproveSats: 100},
+			maxPrice: 50,
+			amount:   51,
+			action:   ActionRefuse,
+			reason:   "--max-price",
+		},
+		{
+			name:   "Allowlisted host within ceiling",
+			policy: Policy{MaxPriceSats: 1000},
+			rule:   &HostRule{Host: "api.example.com", MaxPriceSats: 500},
+			amount: 400,
+			action: ActionPay,
+			reason: "allowlisted",
+		},
+		{
+			name:   "Allowlisted host above global max price",
+			policy: Policy{MaxPriceSats: 10},
+			rule:   &HostRule{Host: "api.example.com", MaxPriceSats: 500},
+			amount: 400,
+			action: ActionRefuse,
+			reason: "max price",
+		},
+		{
+			name:   "Allowlisted host above ceiling",
+			policy: Policy{AutoApproveSats: 1000},
+			rule:   &HostRule{Host: "api.example.com", MaxPriceSats: 500},
+			amount: 501,
+			action: ActionRefuse,
+			reason: "api.example.com ceiling",
+		},
+		{
+			name:   "Daily budget exceeded",
+			policy: Policy{AutoApproveSats: 100, DailyBudgetSats: 100},
+			spent:  Spent{Daily: 95, Weekly: 95, Monthly: 95},
+			amount: 10,
+			action: ActionRefuse,
+			reason: "daily budget",
+		},
+		{
+			name:   "Monthly budget exceeded by allowlisted host",
+			policy: Policy{MonthlyBudgetSats: 1000},
+			rule:   &HostRule{Host: "api.example.com", MaxPriceSats: 500},
+			spent:  Spent{Daily: 0, Weekly: 0, Monthly: 900},
+			amount: 200,
+			action: ActionRefuse,
+			reason: "monthly budget",
+		},
+		{
+			name:   "Budget exactly reached",
+			policy: Policy{AutoApproveSats: 100, WeeklyBudgetSats: 100},
+			spent:  Spent{Weekly: 90},
+			amount: 10,
+			action: ActionPay,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			action, reason := evaluate(
+				&tc.policy, tc.rule, tc.maxPrice, tc.spent, tc.amount,
+			)
+			require.Equal(t, tc.action, action)
+			require.Contains(t, reason, tc.reason)
+		})
+	}
+}
+
+func TestMatchHostRule(t *testing.T) {
+	rules := []HostRule{
+		{Host: "*.example.com", MaxPriceSats: 1},
+		{Host: "*.api.example.com", MaxPriceSats: 2},
+		{Host: "api.example.com", MaxPriceSats: 3},
+	}
+
+	tests := []struct {
+		host     string
+		maxPrice uint64
+		noMatch  bool
+	}{
+		{host: "api.example.com", maxPrice: 3},
+		{host: "v1.api.example.com", maxPrice: 2},
+		{host: "www.example.com", maxPrice: 1},
+		{host: "example.com", noMatch: true},
+		{host: "example.org", noMatch: true},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.host, func(t *testing.T) {
+			rule := matchHostRule(rules, tc.host)
+			if tc.noMatch {
+				require.Nil(t, rule)
+				return
+			}
+
+			require.NotNil(t, rule)
+			require.Equal(t, tc.maxPrice, rule.MaxPriceSats)
+		})
+	}
+}
+
+func TestEngineConcurrentBudgets(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy Policy
+		budget uint64
+	}{
+		{
+			name:   "session budget",
+			policy: Policy{AutoApproveSats: 10},
+			budget: 30,
+		},
+		{
+			name:   "daily budget",
+			policy: Policy{AutoApproveSats: 10, DailyBudgetSats: 30},
+		},
+		{
+			name:   "monthly budget",
+			policy: Policy{AutoApproveSats: 10, MonthlyBudgetSats: 30},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			store := &memStore{policy: tc.policy}
+			engine := NewEngine(store)
+			engine.SetSessionBudget(tc.budget)
+
+			// Ten payments of 10 sats are evaluated at once, before
+			// any of them is paid.
+			var (
+				wg        sync.WaitGroup
+				decisions = make([]*Decision, 10)
+			)
+			for i := range decisions {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+
+					d, err := engine.Evaluate(
+						"https://api.example.com/v1/data", 10,
+					)
+					require.NoError(t, err)
+					decisions[i] = d
+				}(i)
+			}
+			wg.Wait()
+
+			var approved []*Decision
+			for _, d := range decisions {
+				if d.Action == ActionPay {
+					approved = append(approved, d)
+				}
+			}
+			require.Len(t, approved, 3)
+
+			// Paying the reserved amounts keeps the budget exhausted,
+			// releasing one frees its amount.
+			for _, d := range approved[:2] {
+				require.NoError(t, engine.Record(d))
+				require.NoError(t, engine.MarkPaid(d))
+			}
+
+			d, err := engine.Evaluate("https://api.example.com/v1/data", 10)
+			require.NoError(t, err)
+			require.Equal(t, ActionRefuse, d.Action)
+			require.Contains(t, d.Reason, "budget")
+
+			engine.Release(approved[2])
+
+			d, err = engine.Evaluate("https://api.example.com/v1/data", 10)
+			require.NoError(t, err)
+			require.Equal(t, ActionPay, d.Action)
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package policy
+
+import (
+	"errors"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Action is the outcome of evaluating a payment against the spending policy.
+type Action string
+
+const (
+	// ActionPay means the invoice can be paid without asking the user.
+	ActionPay Action = "pay"
+
+	// ActionPrompt means the user has to approve the payment.
+	ActionPrompt Action = "prompt"
+
+	// ActionRefuse means the invoice must not be paid.
+	ActionRefuse Action = "refuse"
+)
+
+var (
+	// ErrPaymentRefused is the error returned when the spending policy does
+	// not allow paying an invoice.
+	ErrPaymentRefused = errors.New("payment refused by spending policy")
+)
+
+// Policy holds the spending limits used to decide if an invoice is paid. A
+// zero value means the limit is not set.
+type Policy struct {
+	// AutoApproveSats is the price up to which invoices are paid without
+	// asking the user.
+	AutoApproveSats uint64 `db:"auto_approve_sats" json:"auto_approve_sats"`
+
+	// MaxPriceSats is the price above which invoices are always refused.
+	MaxPriceSats uint64 `db:"max_price_sats" json:"max_price_sats"`
+
+	// DailyBudgetSats is the maximum amount spent in the last 24 hours.
+	DailyBudgetSats uint64 `db:"daily_budget_sats" json:"daily_budget_sats"`
+
+	// WeeklyBudgetSats is the maximum amount spent in the last 7 days.
+	WeeklyBudgetSats uint64 `db:"weekly_budget_sats" json:"weekly_budget_sats"`
+
+	// MonthlyBudgetSats is the maximum amount spent in the last 30 days.
+	MonthlyBudgetSats uint64 `db:"monthly_budget_sats" json:"monthly_budget_sats"`
+}
+
+// HostRule allowlists a host so its invoices are paid automatically up to
+// its own ceiling.
+type HostRule struct {
+	// Host is the allowlisted host name. A `*.` prefix matches any
+	// subdomain.
+	Host string `db:"host" json:"host"`
+
+	// MaxPriceSats is the ceiling for a single payment to the host.
+	MaxPriceSats uint64 `db:"max_price_sats" json:"max_price_sats"`
+
+	// CreatedAt is the time the host was allowlisted.
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// Decision is a recorded spending policy decision.
+type Decision struct {
+	// ID is the unique identifier of the decision in the local database.
+	ID int64 `db:"id" json:"id"`
+
+	// URL is the URL of the L402 resource.
+	URL string `db:"url" json:"url"`
+
+	// Host is the host of the L402 resource.
+	Host string `db:"host" json:"host"`
+
+	// AmountSats is the price of the invoice.
+	AmountSats uint64 `db:"amount_sats" json:"amount_sats"`
+
+	// Action is the decision taken.
+	Action Action `db:"action" json:"action"`
+
+	// Reason is a human readable explanation of the decision.
+	Reason string `db:"reason" json:"reason"`
+
+	// Paid is true once the invoice has been paid.
+	Paid bool `db:"paid" json:"paid"`
+
+	// CreatedAt is the time the decision was taken.
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+
+	// reserved is true while the amount is set aside from the session
+	// and rolling budgets.
+	reserved bool
+}
+
+// Store is the interface that defines the methods that a spending policy
+// store should implement.
+type Store interface {
+	// GetSpendingPolicy retrieves the spending policy.
+	GetSpendingPolicy() (*Policy, error)
+	// SetSpendingPolicy replaces the spending policy.
+	SetSpendingPolicy(policy *Policy) error
+
+	// ListHostRules retrieves all the allowlisted hosts.
+	ListHostRules() ([]HostRule, error)
+	// UpsertHostRule inserts or updates an allowlisted host.
+	UpsertHostRule(rule *HostRule) error
+	// DeleteHostRule removes a host from the allowlist.
+	DeleteHostRule(host string) error
+
+	// InsertSpendingDecision records a spending decision.
+	InsertSpendingDecision(decision *Decision) error
+	// MarkSpendingDecisionPaid flags the decision as paid.
+	MarkSpendingDecisionPaid(id int64) error
+	// ListSpendingDecisions retrieves the latest spending decisions.
+	ListSpendingDecisions(limit int) ([]Decision, error)
+	// GetSpentSince returns the amount paid since the given time.
+	GetSpentSince(since time.Time) (uint64, error)
+}
+
+// Command creates the policy command with subcommands.
+func Command() *cli.Command {
+	return &cli.Command{
+		Name:  "policy",
+		Usage: "Manage the spending policy for L402 payments.",
+		Subcommands: []*cli.Command{
+			showCommand,
+			setCommand,
+			allowCommand,
+			disallowCommand,
+			decisionsCommand,
+		},
+	}
+}
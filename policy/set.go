@@ -0,0 +1,75 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/urfave/cli/v2"
+)
+
+var setCommand = &cli.Command{
+	Name:  "set",
+	Usage: "Update the spending limits, use 0 to remove a limit.",
+	Flags: []cli.Flag{
+		&cli.Uint64Flag{
+			Name:  "auto-approve",
+			Usage: "Pay invoices up to this price (sats) without asking",
+		},
+		&cli.Uint64Flag{
+			Name:  "max-price",
+			Usage: "Always refuse invoices above this price (sats)",
+		},
+		&cli.Uint64Flag{
+			Name:  "daily-budget",
+			Usage: "Maximum amount (sats) spent in the last 24 hours",
+		},
+		&cli.Uint64Flag{
+			Name:  "weekly-budget",
+			Usage: "Maximum amount (sats) spent in the last 7 days",
+		},
+		&cli.Uint64Flag{
+			Name:  "monthly-budget",
+			Usage: "Maximum amount (sats) spent in the last 30 days",
+		},
+	},
+	Action: setPolicy,
+}
+
+// setPolicy updates the spending limits given as flags, leaving the rest
+// untouched.
+func setPolicy(c *cli.Context) error {
+	store, ok := c.App.Metadata["store"].(Store)
+	if !ok {
+		return errors.New("failed to get store from context")
+	}
+
+	policy, err := store.GetSpendingPolicy()
+	if err != nil {
+		slog.Debug("Failed to get spending policy.", "error", err)
+		return cli.Exit("failed to get spending policy", 1)
+	}
+
+	limits := map[string]*uint64{
+		"auto-approve":   &policy.AutoApproveSats,
+		"max-price":      &policy.MaxPriceSats,
+		"daily-budget":   &policy.DailyBudgetSats,
+		"weekly-budget":  &policy.WeeklyBudgetSats,
+		"monthly-budget": &policy.MonthlyBudgetSats,
+	}
+	for flag, limit := range limits {
+		if c.IsSet(flag) {
+			*limit = c.Uint64(flag)
+		}
+	}
+
+	err = store.SetSpendingPolicy(policy)
+	if err != nil {
+		slog.Debug("Failed to set spending policy.", "error", err)
+		return cli.Exit("failed to set spending policy", 1)
+	}
+
+	fmt.Println("Spending policy updated successfully.")
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package policy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+var showCommand = &cli.Command{
+	Name:   "show",
+	Usage:  "Show the spending policy and the current spent amounts.",
+	Action: showPolicy,
+}
+
+// showPolicy prints the spending policy, the allowlisted hosts and the
+// amounts spent in each budget window.
+func showPolicy(c *cli.Context) error {
+	store, ok := c.App.Metadata["store"].(Store)
+	if !ok {
+		return errors.New("failed to get store from context")
+	}
+
+	policy, err := store.GetSpendingPolicy()
+	if err != nil {
+		slog.Debug("Failed to get spending policy.", "error", err)
+		return cli.Exit("failed to get spending policy", 1)
+	}
+
+	rules, err := store.ListHostRules()
+	if err != nil {
+		slog.Debug("Failed to get allowlisted hosts.", "error", err)
+		return cli.Exit("failed to get allowlisted hosts", 1)
+	}
+
+	spent, err := NewEngine(store).GetSpent(time.Now().UTC())
+	if err != nil {
+		slog.Debug("Failed to get spent amounts.", "error", err)
+		return cli.Exit("failed to get spent amounts", 1)
+	}
+
+	response := struct {
+		Policy *Policy    `json:"policy"`
+		Hosts  []HostRule `json:"hosts"`
+		Spent  *Spent     `json:"spent"`
+	}{
+		Policy: policy,
+		Hosts:  rules,
+		Spent:  spent,
+	}
+
+	jsonOutput, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		return cli.Exit("failed to marshal JSON", 1)
+	}
+
+	fmt.Println(string(jsonOutput))
+
+	return nil
+}
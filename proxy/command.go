@@ -0,0 +1,148 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/fewsats/fewsatscli/client"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	// defaultListenAddr is the default address the proxy listens on.
+	defaultListenAddr = "127.0.0.1:8402"
+
+	// shutdownTimeout is the time given to in flight requests to finish
+	// when the proxy is stopped.
+	shutdownTimeout = 30 * time.Second
+)
+
+// Command creates the proxy command.
+func Command() *cli.Command {
+	return &cli.Command{
+		Name:  "proxy",
+		Usage: "Run a local proxy that pays L402 challenges transparently.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "listen",
+				Value: defaultListenAddr,
+				Usage: "The address the proxy listens on",
+			},
+			&cli.StringFlag{
+				Name: "target",
+				Usage: "Run as a reverse proxy sending every request to " +
+					"this base URL, otherwise run as a forward proxy",
+			},
+			&cli.Uint64Flag{
+				Name:  "max-price",
+				Usage: "Pay invoices up to this price (sats), refuse above it",
+			},
+			&cli.Uint64Flag{
+				Name:  "budget",
+				Usage: "Maximum amount (sats) paid while the proxy is running",
+			},
+			&cli.StringFlag{
+				Name:  "access-log",
+				Usage: "File where the JSON access log is appended, stderr by default",
+			},
+		},
+		Action: runProxy,
+	}
+}
+
+// runProxy starts the proxy and blocks until it is interrupted.
+func runProxy(c *cli.Context) error {
+	var target *url.URL
+	if rawTarget := c.String("target"); rawTarget != "" {
+		var err error
+		target, err = url.ParseRequestURI(rawTarget)
+		if err != nil || target.Host == "" {
+			return cli.Exit(fmt.Sprintf("invalid target url: %s", rawTarget), 1)
+		}
+	}
+
+	var accessLogWriter io.Writer = os.Stderr
+	if path := c.String("access-log"); path != "" {
+		f, err := os.OpenFile(
+			path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600,
+		)
+		if err != nil {
+			slog.Debug("Failed to open access log.", "error", err)
+			return cli.Exit("failed to open access log", 1)
+		}
+		defer f.Close()
+
+		accessLogWriter = f
+	}
+	accessLog := slog.New(slog.NewJSONHandler(accessLogWriter, nil))
+
+	httpClient, err := client.NewHTTPClient()
+	if err != nil {
+		slog.Debug("Failed to create http client.", "error", err)
+		return cli.Exit("failed to create http client", 1)
+	}
+
+	// Nobody can answer a prompt while proxying, the spending policy and
+	// the flags decide on their own.
+	httpClient.DisablePrompt()
+	if c.IsSet("max-price") {
+		httpClient.SetMaxPrice(c.Uint64("max-price"))
+	}
+	if c.IsSet("budget") {
+		httpClient.SetSessionBudget(c.Uint64("budget"))
+	}
+
+	server := &http.Server{
+		Addr:              c.String("listen"),
+		Handler:           NewProxy(httpClient, target, accessLog),
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(
+		c.Context, os.Interrupt, syscall.SIGTERM,
+	)
+	defer stop()
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.ListenAndServe()
+	}()
+
+	mode := "forward proxy"
+	if target != nil {
+		mode = fmt.Sprintf("reverse proxy for %s", target)
+	}
+	fmt.Printf("L402 %s listening on %s\n", mode, server.Addr)
+
+	select {
+	case err := <-errChan:
+		slog.Debug("Proxy stopped.", "error", err)
+		return cli.Exit(fmt.Sprintf("proxy stopped: %v", err), 1)
+
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(
+		context.Background(), shutdownTimeout,
+	)
+	defer cancel()
+
+	err = server.Shutdown(shutdownCtx)
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Debug("Failed to stop proxy.", "error", err)
+		return cli.Exit("failed to stop proxy", 1)
+	}
+
+	fmt.Println("Proxy stopped.")
+
+	return nil
+}
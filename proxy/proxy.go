@@ -0,0 +1,219 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/fewsats/fewsatscli/l402"
+	"github.com/fewsats/fewsatscli/policy"
+)
+
+// hopHeaders are the hop-by-hop headers that must not be forwarded by a
+// proxy (RFC 9110, section 7.6.1).
+var hopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// Client sends the requests to the upstream servers, paying their L402
+// challenges. *client.HttpClient implements it.
+type Client interface {
+	// DoL402Request sends the request handling any L402 challenge on the
+	// way.
+	DoL402Request(req *http.Request) (*http.Response, error)
+}
+
+// Proxy is an HTTP proxy that pays the L402 challenges of the upstream
+// servers on behalf of its clients.
+type Proxy struct {
+	// client is the L402 aware client used to reach the upstream servers.
+	client Client
+
+	// target is the upstream base URL when running as a reverse proxy. A
+	// nil target means the proxy runs as a forward proxy.
+	target *url.URL
+
+	// accessLog is the structured logger used for the access log.
+	accessLog *slog.Logger
+}
+
+// NewProxy creates a new L402 proxy. If target is nil the proxy works as a
+// forward proxy, otherwise every request is sent to the target.
+func NewProxy(httpClient Client, target *url.URL,
+	accessLog *slog.Logger) *Proxy {
+
+	return &Proxy{
+		client:    httpClient,
+		target:    target,
+		accessLog: accessLog,
+	}
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	var info l402.PaymentInfo
+	status, written, err := p.serve(w, r, &info)
+
+	attrs := []any{
+		"remote_addr", r.RemoteAddr,
+		"method", r.Method,
+		"url", p.upstreamURLString(r),
+		"status", status,
+		"bytes", written,
+		"duration_ms", time.Since(start).Milliseconds(),
+		"price_sats", info.AmountSats,
+		"paid", info.Paid,
+	}
+	if err != nil {
+		attrs = append(attrs, "error", err.Error())
+	}
+
+	p.accessLog.Info("request", attrs...)
+}
+
+// serve proxies the request and returns the status code and the number of
+// body bytes sent to the client.
+func (p *Proxy) serve(w http.ResponseWriter, r *http.Request,
+	info *l402.PaymentInfo) (int, int64, error) {
+
+	// The challenges of a tunneled HTTPS connection can not be seen, HTTPS
+	// upstreams need the reverse proxy mode.
+	if r.Method == http.MethodConnect {
+		err := errors.New("CONNECT is not supported: the L402 challenges " +
+			"of HTTPS upstreams can not be paid through a tunnel, run " +
+			"the proxy in reverse mode with --target https://<host>")
+		http.Error(w, err.Error(), http.StatusMethodNotAllowed)
+
+		return http.StatusMethodNotAllowed, 0, err
+	}
+
+	upstreamURL, err := p.upstreamURL(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return http.StatusBadRequest, 0, err
+	}
+
+	ctx := l402.WithPaymentInfo(r.Context(), info)
+	req, err := http.NewRequestWithContext(
+		ctx, r.Method, upstreamURL.String(), r.Body,
+	)
+	if err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return http.StatusBadRequest, 0, err
+	}
+
+	req.ContentLength = r.ContentLength
+	copyHeaders(req.Header, r.Header)
+	removeHopHeaders(req.Header)
+
+	if clientIP, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		req.Header.Add("X-Forwarded-For", clientIP)
+	}
+
+	resp, err := p.client.DoL402Request(req)
+	switch {
+	case errors.Is(err, policy.ErrPaymentRefused):
+		http.Error(w, err.Error(), http.StatusPaymentRequired)
+		return http.StatusPaymentRequired, 0, err
+
+	case err != nil:
+		http.Error(w, "unable to reach upstream server", http.StatusBadGateway)
+		return http.StatusBadGateway, 0, err
+	}
+	defer resp.Body.Close()
+
+	removeHopHeaders(resp.Header)
+	copyHeaders(w.Header(), resp.Header)
+	w.WriteHeader(resp.StatusCode)
+
+	written, err := io.Copy(w, resp.Body)
+	if err != nil {
+		return resp.StatusCode, written, fmt.Errorf("unable to copy "+
+			"response body: %w", err)
+	}
+
+	return resp.StatusCode, written, nil
+}
+
+// upstreamURL returns the URL the request has to be sent to.
+func (p *Proxy) upstreamURL(r *http.Request) (*url.URL, error) {
+	if p.target == nil {
+		if !r.URL.IsAbs() {
+			return nil, errors.New("forward proxy requests must use an " +
+				"absolute URL")
+		}
+
+		return r.URL, nil
+	}
+
+	u := *p.target
+	u.Path = singleJoiningSlash(p.target.Path, r.URL.Path)
+	u.RawPath = ""
+	u.RawQuery = r.URL.RawQuery
+
+	return &u, nil
+}
+
+// upstreamURLString returns the upstream URL for the access log.
+func (p *Proxy) upstreamURLString(r *http.Request) string {
+	u, err := p.upstreamURL(r)
+	if err != nil {
+		return r.URL.String()
+	}
+
+	return u.String()
+}
+
+// singleJoiningSlash joins two URL paths with a single slash.
+func singleJoiningSlash(a, b string) string {
+	aslash := strings.HasSuffix(a, "/")
+	bslash := strings.HasPrefix(b, "/")
+
+	switch {
+	case aslash && bslash:
+		return a + b[1:]
+	case !aslash && !bslash:
+		return a + "/" + b
+	}
+
+	return a + b
+}
+
+// copyHeaders copies all the headers from src into dst.
+func copyHeaders(dst, src http.Header) {
+	for name, values := range src {
+		for _, value := range values {
+			dst.Add(name, value)
+		}
+	}
+}
+
+// removeHopHeaders removes the hop-by-hop headers, including the ones listed
+// in the Connection header.
+func removeHopHeaders(header http.Header) {
+	for _, value := range header.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			header.Del(strings.TrimSpace(name))
+		}
+	}
+
+	for _, name := range hopHeaders {
+		header.Del(name)
+	}
+}
@@ -0,0 +1,321 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fewsats/fewsatscli/fewsats"
+	"github.com/fewsats/fewsatscli/fewsatstest"
+	"github.com/fewsats/fewsatscli/invoices"
+	"github.com/fewsats/fewsatscli/l402"
+	"github.com/fewsats/fewsatscli/policy"
+	"github.com/fewsats/fewsatscli/store"
+	"github.com/fewsats/fewsatscli/wallets"
+	"github.com/stretchr/testify/require"
+)
+
+// testClient sends the proxied requests with an L402 aware http.Client.
+type testClient struct {
+	client *http.Client
+}
+
+func (c *testClient) DoL402Request(req *http.Request) (*http.Response,
+	error) {
+
+	return c.client.Do(req)
+}
+
+// proxyTest holds a stand-in Fewsats API paying the gateways with a dev
+// wallet, and a proxy in front of it.
+type proxyTest struct {
+	api      *fewsats.Client
+	apiURL   *url.URL
+	store    *store.Store
+	engine   *policy.Engine
+	proxy    *httptest.Server
+	logMu    sync.Mutex
+	logLines bytes.Buffer
+}
+
+// newProxyTest starts the stand-in API and a proxy, a reverse proxy for the
+// API if reverse is set, a forward proxy otherwise.
+func newProxyTest(t *testing.T, reverse bool) *proxyTest {
+	t.Helper()
+
+	srv, err := fewsatstest.New(fewsatstest.Config{
+		Network: invoices.NetworkRegtest,
+	})
+	require.NoError(t, err)
+
+	apiServer := httptest.NewServer(srv)
+	t.Cleanup(apiServer.Close)
+
+	apiURL, err := url.Parse(apiServer.URL)
+	require.NoError(t, err)
+
+	db, err := store.NewStore(filepath.Join(t.TempDir(), "proxy.db"))
+	require.NoError(t, err)
+	require.NoError(t, db.RunMigrations())
+
+	wallet, err := wallets.NewDevWallet(wallets.DevConfig{
+		ServerURL: apiServer.URL,
+	})
+	require.NoError(t, err)
+
+	pt := &proxyTest{
+		api:    fewsats.NewClient(apiServer.URL, srv.Config().APIKey),
+		apiURL: apiURL,
+		store:  db,
+		engine: policy.NewEngine(db),
+	}
+
+	var target *url.URL
+	if reverse {
+		target = apiURL
+	}
+
+	accessLog := slog.New(slog.NewJSONHandler(
+		writerFunc(func(p []byte) (int, error) {
+			pt.logMu.Lock()
+			defer pt.logMu.Unlock()
+
+			return pt.logLines.Write(p)
+		}), nil,
+	))
+
+	client := &testClient{
+		client: &http.Client{
+			Transport: &l402.Transport{
+				Wallet:   wallet,
+				Approver: policy.NewApprover(pt.engine, nil),
+				Ledger:   db,
+				Network:  invoices.NetworkRegtest,
+			},
+		},
+	}
+
+	pt.proxy = httptest.NewServer(NewProxy(client, target, accessLog))
+	t.Cleanup(pt.proxy.Close)
+
+	return pt
+}
+
+// writerFunc is an adapter to use an ordinary function as an io.Writer.
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
+
+// newGateway creates a gateway to an upstream echoing the request path, for
+// the given price in cents.
+func (pt *proxyTest) newGateway(t *testing.T, priceCents uint64) string {
+	t.Helper()
+
+	upstream := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, "upstream %s", r.URL.Path)
+		},
+	))
+	t.Cleanup(upstream.Close)
+
+	gateway, err := pt.api.Gateways.Create(
+		context.Background(), &fewsats.CreateGatewayRequest{
+			Name:         "paid",
+			TargetURL:    upstream.URL,
+			PriceInCents: priceCents,
+		},
+	)
+	require.NoError(t, err)
+
+	return gateway.ExternalID
+}
+
+// get requests the resource of the gateway through the proxy.
+func (pt *proxyTest) get(t *testing.T, reverse bool,
+	gatewayID string) (int, string) {
+
+	t.Helper()
+
+	accessURL, err := url.Parse(pt.api.Gateways.AccessURL(gatewayID))
+	require.NoError(t, err)
+	accessURL.Path += "/data"
+
+	client := &http.Client{}
+	if reverse {
+		accessURL.Scheme = "http"
+		accessURL.Host = strings.TrimPrefix(pt.proxy.URL, "http://")
+	} else {
+		proxyURL, err := url.Parse(pt.proxy.URL)
+		require.NoError(t, err)
+
+		client.Transport = &http.Transport{
+			Proxy: http.ProxyURL(proxyURL),
+		}
+	}
+
+	resp, err := client.Get(accessURL.String())
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	return resp.StatusCode, string(body)
+}
+
+// accessLog returns the access log lines written so far.
+func (pt *proxyTest) accessLog(t *testing.T) []map[string]any {
+	t.Helper()
+
+	pt.logMu.Lock()
+	defer pt.logMu.Unlock()
+
+	var lines []map[string]any
+	scanner := bufio.NewScanner(bytes.NewReader(pt.logLines.Bytes()))
+	for scanner.Scan() {
+		var line map[string]any
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+// paidCount returns the number of payments recorded in the ledger.
+func (pt *proxyTest) paidCount(t *testing.T) int {
+	t.Helper()
+
+	ledger, err := pt.store.ListPayments(time.Time{}, 0)
+	require.NoError(t, err)
+
+	return len(ledger)
+}
+
+func TestProxyConcurrentRequests(t *testing.T) {
+	for _, reverse := range []bool{false, true} {
+		reverse := reverse
+		t.Run(fmt.Sprintf("reverse=%v", reverse), func(t *testing.T) {
+			pt := newProxyTest(t, reverse)
+			pt.engine.SetMaxPrice(100)
+			gatewayID := pt.newGateway(t, 1)
+
+			// The parallel requests for the same resource are paid
+			// once.
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					status, body := pt.get(t, reverse, gatewayID)
+					require.Equal(t, http.StatusOK, status, body)
+					require.Equal(t, "upstream /data", body)
+				}()
+			}
+			wg.Wait()
+
+			require.Equal(t, 1, pt.paidCount(t))
+
+			// Every request is written to the access log, only one
+			// of them paid the invoice.
+			lines := pt.accessLog(t)
+			require.Len(t, lines, 10)
+
+			var paid int
+			for _, line := range lines {
+				require.Equal(t, "request", line["msg"])
+				require.Equal(t, http.MethodGet, line["method"])
+				require.Contains(t, line["url"], gatewayID+"/data")
+				require.EqualValues(t, http.StatusOK, line["status"])
+				require.EqualValues(t, len("upstream /data"),
+					line["bytes"])
+				require.Contains(t, line, "duration_ms")
+				require.Contains(t, line, "remote_addr")
+
+				if line["paid"] == true {
+					paid++
+					require.EqualValues(t,
+						fewsatstest.DefaultSatsPerCent,
+						line["price_sats"])
+				}
+			}
+			require.Equal(t, 1, paid)
+		})
+	}
+}
+
+func TestProxyMaxPrice(t *testing.T) {
+	pt := newProxyTest(t, true)
+	pt.engine.SetMaxPrice(fewsatstest.DefaultSatsPerCent)
+
+	// The invoices above --max-price are refused with a 402.
+	expensive := pt.newGateway(t, 2)
+	status, body := pt.get(t, true, expensive)
+	require.Equal(t, http.StatusPaymentRequired, status)
+	require.Contains(t, body, "--max-price")
+	require.Zero(t, pt.paidCount(t))
+
+	lines := pt.accessLog(t)
+	require.Len(t, lines, 1)
+	require.EqualValues(t, http.StatusPaymentRequired, lines[0]["status"])
+	require.Equal(t, false, lines[0]["paid"])
+	require.Contains(t, lines[0]["error"], "--max-price")
+
+	cheap := pt.newGateway(t, 1)
+	status, _ = pt.get(t, true, cheap)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, 1, pt.paidCount(t))
+}
+
+func TestProxyBudget(t *testing.T) {
+	pt := newProxyTest(t, false)
+	pt.engine.SetMaxPrice(100)
+	pt.engine.SetSessionBudget(15)
+
+	first := pt.newGateway(t, 1)
+	second := pt.newGateway(t, 1)
+
+	status, _ := pt.get(t, false, first)
+	require.Equal(t, http.StatusOK, status)
+
+	// The paid resource is still served once the budget is exhausted.
+	status, _ = pt.get(t, false, first)
+	require.Equal(t, http.StatusOK, status)
+
+	status, body := pt.get(t, false, second)
+	require.Equal(t, http.StatusPaymentRequired, status)
+	require.Contains(t, body, "session budget")
+
+	require.Equal(t, 1, pt.paidCount(t))
+}
+
+func TestProxyConnect(t *testing.T) {
+	pt := newProxyTest(t, false)
+
+	req, err := http.NewRequest(http.MethodConnect, pt.proxy.URL, nil)
+	require.NoError(t, err)
+	req.Host = "example.com:443"
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	require.Contains(t, string(body), "reverse mode")
+}
@@ -3,7 +3,6 @@ package storage
 import (
 	"fmt"
 	"log/slog"
-	"net/http"
 
 	"github.com/fewsats/fewsatscli/client"
 	"github.com/urfave/cli/v2"
@@ -34,15 +33,10 @@ func deleteFile(c *cli.Context) error {
 		return cli.Exit("Failed to create HTTP client.", 1)
 	}
 
-	resp, err := client.ExecuteRequest(http.MethodDelete, fmt.Sprintf("/v0/storage/%s", externalID), nil)
+	err = client.API().Files.Delete(c.Context, externalID)
 	if err != nil {
-		slog.Debug("Failed to execute request.", "error", err)
-		return cli.Exit("Failed to execute request.", 1)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return cli.Exit(fmt.Sprintf("Failed request status code: %d", resp.StatusCode), 1)
+		slog.Debug("Failed to delete file.", "error", err)
+		return cli.Exit(fmt.Sprintf("Failed to delete file: %v", err), 1)
 	}
 
 	fmt.Println("File deleted successfully.")
@@ -4,46 +4,33 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
-	"net/http"
-	"net/url"
 	"os"
 
 	"github.com/fewsats/fewsatscli/client"
-	"github.com/fewsats/fewsatscli/config"
 	"github.com/urfave/cli/v2"
 )
 
-const (
-	// downloadFilePath is the path to the download endpoint.
-	downloadFilePath = "/v0/storage/download"
-)
-
 var downloadFileCommand = &cli.Command{
 	Name:      "download",
 	Usage:     "Download a file from the storage service.",
 	ArgsUsage: "<file_id>",
-	Action:    downloadFile,
+	Flags: []cli.Flag{
+		&cli.Uint64Flag{
+			Name:  "max-price",
+			Usage: "Pay invoices up to this price (sats) without asking, refuse above it",
+		},
+	},
+	Action: downloadFile,
 }
 
 // downloadFile downloads a file from the storage service.
 func downloadFile(c *cli.Context) error {
-	cfg, err := config.GetConfig()
-	if err != nil {
-		return cli.Exit("failed to get config", 1)
-	}
-
 	if c.Args().Len() < 1 {
 		return cli.Exit("missing <file_id> argument", 1)
 	}
 
-	file_url := c.Args().Get(0)
-	// Check if the file_url is a valid URL
-	// If not, it means that the user used the file_id, prepend the domain to
-	// create a valid URL
-	_, err = url.ParseRequestURI(file_url)
-	if err != nil {
-		file_url = cfg.Domain + downloadFilePath + "/" + file_url
-	}
+	// The file can be given by its ID or its download URL.
+	fileID := c.Args().Get(0)
 
 	httpClient, err := client.NewHTTPClient()
 	if err != nil {
@@ -55,33 +42,24 @@ func downloadFile(c *cli.Context) error {
 		return cli.Exit("failed to create http client", 1)
 	}
 
-	resp, err := httpClient.ExecuteL402Request(http.MethodGet, file_url, nil, nil)
-	if err != nil {
-		slog.Debug(
-			"Failed to execute L402 request.",
-			"error", err,
-		)
-
-		return cli.Exit("failed to execute request", 1)
+	if c.IsSet("max-price") {
+		httpClient.SetMaxPrice(c.Uint64("max-price"))
 	}
 
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
+	download, err := httpClient.API().Files.Download(c.Context, fileID)
+	if err != nil {
 		slog.Debug(
-			"Request failed",
-			"status_code", resp.StatusCode,
+			"Failed to download file.",
+			"error", err,
 		)
 
-		return cli.Exit("failed to download file", 1)
+		return cli.Exit(fmt.Sprintf("failed to download file: %v", err), 1)
 	}
+	defer download.Body.Close()
 
-	fileName := resp.Header.Get("file-name")
+	fileName := download.Name
 	if fileName == "" {
-		slog.Debug(
-			"Failed to parse filename",
-			"header", resp.Header,
-		)
+		slog.Debug("Failed to parse filename")
 
 		return cli.Exit("failed to parse filename", 1)
 	}
@@ -100,7 +78,7 @@ func downloadFile(c *cli.Context) error {
 	defer outFile.Close()
 
 	// Copy the response body to the new file
-	_, err = io.Copy(outFile, resp.Body)
+	_, err = io.Copy(outFile, download.Body)
 	if err != nil {
 		slog.Debug(
 			"Failed to write to file",
@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
-	"net/http"
 
 	"github.com/fewsats/fewsatscli/client"
 	"github.com/urfave/cli/v2"
@@ -30,24 +29,18 @@ func getFile(c *cli.Context) error {
 		return cli.Exit("Failed to create HTTP client.", 1)
 	}
 
-	resp, err := client.ExecuteRequest(http.MethodGet, fmt.Sprintf("/v0/storage/%s", fileID), nil)
+	file, err := client.API().Files.Get(c.Context, fileID)
 	if err != nil {
-		slog.Debug("Failed to execute request.", "error", err)
-		return cli.Exit("Failed to execute request.", 1)
+		slog.Debug("Failed to get file.", "error", err)
+		return cli.Exit(fmt.Sprintf("Failed to get file: %v", err), 1)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return cli.Exit(fmt.Sprintf("Failed request status code: %d", resp.StatusCode), 1)
+	response := struct {
+		File *File `json:"file"`
+	}{
+		File: file,
 	}
 
-	var response struct {
-		File File `json:"file"`
-	}
-	err = json.NewDecoder(resp.Body).Decode(&response)
-	if err != nil {
-		return cli.Exit("Failed to decode file.", 1)
-	}
 	// Marshal the response struct to JSON
 	jsonOutput, err := json.MarshalIndent(response, "", "  ")
 	if err != nil {
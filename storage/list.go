@@ -4,9 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
-	"net/http"
 
 	"github.com/fewsats/fewsatscli/client"
+	"github.com/fewsats/fewsatscli/fewsats"
 	"github.com/urfave/cli/v2"
 )
 
@@ -28,23 +28,18 @@ func listFiles(c *cli.Context) error {
 		return cli.Exit("Failed to create HTTP client.", 1)
 	}
 
-	resp, err := client.ExecuteRequest(http.MethodGet, "/v0/storage?limit=100", nil)
+	files, err := client.API().Files.List(
+		c.Context, fewsats.ListOptions{Limit: 100},
+	)
 	if err != nil {
-		slog.Debug("Failed to execute request.", "error", err)
-		return cli.Exit("Failed to execute request.", 1)
+		slog.Debug("Failed to list files.", "error", err)
+		return cli.Exit(fmt.Sprintf("Failed to list files: %v", err), 1)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return cli.Exit(fmt.Sprintf("Failed request status code: %d", resp.StatusCode), 1)
-	}
-
-	var response struct {
+	response := struct {
 		Files []File `json:"files"`
-	}
-	err = json.NewDecoder(resp.Body).Decode(&response)
-	if err != nil {
-		return cli.Exit("Failed to decode files.", 1)
+	}{
+		Files: files,
 	}
 
 	jsonOutput, err := json.MarshalIndent(response, "", "  ")
@@ -4,17 +4,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
-	"net/http"
 
 	"github.com/fewsats/fewsatscli/client"
+	"github.com/fewsats/fewsatscli/fewsats"
 	"github.com/urfave/cli/v2"
 )
 
-const (
-	// searchFilesPath is the path to the search endpoint.
-	searchFilesPath = "/v0/storage/search"
-)
-
 var searchCommand = &cli.Command{
 	Name:   "search",
 	Usage:  "Search files.",
@@ -28,23 +23,16 @@ func searchFiles(c *cli.Context) error {
 		return cli.Exit("Failed to create HTTP client.", 1)
 	}
 
-	resp, err := client.ExecuteRequest(http.MethodGet, searchFilesPath, nil)
+	files, err := client.API().Files.Search(c.Context, fewsats.ListOptions{})
 	if err != nil {
-		slog.Debug("Failed to execute request.", "error", err)
-		return cli.Exit("Failed to execute request.", 1)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return cli.Exit(fmt.Sprintf("Failed request status code: %d", resp.StatusCode), 1)
+		slog.Debug("Failed to search files.", "error", err)
+		return cli.Exit(fmt.Sprintf("Failed to search files: %v", err), 1)
 	}
 
-	var response struct {
+	response := struct {
 		Files []File `json:"files"`
-	}
-	err = json.NewDecoder(resp.Body).Decode(&response)
-	if err != nil {
-		return cli.Exit("Failed to decode response.", 1)
+	}{
+		Files: files,
 	}
 
 	// Marshal the response struct to JSON
@@ -1,33 +1,20 @@
 package storage
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
-	"io"
 	"log/slog"
 	"math"
-	"mime/multipart"
-	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
 
 	"github.com/fewsats/fewsatscli/client"
-	"github.com/fewsats/fewsatscli/config"
+	"github.com/fewsats/fewsatscli/fewsats"
 	"github.com/urfave/cli/v2"
 )
 
-const (
-	// uploadFilePath is the path to the upload endpoint.
-	uploadFilePath = "/v0/storage/upload"
-)
-
 // UploadFileResponse is the response body for the upload endpoint.
-type UploadFileResponse struct {
-	FileID       string `json:"file_id"`
-	PresignedURL string `json:"presigned_url"`
-}
+type UploadFileResponse = fewsats.UploadFileResponse
 
 var uploadFileCommand = &cli.Command{
 	Name:  "upload",
@@ -75,16 +62,6 @@ func uploadFile(c *cli.Context) error {
 		return cli.Exit("You need to log in to run this command.", 1)
 	}
 
-	cfg, err := config.GetConfig()
-	if err != nil {
-		slog.Debug(
-			"Failed to get config.",
-			"error", err,
-		)
-
-		return cli.Exit("failed to get config", 1)
-	}
-
 	name := c.String("name")
 	description := c.String("description")
 	priceStr := c.String("price")
@@ -118,62 +95,25 @@ func uploadFile(c *cli.Context) error {
 
 	priceInCents := uint64(math.Floor(price * 100))
 
-	var file *os.File
-	if filePath != "" {
-		file, err = os.Open(filePath)
-		if err != nil {
-			slog.Debug(
-				"Failed to read file.",
-				"error", err,
-			)
-
-			return cli.Exit("failed to read file", 1)
-		}
-		defer file.Close()
-	}
-
-	// Create a new multipart writer
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
-
-	// Add existing fields
-	err = writer.WriteField("name", name)
+	file, err := os.Open(filePath)
 	if err != nil {
 		slog.Debug(
-			"Failed to write name field.",
+			"Failed to read file.",
 			"error", err,
 		)
-		return cli.Exit("failed to write name field", 1)
-	}
 
-	// Add existing fields
-	err = writer.WriteField("file_name", name)
-	if err != nil {
-		slog.Debug(
-			"Failed to write file_name field.",
-			"error", err,
-		)
-		return cli.Exit("failed to write file_name field", 1)
+		return cli.Exit("failed to read file", 1)
 	}
+	defer file.Close()
 
-	err = writer.WriteField("description", description)
-	if err != nil {
-		slog.Debug(
-			"Failed to write description field.",
-			"error", err,
-		)
-		return cli.Exit("failed to write description field", 1)
-	}
-	err = writer.WriteField("price_in_cents", strconv.FormatUint(priceInCents, 10))
-	if err != nil {
-		slog.Debug(
-			"Failed to write price field.",
-			"error", err,
-		)
-		return cli.Exit("failed to write price field", 1)
+	req := &fewsats.UploadFileRequest{
+		Name:         name,
+		Description:  description,
+		PriceInCents: priceInCents,
+		Tags:         c.StringSlice("tags"),
+		File:         file,
 	}
 
-	// Handle the cover image as a file instead of base64 string
 	if coverImagePath != "" {
 		coverFile, err := os.Open(coverImagePath)
 		if err != nil {
@@ -181,30 +121,10 @@ func uploadFile(c *cli.Context) error {
 		}
 		defer coverFile.Close()
 
-		part, err := writer.CreateFormFile("cover", filepath.Base(coverImagePath))
-		if err != nil {
-			return cli.Exit("failed to create form file for cover image", 1)
-		}
-		if _, err := io.Copy(part, coverFile); err != nil {
-			return cli.Exit("failed to write cover image file to form", 1)
-		}
-	}
-
-	// Handle tags
-	tags := c.StringSlice("tags")
-	for _, tag := range tags {
-		if err := writer.WriteField("tags", tag); err != nil {
-			return cli.Exit("failed to write tag field", 1)
-		}
-	}
-
-	// Close the writer to finalize the multipart message
-	err = writer.Close()
-	if err != nil {
-		return cli.Exit("failed to finalize multipart message", 1)
+		req.Cover = coverFile
+		req.CoverName = filepath.Base(coverImagePath)
 	}
 
-	// Create a new HTTP client and request
 	client, err := client.NewHTTPClient()
 	if err != nil {
 		slog.Debug(
@@ -215,95 +135,19 @@ func uploadFile(c *cli.Context) error {
 		return cli.Exit("failed to create HTTP client", 1)
 	}
 
-	// Execute the request
-	resp, err := client.ExecuteMultipartRequest(
-		http.MethodPost, uploadFilePath,
-		body.Bytes(), writer.FormDataContentType(),
-	)
+	api := client.API()
+	respBody, err := api.Files.Upload(c.Context, req)
 	if err != nil {
 		slog.Debug(
-			"Failed to execute request.",
+			"Failed to upload file.",
 			"error", err,
 		)
 
-		return cli.Exit("failed to execute request", 1)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		slog.Debug(
-			"Request failed",
-			"status_code", resp.StatusCode,
-		)
-
-		return cli.Exit("failed to upload file", 1)
-	}
-
-	var respBody UploadFileResponse
-	err = json.NewDecoder(resp.Body).Decode(&respBody)
-	if err != nil {
-		slog.Debug(
-			"Failed to decode response.",
-			"error", err,
-		)
-
-		return cli.Exit("failed to decode response", 1)
-	}
-
-	// presignedURL is empty when we are uploading via fileURL
-	if respBody.PresignedURL != "" {
-		err = uploadFileToPresignedURL(respBody.PresignedURL, file)
-		if err != nil {
-			return cli.Exit("failed to upload file to presigned URL", 1)
-		}
+		return cli.Exit(fmt.Sprintf("failed to upload file: %v", err), 1)
 	}
 
 	fmt.Println("File uploaded successfully.")
-	fmt.Println("Download URL: ", cfg.Domain+downloadFilePath+"/"+respBody.FileID)
-
-	return nil
-}
-
-// uploadFileToPresignedURL uploads the file to the presigned URL.
-func uploadFileToPresignedURL(presignedURL string, file *os.File) error {
-	req, err := http.NewRequest(http.MethodPut, presignedURL, file)
-	if err != nil {
-		slog.Debug(
-			"Failed to create request to presigned URL.",
-			"error", err,
-		)
-		return err
-	}
-
-	stat, err := file.Stat()
-	if err != nil {
-		slog.Debug(
-			"Failed to get file stats.",
-			"error", err,
-		)
-		return err
-	}
-
-	req.ContentLength = stat.Size()
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		slog.Debug(
-			"Failed to upload file to presigned URL.",
-			"error", err,
-		)
-		return err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		slog.Debug(
-			"Upload to presigned URL failed.",
-			"status_code", resp.StatusCode,
-		)
-		return fmt.Errorf("upload to presigned URL failed with status code %d", resp.StatusCode)
-	}
+	fmt.Println("Download URL: ", api.Files.DownloadURL(respBody.FileID))
 
 	return nil
 }
@@ -0,0 +1,158 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/term"
+)
+
+const (
+	// PassphraseEnv is the environment variable holding the passphrase of
+	// the store, used instead of asking for it.
+	PassphraseEnv = "FEWSATS_STORE_PASSPHRASE"
+
+	// NewPassphraseEnv is the environment variable holding the new
+	// passphrase of the store when it is rekeyed.
+	NewPassphraseEnv = "FEWSATS_STORE_NEW_PASSPHRASE"
+)
+
+// Command creates the store command.
+func Command() *cli.Command {
+	return &cli.Command{
+		Name:  "store",
+		Usage: "Manage the encryption of the secrets in the local store.",
+		Subcommands: []*cli.Command{
+			lockCommand,
+			unlockCommand,
+			rekeyCommand,
+			statusCommand,
+		},
+	}
+}
+
+// NewKeySource returns the source of the secret unlocking the store: the key
+// file if set, otherwise the passphrase from PassphraseEnv or asked for on
+// the terminal. The source is configured unless the passphrase is asked for.
+func NewKeySource(keyFile string) *KeySource {
+	return &KeySource{
+		KeyFile:    keyFile,
+		Passphrase: readPassphrase(PassphraseEnv, "store", false),
+		Configured: keyFile != "" || os.Getenv(PassphraseEnv) != "",
+	}
+}
+
+// keyFileFlag is the flag of the key file unlocking the store.
+var keyFileFlag = &cli.StringFlag{
+	Name: "key-file",
+	Usage: "The key file unlocking the store, instead of the " +
+		"STORE_KEY_FILE of the profile or a passphrase",
+}
+
+// commandKeySource returns the key source given by the --key-file flag,
+// falling back to the key file of the profile and to a passphrase.
+func commandKeySource(c *cli.Context, configured *KeySource,
+	confirm bool) *KeySource {
+
+	keyFile := c.String(keyFileFlag.Name)
+	if keyFile == "" && configured != nil {
+		keyFile = configured.KeyFile
+	}
+
+	return &KeySource{
+		KeyFile:    keyFile,
+		Passphrase: readPassphrase(PassphraseEnv, "store", confirm),
+	}
+}
+
+// getStore returns the store saved in the app metadata.
+func getStore(c *cli.Context) (*Store, error) {
+	store, ok := c.App.Metadata["store"].(*Store)
+	if !ok {
+		return nil, errors.New("failed to get store from context")
+	}
+
+	return store, nil
+}
+
+// storeKeyError returns the error printed when the given store command
+// fails.
+func storeKeyError(command string, err error) error {
+	switch {
+	case errors.Is(err, ErrStoreEncrypted):
+		return cli.Exit("the store is already locked, use `store "+
+			"rekey` to change its passphrase or key file", 1)
+
+	case errors.Is(err, ErrStoreNotEncrypted):
+		return cli.Exit("the store is not locked", 1)
+
+	case errors.Is(err, ErrWrongStoreKey):
+		return cli.Exit(ErrWrongStoreKey.Error(), 1)
+
+	case errors.Is(err, ErrStoreLocked):
+		return cli.Exit(fmt.Sprintf("a passphrase is required, set %s "+
+			"or use --key-file", PassphraseEnv), 1)
+	}
+
+	slog.Debug("Failed to "+command+" the store.", "error", err)
+
+	return cli.Exit(fmt.Sprintf("failed to %s store: %v", command, err), 1)
+}
+
+// readPassphrase returns a function reading the passphrase from the given
+// environment variable or asking for the named passphrase on the terminal,
+// twice if confirm is set.
+func readPassphrase(env, name string,
+	confirm bool) func() (string, error) {
+
+	return func() (string, error) {
+		if passphrase := os.Getenv(env); passphrase != "" {
+			return passphrase, nil
+		}
+
+		if !term.IsTerminal(int(syscall.Stdin)) {
+			return "", ErrStoreLocked
+		}
+
+		passphrase, err := promptPassphrase(
+			fmt.Sprintf("Enter %s passphrase: ", name),
+		)
+		if err != nil || !confirm {
+			return passphrase, err
+		}
+
+		again, err := promptPassphrase(
+			fmt.Sprintf("Confirm %s passphrase: ", name),
+		)
+		if err != nil {
+			return "", err
+		}
+
+		if passphrase != again {
+			return "", errors.New("the passphrases do not match")
+		}
+
+		return passphrase, nil
+	}
+}
+
+// promptPassphrase asks for a passphrase on the terminal. The prompt is
+// written to stderr so the output of the commands is not mixed with it.
+func promptPassphrase(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	passphrase, err := term.ReadPassword(int(syscall.Stdin))
+
+	// Newline for the next prompt.
+	fmt.Fprintln(os.Stderr)
+
+	if err != nil {
+		return "", fmt.Errorf("unable to read passphrase: %w", err)
+	}
+
+	return strings.TrimSpace(string(passphrase)), nil
+}
@@ -7,61 +7,223 @@ import (
 	"time"
 
 	"github.com/fewsats/fewsatscli/credentials"
+	"github.com/jmoiron/sqlx"
 )
 
 // InsertL402Credentials inserts the L402 credentials into the database.
 func (s *Store) InsertL402Credentials(
 	challenge *credentials.L402Credentials) error {
 
+	preimage, err := s.sealValue(challenge.Preimage)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt L402 preimage: %w", err)
+	}
+
+	return insertL402Credentials(s.db, challenge, preimage)
+}
+
+// ImportL402Credentials inserts all the given L402 credentials in a single
+// transaction, none of them is stored if any insert fails.
+func (s *Store) ImportL402Credentials(
+	creds []*credentials.L402Credentials) error {
+
+	// The preimages are sealed before the transaction starts, reading the
+	// store key needs its own connection.
+	preimages := make([]string, len(creds))
+	for i, c := range creds {
+		var err error
+		preimages[i], err = s.sealValue(c.Preimage)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt L402 preimage: %w", err)
+		}
+	}
+
+	return s.execTx(func(tx *sqlx.Tx) error {
+		for i, c := range creds {
+			err := insertL402Credentials(tx, c, preimages[i])
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// insertL402Credentials inserts the L402 credentials, with their already
+// sealed preimage, with the given database handle or transaction.
+func insertL402Credentials(db sqlx.Execer,
+	challenge *credentials.L402Credentials, preimage string) error {
+
 	stmt := `
 		INSERT INTO credentials (
-			external_id, macaroon, preimage, invoice, created_at
+			external_id, scheme, host, path_prefix, location,
+			auth_scheme, macaroon, preimage, invoice, expires_at, stale,
+			created_at
 		) VALUES (
-			?, ?, ?, ?, ?
+			?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
 		);
 	`
 
 	challenge.CreatedAt = time.Now().UTC()
 
-	_, err := s.db.Exec(
-		stmt, challenge.ExternalID, challenge.Macaroon, challenge.Preimage,
-		challenge.Invoice, challenge.CreatedAt,
+	res, err := db.Exec(
+		stmt, challenge.ExternalID, challenge.Scheme, challenge.Host,
+		challenge.PathPrefix, challenge.Location, challenge.AuthScheme,
+		challenge.Macaroon, preimage, challenge.Invoice, challenge.ExpiresAt,
+		challenge.Stale, challenge.CreatedAt,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to insert L402 credentials: %w", err)
 	}
 
+	challenge.ID, err = res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get L402 credentials id: %w", err)
+	}
+
 	return nil
 }
 
-// GetL402Credentials retrieves the L402 credentials from the database.
+// GetL402Credentials retrieves the most specific L402 credentials for the
+// given scope from the database. Legacy credentials, only keyed by the last
+// segment of the resource path, are used if no scoped credentials match and
+// the scope is on the origin they were paid on, see SetAPIURL.
 func (s *Store) GetL402Credentials(
-	externalID string) (*credentials.L402Credentials, error) {
+	scope credentials.Scope) (*credentials.L402Credentials, error) {
+
+	// Expired credentials are skipped by credentials.MatchCredentials.
+	stmt := `
+		SELECT *
+		FROM credentials
+		WHERE stale = 0 AND (
+			(scheme = ? AND host = ?) OR (host = '' AND external_id = ?)
+		);
+	`
+
+	// The legacy credentials never leave the origin they were paid on,
+	// they are filtered by credentials.MatchCredentials.
+	var candidates []*credentials.L402Credentials
+	err := s.db.Select(
+		&candidates, stmt, scope.Scheme, scope.Host, scope.ExternalID(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get L402 credentials for %s: %w",
+			scope, err)
+	}
+
+	creds := credentials.MatchCredentials(candidates, scope, s.apiURL)
+	if creds == nil {
+		return nil, credentials.ErrNoCredentialsFound
+	}
+
+	creds.Preimage, err = s.openValue(creds.Preimage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt L402 preimage: %w", err)
+	}
+
+	if creds.Macaroon == "" || creds.Preimage == "" {
+		return nil, fmt.Errorf("invalid L402 credentials for %s (empty "+
+			"macaroon/preimage)", scope)
+	}
+
+	return creds, nil
+}
+
+// MarkL402CredentialsStale flags the L402 credentials as rejected by the
+// server so they are not used anymore.
+func (s *Store) MarkL402CredentialsStale(id int64) error {
+	stmt := `
+		UPDATE credentials
+		SET stale = 1
+		WHERE id = ?;
+	`
+
+	_, err := s.db.Exec(stmt, id)
+	if err != nil {
+		return fmt.Errorf("failed to mark L402 credentials %d as stale: %w",
+			id, err)
+	}
 
-	// TODO(positiveblue): make sure we are getting "valid" credentials:
-	// not expired, etc...
+	return nil
+}
+
+// ListL402Credentials returns all the stored L402 credentials, newest first.
+func (s *Store) ListL402Credentials() ([]*credentials.L402Credentials, error) {
 	stmt := `
 		SELECT *
 		FROM credentials
-		WHERE external_id = ?
-		LIMIT 1;
+		ORDER BY created_at DESC, id DESC;
+	`
+
+	var creds []*credentials.L402Credentials
+	err := s.db.Select(&creds, stmt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list L402 credentials: %w", err)
+	}
+
+	for _, c := range creds {
+		c.Preimage, err = s.openValue(c.Preimage)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt L402 preimage: %w",
+				err)
+		}
+	}
+
+	return creds, nil
+}
+
+// GetL402CredentialsByID retrieves the L402 credentials with the given ID.
+func (s *Store) GetL402CredentialsByID(
+	id int64) (*credentials.L402Credentials, error) {
+
+	stmt := `
+		SELECT *
+		FROM credentials
+		WHERE id = ?;
 	`
 
 	var creds credentials.L402Credentials
-	err := s.db.Get(&creds, stmt, externalID)
+	err := s.db.Get(&creds, stmt, id)
 	switch {
 	case errors.Is(err, sql.ErrNoRows):
 		return nil, credentials.ErrNoCredentialsFound
 
 	case err != nil:
-		return nil, fmt.Errorf("failed to get L402 credentials for %s: %w",
-			externalID, err)
+		return nil, fmt.Errorf("failed to get L402 credentials %d: %w", id,
+			err)
 	}
 
-	if creds.Macaroon == "" || creds.Preimage == "" {
-		return nil, fmt.Errorf("invalid L402 credentials for %s (empty "+
-			"macaroon/preimage)", externalID)
+	creds.Preimage, err = s.openValue(creds.Preimage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt L402 preimage: %w", err)
 	}
 
 	return &creds, nil
 }
+
+// DeleteL402Credentials deletes the L402 credentials with the given ID.
+func (s *Store) DeleteL402Credentials(id int64) error {
+	stmt := `
+		DELETE FROM credentials
+		WHERE id = ?;
+	`
+
+	res, err := s.db.Exec(stmt, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete L402 credentials %d: %w", id,
+			err)
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete L402 credentials %d: %w", id,
+			err)
+	}
+
+	if deleted == 0 {
+		return credentials.ErrNoCredentialsFound
+	}
+
+	return nil
+}
@@ -1,7 +1,9 @@
 package store
 
 import (
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/fewsats/fewsatscli/credentials"
 	"github.com/stretchr/testify/require"
@@ -12,23 +14,27 @@ func TestStoreCredentials(t *testing.T) {
 	store := newTestStore(t)
 
 	// The proper error is returned when the challenge is not found.
-	_, err := store.GetL402Credentials("non-existent-uuid")
+	scope, err := credentials.ParseScope("https://api.example.com/v1/data")
+	require.NoError(t, err)
+
+	_, err = store.GetL402Credentials(scope)
 	require.Error(t, err)
 	require.ErrorIs(t, err, credentials.ErrNoCredentialsFound)
 
 	challenge := &credentials.L402Credentials{
-		ExternalID: "externalID",
 		Macaroon:   "Macaroon",
 		Preimage:   "Preimage",
 		Invoice:    "Invoice",
+		AuthScheme: credentials.SchemeLSAT,
 	}
+	challenge.SetScope(scope)
 
 	// Store the credentials.
 	err = store.InsertL402Credentials(challenge)
 	require.NoError(t, err)
 
 	// Retrieve the credentials.
-	dbChallenge, err := store.GetL402Credentials(challenge.ExternalID)
+	dbChallenge, err := store.GetL402Credentials(scope)
 	require.NoError(t, err)
 
 	// The db challenge should have an ID and a CreatedAt.
@@ -42,3 +48,189 @@ func TestStoreCredentials(t *testing.T) {
 	// The challenges should match.
 	require.Equal(t, challenge, dbChallenge)
 }
+
+func TestStoreCredentialsScopes(t *testing.T) {
+	t.Parallel()
+	store := newTestStore(t)
+
+	insert := func(rawURL, macaroon string) {
+		t.Helper()
+
+		creds := &credentials.L402Credentials{
+			Macaroon: macaroon,
+			Preimage: "Preimage",
+			Invoice:  "Invoice",
+		}
+
+		if rawURL != "" {
+			scope, err := credentials.ParseScope(rawURL)
+			require.NoError(t, err)
+			creds.SetScope(scope)
+		} else {
+			// Credentials stored by older versions only had the last
+			// segment of the path.
+			creds.ExternalID = "data"
+		}
+
+		require.NoError(t, store.InsertL402Credentials(creds))
+	}
+
+	get := func(rawURL string) string {
+		t.Helper()
+
+		scope, err := credentials.ParseScope(rawURL)
+		require.NoError(t, err)
+
+		creds, err := store.GetL402Credentials(scope)
+		if errors.Is(err, credentials.ErrNoCredentialsFound) {
+			return ""
+		}
+		require.NoError(t, err)
+
+		return creds.Macaroon
+	}
+
+	insert("", "legacy")
+	insert("https://a.example.com/v1", "a-v1")
+	insert("https://a.example.com/v1/data", "a-v1-data")
+	insert("https://b.example.com/v1/data", "b-v1-data")
+
+	// The most specific scope wins.
+	require.Equal(t, "a-v1-data", get("https://a.example.com/v1/data"))
+	require.Equal(t, "a-v1-data", get("https://a.example.com/v1/data/x"))
+	require.Equal(t, "a-v1", get("https://a.example.com/v1/other"))
+
+	// The same path on another host does not collide.
+	require.Equal(t, "b-v1-data", get("https://B.example.com:443/v1/data"))
+
+	// Legacy credentials are still used on the Fewsats API origin when
+	// nothing else matches.
+	require.Equal(t, "legacy",
+		get("https://api.fewsats.com/v0/storage/download/data"))
+	require.Equal(t, "", get("https://api.fewsats.com/v0/storage/other"))
+	require.Equal(t, "", get("http://a.example.com/v1/other"))
+
+	// They are never sent to another origin with the same last segment.
+	require.Equal(t, "", get("https://c.example.com/v2/data"))
+	require.Equal(t, "", get("http://api.fewsats.com/v0/data"))
+
+	// With another Fewsats API configured, they are only used on it.
+	store.SetAPIURL("http://localhost:8000")
+	require.Equal(t, "legacy",
+		get("http://localhost:8000/v0/storage/download/data"))
+	require.Equal(t, "",
+		get("https://api.fewsats.com/v0/storage/download/data"))
+}
+
+func TestStoreCredentialsValidity(t *testing.T) {
+	t.Parallel()
+	store := newTestStore(t)
+
+	scope, err := credentials.ParseScope("https://api.example.com/v1/data")
+	require.NoError(t, err)
+
+	expired := time.Now().Add(-time.Minute)
+	creds := &credentials.L402Credentials{
+		Macaroon:  "Macaroon",
+		Preimage:  "Preimage",
+		Invoice:   "Invoice",
+		ExpiresAt: &expired,
+	}
+	creds.SetScope(scope)
+
+	// Expired credentials are skipped.
+	require.NoError(t, store.InsertL402Credentials(creds))
+	_, err = store.GetL402Credentials(scope)
+	require.ErrorIs(t, err, credentials.ErrNoCredentialsFound)
+
+	// Valid credentials are found until they are marked as stale.
+	creds.ExpiresAt = nil
+	require.NoError(t, store.InsertL402Credentials(creds))
+
+	dbCreds, err := store.GetL402Credentials(scope)
+	require.NoError(t, err)
+	require.Equal(t, creds.ID, dbCreds.ID)
+
+	require.NoError(t, store.MarkL402CredentialsStale(creds.ID))
+	_, err = store.GetL402Credentials(scope)
+	require.ErrorIs(t, err, credentials.ErrNoCredentialsFound)
+}
+
+func TestStoreCredentialsAdmin(t *testing.T) {
+	t.Parallel()
+	store := newTestStore(t)
+
+	creds, err := store.ListL402Credentials()
+	require.NoError(t, err)
+	require.Empty(t, creds)
+
+	for _, externalID := range []string{"first", "second"} {
+		err := store.InsertL402Credentials(&credentials.L402Credentials{
+			ExternalID: externalID,
+			Macaroon:   "Macaroon",
+			Preimage:   "Preimage",
+			Invoice:    "Invoice",
+		})
+		require.NoError(t, err)
+	}
+
+	// The newest credentials are listed first.
+	creds, err = store.ListL402Credentials()
+	require.NoError(t, err)
+	require.Len(t, creds, 2)
+	require.Equal(t, "second", creds[0].ExternalID)
+
+	dbCreds, err := store.GetL402CredentialsByID(creds[1].ID)
+	require.NoError(t, err)
+	require.Equal(t, creds[1], dbCreds)
+
+	require.NoError(t, store.DeleteL402Credentials(creds[1].ID))
+
+	_, err = store.GetL402CredentialsByID(creds[1].ID)
+	require.ErrorIs(t, err, credentials.ErrNoCredentialsFound)
+
+	err = store.DeleteL402Credentials(creds[1].ID)
+	require.ErrorIs(t, err, credentials.ErrNoCredentialsFound)
+}
+
+func TestStoreImportCredentials(t *testing.T) {
+	t.Parallel()
+	store := newTestStore(t)
+
+	newCreds := func(macaroon string) *credentials.L402Credentials {
+		return &credentials.L402Credentials{
+			ExternalID: "data",
+			Macaroon:   macaroon,
+			Preimage:   "Preimage",
+			Invoice:    "Invoice",
+		}
+	}
+
+	err := store.ImportL402Credentials([]*credentials.L402Credentials{
+		newCreds("first"), newCreds("second"),
+	})
+	require.NoError(t, err)
+
+	creds, err := store.ListL402Credentials()
+	require.NoError(t, err)
+	require.Len(t, creds, 2)
+
+	// Nothing is stored if any of the inserts fails.
+	_, err = store.db.Exec(`
+		CREATE TRIGGER fail_insert BEFORE INSERT ON credentials
+		WHEN NEW.macaroon = 'fail'
+		BEGIN
+			SELECT RAISE(ABORT, 'insert failed');
+		END;
+	`)
+	require.NoError(t, err)
+
+	err = store.ImportL402Credentials([]*credentials.L402Credentials{
+		newCreds("third"), newCreds("fail"),
+	})
+	require.ErrorContains(t, err, "insert failed")
+
+	creds, err = store.ListL402Credentials()
+	require.NoError(t, err)
+	require.Len(t, creds, 2)
+}
@@ -0,0 +1,684 @@
+package store
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// secretPrefix marks the encrypted values of the secret columns.
+	secretPrefix = "enc:v1:"
+
+	// The derivations of the key encryption key.
+	kdfScrypt  = "scrypt"
+	kdfKeyFile = "keyfile"
+
+	// minKeyFileSize is the size of the smallest key file accepted.
+	minKeyFileSize = 32
+)
+
+var (
+	// ErrStoreLocked is the error returned when a secret of an encrypted
+	// store is read or written without its passphrase or key file.
+	ErrStoreLocked = errors.New("the store is locked, its passphrase or " +
+		"key file is required")
+
+	// ErrWrongStoreKey is the error returned when the passphrase or the
+	// key file does not unlock the store.
+	ErrWrongStoreKey = errors.New("wrong store passphrase or key file")
+
+	// ErrStoreEncrypted is the error returned when locking a store already
+	// encrypted.
+	ErrStoreEncrypted = errors.New("the store is already encrypted")
+
+	// ErrStoreNotEncrypted is the error returned when unlocking or
+	// rekeying a store not encrypted.
+	ErrStoreNotEncrypted = errors.New("the store is not encrypted")
+)
+
+// scryptLogN is the base 2 logarithm of the scrypt cost parameter of the new
+// passphrases, about a second of work.
+var scryptLogN = 18
+
+// secretColumns are the columns holding secrets, encrypted while the store is
+// locked.
+var secretColumns = []struct {
+	table  string
+	key    string
+	column string
+}{
+	{"token_based_preimage_provider", "rowid", "token"},
+	{"api_keys", "id", "key"},
+	{"credentials", "id", "preimage"},
+	{"payments", "id", "preimage"},
+	{"lnd_wallets", "wallet_id", "macaroon"},
+	{"cln_wallets", "wallet_id", "rune"},
+}
+
+// KeySource provides the secret unlocking an encrypted store: the content of a
+// key file or a passphrase.
+type KeySource struct {
+	// KeyFile is the path of the key file. If empty, Passphrase is used.
+	KeyFile string
+
+	// Passphrase returns the passphrase of the store. It is only called
+	// when a secret is needed.
+	Passphrase func() (string, error)
+
+	// Configured is set if the secret is configured instead of asked for,
+	// like the key file of the profile. A store holding secrets in
+	// plaintext, like after upgrading to a version encrypting them, is
+	// locked with a configured source the first time it is unlocked.
+	Configured bool
+}
+
+// secret returns the secret of the source and the key derivation it needs.
+func (k *KeySource) secret() (string, []byte, error) {
+	if k.KeyFile != "" {
+		key, err := os.ReadFile(k.KeyFile)
+		if err != nil {
+			return "", nil, fmt.Errorf("unable to read store key file: %w",
+				err)
+		}
+
+		if len(key) < minKeyFileSize {
+			return "", nil, fmt.Errorf("the store key file must hold at "+
+				"least %d bytes", minKeyFileSize)
+		}
+
+		return kdfKeyFile, key, nil
+	}
+
+	if k.Passphrase == nil {
+		return "", nil, ErrStoreLocked
+	}
+
+	passphrase, err := k.Passphrase()
+	if err != nil {
+		return "", nil, err
+	}
+
+	if passphrase == "" {
+		return "", nil, errors.New("the store passphrase can not be empty")
+	}
+
+	return kdfScrypt, []byte(passphrase), nil
+}
+
+// storeEncryption is the row of the store_encryption table.
+type storeEncryption struct {
+	ID         int       `db:"id"`
+	KDF        string    `db:"kdf"`
+	Salt       string    `db:"salt"`
+	ScryptLogN int       `db:"scrypt_log_n"`
+	WrappedKey string    `db:"wrapped_key"`
+	CreatedAt  time.Time `db:"created_at"`
+}
+
+// newStoreEncryption wraps the data key with the key encryption key derived
+// from the source, with a new salt.
+func newStoreEncryption(source *KeySource,
+	dataKey []byte) (*storeEncryption, error) {
+
+	kdf, secret, err := source.secret()
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("unable to create salt: %w", err)
+	}
+
+	enc := &storeEncryption{
+		ID:        1,
+		KDF:       kdf,
+		Salt:      hex.EncodeToString(salt),
+		CreatedAt: time.Now().UTC(),
+	}
+	if kdf == kdfScrypt {
+		enc.ScryptLogN = scryptLogN
+	}
+
+	kek, err := enc.keyEncryptionKey(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	enc.WrappedKey, err = sealSecret(kek, dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return enc, nil
+}
+
+// keyEncryptionKey derives the key encryption key from the secret.
+func (e *storeEncryption) keyEncryptionKey(secret []byte) (cipher.AEAD,
+	error) {
+
+	salt, err := hex.DecodeString(e.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid store salt: %w", err)
+	}
+
+	var key []byte
+	switch e.KDF {
+	case kdfScrypt:
+		key, err = scrypt.Key(
+			secret, salt, 1<<e.ScryptLogN, 8, 1,
+			chacha20poly1305.KeySize,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("unable to derive store key: %w", err)
+		}
+
+	case kdfKeyFile:
+		key = make([]byte, chacha20poly1305.KeySize)
+		_, err = io.ReadFull(hkdf.New(
+			sha256.New, secret, salt, []byte("fewsats store key file"),
+		), key)
+		if err != nil {
+			return nil, fmt.Errorf("unable to derive store key: %w", err)
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown store key derivation %q", e.KDF)
+	}
+
+	return chacha20poly1305.NewX(key)
+}
+
+// dataKey unwraps the data key with the secret of the source.
+func (e *storeEncryption) dataKey(source *KeySource) ([]byte, error) {
+	kdf, secret, err := source.secret()
+	if err != nil {
+		return nil, err
+	}
+
+	// A passphrase does not unlock a store locked with a key file, and
+	// the other way around.
+	if kdf != e.KDF {
+		return nil, ErrWrongStoreKey
+	}
+
+	kek, err := e.keyEncryptionKey(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey, err := openSecret(kek, e.WrappedKey)
+	if err != nil {
+		return nil, ErrWrongStoreKey
+	}
+
+	return dataKey, nil
+}
+
+// sealSecret encrypts the value, the result is prefixed with secretPrefix.
+func sealSecret(aead cipher.AEAD, value []byte) (string, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(value)+
+		aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("unable to create nonce: %w", err)
+	}
+
+	sealed := aead.Seal(nonce, nonce, value, nil)
+
+	return secretPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// openSecret decrypts a value encrypted by sealSecret.
+func openSecret(aead cipher.AEAD, value string) ([]byte, error) {
+	sealed, err := base64.StdEncoding.DecodeString(
+		strings.TrimPrefix(value, secretPrefix),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encrypted secret: %w", err)
+	}
+
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("invalid encrypted secret")
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt secret: %w", err)
+	}
+
+	return plaintext, nil
+}
+
+// SetKeySource sets the source of the secret unlocking the store, used the
+// first time a secret is read or written if the store is encrypted.
+func (s *Store) SetKeySource(source *KeySource) {
+	s.secretsMu.Lock()
+	defer s.secretsMu.Unlock()
+
+	s.keySource = source
+	s.dataKey = nil
+	s.secrets = nil
+}
+
+// setDataKey caches the data key unlocking the secrets of the store and the
+// source of its wrapping key. secretsMu must be held.
+func (s *Store) setDataKey(source *KeySource, dataKey []byte) error {
+	secrets, err := chacha20poly1305.NewX(dataKey)
+	if err != nil {
+		return err
+	}
+
+	s.keySource = source
+	s.dataKey = dataKey
+	s.secrets = secrets
+
+	return nil
+}
+
+// Encrypted returns true if the secrets of the store are encrypted.
+func (s *Store) Encrypted() (bool, error) {
+	_, err := s.storeEncryption(s.db)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return false, nil
+
+	case err != nil:
+		return false, err
+	}
+
+	return true, nil
+}
+
+// storeEncryption returns the key of the store, sql.ErrNoRows if it is not
+// encrypted.
+func (s *Store) storeEncryption(q sqlx.Queryer) (*storeEncryption, error) {
+	stmt := `
+		SELECT *
+		FROM store_encryption
+		WHERE id = 1;
+	`
+
+	var enc storeEncryption
+	err := sqlx.Get(q, &enc, stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &enc, nil
+}
+
+// secretsCipher returns the cipher of the secrets, unlocking the store the
+// first time. Nil is returned if the store is not encrypted. With a
+// configured key source, a store not encrypted yet is locked and the secrets
+// left in plaintext are encrypted once unlocked.
+func (s *Store) secretsCipher() (cipher.AEAD, error) {
+	s.secretsMu.Lock()
+	defer s.secretsMu.Unlock()
+
+	if s.secrets != nil {
+		return s.secrets, nil
+	}
+
+	configured := s.keySource != nil && s.keySource.Configured
+
+	enc, err := s.storeEncryption(s.db)
+	switch {
+	case errors.Is(err, sql.ErrNoRows) && configured:
+		dataKey, err := s.encryptSecrets(s.keySource)
+		if err != nil {
+			return nil, fmt.Errorf("unable to lock store: %w", err)
+		}
+
+		slog.Debug("Store locked with the configured key source.")
+
+		err = s.setDataKey(s.keySource, dataKey)
+		if err != nil {
+			return nil, err
+		}
+
+		return s.secrets, nil
+
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, nil
+
+	case err != nil:
+		return nil, fmt.Errorf("unable to read store key: %w", err)
+
+	case s.keySource == nil:
+		return nil, ErrStoreLocked
+	}
+
+	dataKey, err := enc.dataKey(s.keySource)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.setDataKey(s.keySource, dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if configured {
+		err = s.execTx(func(tx *sqlx.Tx) error {
+			return sealPlaintextSecrets(tx, s.secrets)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s.secrets, nil
+}
+
+// keySourceConfigured returns true if the key source of the store is
+// configured.
+func (s *Store) keySourceConfigured() bool {
+	s.secretsMu.Lock()
+	defer s.secretsMu.Unlock()
+
+	return s.keySource != nil && s.keySource.Configured
+}
+
+// sealValue encrypts the secret value if the store is encrypted. The empty
+// values are stored as is.
+func (s *Store) sealValue(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	secrets, err := s.secretsCipher()
+	if err != nil || secrets == nil {
+		return value, err
+	}
+
+	return sealSecret(secrets, []byte(value))
+}
+
+// openValue decrypts the secret value if it is encrypted.
+func (s *Store) openValue(value string) (string, error) {
+	if !strings.HasPrefix(value, secretPrefix) {
+		// Reading a plaintext secret unlocks the store too, so it is
+		// encrypted with a configured key source.
+		if value != "" && s.keySourceConfigured() {
+			_, err := s.secretsCipher()
+			if err != nil {
+				return "", err
+			}
+		}
+
+		return value, nil
+	}
+
+	secrets, err := s.secretsCipher()
+	if err != nil {
+		return "", err
+	}
+
+	if secrets == nil {
+		return "", errors.New("encrypted secret in a store not " +
+			"encrypted")
+	}
+
+	plaintext, err := openSecret(secrets, value)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
+
+// PlaintextSecrets returns the number of secrets stored in plaintext by
+// "table.column", only for the columns holding some.
+func (s *Store) PlaintextSecrets() (map[string]int, error) {
+	counts := make(map[string]int)
+	for _, col := range secretColumns {
+		stmt := fmt.Sprintf(
+			`SELECT COUNT(*) FROM %s WHERE %s;`,
+			col.table, fmt.Sprintf(secretFilter, col.column),
+		)
+
+		var count int
+		err := s.db.Get(&count, stmt, secretFilterArgs(false)...)
+		if err != nil {
+			return nil, fmt.Errorf("unable to count %s.%s: %w",
+				col.table, col.column, err)
+		}
+
+		if count > 0 {
+			counts[col.table+"."+col.column] = count
+		}
+	}
+
+	return counts, nil
+}
+
+// EncryptSecrets locks the store: a new data key, wrapped with the secret of
+// the source, encrypts all the secrets stored in plaintext.
+func (s *Store) EncryptSecrets(source *KeySource) error {
+	encrypted, err := s.Encrypted()
+	if err != nil {
+		return err
+	}
+	if encrypted {
+		return ErrStoreEncrypted
+	}
+
+	dataKey, err := s.encryptSecrets(source)
+	if err != nil {
+		return err
+	}
+
+	s.secretsMu.Lock()
+	defer s.secretsMu.Unlock()
+
+	return s.setDataKey(source, dataKey)
+}
+
+// encryptSecrets creates the data key of the store, wrapped with the secret
+// of the source, and encrypts the secrets with it. The data key is returned.
+func (s *Store) encryptSecrets(source *KeySource) ([]byte, error) {
+	dataKey := make([]byte, chacha20poly1305.KeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("unable to create store key: %w", err)
+	}
+
+	enc, err := newStoreEncryption(source, dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	secrets, err := chacha20poly1305.NewX(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.execTx(func(tx *sqlx.Tx) error {
+		err := insertStoreEncryption(tx, enc)
+		if err != nil {
+			return err
+		}
+
+		return sealPlaintextSecrets(tx, secrets)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return dataKey, nil
+}
+
+// DecryptSecrets unlocks the store for good: all the secrets are decrypted
+// with the key source of the store and stored in plaintext.
+func (s *Store) DecryptSecrets() error {
+	secrets, err := s.secretsCipher()
+	if err != nil {
+		return err
+	}
+	if secrets == nil {
+		return ErrStoreNotEncrypted
+	}
+
+	err = s.execTx(func(tx *sqlx.Tx) error {
+		err := rewriteSecrets(tx, true, func(value string) (string, error) {
+			plaintext, err := openSecret(secrets, value)
+			return string(plaintext), err
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`DELETE FROM store_encryption;`)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	s.SetKeySource(nil)
+
+	return nil
+}
+
+// Rekey wraps the data key of the store with the secret of the new source,
+// the store is unlocked with the current key source first. The secrets are
+// not encrypted again.
+func (s *Store) Rekey(newSource *KeySource) error {
+	secrets, err := s.secretsCipher()
+	if err != nil {
+		return err
+	}
+	if secrets == nil {
+		return ErrStoreNotEncrypted
+	}
+
+	s.secretsMu.Lock()
+	dataKey := s.dataKey
+	s.secretsMu.Unlock()
+
+	newEnc, err := newStoreEncryption(newSource, dataKey)
+	if err != nil {
+		return err
+	}
+
+	err = s.execTx(func(tx *sqlx.Tx) error {
+		_, err := tx.Exec(`DELETE FROM store_encryption;`)
+		if err != nil {
+			return err
+		}
+
+		return insertStoreEncryption(tx, newEnc)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.secretsMu.Lock()
+	defer s.secretsMu.Unlock()
+
+	return s.setDataKey(newSource, dataKey)
+}
+
+// insertStoreEncryption stores the key of the store.
+func insertStoreEncryption(tx *sqlx.Tx, enc *storeEncryption) error {
+	stmt := `
+		INSERT INTO store_encryption (
+			id, kdf, salt, scrypt_log_n, wrapped_key, created_at
+		) VALUES (
+			?, ?, ?, ?, ?, ?
+		);
+	`
+
+	_, err := tx.Exec(
+		stmt, enc.ID, enc.KDF, enc.Salt, enc.ScryptLogN, enc.WrappedKey,
+		enc.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("unable to store store key: %w", err)
+	}
+
+	return nil
+}
+
+// sealPlaintextSecrets encrypts the values of the secret columns stored in
+// plaintext.
+func sealPlaintextSecrets(tx *sqlx.Tx, secrets cipher.AEAD) error {
+	return rewriteSecrets(tx, false, func(value string) (string, error) {
+		return sealSecret(secrets, []byte(value))
+	})
+}
+
+// secretFilter is the condition selecting the non empty values of a secret
+// column which are encrypted, or stored in plaintext, depending on its last
+// argument.
+const secretFilter = `%[1]s != '' AND (substr(%[1]s, 1, ?) = ?) = ?`
+
+// secretFilterArgs returns the arguments of secretFilter.
+func secretFilterArgs(encrypted bool) []any {
+	return []any{len(secretPrefix), secretPrefix, encrypted}
+}
+
+// rewriteSecrets replaces the non empty values of the secret columns with
+// the result of rewrite: the encrypted values if encrypted is set, the
+// values stored in plaintext otherwise.
+func rewriteSecrets(tx *sqlx.Tx, encrypted bool,
+	rewrite func(value string) (string, error)) error {
+
+	for _, col := range secretColumns {
+		stmt := fmt.Sprintf(
+			`SELECT %s AS key, %s AS value FROM %s WHERE %s;`,
+			col.key, col.column, col.table,
+			fmt.Sprintf(secretFilter, col.column),
+		)
+
+		var rows []struct {
+			Key   int64  `db:"key"`
+			Value string `db:"value"`
+		}
+		err := tx.Select(&rows, stmt, secretFilterArgs(encrypted)...)
+		if err != nil {
+			return fmt.Errorf("unable to read %s.%s: %w", col.table,
+				col.column, err)
+		}
+
+		update := fmt.Sprintf(
+			`UPDATE %s SET %s = ? WHERE %s = ?;`,
+			col.table, col.column, col.key,
+		)
+		for _, row := range rows {
+			value, err := rewrite(row.Value)
+			if err != nil {
+				return fmt.Errorf("unable to rewrite %s.%s: %w",
+					col.table, col.column, err)
+			}
+
+			if value == row.Value {
+				continue
+			}
+
+			_, err = tx.Exec(update, value, row.Key)
+			if err != nil {
+				return fmt.Errorf("unable to update %s.%s: %w",
+					col.table, col.column, err)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,309 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fewsats/fewsatscli/credentials"
+	"github.com/fewsats/fewsatscli/payments"
+	"github.com/fewsats/fewsatscli/wallets"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	// Keep the passphrase derivation cheap in the tests.
+	scryptLogN = 10
+}
+
+// passphrase returns a key source unlocking the store with the passphrase.
+func passphrase(value string) *KeySource {
+	return &KeySource{
+		Passphrase: func() (string, error) {
+			return value, nil
+		},
+	}
+}
+
+// requireRawSecrets checks whether the secret columns hold encrypted values
+// in the database.
+func requireRawSecrets(t *testing.T, store *Store, encrypted bool) {
+	t.Helper()
+
+	for _, col := range secretColumns {
+		var values []string
+		err := store.db.Select(&values, "SELECT "+col.column+" FROM "+
+			col.table)
+		require.NoError(t, err)
+		require.NotEmpty(t, values, col.table)
+
+		for _, value := range values {
+			require.Equal(t, encrypted,
+				strings.HasPrefix(value, secretPrefix), col.table)
+		}
+	}
+}
+
+func TestStoreEncryption(t *testing.T) {
+	store := newTestStore(t)
+
+	// Store a secret in each of the secret columns.
+	tokenID, err := store.InsertWallet(wallets.WalletTypeLNbits)
+	require.NoError(t, err)
+	require.NoError(t, store.InsertWalletToken(tokenID, "wallet-token"))
+
+	lndID, err := store.InsertWallet(wallets.WalletTypeLND)
+	require.NoError(t, err)
+	lnd := &wallets.LNDConnection{
+		WalletID: lndID,
+		Host:     "localhost:8080",
+		Macaroon: "0201",
+	}
+	require.NoError(t, store.InsertLNDConnection(lnd))
+
+	clnID, err := store.InsertWallet(wallets.WalletTypeCLN)
+	require.NoError(t, err)
+	cln := &wallets.CLNConnection{
+		WalletID: clnID,
+		Host:     "localhost:3010",
+		Rune:     "rune",
+	}
+	require.NoError(t, store.InsertCLNConnection(cln))
+
+	_, err = store.InsertAPIKey("api-key", time.Now().Add(time.Hour), 1)
+	require.NoError(t, err)
+
+	scope, err := credentials.ParseScope("https://api.example.com/v1/data")
+	require.NoError(t, err)
+	creds := &credentials.L402Credentials{
+		Macaroon: "Macaroon",
+		Preimage: "Preimage",
+		Invoice:  "Invoice",
+	}
+	creds.SetScope(scope)
+	require.NoError(t, store.InsertL402Credentials(creds))
+
+	require.NoError(t, store.InsertPayment(&payments.Payment{
+		URL:       "https://api.example.com/v1/data",
+		Preimage:  "00",
+		Status:    payments.StatusSucceeded,
+		CreatedAt: time.Now().UTC(),
+	}))
+
+	// requireSecrets checks the secrets are read back in plaintext.
+	requireSecrets := func(store *Store) {
+		t.Helper()
+
+		token, err := store.GetWalletToken(tokenID)
+		require.NoError(t, err)
+		require.Equal(t, "wallet-token", token)
+
+		gotLND, err := store.GetLNDConnection(lndID)
+		require.NoError(t, err)
+		require.Equal(t, lnd.Macaroon, gotLND.Macaroon)
+
+		gotCLN, err := store.GetCLNConnection(clnID)
+		require.NoError(t, err)
+		require.Equal(t, cln.Rune, gotCLN.Rune)
+
+		apiKey, err := store.GetAPIKey()
+		require.NoError(t, err)
+		require.Equal(t, "api-key", apiKey)
+
+		apiKeys, err := store.GetEnabledAPIKeys()
+		require.NoError(t, err)
+		require.Len(t, apiKeys, 1)
+		require.Equal(t, "api-key", apiKeys[0].Key)
+
+		gotCreds, err := store.GetL402Credentials(scope)
+		require.NoError(t, err)
+		require.Equal(t, "Preimage", gotCreds.Preimage)
+
+		gotCreds, err = store.GetL402CredentialsByID(creds.ID)
+		require.NoError(t, err)
+		require.Equal(t, "Preimage", gotCreds.Preimage)
+
+		list, err := store.ListPayments(time.Time{}, 0)
+		require.NoError(t, err)
+		require.Len(t, list, 1)
+		require.Equal(t, "00", list[0].Preimage)
+	}
+
+	requireRawSecrets(t, store, false)
+	encrypted, err := store.Encrypted()
+	require.NoError(t, err)
+	require.False(t, encrypted)
+
+	// Locking the store encrypts the existing rows.
+	require.NoError(t, store.EncryptSecrets(passphrase("secret")))
+	requireRawSecrets(t, store, true)
+	requireSecrets(store)
+
+	encrypted, err = store.Encrypted()
+	require.NoError(t, err)
+	require.True(t, encrypted)
+
+	require.ErrorIs(t, store.EncryptSecrets(passphrase("secret")),
+		ErrStoreEncrypted)
+
+	// The new secrets are encrypted too.
+	require.NoError(t, store.InsertWalletToken(tokenID+100, "other-token"))
+	var raw string
+	err = store.db.Get(&raw, "SELECT token FROM "+
+		"token_based_preimage_provider WHERE wallet_id = ?", tokenID+100)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(raw, secretPrefix))
+
+	// The secrets can't be read without the key source or with a wrong
+	// one.
+	store.SetKeySource(nil)
+	_, err = store.GetWalletToken(tokenID)
+	require.ErrorIs(t, err, ErrStoreLocked)
+
+	store.SetKeySource(passphrase("wrong"))
+	_, err = store.GetAPIKey()
+	require.ErrorIs(t, err, ErrWrongStoreKey)
+
+	// Rekey the store to a key file.
+	keyFile := filepath.Join(t.TempDir(), "store.key")
+	require.NoError(t, os.WriteFile(keyFile, []byte(strings.Repeat(
+		"k", minKeyFileSize)), 0600))
+
+	require.ErrorIs(t, store.Rekey(&KeySource{KeyFile: keyFile}),
+		ErrWrongStoreKey)
+
+	store.SetKeySource(passphrase("secret"))
+	require.NoError(t, store.Rekey(&KeySource{KeyFile: keyFile}))
+	requireSecrets(store)
+
+	store.SetKeySource(passphrase("secret"))
+	_, err = store.GetAPIKey()
+	require.ErrorIs(t, err, ErrWrongStoreKey)
+
+	store.SetKeySource(&KeySource{KeyFile: keyFile})
+	requireSecrets(store)
+
+	// Unlocking the store decrypts the rows for good.
+	require.NoError(t, store.DecryptSecrets())
+	requireRawSecrets(t, store, false)
+	requireSecrets(store)
+
+	require.ErrorIs(t, store.DecryptSecrets(), ErrStoreNotEncrypted)
+	require.ErrorIs(t, store.Rekey(passphrase("secret")),
+		ErrStoreNotEncrypted)
+}
+
+func TestStoreEncryptionKeyFile(t *testing.T) {
+	store := newTestStore(t)
+
+	shortKey := filepath.Join(t.TempDir(), "short.key")
+	require.NoError(t, os.WriteFile(shortKey, []byte("short"), 0600))
+	require.Error(t, store.EncryptSecrets(&KeySource{KeyFile: shortKey}))
+
+	_, err := store.InsertAPIKey("api-key", time.Now().Add(time.Hour), 1)
+	require.NoError(t, err)
+
+	require.ErrorIs(t, store.EncryptSecrets(&KeySource{}), ErrStoreLocked)
+	encrypted, err := store.Encrypted()
+	require.NoError(t, err)
+	require.False(t, encrypted)
+
+	keyFile := filepath.Join(t.TempDir(), "store.key")
+	require.NoError(t, os.WriteFile(keyFile, []byte(strings.Repeat(
+		"k", minKeyFileSize)), 0600))
+	require.NoError(t, store.EncryptSecrets(&KeySource{KeyFile: keyFile}))
+
+	// Another key file does not unlock the store.
+	otherKey := filepath.Join(t.TempDir(), "other.key")
+	require.NoError(t, os.WriteFile(otherKey, []byte(strings.Repeat(
+		"o", minKeyFileSize)), 0600))
+
+	store.SetKeySource(&KeySource{KeyFile: otherKey})
+	_, err = store.GetAPIKey()
+	require.ErrorIs(t, err, ErrWrongStoreKey)
+
+	store.SetKeySource(&KeySource{KeyFile: keyFile})
+	apiKey, err := store.GetAPIKey()
+	require.NoError(t, err)
+	require.Equal(t, "api-key", apiKey)
+}
+
+func TestStoreEncryptionUpgrade(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "fewsats.db")
+	store, err := NewStore(dbPath)
+	require.NoError(t, err)
+
+	// Store secrets in plaintext before the migration adding the
+	// encryption.
+	m, err := store.migrator()
+	require.NoError(t, err)
+	require.NoError(t, m.Migrate(10))
+
+	_, err = store.db.Exec(`INSERT INTO api_keys (key, expires_at, user_id)
+		VALUES (?, ?, 1);`, "api-key", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	_, err = store.db.Exec(`INSERT INTO token_based_preimage_provider
+		(wallet_id, token) VALUES (1, 'wallet-token');`)
+	require.NoError(t, err)
+
+	require.NoError(t, store.RunMigrations())
+
+	plaintext, err := store.PlaintextSecrets()
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{
+		"api_keys.key":                        1,
+		"token_based_preimage_provider.token": 1,
+	}, plaintext)
+
+	keyFile := filepath.Join(t.TempDir(), "store.key")
+	require.NoError(t, os.WriteFile(keyFile, []byte(strings.Repeat(
+		"k", minKeyFileSize)), 0600))
+
+	// A key source not configured leaves the secrets in plaintext.
+	store.SetKeySource(&KeySource{KeyFile: keyFile})
+	apiKey, err := store.GetAPIKey()
+	require.NoError(t, err)
+	require.Equal(t, "api-key", apiKey)
+
+	encrypted, err := store.Encrypted()
+	require.NoError(t, err)
+	require.False(t, encrypted)
+
+	// The first unlock with a configured key source locks the store.
+	store.SetKeySource(&KeySource{KeyFile: keyFile, Configured: true})
+	apiKey, err = store.GetAPIKey()
+	require.NoError(t, err)
+	require.Equal(t, "api-key", apiKey)
+
+	encrypted, err = store.Encrypted()
+	require.NoError(t, err)
+	require.True(t, encrypted)
+
+	plaintext, err = store.PlaintextSecrets()
+	require.NoError(t, err)
+	require.Empty(t, plaintext)
+
+	// A plaintext secret left in the locked store is encrypted the next
+	// time it is unlocked.
+	_, err = store.db.Exec(`INSERT INTO token_based_preimage_provider
+		(wallet_id, token) VALUES (2, 'other-token');`)
+	require.NoError(t, err)
+
+	reopened, err := NewStore(dbPath)
+	require.NoError(t, err)
+	reopened.SetKeySource(&KeySource{KeyFile: keyFile, Configured: true})
+
+	token, err := reopened.GetWalletToken(1)
+	require.NoError(t, err)
+	require.Equal(t, "wallet-token", token)
+
+	plaintext, err = reopened.PlaintextSecrets()
+	require.NoError(t, err)
+	require.Empty(t, plaintext)
+
+	token, err = reopened.GetWalletToken(2)
+	require.NoError(t, err)
+	require.Equal(t, "other-token", token)
+}
@@ -0,0 +1,35 @@
+package store
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+)
+
+var lockCommand = &cli.Command{
+	Name: "lock",
+	Usage: "Encrypt the wallet tokens, API keys and L402 preimages of the " +
+		"store with a passphrase or a key file.",
+	Flags: []cli.Flag{
+		keyFileFlag,
+	},
+	Action: lockStore,
+}
+
+// lockStore encrypts the secrets stored in plaintext.
+func lockStore(c *cli.Context) error {
+	store, err := getStore(c)
+	if err != nil {
+		return err
+	}
+
+	source := commandKeySource(c, store.keySource, true)
+	err = store.EncryptSecrets(source)
+	if err != nil {
+		return storeKeyError("lock", err)
+	}
+
+	fmt.Println("Store locked successfully.")
+
+	return nil
+}
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS spending_decisions_created_at_index;
+DROP TABLE IF EXISTS spending_decisions;
+DROP TABLE IF EXISTS spending_hosts;
+DROP TABLE IF EXISTS spending_policy;
@@ -0,0 +1,51 @@
+-- spending_policy is a single row table that stores the spending policy used
+-- to decide whether an L402 invoice is paid automatically, needs the user
+-- approval or is refused. A zero value means that limit is not set.
+CREATE TABLE IF NOT EXISTS spending_policy (
+    -- id is the primary key of the table, there is only one policy.
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    -- auto_approve_sats is the price up to which invoices are paid without
+    -- asking the user.
+    auto_approve_sats INTEGER NOT NULL DEFAULT 0,
+    -- max_price_sats is the price above which invoices are always refused.
+    max_price_sats INTEGER NOT NULL DEFAULT 0,
+    -- daily_budget_sats is the maximum amount spent in the last 24 hours.
+    daily_budget_sats INTEGER NOT NULL DEFAULT 0,
+    -- weekly_budget_sats is the maximum amount spent in the last 7 days.
+    weekly_budget_sats INTEGER NOT NULL DEFAULT 0,
+    -- monthly_budget_sats is the maximum amount spent in the last 30 days.
+    monthly_budget_sats INTEGER NOT NULL DEFAULT 0
+);
+
+-- spending_hosts stores the allowlisted hosts whose invoices are paid
+-- automatically up to their own ceiling.
+CREATE TABLE IF NOT EXISTS spending_hosts (
+    -- host is the allowlisted host name, `*.` prefixes match subdomains.
+    host TEXT PRIMARY KEY,
+    -- max_price_sats is the ceiling for a single payment to the host.
+    max_price_sats INTEGER NOT NULL,
+    -- created_at is the date and time when the host was allowlisted.
+    created_at DATETIME NOT NULL
+);
+
+-- spending_decisions records every decision taken by the spending policy.
+CREATE TABLE IF NOT EXISTS spending_decisions (
+    -- id is the primary key of the table.
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    -- url is the URL of the L402 resource.
+    url TEXT NOT NULL,
+    -- host is the host of the L402 resource.
+    host TEXT NOT NULL,
+    -- amount_sats is the price of the invoice.
+    amount_sats INTEGER NOT NULL,
+    -- action is the decision taken: pay or refuse.
+    action TEXT NOT NULL,
+    -- reason is a human readable explanation of the decision.
+    reason TEXT NOT NULL,
+    -- paid is a flag that indicates whether the invoice was paid.
+    paid BOOLEAN NOT NULL DEFAULT 0,
+    -- created_at is the date and time when the decision was taken.
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS spending_decisions_created_at_index ON spending_decisions (created_at);
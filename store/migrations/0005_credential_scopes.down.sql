@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS credentials_host_index;
+ALTER TABLE credentials DROP COLUMN location;
+ALTER TABLE credentials DROP COLUMN path_prefix;
+ALTER TABLE credentials DROP COLUMN host;
+ALTER TABLE credentials DROP COLUMN scheme;
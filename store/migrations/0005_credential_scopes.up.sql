@@ -0,0 +1,15 @@
+-- The credentials are keyed by the normalized scope of the resource they were
+-- paid for instead of the last segment of its path. Existing rows keep an
+-- empty host and are still found by their external_id.
+
+-- scheme is the URL scheme of the resource scope.
+ALTER TABLE credentials ADD COLUMN scheme TEXT NOT NULL DEFAULT '';
+-- host is the host (and non default port) of the resource scope.
+ALTER TABLE credentials ADD COLUMN host TEXT NOT NULL DEFAULT '';
+-- path_prefix is the path prefix of the resource scope, the credentials are
+-- used for any resource under it.
+ALTER TABLE credentials ADD COLUMN path_prefix TEXT NOT NULL DEFAULT '';
+-- location is the location of the macaroon, if any.
+ALTER TABLE credentials ADD COLUMN location TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS credentials_host_index ON credentials (host);
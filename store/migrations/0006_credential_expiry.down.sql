@@ -0,0 +1,2 @@
+ALTER TABLE credentials DROP COLUMN stale;
+ALTER TABLE credentials DROP COLUMN expires_at;
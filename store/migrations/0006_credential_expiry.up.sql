@@ -0,0 +1,6 @@
+-- expires_at is the date and time after which the macaroon is no longer
+-- valid, taken from its time based caveats. NULL if it never expires.
+ALTER TABLE credentials ADD COLUMN expires_at DATETIME;
+-- stale is set when the server rejected the credentials so they are not
+-- used anymore.
+ALTER TABLE credentials ADD COLUMN stale BOOLEAN NOT NULL DEFAULT 0;
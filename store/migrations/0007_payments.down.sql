@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS payments_created_at_index;
+DROP TABLE IF EXISTS payments;
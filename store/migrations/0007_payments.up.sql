@@ -0,0 +1,28 @@
+-- payments is the ledger of every L402 invoice payment attempt.
+CREATE TABLE IF NOT EXISTS payments (
+    -- id is the primary key of the table.
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    -- url is the URL of the L402 resource.
+    url TEXT NOT NULL,
+    -- host is the host of the L402 resource.
+    host TEXT NOT NULL,
+    -- amount_sats is the amount of the invoice.
+    amount_sats INTEGER NOT NULL,
+    -- payment_hash is the hex encoded payment hash of the invoice.
+    payment_hash TEXT NOT NULL,
+    -- preimage is the hex encoded preimage returned by the wallet, empty if
+    -- the payment failed.
+    preimage TEXT NOT NULL DEFAULT '',
+    -- wallet_id is the ID of the wallet used to pay, 0 if unknown.
+    wallet_id INTEGER NOT NULL DEFAULT 0,
+    -- status is the outcome of the payment: succeeded or failed.
+    status TEXT NOT NULL,
+    -- error is the error returned by the wallet if the payment failed.
+    error TEXT NOT NULL DEFAULT '',
+    -- duration_ms is the time the wallet took to pay, in milliseconds.
+    duration_ms INTEGER NOT NULL DEFAULT 0,
+    -- created_at is the date and time when the payment started.
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS payments_created_at_index ON payments (created_at);
@@ -0,0 +1,18 @@
+-- pending_payments holds the L402 payments started but not known to be
+-- settled, so an interrupted payment is recovered instead of paid twice.
+CREATE TABLE IF NOT EXISTS pending_payments (
+    -- payment_hash is the hex encoded payment hash of the invoice.
+    payment_hash TEXT PRIMARY KEY,
+    -- url is the URL of the L402 resource.
+    url TEXT NOT NULL,
+    -- macaroon is the base64 encoded macaroon of the L402 challenge.
+    macaroon TEXT NOT NULL,
+    -- invoice is the invoice of the L402 challenge.
+    invoice TEXT NOT NULL,
+    -- amount_sats is the amount paid.
+    amount_sats INTEGER NOT NULL,
+    -- wallet_id is the ID of the wallet used to pay, 0 if unknown.
+    wallet_id INTEGER NOT NULL DEFAULT 0,
+    -- created_at is the date and time when the payment started.
+    created_at DATETIME NOT NULL
+);
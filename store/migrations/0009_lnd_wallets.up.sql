@@ -0,0 +1,17 @@
+-- lnd_wallets stores the connection parameters of the wallets paying with
+-- an LND node through its REST API.
+CREATE TABLE IF NOT EXISTS lnd_wallets (
+    -- wallet_id is the ID of the wallet the connection belongs to.
+    wallet_id INTEGER PRIMARY KEY,
+    -- host is the address of the LND REST API.
+    host TEXT NOT NULL,
+    -- macaroon is the hex encoded macaroon authenticating the requests.
+    macaroon TEXT NOT NULL,
+    -- tls_cert is the PEM encoded TLS certificate of the node, empty to use
+    -- the system roots.
+    tls_cert TEXT NOT NULL DEFAULT '',
+    -- fee_limit_sats is the maximum routing fee paid per invoice.
+    fee_limit_sats INTEGER NOT NULL,
+    -- timeout_seconds is the time given to the node to pay an invoice.
+    timeout_seconds INTEGER NOT NULL
+);
@@ -0,0 +1,18 @@
+-- cln_wallets stores the connection parameters of the wallets paying with a
+-- Core Lightning node through its clnrest plugin.
+CREATE TABLE IF NOT EXISTS cln_wallets (
+    -- wallet_id is the ID of the wallet the connection belongs to.
+    wallet_id INTEGER PRIMARY KEY,
+    -- host is the address of the clnrest API.
+    host TEXT NOT NULL,
+    -- rune is the rune authenticating the requests.
+    rune TEXT NOT NULL,
+    -- tls_cert is the PEM encoded TLS certificate of the node, empty to use
+    -- the system roots.
+    tls_cert TEXT NOT NULL DEFAULT '',
+    -- max_fee_percent is the maximum routing fee paid per invoice, as a
+    -- percentage of its amount.
+    max_fee_percent REAL NOT NULL,
+    -- retry_for_seconds is the time the node keeps retrying a payment.
+    retry_for_seconds INTEGER NOT NULL
+);
@@ -0,0 +1,19 @@
+-- store_encryption holds the key encrypting the secrets of the store, like the
+-- wallet tokens, the API keys and the L402 preimages. It has a single row
+-- while the store is locked, none while the secrets are stored in plaintext.
+CREATE TABLE IF NOT EXISTS store_encryption (
+    -- id is always 1, there is only one key.
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    -- kdf is how the key encryption key is derived: scrypt from a
+    -- passphrase, or keyfile from the content of a key file.
+    kdf TEXT NOT NULL,
+    -- salt is the hex encoded salt of the key derivation.
+    salt TEXT NOT NULL,
+    -- scrypt_log_n is the base 2 logarithm of the scrypt cost parameter.
+    scrypt_log_n INTEGER NOT NULL DEFAULT 0,
+    -- wrapped_key is the base64 encoded data key encrypting the secrets,
+    -- itself encrypted with the key encryption key.
+    wrapped_key TEXT NOT NULL,
+    -- created_at is the date and time when the key was last wrapped.
+    created_at DATETIME NOT NULL
+);
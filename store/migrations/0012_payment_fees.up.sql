@@ -0,0 +1,3 @@
+-- fee_sats is the routing fee paid on top of the amount, in sats, 0 if the
+-- wallet does not report it.
+ALTER TABLE payments ADD COLUMN fee_sats INTEGER NOT NULL DEFAULT 0;
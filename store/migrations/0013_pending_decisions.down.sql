@@ -0,0 +1 @@
+ALTER TABLE pending_payments DROP COLUMN decision_id;
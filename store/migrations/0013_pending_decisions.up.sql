@@ -0,0 +1,3 @@
+-- decision_id is the ID of the spending decision approving the payment, 0 if
+-- none. The decision is marked as paid when the payment is recovered.
+ALTER TABLE pending_payments ADD COLUMN decision_id INTEGER NOT NULL DEFAULT 0;
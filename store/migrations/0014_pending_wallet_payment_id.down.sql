@@ -0,0 +1 @@
+ALTER TABLE pending_payments DROP COLUMN wallet_payment_id;
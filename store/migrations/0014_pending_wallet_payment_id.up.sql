@@ -0,0 +1,4 @@
+-- wallet_payment_id is the ID the wallet returned when paying, needed by the
+-- wallets looking up the payments by their own ID instead of the payment hash.
+ALTER TABLE pending_payments
+ADD COLUMN wallet_payment_id TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+ALTER TABLE pending_payments DROP COLUMN auth_scheme;
+ALTER TABLE credentials DROP COLUMN auth_scheme;
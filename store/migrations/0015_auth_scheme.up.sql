@@ -0,0 +1,6 @@
+-- auth_scheme is the authentication scheme of the challenge the credentials
+-- were paid for, L402 or the legacy LSAT. It is sent back in the
+-- Authorization header.
+ALTER TABLE credentials ADD COLUMN auth_scheme TEXT NOT NULL DEFAULT 'L402';
+ALTER TABLE pending_payments
+ADD COLUMN auth_scheme TEXT NOT NULL DEFAULT 'L402';
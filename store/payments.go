@@ -0,0 +1,77 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fewsats/fewsatscli/payments"
+)
+
+// InsertPayment records a payment attempt in the ledger.
+func (s *Store) InsertPayment(payment *payments.Payment) error {
+	stmt := `
+		INSERT INTO payments (
+			url, host, amount_sats, fee_sats, payment_hash, preimage,
+			wallet_id, status, error, duration_ms, created_at
+		) VALUES (
+			?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+		);
+	`
+
+	preimage, err := s.sealValue(payment.Preimage)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt payment preimage: %w", err)
+	}
+
+	res, err := s.db.Exec(
+		stmt, payment.URL, payment.Host, payment.AmountSats,
+		payment.FeeSats, payment.PaymentHash, preimage, payment.WalletID,
+		payment.Status, payment.Error, payment.DurationMs,
+		payment.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert payment: %w", err)
+	}
+
+	payment.ID, err = res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get payment id: %w", err)
+	}
+
+	return nil
+}
+
+// ListPayments returns the payments attempted since the given time, newest
+// first. A zero limit returns all of them.
+func (s *Store) ListPayments(since time.Time,
+	limit int) ([]*payments.Payment, error) {
+
+	stmt := `
+		SELECT *
+		FROM payments
+		WHERE created_at >= ?
+		ORDER BY created_at DESC, id DESC
+		LIMIT ?;
+	`
+
+	// A negative LIMIT means no limit in SQLite.
+	if limit <= 0 {
+		limit = -1
+	}
+
+	var result []*payments.Payment
+	err := s.db.Select(&result, stmt, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list payments: %w", err)
+	}
+
+	for _, payment := range result {
+		payment.Preimage, err = s.openValue(payment.Preimage)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt payment "+
+				"preimage: %w", err)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,172 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fewsats/fewsatscli/payments"
+	"github.com/fewsats/fewsatscli/policy"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorePayments(t *testing.T) {
+	t.Parallel()
+	store := newTestStore(t)
+
+	now := time.Now().UTC()
+	for i, createdAt := range []time.Time{
+		now.Add(-48 * time.Hour), now.Add(-time.Hour), now,
+	} {
+		err := store.InsertPayment(&payments.Payment{
+			URL:         "https://a.com/x",
+			Host:        "a.com",
+			AmountSats:  uint64(i + 1),
+			FeeSats:     uint64(i),
+			PaymentHash: "hash",
+			Preimage:    "preimage",
+			WalletID:    1,
+			Status:      payments.StatusSucceeded,
+			DurationMs:  10,
+			CreatedAt:   createdAt,
+		})
+		require.NoError(t, err)
+	}
+
+	// All the payments, newest first.
+	all, err := store.ListPayments(time.Time{}, 0)
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+	require.Equal(t, uint64(3), all[0].AmountSats)
+	require.Equal(t, uint64(2), all[0].FeeSats)
+	require.NotZero(t, all[0].ID)
+
+	// Filtered by date and limited.
+	recent, err := store.ListPayments(now.Add(-24*time.Hour), 0)
+	require.NoError(t, err)
+	require.Len(t, recent, 2)
+
+	limited, err := store.ListPayments(time.Time{}, 1)
+	require.NoError(t, err)
+	require.Len(t, limited, 1)
+	require.Equal(t, all[0], limited[0])
+}
+
+func TestStorePendingPayments(t *testing.T) {
+	t.Parallel()
+	store := newTestStore(t)
+
+	now := time.Now().UTC()
+	for i, hash := range []string{"hash1", "hash2"} {
+		err := store.InsertPendingPayment(&payments.Pending{
+			PaymentHash: hash,
+			URL:         "https://a.com/x",
+			Macaroon:    "mac",
+			Invoice:     "lnbc1",
+			AmountSats:  10,
+			WalletID:    1,
+			CreatedAt:   now.Add(time.Duration(i) * time.Minute),
+		})
+		require.NoError(t, err)
+	}
+
+	// A payment hash already pending keeps the first payment.
+	err := store.InsertPendingPayment(&payments.Pending{
+		PaymentHash: "hash1",
+		URL:         "https://b.com/y",
+	})
+	require.NoError(t, err)
+
+	pending, err := store.ListPendingPayments()
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	require.Equal(t, "hash1", pending[0].PaymentHash)
+	require.Equal(t, "https://a.com/x", pending[0].URL)
+	require.Equal(t, uint64(10), pending[0].AmountSats)
+
+	err = store.DeletePendingPayment("hash1")
+	require.NoError(t, err)
+
+	err = store.DeletePendingPayment("hash1")
+	require.ErrorIs(t, err, payments.ErrNoPendingPayment)
+
+	pending, err = store.ListPendingPayments()
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	require.Equal(t, "hash2", pending[0].PaymentHash)
+}
+
+func TestStoreSettlePendingPayment(t *testing.T) {
+	t.Parallel()
+	store := newTestStore(t)
+
+	now := time.Now().UTC()
+	decision := &policy.Decision{
+		URL:        "https://a.com/x",
+		Host:       "a.com",
+		AmountSats: 10,
+		Action:     policy.ActionPay,
+		CreatedAt:  now,
+	}
+	require.NoError(t, store.InsertSpendingDecision(decision))
+
+	err := store.InsertPendingPayment(&payments.Pending{
+		PaymentHash: "hash1",
+		URL:         "https://a.com/x",
+		AmountSats:  10,
+		DecisionID:  decision.ID,
+		CreatedAt:   now,
+	})
+	require.NoError(t, err)
+
+	pending, err := store.ListPendingPayments()
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	require.Equal(t, decision.ID, pending[0].DecisionID)
+
+	// Settling the pending payment marks its decision as paid.
+	require.NoError(t, store.SettlePendingPayment("hash1"))
+
+	spent, err := store.GetSpentSince(now.Add(-time.Hour))
+	require.NoError(t, err)
+	require.EqualValues(t, 10, spent)
+
+	pending, err = store.ListPendingPayments()
+	require.NoError(t, err)
+	require.Empty(t, pending)
+
+	err = store.SettlePendingPayment("hash1")
+	require.ErrorIs(t, err, payments.ErrNoPendingPayment)
+}
+
+func TestStoreWalletPaymentID(t *testing.T) {
+	t.Parallel()
+	store := newTestStore(t)
+
+	err := store.SetWalletPaymentID("hash1", "payment-1")
+	require.ErrorIs(t, err, payments.ErrNoPendingPayment)
+
+	_, err = store.GetWalletPaymentID("hash1")
+	require.ErrorIs(t, err, payments.ErrNoPendingPayment)
+
+	err = store.InsertPendingPayment(&payments.Pending{
+		PaymentHash: "hash1",
+		URL:         "https://a.com/x",
+	})
+	require.NoError(t, err)
+
+	// The ID is unknown until the wallet returns it.
+	id, err := store.GetWalletPaymentID("hash1")
+	require.NoError(t, err)
+	require.Empty(t, id)
+
+	require.NoError(t, store.SetWalletPaymentID("hash1", "payment-1"))
+
+	id, err = store.GetWalletPaymentID("hash1")
+	require.NoError(t, err)
+	require.Equal(t, "payment-1", id)
+
+	pending, err := store.ListPendingPayments()
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	require.Equal(t, "payment-1", pending[0].WalletPaymentID)
+}
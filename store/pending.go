@@ -0,0 +1,164 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fewsats/fewsatscli/payments"
+	"github.com/jmoiron/sqlx"
+)
+
+// InsertPendingPayment stores the pending payment, the existing one is kept
+// if its payment hash is already pending.
+func (s *Store) InsertPendingPayment(pending *payments.Pending) error {
+	stmt := `
+		INSERT INTO pending_payments (
+			payment_hash, url, macaroon, auth_scheme, invoice,
+			amount_sats, wallet_id, decision_id, created_at
+		) VALUES (
+			?, ?, ?, ?, ?, ?, ?, ?, ?
+		) ON CONFLICT (payment_hash) DO NOTHING;
+	`
+
+	if pending.CreatedAt.IsZero() {
+		pending.CreatedAt = time.Now().UTC()
+	}
+
+	_, err := s.db.Exec(
+		stmt, pending.PaymentHash, pending.URL, pending.Macaroon,
+		pending.AuthScheme, pending.Invoice, pending.AmountSats, pending.WalletID,
+		pending.DecisionID, pending.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert pending payment: %w", err)
+	}
+
+	return nil
+}
+
+// ListPendingPayments returns all the pending payments, oldest first.
+func (s *Store) ListPendingPayments() ([]*payments.Pending, error) {
+	stmt := `
+		SELECT *
+		FROM pending_payments
+		ORDER BY created_at ASC;
+	`
+
+	var result []*payments.Pending
+	err := s.db.Select(&result, stmt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pending payments: %w", err)
+	}
+
+	return result, nil
+}
+
+// DeletePendingPayment removes the pending payment with the given payment
+// hash.
+func (s *Store) DeletePendingPayment(paymentHash string) error {
+	return deletePendingPayment(s.db, paymentHash)
+}
+
+// SettlePendingPayment removes the pending payment with the given payment
+// hash and marks its spending decision as paid, in one transaction.
+func (s *Store) SettlePendingPayment(paymentHash string) error {
+	return s.execTx(func(tx *sqlx.Tx) error {
+		stmt := `
+			UPDATE spending_decisions
+			SET paid = 1
+			WHERE id = (
+				SELECT decision_id
+				FROM pending_payments
+				WHERE payment_hash = ?
+			);
+		`
+
+		_, err := tx.Exec(stmt, paymentHash)
+		if err != nil {
+			return fmt.Errorf("failed to mark spending decision of "+
+				"pending payment %s as paid: %w", paymentHash, err)
+		}
+
+		return deletePendingPayment(tx, paymentHash)
+	})
+}
+
+// SetWalletPaymentID stores the ID the wallet returned when paying the
+// pending payment with the given payment hash.
+func (s *Store) SetWalletPaymentID(paymentHash, paymentID string) error {
+	stmt := `
+		UPDATE pending_payments
+		SET wallet_payment_id = ?
+		WHERE payment_hash = ?;
+	`
+
+	res, err := s.db.Exec(stmt, paymentID, paymentHash)
+	if err != nil {
+		return fmt.Errorf("failed to set wallet payment ID of pending "+
+			"payment %s: %w", paymentHash, err)
+	}
+
+	updated, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to set wallet payment ID of pending "+
+			"payment %s: %w", paymentHash, err)
+	}
+
+	if updated == 0 {
+		return payments.ErrNoPendingPayment
+	}
+
+	return nil
+}
+
+// GetWalletPaymentID returns the ID the wallet returned when paying the
+// pending payment with the given payment hash, empty if it is unknown.
+func (s *Store) GetWalletPaymentID(paymentHash string) (string, error) {
+	stmt := `
+		SELECT wallet_payment_id
+		FROM pending_payments
+		WHERE payment_hash = ?;
+	`
+
+	var paymentID string
+	err := s.db.Get(&paymentID, stmt, paymentHash)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return "", payments.ErrNoPendingPayment
+
+	case err != nil:
+		return "", fmt.Errorf("failed to get wallet payment ID of "+
+			"pending payment %s: %w", paymentHash, err)
+	}
+
+	return paymentID, nil
+}
+
+// deletePendingPayment removes the pending payment with the given payment
+// hash.
+func deletePendingPayment(db sqlx.Execer, paymentHash string) error {
+	stmt := `
+		DELETE FROM pending_payments
+		WHERE payment_hash = ?;
+	`
+
+	res, err := db.Exec(stmt, paymentHash)
+	if err != nil {
+		return fmt.Errorf("failed to delete pending payment %s: %w",
+			paymentHash, err)
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete pending payment %s: %w",
+			paymentHash, err)
+	}
+
+	if deleted == 0 {
+		return payments.ErrNoPendingPayment
+	}
+
+	return nil
+}
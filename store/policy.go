@@ -0,0 +1,194 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fewsats/fewsatscli/policy"
+)
+
+// GetSpendingPolicy returns the spending policy, or an empty policy if none
+// has been set.
+func (s *Store) GetSpendingPolicy() (*policy.Policy, error) {
+	stmt := `
+		SELECT auto_approve_sats, max_price_sats, daily_budget_sats,
+			weekly_budget_sats, monthly_budget_sats
+		FROM spending_policy
+		WHERE id = 1;
+	`
+
+	var p policy.Policy
+	err := s.db.Get(&p, stmt)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return &policy.Policy{}, nil
+
+	case err != nil:
+		return nil, fmt.Errorf("failed to get spending policy: %w", err)
+	}
+
+	return &p, nil
+}
+
+// SetSpendingPolicy replaces the spending policy.
+func (s *Store) SetSpendingPolicy(p *policy.Policy) error {
+	stmt := `
+		INSERT INTO spending_policy (
+			id, auto_approve_sats, max_price_sats, daily_budget_sats,
+			weekly_budget_sats, monthly_budget_sats
+		) VALUES (
+			1, ?, ?, ?, ?, ?
+		)
+		ON CONFLICT (id) DO UPDATE SET
+			auto_approve_sats = excluded.auto_approve_sats,
+			max_price_sats = excluded.max_price_sats,
+			daily_budget_sats = excluded.daily_budget_sats,
+			weekly_budget_sats = excluded.weekly_budget_sats,
+			monthly_budget_sats = excluded.monthly_budget_sats;
+	`
+
+	_, err := s.db.Exec(
+		stmt, p.AutoApproveSats, p.MaxPriceSats, p.DailyBudgetSats,
+		p.WeeklyBudgetSats, p.MonthlyBudgetSats,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set spending policy: %w", err)
+	}
+
+	return nil
+}
+
+// ListHostRules returns all the allowlisted hosts.
+func (s *Store) ListHostRules() ([]policy.HostRule, error) {
+	stmt := `
+		SELECT host, max_price_sats, created_at
+		FROM spending_hosts
+		ORDER BY host;
+	`
+
+	rules := []policy.HostRule{}
+	err := s.db.Select(&rules, stmt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list allowlisted hosts: %w", err)
+	}
+
+	return rules, nil
+}
+
+// UpsertHostRule inserts or updates an allowlisted host.
+func (s *Store) UpsertHostRule(rule *policy.HostRule) error {
+	stmt := `
+		INSERT INTO spending_hosts (host, max_price_sats, created_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT (host) DO UPDATE SET
+			max_price_sats = excluded.max_price_sats;
+	`
+
+	_, err := s.db.Exec(stmt, rule.Host, rule.MaxPriceSats, rule.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to upsert allowlisted host: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteHostRule removes a host from the allowlist.
+func (s *Store) DeleteHostRule(host string) error {
+	stmt := `
+		DELETE
+		FROM spending_hosts
+		WHERE host = ?;
+	`
+
+	_, err := s.db.Exec(stmt, host)
+	if err != nil {
+		return fmt.Errorf("failed to delete allowlisted host: %w", err)
+	}
+
+	return nil
+}
+
+// InsertSpendingDecision records a spending decision.
+func (s *Store) InsertSpendingDecision(decision *policy.Decision) error {
+	stmt := `
+		INSERT INTO spending_decisions (
+			url, host, amount_sats, action, reason, paid, created_at
+		) VALUES (
+			?, ?, ?, ?, ?, ?, ?
+		);
+	`
+
+	if decision.CreatedAt.IsZero() {
+		decision.CreatedAt = time.Now().UTC()
+	}
+
+	result, err := s.db.Exec(
+		stmt, decision.URL, decision.Host, decision.AmountSats,
+		decision.Action, decision.Reason, decision.Paid,
+		decision.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert spending decision: %w", err)
+	}
+
+	decision.ID, err = result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get spending decision id: %w", err)
+	}
+
+	return nil
+}
+
+// MarkSpendingDecisionPaid flags the decision as paid.
+func (s *Store) MarkSpendingDecisionPaid(id int64) error {
+	stmt := `
+		UPDATE spending_decisions
+		SET paid = 1
+		WHERE id = ?;
+	`
+
+	_, err := s.db.Exec(stmt, id)
+	if err != nil {
+		return fmt.Errorf("failed to mark spending decision as paid: %w",
+			err)
+	}
+
+	return nil
+}
+
+// ListSpendingDecisions returns the latest spending decisions.
+func (s *Store) ListSpendingDecisions(limit int) ([]policy.Decision, error) {
+	stmt := `
+		SELECT *
+		FROM spending_decisions
+		ORDER BY id DESC
+		LIMIT ?;
+	`
+
+	decisions := []policy.Decision{}
+	err := s.db.Select(&decisions, stmt, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list spending decisions: %w", err)
+	}
+
+	return decisions, nil
+}
+
+// GetSpentSince returns the amount paid since the given time.
+func (s *Store) GetSpentSince(since time.Time) (uint64, error) {
+	stmt := `
+		SELECT COALESCE(SUM(amount_sats), 0)
+		FROM spending_decisions
+		WHERE paid = 1 AND created_at >= ?;
+	`
+
+	var spent uint64
+	err := s.db.Get(&spent, stmt, since.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to get spent amount: %w", err)
+	}
+
+	return spent, nil
+}
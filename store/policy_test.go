@@ -0,0 +1,112 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fewsats/fewsatscli/policy"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreSpendingPolicy(t *testing.T) {
+	t.Parallel()
+	store := newTestStore(t)
+
+	// An empty policy is returned when none has been set.
+	p, err := store.GetSpendingPolicy()
+	require.NoError(t, err)
+	require.Equal(t, &policy.Policy{}, p)
+
+	// Set and update the policy.
+	p.AutoApproveSats = 10
+	p.DailyBudgetSats = 1000
+	require.NoError(t, store.SetSpendingPolicy(p))
+
+	p.MaxPriceSats = 100
+	require.NoError(t, store.SetSpendingPolicy(p))
+
+	dbPolicy, err := store.GetSpendingPolicy()
+	require.NoError(t, err)
+	require.Equal(t, p, dbPolicy)
+
+	// Host rules are upserted by host.
+	rule := &policy.HostRule{
+		Host:         "api.example.com",
+		MaxPriceSats: 50,
+		CreatedAt:    time.Now().UTC(),
+	}
+	require.NoError(t, store.UpsertHostRule(rule))
+
+	rule.MaxPriceSats = 60
+	require.NoError(t, store.UpsertHostRule(rule))
+
+	rules, err := store.ListHostRules()
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	require.EqualValues(t, 60, rules[0].MaxPriceSats)
+
+	require.NoError(t, store.DeleteHostRule(rule.Host))
+	rules, err = store.ListHostRules()
+	require.NoError(t, err)
+	require.Empty(t, rules)
+}
+
+func TestStoreSpendingDecisions(t *testing.T) {
+	t.Parallel()
+	store := newTestStore(t)
+
+	now := time.Now().UTC()
+	old := &policy.Decision{
+		URL:        "https://api.example.com/v1/data",
+		Host:       "api.example.com",
+		AmountSats: 100,
+		Action:     policy.ActionPay,
+		Reason:     "approved by the user",
+		CreatedAt:  now.Add(-48 * time.Hour),
+	}
+	recent := &policy.Decision{
+		URL:        "https://api.example.com/v1/data",
+		Host:       "api.example.com",
+		AmountSats: 10,
+		Action:     policy.ActionPay,
+		Reason:     "approved by the user",
+		CreatedAt:  now.Add(-time.Hour),
+	}
+	refused := &policy.Decision{
+		URL:        "https://api.example.com/v1/data",
+		Host:       "api.example.com",
+		AmountSats: 1000,
+		Action:     policy.ActionRefuse,
+		Reason:     "rejected by the user",
+		CreatedAt:  now,
+	}
+
+	for _, d := range []*policy.Decision{old, recent, refused} {
+		require.NoError(t, store.InsertSpendingDecision(d))
+		require.NotZero(t, d.ID)
+	}
+
+	// Only paid decisions count towards the spent amount.
+	spent, err := store.GetSpentSince(now.Add(-72 * time.Hour))
+	require.NoError(t, err)
+	require.Zero(t, spent)
+
+	require.NoError(t, store.MarkSpendingDecisionPaid(old.ID))
+	require.NoError(t, store.MarkSpendingDecisionPaid(recent.ID))
+
+	spent, err = store.GetSpentSince(now.Add(-24 * time.Hour))
+	require.NoError(t, err)
+	require.EqualValues(t, 10, spent)
+
+	spent, err = store.GetSpentSince(now.Add(-72 * time.Hour))
+	require.NoError(t, err)
+	require.EqualValues(t, 110, spent)
+
+	// Decisions are listed newest first.
+	decisions, err := store.ListSpendingDecisions(2)
+	require.NoError(t, err)
+	require.Len(t, decisions, 2)
+	require.Equal(t, refused.ID, decisions[0].ID)
+	require.Equal(t, recent.ID, decisions[1].ID)
+	require.True(t, decisions[1].Paid)
+}
@@ -0,0 +1,52 @@
+package store
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+)
+
+var rekeyCommand = &cli.Command{
+	Name: "rekey",
+	Usage: "Change the passphrase or the key file of the store, the " +
+		"secrets are not encrypted again.",
+	Flags: []cli.Flag{
+		keyFileFlag,
+		&cli.StringFlag{
+			Name: "new-key-file",
+			Usage: fmt.Sprintf("The new key file of the store, a new "+
+				"passphrase is asked for or read from %s otherwise",
+				NewPassphraseEnv),
+		},
+	},
+	Action: rekeyStore,
+}
+
+// rekeyStore wraps the data key of the store with a new passphrase or key
+// file.
+func rekeyStore(c *cli.Context) error {
+	store, err := getStore(c)
+	if err != nil {
+		return err
+	}
+
+	store.SetKeySource(commandKeySource(c, store.keySource, false))
+
+	newSource := &KeySource{
+		KeyFile:    c.String("new-key-file"),
+		Passphrase: readPassphrase(NewPassphraseEnv, "new store", true),
+	}
+
+	err = store.Rekey(newSource)
+	if err != nil {
+		return storeKeyError("rekey", err)
+	}
+
+	fmt.Println("Store rekeyed successfully.")
+	if newSource.KeyFile != "" {
+		fmt.Println("Set STORE_KEY_FILE in the profile to unlock the " +
+			"store with the new key file.")
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+
+	"github.com/urfave/cli/v2"
+)
+
+var statusCommand = &cli.Command{
+	Name: "status",
+	Usage: "Show whether the store is locked and warn about the secrets " +
+		"still stored in plaintext.",
+	Action: storeStatus,
+}
+
+// storeStatus prints how the store is locked and the secrets stored in
+// plaintext, without unlocking it.
+func storeStatus(c *cli.Context) error {
+	store, err := getStore(c)
+	if err != nil {
+		return err
+	}
+
+	enc, err := store.storeEncryption(store.db)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		fmt.Println("Store: not locked.")
+
+	case err != nil:
+		slog.Debug("Failed to read the store key.", "error", err)
+		return cli.Exit("failed to read store status", 1)
+
+	case enc.KDF == kdfKeyFile:
+		fmt.Println("Store: locked with a key file.")
+
+	default:
+		fmt.Println("Store: locked with a passphrase.")
+	}
+
+	plaintext, err := store.PlaintextSecrets()
+	if err != nil {
+		slog.Debug("Failed to count the plaintext secrets.", "error", err)
+		return cli.Exit("failed to read store status", 1)
+	}
+
+	if len(plaintext) == 0 {
+		fmt.Println("No secrets stored in plaintext.")
+		return nil
+	}
+
+	columns := make([]string, 0, len(plaintext))
+	for column := range plaintext {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	fmt.Println("Warning: secrets stored in plaintext:")
+	for _, column := range columns {
+		fmt.Printf("  %s: %d\n", column, plaintext[column])
+	}
+
+	switch {
+	case store.keySourceConfigured():
+		fmt.Println("They are encrypted the next time the store is " +
+			"unlocked with its configured key file or passphrase.")
+
+	case enc != nil:
+		fmt.Printf("Set STORE_KEY_FILE in the profile or %s to encrypt "+
+			"them the next time the store is unlocked.\n", PassphraseEnv)
+
+	default:
+		fmt.Println("Run `fewsatscli store lock` to encrypt them.")
+	}
+
+	return nil
+}
@@ -1,6 +1,7 @@
 package store
 
 import (
+	"crypto/cipher"
 	"fmt"
 	"log"
 	"log/slog"
@@ -26,6 +27,17 @@ var (
 
 type Store struct {
 	db *sqlx.DB
+
+	// keySource unlocks the secrets of an encrypted store, dataKey and
+	// secrets are their key and cipher once unlocked.
+	keySource *KeySource
+	dataKey   []byte
+	secrets   cipher.AEAD
+	secretsMu sync.Mutex
+
+	// apiURL is the URL of the Fewsats API the legacy credentials were
+	// paid on, if their macaroon does not tell.
+	apiURL string
 }
 
 func GetStore() *Store {
@@ -36,10 +48,21 @@ func GetStore() *Store {
 		}
 
 		instance, _ = NewStore(cfg.DBFilePath)
+		if instance != nil {
+			instance.SetKeySource(NewKeySource(cfg.StoreKeyFile))
+			instance.SetAPIURL(cfg.Domain)
+		}
 	})
 	return instance
 }
 
+// SetAPIURL sets the URL of the configured Fewsats API, the origin the legacy
+// credentials are used for if their macaroon does not tell where they were
+// paid.
+func (s *Store) SetAPIURL(apiURL string) {
+	s.apiURL = apiURL
+}
+
 func NewStore(dbPath string) (*Store, error) {
 	db, err := sqlx.Connect("sqlite3", dbPath)
 	if err != nil {
@@ -50,17 +73,7 @@ func NewStore(dbPath string) (*Store, error) {
 
 // RunMigrations applies the database migrations to the latest version.
 func (s *Store) RunMigrations() error {
-	driver, err := sqlite3.WithInstance(s.db.DB, &sqlite3.Config{})
-	if err != nil {
-		return err
-	}
-
-	src, err := httpfs.New(http.FS(sqlSchemas), "migrations")
-	if err != nil {
-		return err
-	}
-
-	m, err := migrate.NewWithInstance("httpfs", src, "sqlite3", driver)
+	m, err := s.migrator()
 	if err != nil {
 		return err
 	}
@@ -78,7 +91,27 @@ func (s *Store) RunMigrations() error {
 	return nil
 }
 
+// migrator returns the migrations of the database.
+func (s *Store) migrator() (*migrate.Migrate, error) {
+	driver, err := sqlite3.WithInstance(s.db.DB, &sqlite3.Config{})
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := httpfs.New(http.FS(sqlSchemas), "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	return migrate.NewWithInstance("httpfs", src, "sqlite3", driver)
+}
+
 func (s *Store) InsertAPIKey(key string, expiresAt time.Time, userID int64) (int64, error) {
+	key, err := s.sealValue(key)
+	if err != nil {
+		return 0, err
+	}
+
 	result, err := s.db.Exec("INSERT INTO api_keys (key, expires_at, user_id, enabled) VALUES (?, ?, ?, 1)", key, expiresAt, userID)
 	if err != nil {
 		return 0, err
@@ -95,14 +128,25 @@ func (s *Store) GetAPIKey() (string, error) {
 		}
 		return "", err
 	}
-	return apiKey, nil
+	return s.openValue(apiKey)
 }
 
 // GetEnabledAPIKeys retrieves all enabled API keys that have not expired.
 func (s *Store) GetEnabledAPIKeys() ([]APIKey, error) {
 	var apiKeys []APIKey
 	err := s.db.Select(&apiKeys, "SELECT * FROM api_keys WHERE expires_at > CURRENT_TIMESTAMP AND enabled = 1")
-	return apiKeys, err
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range apiKeys {
+		apiKeys[i].Key, err = s.openValue(apiKeys[i].Key)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return apiKeys, nil
 }
 
 // DisableAPIKey sets the enabled field of an API key to false.
@@ -110,3 +154,24 @@ func (s *Store) DisableAPIKey(id uint64) error {
 	_, err := s.db.Exec("UPDATE api_keys SET enabled = 0 WHERE id = ?", id)
 	return err
 }
+
+// execTx runs fn in a transaction, nothing is written if it fails.
+func (s *Store) execTx(fn func(tx *sqlx.Tx) error) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("unable to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = fn(tx)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("unable to commit transaction: %w", err)
+	}
+
+	return nil
+}
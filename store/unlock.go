@@ -0,0 +1,42 @@
+package store
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+)
+
+var unlockCommand = &cli.Command{
+	Name: "unlock",
+	Usage: "Decrypt the secrets of the store and store them in plaintext " +
+		"again.",
+	Flags: []cli.Flag{
+		keyFileFlag,
+	},
+	Action: unlockStore,
+}
+
+// unlockStore decrypts the secrets of an encrypted store for good.
+func unlockStore(c *cli.Context) error {
+	store, err := getStore(c)
+	if err != nil {
+		return err
+	}
+
+	configured := store.keySourceConfigured()
+	store.SetKeySource(commandKeySource(c, store.keySource, false))
+
+	err = store.DecryptSecrets()
+	if err != nil {
+		return storeKeyError("unlock", err)
+	}
+
+	fmt.Println("Store unlocked successfully.")
+	if configured {
+		fmt.Printf("Remove STORE_KEY_FILE from the profile and unset %s, "+
+			"or the store is locked again the next time it is used.\n",
+			PassphraseEnv)
+	}
+
+	return nil
+}
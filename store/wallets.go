@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/fewsats/fewsatscli/wallets"
+	"github.com/jmoiron/sqlx"
 )
 
 // GetDefaultWallet returns the default wallet in the database.
@@ -96,20 +97,74 @@ func (s *Store) GetWallet(id uint64) (*wallets.Wallet, error) {
 	return &wallet, nil
 }
 
-// DeleteWallet deletes the wallet with the given ID from the database.
-func (s *Store) DeleteWallet(id uint64) error {
+// ListWallets returns all the wallets stored in the database, oldest first.
+func (s *Store) ListWallets() ([]*wallets.Wallet, error) {
 	stmt := `
-		DELETE
+		SELECT id, wallet_type, created_at
 		FROM wallets
-		WHERE id = $1;
+		ORDER BY id;
 	`
 
-	_, err := s.db.Exec(stmt, id)
+	var walletList []*wallets.Wallet
+	err := s.db.Select(&walletList, stmt)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	return walletList, nil
+}
+
+// DeleteWallet deletes the wallet with the given ID from the database. If it
+// was the default wallet, the most recent remaining wallet becomes the default
+// one in the same transaction.
+func (s *Store) DeleteWallet(id uint64) error {
+	return s.execTx(func(tx *sqlx.Tx) error {
+		stmt := `
+			DELETE
+			FROM wallets
+			WHERE id = $1;
+		`
+
+		_, err := tx.Exec(stmt, id)
+		if err != nil {
+			return err
+		}
+
+		stmt = `
+			DELETE
+			FROM default_wallet
+			WHERE wallet_id = $1;
+		`
+
+		res, err := tx.Exec(stmt, id)
+		if err != nil {
+			return fmt.Errorf("failed to delete default wallet: %w", err)
+		}
+
+		deleted, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to delete default wallet: %w", err)
+		}
+
+		if deleted == 0 {
+			return nil
+		}
+
+		stmt = `
+			INSERT INTO default_wallet (wallet_id)
+			SELECT id
+			FROM wallets
+			ORDER BY id DESC
+			LIMIT 1;
+		`
+
+		_, err = tx.Exec(stmt)
+		if err != nil {
+			return fmt.Errorf("failed to set default wallet: %w", err)
+		}
+
+		return nil
+	})
 }
 
 // InsertWalletToken inserts a new wallet token in the database.
@@ -119,7 +174,12 @@ func (s *Store) InsertWalletToken(walletID uint64, token string) error {
 		VALUES ($1, $2);
 	`
 
-	_, err := s.db.Exec(stmt, walletID, token)
+	token, err := s.sealValue(token)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(stmt, walletID, token)
 	if err != nil {
 		return err
 	}
@@ -141,7 +201,7 @@ func (s *Store) GetWalletToken(id uint64) (string, error) {
 		return "", err
 	}
 
-	return token, nil
+	return s.openValue(token)
 }
 
 // DeleteWalletToken deletes the wallet token with the given ID from the database.
@@ -159,3 +219,149 @@ func (s *Store) DeleteWalletToken(id uint64) error {
 
 	return nil
 }
+
+// InsertLNDConnection inserts the connection parameters of an LND wallet in
+// the database.
+func (s *Store) InsertLNDConnection(conn *wallets.LNDConnection) error {
+	stmt := `
+		INSERT INTO lnd_wallets (
+			wallet_id, host, macaroon, tls_cert, fee_limit_sats,
+			timeout_seconds
+		) VALUES (
+			$1, $2, $3, $4, $5, $6
+		);
+	`
+
+	macaroon, err := s.sealValue(conn.Macaroon)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(
+		stmt, conn.WalletID, conn.Host, macaroon, conn.TLSCert,
+		conn.FeeLimitSats, conn.TimeoutSeconds,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetLNDConnection returns the connection parameters of the LND wallet with
+// the given ID.
+func (s *Store) GetLNDConnection(id uint64) (*wallets.LNDConnection, error) {
+	stmt := `
+		SELECT wallet_id, host, macaroon, tls_cert, fee_limit_sats,
+			timeout_seconds
+		FROM lnd_wallets
+		WHERE wallet_id = ?;
+	`
+
+	var conn wallets.LNDConnection
+	err := s.db.Get(&conn, stmt, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, wallets.ErrNoWalletFound
+		}
+
+		return nil, err
+	}
+
+	conn.Macaroon, err = s.openValue(conn.Macaroon)
+	if err != nil {
+		return nil, err
+	}
+
+	return &conn, nil
+}
+
+// DeleteLNDConnection deletes the connection parameters of the LND wallet
+// with the given ID from the database.
+func (s *Store) DeleteLNDConnection(id uint64) error {
+	stmt := `
+		DELETE
+		FROM lnd_wallets
+		WHERE wallet_id = $1;
+	`
+
+	_, err := s.db.Exec(stmt, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// InsertCLNConnection inserts the connection parameters of a CLN wallet in
+// the database.
+func (s *Store) InsertCLNConnection(conn *wallets.CLNConnection) error {
+	stmt := `
+		INSERT INTO cln_wallets (
+			wallet_id, host, rune, tls_cert, max_fee_percent,
+			retry_for_seconds
+		) VALUES (
+			$1, $2, $3, $4, $5, $6
+		);
+	`
+
+	rune, err := s.sealValue(conn.Rune)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(
+		stmt, conn.WalletID, conn.Host, rune, conn.TLSCert,
+		conn.MaxFeePercent, conn.RetryForSeconds,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetCLNConnection returns the connection parameters of the CLN wallet with
+// the given ID.
+func (s *Store) GetCLNConnection(id uint64) (*wallets.CLNConnection, error) {
+	stmt := `
+		SELECT wallet_id, host, rune, tls_cert, max_fee_percent,
+			retry_for_seconds
+		FROM cln_wallets
+		WHERE wallet_id = ?;
+	`
+
+	var conn wallets.CLNConnection
+	err := s.db.Get(&conn, stmt, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, wallets.ErrNoWalletFound
+		}
+
+		return nil, err
+	}
+
+	conn.Rune, err = s.openValue(conn.Rune)
+	if err != nil {
+		return nil, err
+	}
+
+	return &conn, nil
+}
+
+// DeleteCLNConnection deletes the connection parameters of the CLN wallet
+// with the given ID from the database.
+func (s *Store) DeleteCLNConnection(id uint64) error {
+	stmt := `
+		DELETE
+		FROM cln_wallets
+		WHERE wallet_id = $1;
+	`
+
+	_, err := s.db.Exec(stmt, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/fewsats/fewsatscli/wallets"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreListWallets(t *testing.T) {
+	store := newTestStore(t)
+
+	walletList, err := store.ListWallets()
+	require.NoError(t, err)
+	require.Empty(t, walletList)
+
+	albyID, err := store.InsertWallet(wallets.WalletTypeAlby)
+	require.NoError(t, err)
+
+	devID, err := store.InsertWallet(wallets.WalletTypeDev)
+	require.NoError(t, err)
+
+	walletList, err = store.ListWallets()
+	require.NoError(t, err)
+	require.Len(t, walletList, 2)
+	require.Equal(t, albyID, walletList[0].ID)
+	require.Equal(t, wallets.WalletTypeAlby, walletList[0].Type)
+	require.Equal(t, devID, walletList[1].ID)
+	require.Equal(t, wallets.WalletTypeDev, walletList[1].Type)
+}
+
+func TestStoreDeleteDefaultWallet(t *testing.T) {
+	store := newTestStore(t)
+
+	albyID, err := store.InsertWallet(wallets.WalletTypeAlby)
+	require.NoError(t, err)
+
+	devID, err := store.InsertWallet(wallets.WalletTypeDev)
+	require.NoError(t, err)
+
+	lndID, err := store.InsertWallet(wallets.WalletTypeLND)
+	require.NoError(t, err)
+
+	// Removing another wallet keeps the default one.
+	require.NoError(t, store.SetDefaultWallet(devID))
+	require.NoError(t, store.DeleteWallet(lndID))
+
+	defaultID, err := store.GetDefaultWallet()
+	require.NoError(t, err)
+	require.Equal(t, devID, defaultID)
+
+	// The most recent remaining wallet replaces the removed default one.
+	require.NoError(t, store.DeleteWallet(devID))
+
+	defaultID, err = store.GetDefaultWallet()
+	require.NoError(t, err)
+	require.Equal(t, albyID, defaultID)
+
+	// Removing the last wallet leaves no default wallet.
+	require.NoError(t, store.DeleteWallet(albyID))
+
+	_, err = store.GetDefaultWallet()
+	require.ErrorIs(t, err, wallets.ErrNoWalletFound)
+}
+
+func TestStoreLNDConnection(t *testing.T) {
+	store := newTestStore(t)
+
+	id, err := store.InsertWallet(wallets.WalletTypeLND)
+	require.NoError(t, err)
+
+	conn := &wallets.LNDConnection{
+		WalletID:       id,
+		Host:           "localhost:8080",
+		Macaroon:       "0201",
+		TLSCert:        "cert",
+		FeeLimitSats:   10,
+		TimeoutSeconds: 30,
+	}
+	require.NoError(t, store.InsertLNDConnection(conn))
+
+	got, err := store.GetLNDConnection(id)
+	require.NoError(t, err)
+	require.Equal(t, conn, got)
+
+	require.NoError(t, wallets.DeleteWallet(store, id))
+
+	_, err = store.GetLNDConnection(id)
+	require.ErrorIs(t, err, wallets.ErrNoWalletFound)
+
+	_, err = store.GetWallet(id)
+	require.ErrorIs(t, err, wallets.ErrNoWalletFound)
+}
+
+func TestStoreCLNConnection(t *testing.T) {
+	store := newTestStore(t)
+
+	id, err := store.InsertWallet(wallets.WalletTypeCLN)
+	require.NoError(t, err)
+
+	conn := &wallets.CLNConnection{
+		WalletID:        id,
+		Host:            "localhost:3010",
+		Rune:            "rune",
+		MaxFeePercent:   0.25,
+		RetryForSeconds: 30,
+	}
+	require.NoError(t, store.InsertCLNConnection(conn))
+
+	got, err := store.GetCLNConnection(id)
+	require.NoError(t, err)
+	require.Equal(t, conn, got)
+
+	require.NoError(t, wallets.DeleteWallet(store, id))
+
+	_, err = store.GetCLNConnection(id)
+	require.ErrorIs(t, err, wallets.ErrNoWalletFound)
+}
@@ -1,10 +1,8 @@
 package users
 
 import (
-	"encoding/json"
 	"fmt"
 	"log/slog"
-	"net/http"
 
 	"github.com/fewsats/fewsatscli/client"
 	"github.com/urfave/cli/v2"
@@ -23,19 +21,14 @@ func getBillingInformation(c *cli.Context) error {
 		return cli.Exit("Failed to create HTTP client", 1)
 	}
 
-	resp, err := client.ExecuteRequest(http.MethodGet, "/v0/users/billing", nil)
+	billingInfo, err := client.API().Users.GetBilling(c.Context)
 	if err != nil {
 		slog.Debug("Failed to get billing information.", "error", err)
-		return cli.Exit("Failed to get billing information", 1)
+		return cli.Exit(
+			fmt.Sprintf("Failed to get billing information: %v", err), 1,
+		)
 	}
-	defer resp.Body.Close()
 
-	var billingInfo BillingInformation
-	if err := json.NewDecoder(resp.Body).Decode(&billingInfo); err != nil {
-		slog.Debug("Failed to decode billing information.", "error", err)
-		return cli.Exit("Failed to decode billing information", 1)
-	}
-
-	fmt.Println("Billing Information:", billingInfo)
+	fmt.Println("Billing Information:", *billingInfo)
 	return nil
 }
@@ -1,10 +1,8 @@
 package users
 
 import (
-	"encoding/json"
 	"fmt"
 	"log/slog"
-	"net/http"
 
 	"github.com/fewsats/fewsatscli/client"
 	"github.com/urfave/cli/v2"
@@ -23,19 +21,12 @@ func getUserDetails(c *cli.Context) error {
 		return cli.Exit("Failed to create HTTP client", 1)
 	}
 
-	resp, err := client.ExecuteRequest(http.MethodGet, "/v0/users/details", nil)
+	userDetails, err := client.API().Users.Get(c.Context)
 	if err != nil {
 		slog.Debug("Failed to get user details.", "error", err)
-		return cli.Exit("Failed to get user details", 1)
+		return cli.Exit(fmt.Sprintf("Failed to get user details: %v", err), 1)
 	}
-	defer resp.Body.Close()
 
-	var userDetails User
-	if err := json.NewDecoder(resp.Body).Decode(&userDetails); err != nil {
-		slog.Debug("Failed to decode user details.", "error", err)
-		return cli.Exit("Failed to decode user details", 1)
-	}
-
-	fmt.Printf("User Details: %+v\n", userDetails)
+	fmt.Printf("User Details: %+v\n", *userDetails)
 	return nil
 }
@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
-	"net/http"
 	"os"
 
 	"github.com/fewsats/fewsatscli/client"
@@ -45,23 +44,19 @@ func updateBillingInformation(c *cli.Context) error {
 		return cli.Exit("Either --file or --json must be provided", 1)
 	}
 
-	reqBody, err := json.Marshal(billingInfo)
-	if err != nil {
-		return cli.Exit("Failed to marshal billing information", 1)
-	}
-
 	client, err := client.NewHTTPClient()
 	if err != nil {
 		slog.Debug("Failed to create HTTP client.", "error", err)
 		return cli.Exit("Failed to create HTTP client", 1)
 	}
 
-	resp, err := client.ExecuteRequest(http.MethodPut, "/v0/users/billing", reqBody)
+	err = client.API().Users.UpdateBilling(c.Context, &billingInfo)
 	if err != nil {
 		slog.Debug("Failed to update billing information.", "error", err)
-		return cli.Exit("Failed to update billing information", 1)
+		return cli.Exit(
+			fmt.Sprintf("Failed to update billing information: %v", err), 1,
+		)
 	}
-	defer resp.Body.Close()
 
 	fmt.Println("Billing information updated successfully.")
 	return nil
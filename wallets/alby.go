@@ -2,10 +2,12 @@ package wallets
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 )
 
 const (
@@ -40,6 +42,9 @@ func NewAlbyClient(apiKey string) *AlbyClient {
 // AlbyPaymentRequest is the request body for the Alby payment endpoint.
 type AlbyPaymentRequest struct {
 	Invoice string `json:"invoice"`
+
+	// Amount is the amount in sats to pay for amountless invoices.
+	Amount uint64 `json:"amount,omitempty"`
 }
 
 // AlbyPaymentResponse is the response body for the Alby payment endpoint.
@@ -49,12 +54,36 @@ type AlbyPaymentResponse struct {
 
 // GetPreimage returns the preimage for the given LN invoice.
 func (a *AlbyClient) GetPreimage(invoice string) (string, error) {
+	return a.pay(context.Background(), invoice, 0)
+}
+
+// GetPreimageContext returns the preimage for the given LN invoice, giving up
+// when the context is done.
+func (a *AlbyClient) GetPreimageContext(ctx context.Context,
+	invoice string) (string, error) {
+
+	return a.pay(ctx, invoice, 0)
+}
+
+// GetPreimageForAmount pays the amountless LN invoice with the given amount
+// and returns its preimage.
+func (a *AlbyClient) GetPreimageForAmount(ctx context.Context, invoice string,
+	amountSats uint64) (string, error) {
+
+	return a.pay(ctx, invoice, amountSats)
+}
+
+// pay pays the LN invoice, amountSats is only set for amountless invoices.
+func (a *AlbyClient) pay(ctx context.Context, invoice string,
+	amountSats uint64) (string, error) {
+
 	// Get the payment bolt 11 endpoint URL.
 	url := fmt.Sprintf("%s/payments/bolt11", albyURL)
 
 	// Create the request body.
 	body := AlbyPaymentRequest{
 		Invoice: invoice,
+		Amount:  amountSats,
 	}
 
 	// Convert the request body to JSON.
@@ -64,8 +93,8 @@ func (a *AlbyClient) GetPreimage(invoice string) (string, error) {
 	}
 
 	// Create the request.
-	req, err := http.NewRequest(
-		http.MethodPost, url, bytes.NewBuffer(reqBodyBytes),
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, url, bytes.NewBuffer(reqBodyBytes),
 	)
 	if err != nil {
 		return "", fmt.Errorf("unable to create request: %w", err)
@@ -103,3 +132,136 @@ func (a *AlbyClient) GetPreimage(invoice string) (string, error) {
 
 	return paymentResponse.PaymentPreimage, nil
 }
+
+// AlbyInvoiceResponse is the response body for the Alby invoice endpoint,
+// which returns both the incoming and the outgoing invoices.
+type AlbyInvoiceResponse struct {
+	Type     string `json:"type"`
+	State    string `json:"state"`
+	Settled  bool   `json:"settled"`
+	Preimage string `json:"preimage"`
+}
+
+// LookupPayment returns the status of the payment of the invoice with the
+// given payment hash.
+func (a *AlbyClient) LookupPayment(ctx context.Context,
+	paymentHash string) (*PaymentStatus, error) {
+
+	url := fmt.Sprintf("%s/invoices/%s", albyURL, paymentHash)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+a.APIKey)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read response body: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+
+	case http.StatusNotFound:
+		return nil, ErrPaymentNotFound
+
+	default:
+		return nil, fmt.Errorf("unexpected response(%d): %s",
+			resp.StatusCode, respBodyBytes)
+	}
+
+	var invoice AlbyInvoiceResponse
+	err = json.Unmarshal(respBodyBytes, &invoice)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse response body: %w", err)
+	}
+
+	switch {
+	case invoice.Type != "" && invoice.Type != "outgoing":
+		return nil, ErrPaymentNotFound
+
+	case invoice.Settled && invoice.Preimage != "":
+		return &PaymentStatus{
+			State:    PaymentSucceeded,
+			Preimage: invoice.Preimage,
+		}, nil
+
+	case strings.EqualFold(invoice.State, "FAILED") ||
+		strings.EqualFold(invoice.State, "CANCELLED"):
+
+		return &PaymentStatus{State: PaymentFailed}, nil
+	}
+
+	return &PaymentStatus{State: PaymentInFlight}, nil
+}
+
+// AlbyBalanceResponse is the response body for the Alby balance endpoint.
+type AlbyBalanceResponse struct {
+	Balance  int64  `json:"balance"`
+	Currency string `json:"currency"`
+	Unit     string `json:"unit"`
+}
+
+// CheckAuth checks that Alby accepts the token by reading the balance, the
+// token needs the balance:read scope.
+func (a *AlbyClient) CheckAuth(ctx context.Context) error {
+	_, err := a.Balance(ctx)
+	return err
+}
+
+// Balance returns the balance of the Alby account, in sats.
+func (a *AlbyClient) Balance(ctx context.Context) (uint64, error) {
+	url := fmt.Sprintf("%s/balance", albyURL)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+a.APIKey)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("unable to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("unable to read response body: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return 0, fmt.Errorf("%w: %s", ErrUnauthorized,
+			bytes.TrimSpace(respBodyBytes))
+
+	default:
+		return 0, fmt.Errorf("unexpected response(%d): %s",
+			resp.StatusCode, respBodyBytes)
+	}
+
+	var balance AlbyBalanceResponse
+	err = json.Unmarshal(respBodyBytes, &balance)
+	if err != nil {
+		return 0, fmt.Errorf("unable to parse response body: %w", err)
+	}
+
+	if balance.Balance < 0 {
+		return 0, nil
+	}
+
+	return uint64(balance.Balance), nil
+}
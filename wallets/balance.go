@@ -0,0 +1,62 @@
+package wallets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/urfave/cli/v2"
+)
+
+var balanceCommand = &cli.Command{
+	Name: "balance",
+	Usage: "Show the balance of the wallet. The balance of the default " +
+		"wallet is shown if no ID is given.",
+	ArgsUsage: "[id]",
+	Flags: []cli.Flag{
+		timeoutFlag,
+	},
+	Action: showBalance,
+}
+
+// showBalance prints the balance of the wallet with the given ID.
+func showBalance(c *cli.Context) error {
+	id, wallet, err := walletFromArgs(c)
+	if err != nil {
+		return err
+	}
+
+	provider, ok := wallet.(BalanceProvider)
+	if !ok {
+		return cli.Exit(fmt.Sprintf("wallet %d does not report its "+
+			"balance", id), 1)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context, c.Duration("timeout"))
+	defer cancel()
+
+	balance, err := provider.Balance(ctx)
+	if err != nil {
+		slog.Debug("Failed to get wallet balance.", "error", err)
+		return cli.Exit(fmt.Sprintf("failed to get wallet %d balance: %v",
+			id, err), 1)
+	}
+
+	response := struct {
+		WalletID    uint64 `json:"wallet_id"`
+		BalanceSats uint64 `json:"balance_sats"`
+	}{
+		WalletID:    id,
+		BalanceSats: balance,
+	}
+
+	jsonOutput, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		return cli.Exit("failed to marshal JSON", 1)
+	}
+
+	fmt.Println(string(jsonOutput))
+
+	return nil
+}
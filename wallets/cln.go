@@ -0,0 +1,413 @@
+package wallets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultCLNMaxFeePercent is the maximum routing fee paid per invoice,
+	// as a percentage of its amount, when none is set.
+	DefaultCLNMaxFeePercent = 0.5
+
+	// DefaultCLNRetryFor is the time the node keeps retrying a payment when
+	// none is set.
+	DefaultCLNRetryFor = 60 * time.Second
+)
+
+// The states of the CLN payments.
+const (
+	clnStatusComplete = "complete"
+	clnStatusFailed   = "failed"
+)
+
+// clnStateChannelNormal is the state of the channels able to send payments.
+const clnStateChannelNormal = "CHANNELD_NORMAL"
+
+// The error codes of the CLN pay command and of the clnrest runes.
+const (
+	clnCodeInProgress     = 200
+	clnCodeRuneNotAuthed  = 1501
+	clnCodeRuneNotAllowed = 1502
+)
+
+// CLNConnection holds the parameters to connect to the clnrest API of a Core
+// Lightning node.
+type CLNConnection struct {
+	WalletID uint64 `db:"wallet_id"`
+
+	// Host is the address of the clnrest API, like localhost:3010. HTTPS
+	// is used if no scheme is given.
+	Host string `db:"host"`
+
+	// Rune is the rune authenticating the requests.
+	Rune string `db:"rune"`
+
+	// TLSCert is the PEM encoded TLS certificate of the node, empty to
+	// use the system roots.
+	TLSCert string `db:"tls_cert"`
+
+	// MaxFeePercent is the maximum routing fee paid per invoice, as a
+	// percentage of its amount.
+	MaxFeePercent float64 `db:"max_fee_percent"`
+
+	// RetryForSeconds is the time the node keeps retrying a payment.
+	RetryForSeconds uint64 `db:"retry_for_seconds"`
+}
+
+// DeleteCLNWallet deletes the CLN wallet with the given ID.
+func DeleteCLNWallet(store Store, id uint64) error {
+	err := store.DeleteCLNConnection(id)
+	if err != nil {
+		return fmt.Errorf("unable to delete CLN connection: %w", err)
+	}
+
+	err = store.DeleteWallet(id)
+
+	return err
+}
+
+// CLNClient is a client paying the invoices with a Core Lightning node
+// through its clnrest plugin.
+type CLNClient struct {
+	// BaseURL is the URL of the clnrest API.
+	BaseURL string
+
+	// Rune is the rune authenticating the requests.
+	Rune string
+
+	// MaxFeePercent is the maximum routing fee paid per invoice, as a
+	// percentage of its amount.
+	MaxFeePercent float64
+
+	// RetryFor is the time the node keeps retrying a payment.
+	RetryFor time.Duration
+
+	// HTTPClient sends the requests, trusting the TLS certificate of the
+	// node.
+	HTTPClient *http.Client
+}
+
+// NewCLNClient returns a new client for the CLN node of the connection.
+func NewCLNClient(conn *CLNConnection) (*CLNClient, error) {
+	switch {
+	case conn.Host == "":
+		return nil, errors.New("the CLN host is required")
+
+	case conn.Rune == "":
+		return nil, errors.New("the CLN rune is required")
+
+	case conn.MaxFeePercent < 0 || conn.MaxFeePercent > 100:
+		return nil, errors.New("the CLN max fee percent must be " +
+			"between 0 and 100")
+	}
+
+	httpClient, err := newNodeHTTPClient(conn.TLSCert)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load CLN TLS certificate: %w",
+			err)
+	}
+
+	client := &CLNClient{
+		BaseURL:       nodeBaseURL(conn.Host),
+		Rune:          conn.Rune,
+		MaxFeePercent: conn.MaxFeePercent,
+		RetryFor:      time.Duration(conn.RetryForSeconds) * time.Second,
+		HTTPClient:    httpClient,
+	}
+	if client.MaxFeePercent == 0 {
+		client.MaxFeePercent = DefaultCLNMaxFeePercent
+	}
+	if client.RetryFor == 0 {
+		client.RetryFor = DefaultCLNRetryFor
+	}
+
+	return client, nil
+}
+
+// CLNPayRequest is the request body for the clnrest pay endpoint.
+type CLNPayRequest struct {
+	Bolt11        string  `json:"bolt11"`
+	MaxFeePercent float64 `json:"maxfeepercent"`
+	RetryFor      uint64  `json:"retry_for"`
+
+	// AmountMsat is the amount to pay for amountless invoices.
+	AmountMsat uint64 `json:"amount_msat,omitempty"`
+}
+
+// CLNPayment is a payment of the CLN node.
+type CLNPayment struct {
+	PaymentHash     string `json:"payment_hash"`
+	PaymentPreimage string `json:"payment_preimage"`
+	Status          string `json:"status"`
+
+	// AmountMsat is the amount delivered to the payee and AmountSentMsat
+	// the amount sent including the routing fees.
+	AmountMsat     uint64 `json:"amount_msat"`
+	AmountSentMsat uint64 `json:"amount_sent_msat"`
+}
+
+// CLNError is an error returned by the clnrest API. It matches the typed
+// wallet error of its cause with errors.Is.
+type CLNError struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+
+	// Code is the error code of the CLN command.
+	Code int `json:"code"`
+
+	// Message is the error message sent by the node.
+	Message string `json:"message"`
+}
+
+// Error returns the error message.
+func (e *CLNError) Error() string {
+	return fmt.Sprintf("CLN error(%d): %s", e.Code, e.Message)
+}
+
+// Unwrap returns the typed wallet error of the CLN error, nil if there is
+// none.
+func (e *CLNError) Unwrap() error {
+	message := strings.ToLower(e.Message)
+
+	switch {
+	case e.StatusCode == http.StatusUnauthorized ||
+		e.StatusCode == http.StatusForbidden ||
+		e.Code == clnCodeRuneNotAuthed ||
+		e.Code == clnCodeRuneNotAllowed:
+
+		return ErrUnauthorized
+
+	case strings.Contains(message, "insufficient") ||
+		strings.Contains(message, "not enough"):
+
+		return ErrInsufficientBalance
+
+	case e.Code == clnCodeInProgress:
+		return nil
+
+	// The pay command fails with the 2xx codes, nothing was paid.
+	case e.Code >= 200 && e.Code < 300:
+		return ErrPaymentFailed
+	}
+
+	return nil
+}
+
+// GetPreimage returns the preimage for the given LN invoice.
+func (c *CLNClient) GetPreimage(invoice string) (string, error) {
+	return c.GetPreimageContext(context.Background(), invoice)
+}
+
+// GetPreimageContext returns the preimage for the given LN invoice, giving up
+// when the context is done.
+func (c *CLNClient) GetPreimageContext(ctx context.Context,
+	invoice string) (string, error) {
+
+	return c.GetPreimageForAmount(ctx, invoice, 0)
+}
+
+// GetPreimageForAmount pays the amountless LN invoice with the given amount
+// and returns its preimage.
+func (c *CLNClient) GetPreimageForAmount(ctx context.Context, invoice string,
+	amountSats uint64) (string, error) {
+
+	result, err := c.PayInvoice(ctx, invoice, amountSats)
+	if err != nil {
+		return "", err
+	}
+
+	return result.Preimage, nil
+}
+
+// PayInvoice pays the LN invoice with the pay command, which returns once
+// the payment settles or fails, amountSats is only set for amountless
+// invoices.
+func (c *CLNClient) PayInvoice(ctx context.Context, invoice string,
+	amountSats uint64) (*PaymentResult, error) {
+
+	// The node gives up after retry_for, the request is given a bit more
+	// to get the outcome.
+	ctx, cancel := context.WithTimeout(ctx, c.RetryFor+10*time.Second)
+	defer cancel()
+
+	var payment CLNPayment
+	err := c.call(ctx, "/v1/pay", &CLNPayRequest{
+		Bolt11:        invoice,
+		MaxFeePercent: c.MaxFeePercent,
+		RetryFor:      uint64(c.RetryFor / time.Second),
+		AmountMsat:    amountSats * 1000,
+	}, &payment)
+	if err != nil {
+		return nil, err
+	}
+
+	switch payment.Status {
+	case clnStatusComplete:
+		result := &PaymentResult{Preimage: payment.PaymentPreimage}
+		if payment.AmountSentMsat > payment.AmountMsat {
+			result.FeeSats = msatToSats(
+				payment.AmountSentMsat - payment.AmountMsat,
+			)
+		}
+
+		return result, nil
+
+	case clnStatusFailed:
+		return nil, fmt.Errorf("CLN payment failed: %w", ErrPaymentFailed)
+	}
+
+	return nil, fmt.Errorf("CLN payment %s is %s", payment.PaymentHash,
+		payment.Status)
+}
+
+// LookupPayment returns the status of the payment of the invoice with the
+// given payment hash.
+func (c *CLNClient) LookupPayment(ctx context.Context,
+	paymentHash string) (*PaymentStatus, error) {
+
+	var response struct {
+		Pays []struct {
+			Status   string `json:"status"`
+			Preimage string `json:"preimage"`
+		} `json:"pays"`
+	}
+	err := c.call(ctx, "/v1/listpays", map[string]string{
+		"payment_hash": paymentHash,
+	}, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(response.Pays) == 0 {
+		return nil, ErrPaymentNotFound
+	}
+
+	// An invoice is only paid once, any completed attempt settled it.
+	failed := true
+	for _, pay := range response.Pays {
+		switch pay.Status {
+		case clnStatusComplete:
+			return &PaymentStatus{
+				State:    PaymentSucceeded,
+				Preimage: pay.Preimage,
+			}, nil
+
+		case clnStatusFailed:
+
+		default:
+			failed = false
+		}
+	}
+
+	if failed {
+		return &PaymentStatus{State: PaymentFailed}, nil
+	}
+
+	return &PaymentStatus{State: PaymentInFlight}, nil
+}
+
+// CheckAuth checks that the node accepts the rune by reading its info.
+func (c *CLNClient) CheckAuth(ctx context.Context) error {
+	var info struct {
+		ID string `json:"id"`
+	}
+
+	return c.call(ctx, "/v1/getinfo", struct{}{}, &info)
+}
+
+// Balance returns the balance of the usable channels of the node, in sats.
+func (c *CLNClient) Balance(ctx context.Context) (uint64, error) {
+	var funds struct {
+		Channels []struct {
+			State         string `json:"state"`
+			OurAmountMsat uint64 `json:"our_amount_msat"`
+		} `json:"channels"`
+	}
+	err := c.call(ctx, "/v1/listfunds", struct{}{}, &funds)
+	if err != nil {
+		return 0, err
+	}
+
+	var balanceMsat uint64
+	for _, channel := range funds.Channels {
+		if channel.State == clnStateChannelNormal {
+			balanceMsat += channel.OurAmountMsat
+		}
+	}
+
+	return balanceMsat / 1000, nil
+}
+
+// call runs a CLN command through the clnrest API and decodes its result
+// into out.
+func (c *CLNClient) call(ctx context.Context, path string, in,
+	out any) error {
+
+	reqBodyBytes, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("unable to encode request body: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, c.BaseURL+path, bytes.NewReader(reqBodyBytes),
+	)
+	if err != nil {
+		return fmt.Errorf("unable to create request: %w", err)
+	}
+
+	req.Header.Set("Rune", c.Rune)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("unable to read response body: %w", err)
+	}
+
+	if resp.StatusCode >= 300 {
+		// The command errors are sent as is, the clnrest ones wrapped in
+		// an error field.
+		var response struct {
+			CLNError
+			Error *CLNError `json:"error"`
+		}
+		clnErr := &response.CLNError
+		if json.Unmarshal(respBodyBytes, &response) == nil &&
+			response.Error != nil {
+
+			clnErr = response.Error
+		}
+		if clnErr.Message == "" {
+			clnErr.Message = strings.TrimSpace(string(respBodyBytes))
+		}
+		clnErr.StatusCode = resp.StatusCode
+
+		return clnErr
+	}
+
+	err = json.Unmarshal(respBodyBytes, out)
+	if err != nil {
+		return fmt.Errorf("unable to parse response body: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,212 @@
+package wallets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fewsats/fewsatscli/fewsatstest"
+	"github.com/stretchr/testify/require"
+)
+
+// clnStandIn is a local stand-in for the clnrest API.
+type clnStandIn struct {
+	t *testing.T
+
+	// status and response are the answer to the pay and listpays
+	// commands.
+	status   int
+	response string
+
+	request CLNPayRequest
+}
+
+func (c *clnStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Rune") != "test-rune" {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"error": {"code": 1501, `+
+			`"message": "Not authorized: Not derived from master"}}`)
+		return
+	}
+
+	if r.URL.Path == "/v1/pay" {
+		require.NoError(c.t, json.NewDecoder(r.Body).Decode(&c.request))
+	}
+
+	if c.status != 0 {
+		w.WriteHeader(c.status)
+	}
+	fmt.Fprint(w, c.response)
+}
+
+func TestCLNClient(t *testing.T) {
+	srv, err := fewsatstest.New(fewsatstest.Config{})
+	require.NoError(t, err)
+
+	invoice, preimage, err := srv.CreateInvoice(21, "cln")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		rune      string
+		standIn   *clnStandIn
+		wantFee   uint64
+		wantError error
+	}{
+		{
+			name: "complete",
+			rune: "test-rune",
+			standIn: &clnStandIn{
+				response: `{"status": "complete", ` +
+					`"payment_preimage": "` + preimage + `", ` +
+					`"amount_msat": 21000, ` +
+					`"amount_sent_msat": 21042}`,
+			},
+			wantFee: 1,
+		},
+		{
+			name: "no route",
+			rune: "test-rune",
+			standIn: &clnStandIn{
+				status: http.StatusInternalServerError,
+				response: `{"code": 205, ` +
+					`"message": "Ran out of routes to try"}`,
+			},
+			wantError: ErrPaymentFailed,
+		},
+		{
+			name: "insufficient balance",
+			rune: "test-rune",
+			standIn: &clnStandIn{
+				status: http.StatusInternalServerError,
+				response: `{"code": 205, "message": "We have ` +
+					`insufficient balance to pay this invoice"}`,
+			},
+			wantError: ErrInsufficientBalance,
+		},
+		{
+			name:      "wrong rune",
+			rune:      "other-rune",
+			standIn:   &clnStandIn{},
+			wantError: ErrUnauthorized,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.standIn.t = t
+			server := httptest.NewServer(tc.standIn)
+			defer server.Close()
+
+			client, err := NewCLNClient(&CLNConnection{
+				Host:            server.URL,
+				Rune:            tc.rune,
+				MaxFeePercent:   1.5,
+				RetryForSeconds: 30,
+			})
+			require.NoError(t, err)
+
+			got, err := client.PayInvoice(
+				context.Background(), invoice, 0,
+			)
+			if tc.wantError != nil {
+				require.ErrorIs(t, err, tc.wantError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, preimage, got.Preimage)
+			require.Equal(t, tc.wantFee, got.FeeSats)
+
+			// The fee and retry settings are sent with the payment.
+			require.Equal(t, CLNPayRequest{
+				Bolt11:        invoice,
+				MaxFeePercent: 1.5,
+				RetryFor:      30,
+			}, tc.standIn.request)
+		})
+	}
+}
+
+func TestCLNClientLookupPayment(t *testing.T) {
+	tests := []struct {
+		name      string
+		response  string
+		wantState PaymentState
+		wantError error
+	}{
+		{
+			name: "complete",
+			response: `{"pays": [{"status": "failed"}, ` +
+				`{"status": "complete", "preimage": "00"}]}`,
+			wantState: PaymentSucceeded,
+		},
+		{
+			name:      "pending",
+			response:  `{"pays": [{"status": "pending"}]}`,
+			wantState: PaymentInFlight,
+		},
+		{
+			name:      "failed",
+			response:  `{"pays": [{"status": "failed"}]}`,
+			wantState: PaymentFailed,
+		},
+		{
+			name:      "not found",
+			response:  `{"pays": []}`,
+			wantError: ErrPaymentNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			standIn := &clnStandIn{t: t, response: tc.response}
+			server := httptest.NewServer(standIn)
+			defer server.Close()
+
+			client, err := NewCLNClient(&CLNConnection{
+				Host: server.URL,
+				Rune: "test-rune",
+			})
+			require.NoError(t, err)
+
+			status, err := client.LookupPayment(
+				context.Background(), "00",
+			)
+			if tc.wantError != nil {
+				require.ErrorIs(t, err, tc.wantError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.wantState, status.State)
+		})
+	}
+}
+
+func TestCLNClientBalance(t *testing.T) {
+	standIn := &clnStandIn{
+		t: t,
+		response: `{"channels": [` +
+			`{"state": "CHANNELD_NORMAL", "our_amount_msat": 1000000}, ` +
+			`{"state": "ONCHAIN", "our_amount_msat": 5000000}, ` +
+			`{"state": "CHANNELD_NORMAL", "our_amount_msat": 234999}]}`,
+	}
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	client, err := NewCLNClient(&CLNConnection{
+		Host: server.URL,
+		Rune: "test-rune",
+	})
+	require.NoError(t, err)
+
+	balance, err := client.Balance(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, uint64(1234), balance)
+
+	client.Rune = "other-rune"
+	require.ErrorIs(t, client.CheckAuth(context.Background()),
+		ErrUnauthorized)
+}
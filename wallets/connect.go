@@ -1,8 +1,13 @@
 package wallets
 
 import (
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/urfave/cli/v2"
 )
@@ -29,6 +34,54 @@ func connectWallet(c *cli.Context) error {
 			return err
 		}
 
+	case WalletTypeDev:
+		_, err := connectDevWallet(store, DevConfig{
+			ServerURL:   c.String("server-url"),
+			PreimageDir: c.String("preimage-dir"),
+			Mode:        c.String("mode"),
+			Delay:       c.Duration("delay"),
+		})
+		if err != nil {
+			return err
+		}
+
+	case WalletTypeLND:
+		conn, err := lndConnectionFromFlags(c)
+		if err != nil {
+			return err
+		}
+
+		_, err = connectLNDWallet(store, conn)
+		if err != nil {
+			return err
+		}
+
+	case WalletTypeCLN:
+		conn, err := clnConnectionFromFlags(c)
+		if err != nil {
+			return err
+		}
+
+		_, err = connectCLNWallet(store, conn)
+		if err != nil {
+			return err
+		}
+
+	case WalletTypeNWC:
+		_, err := connectNWCWallet(store, c.String("uri"))
+		if err != nil {
+			return err
+		}
+
+	case WalletTypeLNbits:
+		_, err := connectLNbitsWallet(store, LNbitsConfig{
+			URL:      c.String("server-url"),
+			AdminKey: strings.TrimSpace(c.String("token")),
+		})
+		if err != nil {
+			return err
+		}
+
 	default:
 		return fmt.Errorf("unsupported wallet type: %s", walletType)
 	}
@@ -52,3 +105,167 @@ func connectTokenWallet(store Store, walletType, apiKey string) (uint64,
 
 	return id, nil
 }
+
+// connectDevWallet connects a new dev wallet with the given configuration,
+// stored as its token.
+func connectDevWallet(store Store, cfg DevConfig) (uint64, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return 0, err
+	}
+
+	token, err := json.Marshal(cfg)
+	if err != nil {
+		return 0, fmt.Errorf("unable to encode dev wallet config: %w", err)
+	}
+
+	return connectTokenWallet(store, WalletTypeDev, string(token))
+}
+
+// connectLNbitsWallet connects a new LNbits wallet with the given
+// configuration, stored as its token.
+func connectLNbitsWallet(store Store, cfg LNbitsConfig) (uint64, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return 0, err
+	}
+
+	token, err := json.Marshal(cfg)
+	if err != nil {
+		return 0, fmt.Errorf("unable to encode LNbits wallet config: %w",
+			err)
+	}
+
+	return connectTokenWallet(store, WalletTypeLNbits, string(token))
+}
+
+// lndConnectionFromFlags reads the LND connection parameters of the connect
+// command flags, loading the macaroon and the TLS certificate files.
+func lndConnectionFromFlags(c *cli.Context) (*LNDConnection, error) {
+	conn := &LNDConnection{
+		Host:           c.String("host"),
+		Macaroon:       strings.TrimSpace(c.String("macaroon-hex")),
+		FeeLimitSats:   c.Uint64("fee-limit"),
+		TimeoutSeconds: uint64(c.Duration("payment-timeout") / time.Second),
+	}
+
+	if path := c.String("macaroon-path"); path != "" {
+		if conn.Macaroon != "" {
+			return nil, errors.New("only one of macaroon-path and " +
+				"macaroon-hex can be set")
+		}
+
+		macaroon, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read macaroon: %w", err)
+		}
+		conn.Macaroon = hex.EncodeToString(macaroon)
+	}
+
+	cert, err := tlsCertFromFlags(c)
+	if err != nil {
+		return nil, err
+	}
+	conn.TLSCert = cert
+
+	return conn, nil
+}
+
+// connectLNDWallet connects a new LND wallet with the given connection
+// parameters.
+func connectLNDWallet(store Store, conn *LNDConnection) (uint64, error) {
+	// The connection parameters are checked before storing them.
+	_, err := NewLNDClient(conn)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := store.InsertWallet(WalletTypeLND)
+	if err != nil {
+		return 0, fmt.Errorf("unable to insert wallet: %w", err)
+	}
+
+	conn.WalletID = id
+	err = store.InsertLNDConnection(conn)
+	if err != nil {
+		store.DeleteWallet(id)
+		return 0, fmt.Errorf("unable to insert LND connection: %w", err)
+	}
+
+	return id, nil
+}
+
+// clnConnectionFromFlags reads the CLN connection parameters of the connect
+// command flags, loading the TLS certificate file.
+func clnConnectionFromFlags(c *cli.Context) (*CLNConnection, error) {
+	conn := &CLNConnection{
+		Host:            c.String("host"),
+		Rune:            strings.TrimSpace(c.String("rune")),
+		MaxFeePercent:   c.Float64("max-fee-percent"),
+		RetryForSeconds: uint64(c.Duration("retry-for") / time.Second),
+	}
+
+	cert, err := tlsCertFromFlags(c)
+	if err != nil {
+		return nil, err
+	}
+	conn.TLSCert = cert
+
+	return conn, nil
+}
+
+// tlsCertFromFlags reads the TLS certificate file of the connect command
+// flags, an empty string is returned if none is set.
+func tlsCertFromFlags(c *cli.Context) (string, error) {
+	path := c.String("tls-cert-path")
+	if path == "" {
+		return "", nil
+	}
+
+	cert, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("unable to read TLS certificate: %w", err)
+	}
+
+	return string(cert), nil
+}
+
+// connectCLNWallet connects a new CLN wallet with the given connection
+// parameters.
+func connectCLNWallet(store Store, conn *CLNConnection) (uint64, error) {
+	// The connection parameters are checked before storing them.
+	_, err := NewCLNClient(conn)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := store.InsertWallet(WalletTypeCLN)
+	if err != nil {
+		return 0, fmt.Errorf("unable to insert wallet: %w", err)
+	}
+
+	conn.WalletID = id
+	err = store.InsertCLNConnection(conn)
+	if err != nil {
+		store.DeleteWallet(id)
+		return 0, fmt.Errorf("unable to insert CLN connection: %w", err)
+	}
+
+	return id, nil
+}
+
+// connectNWCWallet connects a new NWC wallet with the given
+// nostr+walletconnect:// URI, stored as its token.
+func connectNWCWallet(store Store, uri string) (uint64, error) {
+	if uri == "" {
+		return 0, errors.New("uri argument is required for nwc wallets")
+	}
+
+	// The URI is checked before storing it.
+	_, err := ParseNWCURI(uri)
+	if err != nil {
+		return 0, err
+	}
+
+	return connectTokenWallet(store, WalletTypeNWC, strings.TrimSpace(uri))
+}
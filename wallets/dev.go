@@ -0,0 +1,301 @@
+package wallets
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fewsats/fewsatscli/invoices"
+)
+
+const (
+	// DevModeSucceed makes the dev wallet return the right preimages.
+	DevModeSucceed = "succeed"
+
+	// DevModeFail makes every payment of the dev wallet fail.
+	DevModeFail = "fail"
+
+	// DevModeWrongPreimage makes the dev wallet return a preimage not
+	// matching the payment hash of the invoices.
+	DevModeWrongPreimage = "wrong-preimage"
+)
+
+var (
+	// ErrDevPaymentFailed is the error returned by the dev wallet set up to
+	// fail. It matches ErrPaymentFailed.
+	ErrDevPaymentFailed = fmt.Errorf("dev wallet: %w", ErrPaymentFailed)
+
+	// AllDevModes are the modes of the dev wallet.
+	AllDevModes = []string{
+		DevModeSucceed,
+		DevModeFail,
+		DevModeWrongPreimage,
+	}
+)
+
+// DevConfig is the configuration of a dev wallet.
+type DevConfig struct {
+	// ServerURL is the base URL of a local stand-in server, like the one
+	// of `fewsatscli dev server`, settling its invoices with
+	// POST /dev/pay.
+	ServerURL string `json:"server_url,omitempty"`
+
+	// PreimageDir is a directory holding the preimages of the invoices, in
+	// files named after their hex encoded payment hash. It is only used if
+	// ServerURL is empty.
+	PreimageDir string `json:"preimage_dir,omitempty"`
+
+	// Mode is one of AllDevModes, DevModeSucceed if empty.
+	Mode string `json:"mode,omitempty"`
+
+	// Delay is the time every payment takes.
+	Delay time.Duration `json:"delay,omitempty"`
+}
+
+// Validate checks that the configuration is complete.
+func (c *DevConfig) Validate() error {
+	if c.ServerURL == "" && c.PreimageDir == "" {
+		return errors.New("a server URL or a preimage dir is required " +
+			"for dev wallets")
+	}
+
+	switch c.Mode {
+	case "", DevModeSucceed, DevModeFail, DevModeWrongPreimage:
+
+	default:
+		return fmt.Errorf("unknown dev wallet mode %q, expected one of "+
+			"{%s}", c.Mode, strings.Join(AllDevModes, ", "))
+	}
+
+	if c.Delay < 0 {
+		return errors.New("dev wallet delay can not be negative")
+	}
+
+	return nil
+}
+
+// DeleteDevWallet deletes the dev wallet with the given ID.
+func DeleteDevWallet(store Store, id uint64) error {
+	err := store.DeleteWalletToken(id)
+	if err != nil {
+		return fmt.Errorf("unable to delete wallet token: %w", err)
+	}
+
+	err = store.DeleteWallet(id)
+
+	return err
+}
+
+// DevWallet is a fake wallet for offline development and tests. It "pays"
+// the invoices of a local stand-in L402 server, without moving any sats, and
+// can be set up to fail, delay the payments or return wrong preimages.
+type DevWallet struct {
+	// Config is the configuration of the wallet.
+	Config DevConfig
+
+	// HTTPClient sends the requests to the stand-in server. If nil,
+	// http.DefaultClient is used.
+	HTTPClient *http.Client
+}
+
+// NewDevWallet returns a new dev wallet with the given configuration.
+func NewDevWallet(cfg DevConfig) (*DevWallet, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return &DevWallet{
+		Config: cfg,
+	}, nil
+}
+
+// newDevWalletFromToken returns the dev wallet of the stored configuration.
+func newDevWalletFromToken(token string) (*DevWallet, error) {
+	var cfg DevConfig
+	err := json.Unmarshal([]byte(token), &cfg)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse dev wallet config: %w", err)
+	}
+
+	return NewDevWallet(cfg)
+}
+
+// GetPreimage returns the preimage for the given LN invoice.
+func (d *DevWallet) GetPreimage(invoice string) (string, error) {
+	return d.GetPreimageContext(context.Background(), invoice)
+}
+
+// GetPreimageContext returns the preimage for the given LN invoice, giving up
+// when the context is done.
+func (d *DevWallet) GetPreimageContext(ctx context.Context,
+	invoice string) (string, error) {
+
+	if d.Config.Delay > 0 {
+		timer := time.NewTimer(d.Config.Delay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+
+	if d.Config.Mode == DevModeFail {
+		return "", ErrDevPaymentFailed
+	}
+
+	var (
+		preimage string
+		err      error
+	)
+	if d.Config.ServerURL != "" {
+		preimage, err = d.payServer(ctx, invoice)
+	} else {
+		preimage, err = d.readPreimage(invoice)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if d.Config.Mode == DevModeWrongPreimage {
+		return wrongPreimage(preimage), nil
+	}
+
+	return preimage, nil
+}
+
+// CheckAuth checks that the stand-in server answers, or that the preimage dir
+// exists.
+func (d *DevWallet) CheckAuth(ctx context.Context) error {
+	if d.Config.ServerURL == "" {
+		info, err := os.Stat(d.Config.PreimageDir)
+		if err != nil {
+			return fmt.Errorf("unable to read preimage dir: %w", err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("preimage dir %s is not a directory",
+				d.Config.PreimageDir)
+		}
+
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodGet, d.Config.ServerURL, nil,
+	)
+	if err != nil {
+		return fmt.Errorf("unable to create request: %w", err)
+	}
+
+	client := d.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to reach dev server: %w", err)
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// payServer settles the invoice with the stand-in server.
+func (d *DevWallet) payServer(ctx context.Context,
+	invoice string) (string, error) {
+
+	url := strings.TrimRight(d.Config.ServerURL, "/") + "/dev/pay"
+
+	reqBodyBytes, err := json.Marshal(map[string]string{
+		"invoice": invoice,
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to encode request body: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, url, bytes.NewReader(reqBodyBytes),
+	)
+	if err != nil {
+		return "", fmt.Errorf("unable to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := d.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("unable to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("unable to read response body: %w", err)
+	}
+
+	switch {
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		// Unknown or expired invoices.
+		return "", fmt.Errorf("%w: %s", ErrDevPaymentFailed,
+			bytes.TrimSpace(respBodyBytes))
+
+	case resp.StatusCode != http.StatusOK:
+		return "", fmt.Errorf("unexpected response(%d): %s",
+			resp.StatusCode, respBodyBytes)
+	}
+
+	var paymentResponse struct {
+		Preimage string `json:"preimage"`
+	}
+	err = json.Unmarshal(respBodyBytes, &paymentResponse)
+	if err != nil {
+		return "", fmt.Errorf("unable to parse response body: %w", err)
+	}
+
+	return paymentResponse.Preimage, nil
+}
+
+// readPreimage reads the preimage of the invoice from the preimage dir.
+func (d *DevWallet) readPreimage(invoice string) (string, error) {
+	details, err := invoices.Parse(invoice)
+	if err != nil {
+		return "", fmt.Errorf("unable to decode invoice: %w", err)
+	}
+
+	preimage, err := os.ReadFile(
+		filepath.Join(d.Config.PreimageDir, details.PaymentHash),
+	)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("no preimage for payment hash %s: %w",
+			details.PaymentHash, ErrDevPaymentFailed)
+	}
+	if err != nil {
+		return "", fmt.Errorf("unable to read preimage: %w", err)
+	}
+
+	return strings.TrimSpace(string(preimage)), nil
+}
+
+// wrongPreimage derives a preimage not matching the payment hash from the
+// right one, so the wrong preimages are deterministic too.
+func wrongPreimage(preimage string) string {
+	hash := sha256.Sum256([]byte(preimage))
+	return hex.EncodeToString(hash[:])
+}
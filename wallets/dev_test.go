@@ -0,0 +1,109 @@
+package wallets
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fewsats/fewsatscli/fewsatstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDevWallet(t *testing.T) {
+	dir := t.TempDir()
+	srv, err := fewsatstest.New(fewsatstest.Config{PreimageDir: dir})
+	require.NoError(t, err)
+
+	httpServer := httptest.NewServer(srv)
+	defer httpServer.Close()
+
+	tests := []struct {
+		name      string
+		cfg       DevConfig
+		wantError error
+		wantWrong bool
+	}{
+		{
+			name: "server",
+			cfg:  DevConfig{ServerURL: httpServer.URL},
+		},
+		{
+			name: "preimage dir",
+			cfg:  DevConfig{PreimageDir: dir},
+		},
+		{
+			name:      "fail",
+			cfg:       DevConfig{ServerURL: httpServer.URL, Mode: DevModeFail},
+			wantError: ErrDevPaymentFailed,
+		},
+		{
+			name: "wrong preimage",
+			cfg: DevConfig{
+				PreimageDir: dir,
+				Mode:        DevModeWrongPreimage,
+			},
+			wantWrong: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			invoice, preimage, err := srv.CreateInvoice(10, tc.name)
+			require.NoError(t, err)
+
+			wallet, err := NewDevWallet(tc.cfg)
+			require.NoError(t, err)
+
+			got, err := wallet.GetPreimage(invoice)
+			if tc.wantError != nil {
+				require.ErrorIs(t, err, tc.wantError)
+				return
+			}
+			require.NoError(t, err)
+
+			if tc.wantWrong {
+				require.NotEqual(t, preimage, got)
+				require.Len(t, got, len(preimage))
+				return
+			}
+			require.Equal(t, preimage, got)
+		})
+	}
+
+	// Invoices not issued by the stand-in are never paid.
+	wallet, err := NewDevWallet(DevConfig{PreimageDir: t.TempDir()})
+	require.NoError(t, err)
+
+	invoice, _, err := srv.CreateInvoice(10, "unknown")
+	require.NoError(t, err)
+
+	_, err = wallet.GetPreimage(invoice)
+	require.ErrorIs(t, err, ErrDevPaymentFailed)
+}
+
+func TestDevWalletDelay(t *testing.T) {
+	wallet, err := NewDevWallet(DevConfig{
+		PreimageDir: t.TempDir(),
+		Delay:       time.Minute,
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(
+		context.Background(), 10*time.Millisecond,
+	)
+	defer cancel()
+
+	_, err = wallet.GetPreimageContext(ctx, "lnbc1")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestDevConfigValidate(t *testing.T) {
+	require.Error(t, (&DevConfig{}).Validate())
+	require.Error(t, (&DevConfig{PreimageDir: "x", Mode: "maybe"}).Validate())
+	require.Error(t, (&DevConfig{PreimageDir: "x", Delay: -1}).Validate())
+	require.NoError(t, (&DevConfig{
+		ServerURL: "http://127.0.0.1:8081",
+		Mode:      DevModeWrongPreimage,
+	}).Validate())
+}
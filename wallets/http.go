@@ -0,0 +1,37 @@
+package wallets
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// newNodeHTTPClient returns an HTTP client trusting the PEM encoded TLS
+// certificate of a self-hosted node, or the system roots if it is empty.
+func newNodeHTTPClient(certPEM string) (*http.Client, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if certPEM != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(certPEM)) {
+			return nil, errors.New("invalid TLS certificate")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	return &http.Client{Transport: transport}, nil
+}
+
+// nodeBaseURL returns the base URL of the REST API of a node at the given
+// host, HTTPS is used if no scheme is given.
+func nodeBaseURL(host string) string {
+	if !strings.Contains(host, "://") {
+		host = "https://" + host
+	}
+
+	return strings.TrimRight(host, "/")
+}
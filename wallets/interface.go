@@ -1,11 +1,165 @@
 package wallets
 
+import (
+	"context"
+	"errors"
+)
+
 // PreimageProvider is an interface for providing preimages for LN invoices.
 type PreimageProvider interface {
 	// GetPreimage returns the preimage for the given LN invoice.
 	GetPreimage(invoice string) (string, error)
 }
 
+// ContextPreimageProvider is implemented by the wallets whose payments can be
+// cancelled or bounded by a deadline.
+type ContextPreimageProvider interface {
+	// GetPreimageContext returns the preimage for the given LN invoice,
+	// giving up when the context is done.
+	GetPreimageContext(ctx context.Context, invoice string) (string, error)
+}
+
+// AmountPreimageProvider is implemented by the wallets able to pay amountless
+// LN invoices.
+type AmountPreimageProvider interface {
+	// GetPreimageForAmount pays the given amountless LN invoice with the
+	// given amount and returns its preimage.
+	GetPreimageForAmount(ctx context.Context, invoice string,
+		amountSats uint64) (string, error)
+}
+
+// PaymentResult is the outcome of a payment sent by a wallet.
+type PaymentResult struct {
+	// Preimage is the hex encoded preimage of the paid invoice.
+	Preimage string
+
+	// FeeSats is the routing fee paid on top of the amount, in sats
+	// rounded up. It is zero if the wallet does not report it.
+	FeeSats uint64
+}
+
+// FeeReporter is implemented by the wallets that report the routing fee of
+// the payments they send.
+type FeeReporter interface {
+	// PayInvoice pays the LN invoice and returns its preimage and the fee
+	// paid. amountSats is only set for amountless invoices.
+	PayInvoice(ctx context.Context, invoice string,
+		amountSats uint64) (*PaymentResult, error)
+}
+
+// AuthChecker is implemented by the wallets able to check their credentials
+// without paying anything.
+type AuthChecker interface {
+	// CheckAuth checks that the wallet backend accepts the credentials of
+	// the wallet. ErrUnauthorized is returned if it rejects them.
+	CheckAuth(ctx context.Context) error
+}
+
+// BalanceProvider is implemented by the wallets able to report their
+// balance.
+type BalanceProvider interface {
+	// Balance returns the balance the wallet can spend, in sats.
+	Balance(ctx context.Context) (uint64, error)
+}
+
+// PaymentState is the state of a payment in the wallet backend.
+type PaymentState string
+
+const (
+	// PaymentSucceeded is the state of the paid invoices.
+	PaymentSucceeded PaymentState = "succeeded"
+
+	// PaymentInFlight is the state of the payments not settled yet.
+	PaymentInFlight PaymentState = "in_flight"
+
+	// PaymentFailed is the state of the payments that will never settle.
+	PaymentFailed PaymentState = "failed"
+)
+
+// PaymentStatus is the status of a payment sent by a wallet.
+type PaymentStatus struct {
+	// State is the state of the payment.
+	State PaymentState
+
+	// Preimage is the hex encoded preimage, only set if the payment
+	// succeeded.
+	Preimage string
+}
+
+// PaymentLookup is implemented by the wallets able to look up the payments
+// they sent, used to recover the payments interrupted before the wallet
+// answered.
+type PaymentLookup interface {
+	// LookupPayment returns the status of the payment of the invoice with
+	// the given hex encoded payment hash. ErrPaymentNotFound is returned if
+	// the wallet never sent it.
+	LookupPayment(ctx context.Context,
+		paymentHash string) (*PaymentStatus, error)
+}
+
+// PaymentIDStore persists the IDs the wallets return when paying, for the
+// wallets looking up the payments by their own ID instead of the payment hash.
+// It lets a payment be looked up by a process other than the one sending it.
+type PaymentIDStore interface {
+	// SetWalletPaymentID stores the ID the wallet returned when paying the
+	// invoice with the given payment hash.
+	SetWalletPaymentID(paymentHash, paymentID string) error
+
+	// GetWalletPaymentID returns the ID the wallet returned when paying
+	// the invoice with the given payment hash, empty if it is unknown.
+	GetWalletPaymentID(paymentHash string) (string, error)
+}
+
+// GetPreimage pays the LN invoice with the wallet and returns its preimage.
+// The payment is bounded by the context if the wallet supports it.
+func GetPreimage(ctx context.Context, wallet PreimageProvider,
+	invoice string) (string, error) {
+
+	if w, ok := wallet.(ContextPreimageProvider); ok {
+		return w.GetPreimageContext(ctx, invoice)
+	}
+
+	return wallet.GetPreimage(invoice)
+}
+
+// Pay pays the LN invoice with the wallet, amountSats is only set for
+// amountless invoices and requires an AmountPreimageProvider. The fee is only
+// reported by the wallets implementing FeeReporter.
+func Pay(ctx context.Context, wallet PreimageProvider, invoice string,
+	amountSats uint64) (*PaymentResult, error) {
+
+	if w, ok := wallet.(FeeReporter); ok {
+		return w.PayInvoice(ctx, invoice, amountSats)
+	}
+
+	var (
+		preimage string
+		err      error
+	)
+	if amountSats != 0 {
+		w, ok := wallet.(AmountPreimageProvider)
+		if !ok {
+			return nil, errors.New("the wallet can not pay amountless " +
+				"invoices")
+		}
+
+		preimage, err = w.GetPreimageForAmount(ctx, invoice, amountSats)
+	} else {
+		preimage, err = GetPreimage(ctx, wallet, invoice)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &PaymentResult{Preimage: preimage}, nil
+}
+
+// msatToSats converts millisatoshis to sats, rounding up so a fee is never
+// under reported.
+func msatToSats(msat uint64) uint64 {
+	return (msat + 999) / 1000
+}
+
 type Store interface {
 	// GetDefaultWallet retrieves the default wallet ID.
 	GetDefaultWallet() (uint64, error)
@@ -16,7 +170,11 @@ type Store interface {
 	InsertWallet(walletType string) (uint64, error)
 	// GetWallet retrieves the wallet with the given ID.
 	GetWallet(id uint64) (*Wallet, error)
-	// GetWallets retrieves all wallets.
+	// ListWallets retrieves all wallets.
+	ListWallets() ([]*Wallet, error)
+	// DeleteWallet deletes the wallet with the given ID. If it was the
+	// default wallet, the most recent remaining wallet becomes the default
+	// one.
 	DeleteWallet(id uint64) error
 
 	// InsertWalletToken inserts a new token for the wallet with the given ID.
@@ -25,4 +183,24 @@ type Store interface {
 	GetWalletToken(id uint64) (string, error)
 	// DeleteWalletToken deletes the token for the wallet with the given ID.
 	DeleteWalletToken(id uint64) error
+
+	// InsertLNDConnection inserts the connection parameters of an LND
+	// wallet.
+	InsertLNDConnection(conn *LNDConnection) error
+	// GetLNDConnection retrieves the connection parameters of the LND
+	// wallet with the given ID.
+	GetLNDConnection(id uint64) (*LNDConnection, error)
+	// DeleteLNDConnection deletes the connection parameters of the LND
+	// wallet with the given ID.
+	DeleteLNDConnection(id uint64) error
+
+	// InsertCLNConnection inserts the connection parameters of a CLN
+	// wallet.
+	InsertCLNConnection(conn *CLNConnection) error
+	// GetCLNConnection retrieves the connection parameters of the CLN
+	// wallet with the given ID.
+	GetCLNConnection(id uint64) (*CLNConnection, error)
+	// DeleteCLNConnection deletes the connection parameters of the CLN
+	// wallet with the given ID.
+	DeleteCLNConnection(id uint64) error
 }
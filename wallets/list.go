@@ -0,0 +1,68 @@
+package wallets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+var listCommand = &cli.Command{
+	Name:   "list",
+	Usage:  "List the connected wallets.",
+	Action: listWallets,
+}
+
+// Info is the summary of a connected wallet printed by the wallet commands.
+type Info struct {
+	ID        uint64    `json:"id"`
+	Type      string    `json:"type"`
+	Default   bool      `json:"default"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// listWallets prints a summary of all the connected wallets.
+func listWallets(c *cli.Context) error {
+	store, ok := c.App.Metadata["store"].(Store)
+	if !ok {
+		return errors.New("failed to get store from context")
+	}
+
+	walletList, err := store.ListWallets()
+	if err != nil {
+		slog.Debug("Failed to list wallets.", "error", err)
+		return cli.Exit("failed to list wallets", 1)
+	}
+
+	defaultID, err := store.GetDefaultWallet()
+	if err != nil && !errors.Is(err, ErrNoWalletFound) {
+		slog.Debug("Failed to get default wallet.", "error", err)
+		return cli.Exit("failed to list wallets", 1)
+	}
+
+	response := struct {
+		Wallets []Info `json:"wallets"`
+	}{
+		Wallets: make([]Info, 0, len(walletList)),
+	}
+	for _, wallet := range walletList {
+		response.Wallets = append(response.Wallets, Info{
+			ID:        wallet.ID,
+			Type:      wallet.Type,
+			Default:   wallet.ID == defaultID,
+			CreatedAt: wallet.CreatedAt,
+		})
+	}
+
+	jsonOutput, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		return cli.Exit("failed to marshal JSON", 1)
+	}
+
+	fmt.Println(string(jsonOutput))
+
+	return nil
+}
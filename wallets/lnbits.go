@@ -0,0 +1,426 @@
+package wallets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultLNbitsPollInterval is the wait between two checks of a payment
+	// not settled yet.
+	DefaultLNbitsPollInterval = time.Second
+
+	// DefaultLNbitsPaymentTimeout bounds the payments made without a
+	// context.
+	DefaultLNbitsPaymentTimeout = 60 * time.Second
+
+	// lnbitsStatusPaymentError is the HTTP status code of the payments the
+	// LNbits funding source failed to send.
+	lnbitsStatusPaymentError = 520
+)
+
+// The states of the LNbits payments.
+const (
+	lnbitsStatusSuccess = "success"
+	lnbitsStatusFailed  = "failed"
+)
+
+// DeleteLNbitsWallet deletes the LNbits wallet with the given ID.
+func DeleteLNbitsWallet(store Store, id uint64) error {
+	err := store.DeleteWalletToken(id)
+	if err != nil {
+		return fmt.Errorf("unable to delete wallet token: %w", err)
+	}
+
+	err = store.DeleteWallet(id)
+
+	return err
+}
+
+// LNbitsConfig is the configuration of an LNbits wallet, stored as its token.
+type LNbitsConfig struct {
+	// URL is the URL of the LNbits instance, like https://legend.lnbits.com.
+	URL string `json:"url"`
+
+	// AdminKey is the admin key of the LNbits wallet, allowed to send
+	// payments.
+	AdminKey string `json:"admin_key"`
+}
+
+// Validate checks the configuration.
+func (c *LNbitsConfig) Validate() error {
+	switch {
+	case c.URL == "":
+		return errors.New("the LNbits URL is required")
+
+	case c.AdminKey == "":
+		return errors.New("the LNbits admin key is required")
+	}
+
+	u, err := url.Parse(c.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") ||
+		u.Host == "" {
+
+		return fmt.Errorf("invalid LNbits URL %q", c.URL)
+	}
+
+	return nil
+}
+
+// LNbitsClient is a client paying the invoices with an LNbits wallet.
+type LNbitsClient struct {
+	// BaseURL is the URL of the LNbits instance.
+	BaseURL string
+
+	// AdminKey is the admin key of the LNbits wallet.
+	AdminKey string
+
+	// HTTPClient sends the requests. If nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+
+	// PollInterval is the wait between two checks of a payment not settled
+	// yet. If zero, DefaultLNbitsPollInterval is used.
+	PollInterval time.Duration
+}
+
+// NewLNbitsClient returns a new client for the LNbits wallet of the
+// configuration.
+func NewLNbitsClient(cfg LNbitsConfig) (*LNbitsClient, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return &LNbitsClient{
+		BaseURL:  strings.TrimRight(cfg.URL, "/"),
+		AdminKey: cfg.AdminKey,
+	}, nil
+}
+
+// newLNbitsClientFromToken returns the client of the stored configuration.
+func newLNbitsClientFromToken(token string) (*LNbitsClient, error) {
+	var cfg LNbitsConfig
+	err := json.Unmarshal([]byte(token), &cfg)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse LNbits wallet config: %w",
+			err)
+	}
+
+	return NewLNbitsClient(cfg)
+}
+
+// LNbitsPaymentRequest is the request body for the LNbits payment endpoint.
+type LNbitsPaymentRequest struct {
+	// Out is true for outgoing payments, false creates an invoice.
+	Out    bool   `json:"out"`
+	Bolt11 string `json:"bolt11"`
+}
+
+// LNbitsPayment is a payment of the LNbits API.
+type LNbitsPayment struct {
+	PaymentHash string `json:"payment_hash"`
+
+	// Paid and Status are only set when checking the payment, Status
+	// by the recent LNbits versions only.
+	Paid     bool   `json:"paid"`
+	Status   string `json:"status"`
+	Preimage string `json:"preimage"`
+
+	Details struct {
+		// Amount is the amount in millisatoshis, negative for the
+		// outgoing payments.
+		Amount int64  `json:"amount"`
+		Status string `json:"status"`
+
+		// Fee is the routing fee in millisatoshis, negative for the
+		// outgoing payments on some LNbits versions.
+		Fee int64 `json:"fee"`
+	} `json:"details"`
+}
+
+// status returns the status of the payment, read from its details on older
+// LNbits versions.
+func (p *LNbitsPayment) status() string {
+	switch {
+	case p.Paid:
+		return lnbitsStatusSuccess
+
+	case p.Status != "":
+		return p.Status
+	}
+
+	return p.Details.Status
+}
+
+// LNbitsError is an error returned by the LNbits API. It matches the typed
+// wallet error of its cause with errors.Is.
+type LNbitsError struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+
+	// Detail is the error message sent by LNbits.
+	Detail string `json:"detail"`
+}
+
+// Error returns the error message.
+func (e *LNbitsError) Error() string {
+	return fmt.Sprintf("LNbits error(%d): %s", e.StatusCode, e.Detail)
+}
+
+// Unwrap returns the typed wallet error of the LNbits error, nil if there is
+// none.
+func (e *LNbitsError) Unwrap() error {
+	detail := strings.ToLower(e.Detail)
+
+	switch {
+	case e.StatusCode == http.StatusUnauthorized ||
+		e.StatusCode == http.StatusForbidden:
+
+		return ErrUnauthorized
+
+	case e.StatusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+
+	case strings.Contains(detail, "insufficient balance"):
+		return ErrInsufficientBalance
+
+	case e.StatusCode == http.StatusNotFound:
+		return ErrPaymentNotFound
+
+	case e.StatusCode == lnbitsStatusPaymentError ||
+		(e.StatusCode >= 400 && e.StatusCode < 500):
+
+		return ErrPaymentFailed
+	}
+
+	return nil
+}
+
+// GetPreimage returns the preimage for the given LN invoice.
+func (l *LNbitsClient) GetPreimage(invoice string) (string, error) {
+	ctx, cancel := context.WithTimeout(
+		context.Background(), DefaultLNbitsPaymentTimeout,
+	)
+	defer cancel()
+
+	return l.GetPreimageContext(ctx, invoice)
+}
+
+// GetPreimageContext returns the preimage for the given LN invoice, giving up
+// when the context is done.
+func (l *LNbitsClient) GetPreimageContext(ctx context.Context,
+	invoice string) (string, error) {
+
+	result, err := l.pay(ctx, invoice)
+	if err != nil {
+		return "", err
+	}
+
+	return result.Preimage, nil
+}
+
+// PayInvoice pays the LN invoice and returns its preimage and the fee paid.
+// LNbits does not pay amountless invoices.
+func (l *LNbitsClient) PayInvoice(ctx context.Context, invoice string,
+	amountSats uint64) (*PaymentResult, error) {
+
+	if amountSats != 0 {
+		return nil, fmt.Errorf("LNbits can not pay amountless invoices: "+
+			"%w", ErrPaymentFailed)
+	}
+
+	return l.pay(ctx, invoice)
+}
+
+// GetPreimageForAmount fails, LNbits does not pay amountless invoices.
+func (l *LNbitsClient) GetPreimageForAmount(ctx context.Context,
+	invoice string, amountSats uint64) (string, error) {
+
+	return "", fmt.Errorf("LNbits can not pay amountless invoices: %w",
+		ErrPaymentFailed)
+}
+
+// pay sends the payment of the LN invoice and waits until it settles.
+func (l *LNbitsClient) pay(ctx context.Context,
+	invoice string) (*PaymentResult, error) {
+
+	var payment LNbitsPayment
+	err := l.call(ctx, http.MethodPost, "/api/v1/payments",
+		&LNbitsPaymentRequest{Out: true, Bolt11: invoice}, &payment)
+	if err != nil {
+		return nil, err
+	}
+
+	interval := l.PollInterval
+	if interval == 0 {
+		interval = DefaultLNbitsPollInterval
+	}
+
+	hash := payment.PaymentHash
+	for {
+		switch payment.status() {
+		case lnbitsStatusSuccess:
+			if payment.Preimage == "" {
+				return nil, fmt.Errorf("LNbits payment %s settled "+
+					"without preimage", hash)
+			}
+
+			fee := payment.Details.Fee
+			if fee < 0 {
+				fee = -fee
+			}
+
+			return &PaymentResult{
+				Preimage: payment.Preimage,
+				FeeSats:  msatToSats(uint64(fee)),
+			}, nil
+
+		case lnbitsStatusFailed:
+			return nil, fmt.Errorf("LNbits payment %s failed: %w", hash,
+				ErrPaymentFailed)
+		}
+
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return nil, fmt.Errorf("LNbits payment %s not settled: %w",
+				hash, ctx.Err())
+		}
+
+		payment = LNbitsPayment{}
+		err = l.call(ctx, http.MethodGet, "/api/v1/payments/"+hash, nil,
+			&payment)
+		if err != nil {
+			return nil, fmt.Errorf("unable to check LNbits payment %s: "+
+				"%w", hash, err)
+		}
+	}
+}
+
+// LookupPayment returns the status of the payment of the invoice with the
+// given payment hash.
+func (l *LNbitsClient) LookupPayment(ctx context.Context,
+	paymentHash string) (*PaymentStatus, error) {
+
+	var payment LNbitsPayment
+	err := l.call(ctx, http.MethodGet, "/api/v1/payments/"+paymentHash,
+		nil, &payment)
+	if err != nil {
+		return nil, err
+	}
+
+	// The invoices of the wallet are looked up the same way.
+	if payment.Details.Amount > 0 {
+		return nil, ErrPaymentNotFound
+	}
+
+	switch payment.status() {
+	case lnbitsStatusSuccess:
+		return &PaymentStatus{
+			State:    PaymentSucceeded,
+			Preimage: payment.Preimage,
+		}, nil
+
+	case lnbitsStatusFailed:
+		return &PaymentStatus{State: PaymentFailed}, nil
+	}
+
+	return &PaymentStatus{State: PaymentInFlight}, nil
+}
+
+// LNbitsWallet is the wallet of the LNbits API.
+type LNbitsWallet struct {
+	Name string `json:"name"`
+
+	// Balance is the balance in millisatoshis.
+	Balance int64 `json:"balance"`
+}
+
+// CheckAuth checks that LNbits accepts the admin key by reading the wallet.
+func (l *LNbitsClient) CheckAuth(ctx context.Context) error {
+	_, err := l.Balance(ctx)
+	return err
+}
+
+// Balance returns the balance of the LNbits wallet, in sats.
+func (l *LNbitsClient) Balance(ctx context.Context) (uint64, error) {
+	var wallet LNbitsWallet
+	err := l.call(ctx, http.MethodGet, "/api/v1/wallet", nil, &wallet)
+	if err != nil {
+		return 0, err
+	}
+
+	if wallet.Balance < 0 {
+		return 0, nil
+	}
+
+	return uint64(wallet.Balance) / 1000, nil
+}
+
+// call sends a request to the LNbits API and decodes the response into out.
+func (l *LNbitsClient) call(ctx context.Context, method, path string, in,
+	out any) error {
+
+	var body io.Reader
+	if in != nil {
+		reqBodyBytes, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("unable to encode request body: %w", err)
+		}
+		body = bytes.NewReader(reqBodyBytes)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, l.BaseURL+path, body)
+	if err != nil {
+		return fmt.Errorf("unable to create request: %w", err)
+	}
+
+	req.Header.Set("X-Api-Key", l.AdminKey)
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	client := l.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("unable to read response body: %w", err)
+	}
+
+	if resp.StatusCode >= 300 {
+		lnbitsErr := &LNbitsError{}
+		if json.Unmarshal(respBodyBytes, lnbitsErr) != nil ||
+			lnbitsErr.Detail == "" {
+
+			lnbitsErr.Detail = strings.TrimSpace(string(respBodyBytes))
+		}
+		lnbitsErr.StatusCode = resp.StatusCode
+
+		return lnbitsErr
+	}
+
+	err = json.Unmarshal(respBodyBytes, out)
+	if err != nil {
+		return fmt.Errorf("unable to parse response body: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,314 @@
+package wallets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fewsats/fewsatscli/fewsatstest"
+	"github.com/fewsats/fewsatscli/invoices"
+	"github.com/stretchr/testify/require"
+)
+
+// lnbitsStandIn is a local stand-in for the LNbits payments API.
+type lnbitsStandIn struct {
+	t *testing.T
+
+	// payStatus and payDetail are the status code and the error detail of
+	// the payment responses.
+	payStatus int
+	payDetail string
+
+	// settleAfter is the number of checks the payments stay pending for,
+	// finalStatus their status afterwards.
+	settleAfter int32
+	finalStatus string
+
+	// check is the answer to the payment checks, if set.
+	check string
+
+	// feeMsat is the fee of the settled payments.
+	feeMsat int64
+
+	paymentHash string
+	preimage    string
+	request     LNbitsPaymentRequest
+	checks      atomic.Int32
+}
+
+func (l *lnbitsStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Api-Key") != "admin-key" {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"detail": "Invalid adminkey."}`)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v1/payments":
+		require.NoError(l.t, json.NewDecoder(r.Body).Decode(&l.request))
+
+		if l.payStatus != 0 {
+			w.WriteHeader(l.payStatus)
+			json.NewEncoder(w).Encode(&LNbitsError{Detail: l.payDetail})
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"payment_hash": %q, "checking_id": %q}`,
+			l.paymentHash, l.paymentHash)
+
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v1/wallet":
+		fmt.Fprint(w, `{"name": "team", "balance": 1234999}`)
+
+	case r.Method == http.MethodGet &&
+		r.URL.Path == "/api/v1/payments/"+l.paymentHash:
+
+		if l.check != "" {
+			fmt.Fprint(w, l.check)
+			return
+		}
+
+		payment := LNbitsPayment{Status: "pending"}
+		if l.checks.Add(1) > l.settleAfter {
+			payment.Status = l.finalStatus
+			payment.Paid = l.finalStatus == "success"
+			if payment.Paid {
+				payment.Preimage = l.preimage
+				payment.Details.Fee = l.feeMsat
+			}
+		}
+		json.NewEncoder(w).Encode(&payment)
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"detail": "Payment does not exist."}`)
+	}
+}
+
+func TestLNbitsClient(t *testing.T) {
+	srv, err := fewsatstest.New(fewsatstest.Config{})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		adminKey   string
+		standIn    *lnbitsStandIn
+		timeout    time.Duration
+		wantFee    uint64
+		wantError  error
+		wantChecks int32
+	}{
+		{
+			name: "settled",
+			standIn: &lnbitsStandIn{
+				settleAfter: 2,
+				finalStatus: "success",
+				feeMsat:     -3000,
+			},
+			wantFee:    3,
+			wantChecks: 3,
+		},
+		{
+			name: "failed",
+			standIn: &lnbitsStandIn{
+				finalStatus: "failed",
+			},
+			wantError:  ErrPaymentFailed,
+			wantChecks: 1,
+		},
+		{
+			name: "failed on older versions",
+			standIn: &lnbitsStandIn{
+				check: `{"paid": false, ` +
+					`"details": {"status": "failed"}}`,
+			},
+			wantError: ErrPaymentFailed,
+		},
+		{
+			name:      "wrong key",
+			adminKey:  "invoice-key",
+			standIn:   &lnbitsStandIn{},
+			wantError: ErrUnauthorized,
+		},
+		{
+			name: "insufficient balance",
+			standIn: &lnbitsStandIn{
+				payStatus: http.StatusPaymentRequired,
+				payDetail: "Insufficient balance.",
+			},
+			wantError: ErrInsufficientBalance,
+		},
+		{
+			name: "funding source error",
+			standIn: &lnbitsStandIn{
+				payStatus: lnbitsStatusPaymentError,
+				payDetail: "Payment failed: no route",
+			},
+			wantError: ErrPaymentFailed,
+		},
+		{
+			name: "never settled",
+			standIn: &lnbitsStandIn{
+				settleAfter: 1000,
+			},
+			timeout:   50 * time.Millisecond,
+			wantError: context.DeadlineExceeded,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			invoice, preimage, err := srv.CreateInvoice(21, tc.name)
+			require.NoError(t, err)
+
+			details, err := invoices.Parse(invoice)
+			require.NoError(t, err)
+
+			standIn := tc.standIn
+			standIn.t = t
+			standIn.paymentHash = details.PaymentHash
+			standIn.preimage = preimage
+
+			server := httptest.NewServer(standIn)
+			defer server.Close()
+
+			adminKey := tc.adminKey
+			if adminKey == "" {
+				adminKey = "admin-key"
+			}
+
+			client, err := NewLNbitsClient(LNbitsConfig{
+				URL:      server.URL + "/",
+				AdminKey: adminKey,
+			})
+			require.NoError(t, err)
+			client.PollInterval = time.Millisecond
+
+			ctx := context.Background()
+			if tc.timeout != 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tc.timeout)
+				defer cancel()
+			}
+
+			got, err := client.PayInvoice(ctx, invoice, 0)
+			if tc.wantError != nil {
+				require.ErrorIs(t, err, tc.wantError)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, preimage, got.Preimage)
+				require.Equal(t, tc.wantFee, got.FeeSats)
+			}
+
+			if tc.wantChecks != 0 {
+				require.Equal(t, tc.wantChecks, standIn.checks.Load())
+			}
+		})
+	}
+}
+
+func TestLNbitsClientLookupPayment(t *testing.T) {
+	tests := []struct {
+		name      string
+		check     string
+		wantState PaymentState
+		wantError error
+	}{
+		{
+			name: "paid",
+			check: `{"paid": true, "preimage": "00", ` +
+				`"details": {"amount": -21000}}`,
+			wantState: PaymentSucceeded,
+		},
+		{
+			name:      "pending",
+			check:     `{"paid": false, "details": {"amount": -21000}}`,
+			wantState: PaymentInFlight,
+		},
+		{
+			name: "failed",
+			check: `{"paid": false, "status": "failed", ` +
+				`"details": {"amount": -21000}}`,
+			wantState: PaymentFailed,
+		},
+		{
+			name:      "incoming",
+			check:     `{"paid": true, "details": {"amount": 21000}}`,
+			wantError: ErrPaymentNotFound,
+		},
+		{
+			name:      "not found",
+			wantError: ErrPaymentNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			standIn := &lnbitsStandIn{t: t, check: tc.check}
+			if tc.check != "" {
+				standIn.paymentHash = "00"
+			}
+			server := httptest.NewServer(standIn)
+			defer server.Close()
+
+			client, err := NewLNbitsClient(LNbitsConfig{
+				URL:      server.URL,
+				AdminKey: "admin-key",
+			})
+			require.NoError(t, err)
+
+			status, err := client.LookupPayment(
+				context.Background(), "00",
+			)
+			if tc.wantError != nil {
+				require.ErrorIs(t, err, tc.wantError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.wantState, status.State)
+		})
+	}
+}
+
+func TestLNbitsClientBalance(t *testing.T) {
+	server := httptest.NewServer(&lnbitsStandIn{t: t})
+	defer server.Close()
+
+	client, err := NewLNbitsClient(LNbitsConfig{
+		URL:      server.URL,
+		AdminKey: "admin-key",
+	})
+	require.NoError(t, err)
+
+	balance, err := client.Balance(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, uint64(1234), balance)
+	require.NoError(t, client.CheckAuth(context.Background()))
+
+	client.AdminKey = "invoice-key"
+	require.ErrorIs(t, client.CheckAuth(context.Background()),
+		ErrUnauthorized)
+}
+
+func TestLNbitsConfig(t *testing.T) {
+	invalid := []LNbitsConfig{
+		{AdminKey: "admin-key"},
+		{URL: "https://lnbits.example.com"},
+		{URL: "lnbits.example.com", AdminKey: "admin-key"},
+	}
+	for _, cfg := range invalid {
+		require.Error(t, cfg.Validate(), cfg.URL)
+	}
+
+	client, err := newLNbitsClientFromToken(
+		`{"url": "https://lnbits.example.com/", "admin_key": "admin-key"}`,
+	)
+	require.NoError(t, err)
+	require.Equal(t, "https://lnbits.example.com", client.BaseURL)
+	require.Equal(t, "admin-key", client.AdminKey)
+}
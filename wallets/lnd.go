@@ -0,0 +1,504 @@
+package wallets
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fewsats/fewsatscli/invoices"
+)
+
+const (
+	// DefaultLNDFeeLimitSats is the maximum routing fee paid per invoice
+	// when none is set.
+	DefaultLNDFeeLimitSats = 100
+
+	// DefaultLNDPaymentTimeout is the time given to the node to pay an
+	// invoice when none is set.
+	DefaultLNDPaymentTimeout = 60 * time.Second
+)
+
+// The states of the LND payments.
+const (
+	lndStatusSucceeded = "SUCCEEDED"
+	lndStatusFailed    = "FAILED"
+)
+
+// The gRPC status codes of the LND errors.
+const (
+	grpcCodeNotFound         = 5
+	grpcCodeAlreadyExists    = 6
+	grpcCodePermissionDenied = 7
+	grpcCodeUnauthenticated  = 16
+)
+
+// LNDConnection holds the parameters to connect to the REST API of an LND
+// node.
+type LNDConnection struct {
+	WalletID uint64 `db:"wallet_id"`
+
+	// Host is the address of the REST API, like localhost:8080. HTTPS is
+	// used if no scheme is given.
+	Host string `db:"host"`
+
+	// Macaroon is the hex encoded macaroon authenticating the requests.
+	Macaroon string `db:"macaroon"`
+
+	// TLSCert is the PEM encoded TLS certificate of the node, empty to
+	// use the system roots.
+	TLSCert string `db:"tls_cert"`
+
+	// FeeLimitSats is the maximum routing fee paid per invoice.
+	FeeLimitSats uint64 `db:"fee_limit_sats"`
+
+	// TimeoutSeconds is the time given to the node to pay an invoice.
+	TimeoutSeconds uint64 `db:"timeout_seconds"`
+}
+
+// DeleteLNDWallet deletes the LND wallet with the given ID.
+func DeleteLNDWallet(store Store, id uint64) error {
+	err := store.DeleteLNDConnection(id)
+	if err != nil {
+		return fmt.Errorf("unable to delete LND connection: %w", err)
+	}
+
+	err = store.DeleteWallet(id)
+
+	return err
+}
+
+// LNDClient is a client paying the invoices with an LND node through its
+// REST API.
+type LNDClient struct {
+	// BaseURL is the URL of the REST API.
+	BaseURL string
+
+	// Macaroon is the hex encoded macaroon authenticating the requests.
+	Macaroon string
+
+	// FeeLimitSats is the maximum routing fee paid per invoice.
+	FeeLimitSats uint64
+
+	// Timeout is the time given to the node to pay an invoice.
+	Timeout time.Duration
+
+	// HTTPClient sends the requests, trusting the TLS certificate of the
+	// node.
+	HTTPClient *http.Client
+}
+
+// NewLNDClient returns a new client for the LND node of the connection.
+func NewLNDClient(conn *LNDConnection) (*LNDClient, error) {
+	if conn.Host == "" {
+		return nil, errors.New("the LND host is required")
+	}
+
+	if _, err := hex.DecodeString(conn.Macaroon); err != nil ||
+		conn.Macaroon == "" {
+
+		return nil, errors.New("the LND macaroon must be hex encoded")
+	}
+
+	httpClient, err := newNodeHTTPClient(conn.TLSCert)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load LND TLS certificate: %w",
+			err)
+	}
+
+	client := &LNDClient{
+		BaseURL:      nodeBaseURL(conn.Host),
+		Macaroon:     conn.Macaroon,
+		FeeLimitSats: conn.FeeLimitSats,
+		Timeout:      time.Duration(conn.TimeoutSeconds) * time.Second,
+		HTTPClient:   httpClient,
+	}
+	if client.FeeLimitSats == 0 {
+		client.FeeLimitSats = DefaultLNDFeeLimitSats
+	}
+	if client.Timeout == 0 {
+		client.Timeout = DefaultLNDPaymentTimeout
+	}
+
+	return client, nil
+}
+
+// LNDSendPaymentRequest is the request body for the LND router send endpoint.
+type LNDSendPaymentRequest struct {
+	PaymentRequest    string `json:"payment_request"`
+	TimeoutSeconds    int32  `json:"timeout_seconds"`
+	FeeLimitSat       string `json:"fee_limit_sat"`
+	NoInflightUpdates bool   `json:"no_inflight_updates"`
+
+	// Amt is the amount in sats to pay for amountless invoices.
+	Amt string `json:"amt,omitempty"`
+}
+
+// LNDPayment is a payment of the LND node.
+type LNDPayment struct {
+	PaymentHash     string `json:"payment_hash"`
+	PaymentPreimage string `json:"payment_preimage"`
+	Status          string `json:"status"`
+	FailureReason   string `json:"failure_reason"`
+
+	// FeeMsat is the routing fee paid, in millisatoshis.
+	FeeMsat string `json:"fee_msat"`
+}
+
+// LNDError is an error returned by the LND REST API. It matches the typed
+// wallet error of its cause with errors.Is.
+type LNDError struct {
+	// Code is the gRPC status code of the error.
+	Code int `json:"code"`
+
+	// Message is the error message sent by the node.
+	Message string `json:"message"`
+
+	// HTTPCode is the HTTP status code of the error.
+	HTTPCode int `json:"http_code"`
+}
+
+// Error returns the error message.
+func (e *LNDError) Error() string {
+	return fmt.Sprintf("LND error(%d): %s", e.Code, e.Message)
+}
+
+// Unwrap returns the typed wallet error of the LND error, nil if there is
+// none.
+func (e *LNDError) Unwrap() error {
+	message := strings.ToLower(e.Message)
+
+	switch {
+	case e.Code == grpcCodeUnauthenticated ||
+		e.Code == grpcCodePermissionDenied ||
+		e.HTTPCode == http.StatusUnauthorized ||
+		e.HTTPCode == http.StatusForbidden ||
+		strings.Contains(message, "macaroon") ||
+		strings.Contains(message, "verification failed"):
+
+		return ErrUnauthorized
+
+	case e.Code == grpcCodeNotFound:
+		return ErrPaymentNotFound
+
+	case strings.Contains(message, "insufficient"):
+		return ErrInsufficientBalance
+	}
+
+	return nil
+}
+
+// lndStreamMessage is a message of the LND streaming endpoints.
+type lndStreamMessage struct {
+	Result *LNDPayment `json:"result"`
+	Error  *LNDError   `json:"error"`
+}
+
+// GetPreimage returns the preimage for the given LN invoice.
+func (l *LNDClient) GetPreimage(invoice string) (string, error) {
+	return l.GetPreimageContext(context.Background(), invoice)
+}
+
+// GetPreimageContext returns the preimage for the given LN invoice, giving up
+// when the context is done.
+func (l *LNDClient) GetPreimageContext(ctx context.Context,
+	invoice string) (string, error) {
+
+	return l.GetPreimageForAmount(ctx, invoice, 0)
+}
+
+// GetPreimageForAmount pays the amountless LN invoice with the given amount
+// and returns its preimage.
+func (l *LNDClient) GetPreimageForAmount(ctx context.Context, invoice string,
+	amountSats uint64) (string, error) {
+
+	result, err := l.PayInvoice(ctx, invoice, amountSats)
+	if err != nil {
+		return "", err
+	}
+
+	return result.Preimage, nil
+}
+
+// PayInvoice pays the LN invoice with the router and waits for its outcome,
+// amountSats is only set for amountless invoices.
+func (l *LNDClient) PayInvoice(ctx context.Context, invoice string,
+	amountSats uint64) (*PaymentResult, error) {
+
+	// The node gives up after the timeout, the request is given a bit more
+	// to get the final update.
+	ctx, cancel := context.WithTimeout(ctx, l.Timeout+10*time.Second)
+	defer cancel()
+
+	body := LNDSendPaymentRequest{
+		PaymentRequest:    invoice,
+		TimeoutSeconds:    int32(l.Timeout / time.Second),
+		FeeLimitSat:       strconv.FormatUint(l.FeeLimitSats, 10),
+		NoInflightUpdates: true,
+	}
+	if amountSats != 0 {
+		body.Amt = strconv.FormatUint(amountSats, 10)
+	}
+
+	payment, err := l.stream(ctx, http.MethodPost, "/v2/router/send", &body,
+		func(p *LNDPayment) bool {
+			return p.Status == lndStatusSucceeded ||
+				p.Status == lndStatusFailed
+		},
+	)
+
+	var lndErr *LNDError
+	if errors.As(err, &lndErr) && lndErr.Code == grpcCodeAlreadyExists {
+		// The invoice was paid before, by an interrupted payment. No
+		// fee is paid this time.
+		preimage, err := l.paidPreimage(ctx, invoice, err)
+		if err != nil {
+			return nil, err
+		}
+
+		return &PaymentResult{Preimage: preimage}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch payment.Status {
+	case lndStatusSucceeded:
+		// A fee the node reports in an unexpected format is not worth
+		// failing a settled payment.
+		feeMsat, _ := strconv.ParseUint(payment.FeeMsat, 10, 64)
+
+		return &PaymentResult{
+			Preimage: payment.PaymentPreimage,
+			FeeSats:  msatToSats(feeMsat),
+		}, nil
+
+	case lndStatusFailed:
+		if payment.FailureReason ==
+			"FAILURE_REASON_INSUFFICIENT_BALANCE" {
+
+			return nil, fmt.Errorf("LND payment failed: %s: %w",
+				payment.FailureReason, ErrInsufficientBalance)
+		}
+
+		return nil, fmt.Errorf("LND payment failed: %s: %w",
+			payment.FailureReason, ErrPaymentFailed)
+	}
+
+	return nil, fmt.Errorf("unexpected LND payment status %q",
+		payment.Status)
+}
+
+// paidPreimage returns the preimage of an invoice the node already paid,
+// payErr is returned if it is not settled.
+func (l *LNDClient) paidPreimage(ctx context.Context, invoice string,
+	payErr error) (string, error) {
+
+	details, err := invoices.Parse(invoice)
+	if err != nil {
+		return "", payErr
+	}
+
+	status, err := l.LookupPayment(ctx, details.PaymentHash)
+	if err != nil || status.State != PaymentSucceeded {
+		return "", payErr
+	}
+
+	return status.Preimage, nil
+}
+
+// LookupPayment returns the status of the payment of the invoice with the
+// given payment hash.
+func (l *LNDClient) LookupPayment(ctx context.Context,
+	paymentHash string) (*PaymentStatus, error) {
+
+	hash, err := hex.DecodeString(paymentHash)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payment hash: %w", err)
+	}
+
+	// The first update of the track stream is the current state of the
+	// payment.
+	path := "/v2/router/track/" + base64.URLEncoding.EncodeToString(hash) +
+		"?no_inflight_updates=false"
+
+	payment, err := l.stream(ctx, http.MethodGet, path, nil,
+		func(*LNDPayment) bool {
+			return true
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	switch payment.Status {
+	case lndStatusSucceeded:
+		return &PaymentStatus{
+			State:    PaymentSucceeded,
+			Preimage: payment.PaymentPreimage,
+		}, nil
+
+	case lndStatusFailed:
+		return &PaymentStatus{State: PaymentFailed}, nil
+	}
+
+	return &PaymentStatus{State: PaymentInFlight}, nil
+}
+
+// CheckAuth checks that the node accepts the macaroon by reading its info.
+func (l *LNDClient) CheckAuth(ctx context.Context) error {
+	var info struct {
+		IdentityPubkey string `json:"identity_pubkey"`
+	}
+
+	return l.call(ctx, http.MethodGet, "/v1/getinfo", &info)
+}
+
+// Balance returns the local balance of the channels of the node, in sats.
+func (l *LNDClient) Balance(ctx context.Context) (uint64, error) {
+	var balance struct {
+		LocalBalance struct {
+			Sat string `json:"sat"`
+		} `json:"local_balance"`
+	}
+	err := l.call(ctx, http.MethodGet, "/v1/balance/channels", &balance)
+	if err != nil {
+		return 0, err
+	}
+
+	// The int64 fields are sent as strings, omitted when zero.
+	if balance.LocalBalance.Sat == "" {
+		return 0, nil
+	}
+
+	sats, err := strconv.ParseUint(balance.LocalBalance.Sat, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid LND balance %q: %w",
+			balance.LocalBalance.Sat, err)
+	}
+
+	return sats, nil
+}
+
+// stream sends a request to a streaming endpoint of the REST API and returns
+// the first payment update accepted by done.
+func (l *LNDClient) stream(ctx context.Context, method, path string, in any,
+	done func(*LNDPayment) bool) (*LNDPayment, error) {
+
+	resp, err := l.request(ctx, method, path, in)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// The updates are sent as one JSON object per line.
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var msg lndStreamMessage
+		err := json.Unmarshal(line, &msg)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse response: %w", err)
+		}
+
+		switch {
+		case msg.Error != nil:
+			return nil, msg.Error
+
+		case msg.Result != nil && done(msg.Result):
+			return msg.Result, nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read response: %w", err)
+	}
+
+	return nil, errors.New("LND stream closed without a final update")
+}
+
+// call sends a request to a unary endpoint of the REST API and decodes the
+// response into out.
+func (l *LNDClient) call(ctx context.Context, method, path string,
+	out any) error {
+
+	resp, err := l.request(ctx, method, path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	err = json.NewDecoder(resp.Body).Decode(out)
+	if err != nil {
+		return fmt.Errorf("unable to parse response body: %w", err)
+	}
+
+	return nil
+}
+
+// request sends a request to the REST API, the errors of the node are
+// returned as LNDError.
+func (l *LNDClient) request(ctx context.Context, method, path string,
+	in any) (*http.Response, error) {
+
+	var body io.Reader
+	if in != nil {
+		reqBodyBytes, err := json.Marshal(in)
+		if err != nil {
+			return nil, fmt.Errorf("unable to encode request body: %w",
+				err)
+		}
+		body = bytes.NewReader(reqBodyBytes)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, l.BaseURL+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	req.Header.Set("Grpc-Metadata-macaroon", l.Macaroon)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	client := l.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to send request: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		respBodyBytes, _ := io.ReadAll(resp.Body)
+
+		lndErr := &LNDError{}
+		if json.Unmarshal(respBodyBytes, lndErr) != nil ||
+			lndErr.Message == "" {
+
+			lndErr.Message = strings.TrimSpace(string(respBodyBytes))
+		}
+		lndErr.HTTPCode = resp.StatusCode
+
+		return nil, lndErr
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,273 @@
+package wallets
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fewsats/fewsatscli/fewsatstest"
+	"github.com/fewsats/fewsatscli/invoices"
+	"github.com/stretchr/testify/require"
+)
+
+// lndStandIn is a local stand-in for the LND REST router endpoints.
+type lndStandIn struct {
+	t *testing.T
+
+	// updates are the lines streamed by the send endpoint.
+	updates []string
+
+	// tracked is the line streamed by the track endpoint.
+	tracked string
+
+	request LNDSendPaymentRequest
+}
+
+func (l *lndStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Grpc-Metadata-macaroon") != "0201" {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, `{"code": 2, "message": "verification failed: `+
+			`signature mismatch after caveat verification"}`)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/v2/router/send":
+		require.NoError(l.t, json.NewDecoder(r.Body).Decode(&l.request))
+		for _, update := range l.updates {
+			fmt.Fprintln(w, update)
+		}
+
+	default:
+		fmt.Fprintln(w, l.tracked)
+	}
+}
+
+// newLNDTestClient starts the stand-in with TLS and returns a client
+// trusting its certificate.
+func newLNDTestClient(t *testing.T, standIn *lndStandIn,
+	macaroon string) *LNDClient {
+
+	standIn.t = t
+	server := httptest.NewTLSServer(standIn)
+	t.Cleanup(server.Close)
+
+	cert := pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: server.Certificate().Raw,
+	})
+
+	client, err := NewLNDClient(&LNDConnection{
+		Host:         server.URL,
+		Macaroon:     macaroon,
+		TLSCert:      string(cert),
+		FeeLimitSats: 5,
+	})
+	require.NoError(t, err)
+
+	return client
+}
+
+func TestLNDClient(t *testing.T) {
+	srv, err := fewsatstest.New(fewsatstest.Config{})
+	require.NoError(t, err)
+
+	invoice, preimage, err := srv.CreateInvoice(21, "lnd")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		macaroon  string
+		standIn   *lndStandIn
+		wantFee   uint64
+		wantError error
+		wantText  string
+	}{
+		{
+			name:     "succeeded",
+			macaroon: "0201",
+			standIn: &lndStandIn{
+				updates: []string{
+					`{"result": {"status": "SUCCEEDED", ` +
+						`"payment_preimage": "` + preimage + `", ` +
+						`"fee_msat": "1500"}}`,
+				},
+			},
+			wantFee: 2,
+		},
+		{
+			name:     "no route",
+			macaroon: "0201",
+			standIn: &lndStandIn{
+				updates: []string{
+					`{"result": {"status": "FAILED", ` +
+						`"failure_reason": "FAILURE_REASON_NO_ROUTE"}}`,
+				},
+			},
+			wantError: ErrPaymentFailed,
+		},
+		{
+			name:     "insufficient balance",
+			macaroon: "0201",
+			standIn: &lndStandIn{
+				updates: []string{
+					`{"result": {"status": "FAILED", "failure_reason": ` +
+						`"FAILURE_REASON_INSUFFICIENT_BALANCE"}}`,
+				},
+			},
+			wantError: ErrInsufficientBalance,
+		},
+		{
+			name:      "wrong macaroon",
+			macaroon:  "0202",
+			standIn:   &lndStandIn{},
+			wantError: ErrUnauthorized,
+		},
+		{
+			name:     "already paid",
+			macaroon: "0201",
+			standIn: &lndStandIn{
+				updates: []string{
+					`{"error": {"code": 6, ` +
+						`"message": "invoice is already paid"}}`,
+				},
+				tracked: `{"result": {"status": "SUCCEEDED", ` +
+					`"payment_preimage": "` + preimage + `"}}`,
+			},
+		},
+		{
+			name:     "stream closed",
+			macaroon: "0201",
+			standIn: &lndStandIn{
+				updates: []string{`{"result": {"status": "IN_FLIGHT"}}`},
+			},
+			wantText: "without a final update",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			client := newLNDTestClient(t, tc.standIn, tc.macaroon)
+
+			got, err := client.PayInvoice(
+				context.Background(), invoice, 0,
+			)
+			switch {
+			case tc.wantError != nil:
+				require.ErrorIs(t, err, tc.wantError)
+
+			case tc.wantText != "":
+				require.ErrorContains(t, err, tc.wantText)
+
+			default:
+				require.NoError(t, err)
+				require.Equal(t, preimage, got.Preimage)
+				require.Equal(t, tc.wantFee, got.FeeSats)
+			}
+		})
+	}
+
+	// The fee limit and the timeout are sent with the payment.
+	standIn := &lndStandIn{}
+	client := newLNDTestClient(t, standIn, "0201")
+	client.GetPreimage(invoice)
+	require.Equal(t, invoice, standIn.request.PaymentRequest)
+	require.Equal(t, "5", standIn.request.FeeLimitSat)
+	require.EqualValues(t, 60, standIn.request.TimeoutSeconds)
+	require.Empty(t, standIn.request.Amt)
+}
+
+func TestLNDClientLookupPayment(t *testing.T) {
+	srv, err := fewsatstest.New(fewsatstest.Config{})
+	require.NoError(t, err)
+
+	invoice, preimage, err := srv.CreateInvoice(21, "lnd")
+	require.NoError(t, err)
+
+	details, err := invoices.Parse(invoice)
+	require.NoError(t, err)
+
+	var trackedPath string
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			trackedPath = r.URL.Path
+			fmt.Fprintln(w, `{"result": {"status": "SUCCEEDED", `+
+				`"payment_preimage": "`+preimage+`"}}`)
+		},
+	))
+	defer server.Close()
+
+	client, err := NewLNDClient(&LNDConnection{
+		Host:     server.URL,
+		Macaroon: "0201",
+	})
+	require.NoError(t, err)
+
+	status, err := client.LookupPayment(
+		context.Background(), details.PaymentHash,
+	)
+	require.NoError(t, err)
+	require.Equal(t, PaymentSucceeded, status.State)
+	require.Equal(t, preimage, status.Preimage)
+
+	hash, err := hex.DecodeString(details.PaymentHash)
+	require.NoError(t, err)
+	require.Equal(t,
+		"/v2/router/track/"+base64.URLEncoding.EncodeToString(hash),
+		trackedPath,
+	)
+
+	// Unknown payments are not found.
+	server.Config.Handler = http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintln(w, `{"error": {"code": 5, `+
+				`"message": "payment isn't initiated"}}`)
+		},
+	)
+	_, err = client.LookupPayment(context.Background(), details.PaymentHash)
+	require.ErrorIs(t, err, ErrPaymentNotFound)
+}
+
+func TestNewLNDClient(t *testing.T) {
+	_, err := NewLNDClient(&LNDConnection{Macaroon: "0201"})
+	require.Error(t, err)
+
+	_, err = NewLNDClient(&LNDConnection{Host: "localhost:8080",
+		Macaroon: "not hex"})
+	require.Error(t, err)
+
+	_, err = NewLNDClient(&LNDConnection{Host: "localhost:8080",
+		Macaroon: "0201", TLSCert: "not a cert"})
+	require.Error(t, err)
+
+	client, err := NewLNDClient(&LNDConnection{Host: "localhost:8080",
+		Macaroon: "0201"})
+	require.NoError(t, err)
+	require.Equal(t, "https://localhost:8080", client.BaseURL)
+	require.EqualValues(t, DefaultLNDFeeLimitSats, client.FeeLimitSats)
+	require.Equal(t, DefaultLNDPaymentTimeout, client.Timeout)
+}
+
+func TestLNDClientBalance(t *testing.T) {
+	standIn := &lndStandIn{
+		tracked: `{"balance": "1234", "local_balance": {"sat": "1234", ` +
+			`"msat": "1234000"}}`,
+	}
+	client := newLNDTestClient(t, standIn, "0201")
+
+	balance, err := client.Balance(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, uint64(1234), balance)
+
+	require.NoError(t, client.CheckAuth(context.Background()))
+
+	client = newLNDTestClient(t, standIn, "0202")
+	require.ErrorIs(t, client.CheckAuth(context.Background()),
+		ErrUnauthorized)
+}
@@ -0,0 +1,201 @@
+package wallets
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/gorilla/websocket"
+)
+
+// nostrEvent is a signed nostr event, as defined by NIP-01.
+type nostrEvent struct {
+	ID        string     `json:"id"`
+	PubKey    string     `json:"pubkey"`
+	CreatedAt int64      `json:"created_at"`
+	Kind      int        `json:"kind"`
+	Tags      [][]string `json:"tags"`
+	Content   string     `json:"content"`
+	Sig       string     `json:"sig"`
+}
+
+// hash returns the hash of the canonical serialization of the event, its ID.
+func (e *nostrEvent) hash() ([]byte, error) {
+	tags := e.Tags
+	if tags == nil {
+		tags = [][]string{}
+	}
+
+	// The strings are serialized with the minimal JSON escaping, the HTML
+	// characters must not be escaped.
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+
+	err := encoder.Encode([]any{
+		0, e.PubKey, e.CreatedAt, e.Kind, tags, e.Content,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to serialize event: %w", err)
+	}
+
+	hash := sha256.Sum256(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+
+	return hash[:], nil
+}
+
+// sign sets the public key, the ID and the signature of the event.
+func (e *nostrEvent) sign(key *btcec.PrivateKey) error {
+	e.PubKey = hex.EncodeToString(schnorr.SerializePubKey(key.PubKey()))
+
+	hash, err := e.hash()
+	if err != nil {
+		return err
+	}
+
+	sig, err := schnorr.Sign(key, hash)
+	if err != nil {
+		return fmt.Errorf("unable to sign event: %w", err)
+	}
+
+	e.ID = hex.EncodeToString(hash)
+	e.Sig = hex.EncodeToString(sig.Serialize())
+
+	return nil
+}
+
+// verify checks the ID and the signature of the event.
+func (e *nostrEvent) verify() error {
+	hash, err := e.hash()
+	if err != nil {
+		return err
+	}
+
+	if e.ID != hex.EncodeToString(hash) {
+		return errors.New("invalid event ID")
+	}
+
+	pubKey, err := parseNostrPubKey(e.PubKey)
+	if err != nil {
+		return err
+	}
+
+	sigBytes, err := hex.DecodeString(e.Sig)
+	if err != nil {
+		return fmt.Errorf("invalid event signature: %w", err)
+	}
+
+	sig, err := schnorr.ParseSignature(sigBytes)
+	if err != nil {
+		return fmt.Errorf("invalid event signature: %w", err)
+	}
+
+	if !sig.Verify(hash, pubKey) {
+		return errors.New("invalid event signature")
+	}
+
+	return nil
+}
+
+// tag returns the first value of the tag with the given name, empty if the
+// event has none.
+func (e *nostrEvent) tag(name string) string {
+	for _, tag := range e.Tags {
+		if len(tag) >= 2 && tag[0] == name {
+			return tag[1]
+		}
+	}
+
+	return ""
+}
+
+// parseNostrPubKey parses a hex encoded x-only public key.
+func parseNostrPubKey(pubKey string) (*btcec.PublicKey, error) {
+	pubKeyBytes, err := hex.DecodeString(pubKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+
+	key, err := schnorr.ParsePubKey(pubKeyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+
+	return key, nil
+}
+
+// nostrFilter is a subscription filter, as defined by NIP-01.
+type nostrFilter struct {
+	Kinds   []int    `json:"kinds,omitempty"`
+	Authors []string `json:"authors,omitempty"`
+	Events  []string `json:"#e,omitempty"`
+	Limit   int      `json:"limit,omitempty"`
+}
+
+// nostrRelay is a websocket connection to a nostr relay.
+type nostrRelay struct {
+	conn *websocket.Conn
+
+	// stop stops closing the connection when the context is done.
+	stop func() bool
+}
+
+// dialNostrRelay connects to the relay at the given websocket URL. The
+// connection is closed when the context is done.
+func dialNostrRelay(ctx context.Context, url string) (*nostrRelay, error) {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to relay %s: %w", url,
+			err)
+	}
+
+	// Closing the connection unblocks the pending reads and writes.
+	stop := context.AfterFunc(ctx, func() {
+		conn.Close()
+	})
+
+	return &nostrRelay{conn: conn, stop: stop}, nil
+}
+
+// send sends a message to the relay, like ["EVENT", event].
+func (r *nostrRelay) send(msg ...any) error {
+	err := r.conn.WriteJSON(msg)
+	if err != nil {
+		return fmt.Errorf("unable to send to relay: %w", err)
+	}
+
+	return nil
+}
+
+// read returns the type and the fields of the next message of the relay.
+func (r *nostrRelay) read() (string, []json.RawMessage, error) {
+	var msg []json.RawMessage
+	err := r.conn.ReadJSON(&msg)
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to read from relay: %w", err)
+	}
+
+	if len(msg) == 0 {
+		return "", nil, errors.New("empty relay message")
+	}
+
+	var msgType string
+	err = json.Unmarshal(msg[0], &msgType)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid relay message: %w", err)
+	}
+
+	return msgType, msg[1:], nil
+}
+
+// close closes the connection.
+func (r *nostrRelay) close() error {
+	r.stop()
+	return r.conn.Close()
+}
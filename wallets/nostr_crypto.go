@@ -0,0 +1,233 @@
+package wallets
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// nip44Version is the version byte of the NIP-44 payloads.
+	nip44Version = 2
+
+	// nip44MaxPlaintext is the size of the largest NIP-44 plaintext.
+	nip44MaxPlaintext = 65535
+)
+
+// nostrSharedSecret returns the x coordinate of the ECDH point of the keys,
+// shared by both parties.
+func nostrSharedSecret(key *btcec.PrivateKey, pubKey *btcec.PublicKey) []byte {
+	return btcec.GenerateSharedSecret(key, pubKey)
+}
+
+// nip04Encrypt encrypts the plaintext with the NIP-04 scheme: AES-256-CBC
+// keyed with the shared secret.
+func nip04Encrypt(sharedSecret []byte, plaintext string) (string, error) {
+	block, err := aes.NewCipher(sharedSecret)
+	if err != nil {
+		return "", err
+	}
+
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(iv); err != nil {
+		return "", fmt.Errorf("unable to create IV: %w", err)
+	}
+
+	// PKCS#7 padding.
+	padding := aes.BlockSize - len(plaintext)%aes.BlockSize
+	padded := append([]byte(plaintext),
+		bytes.Repeat([]byte{byte(padding)}, padding)...)
+
+	ciphertext := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, padded)
+
+	return base64.StdEncoding.EncodeToString(ciphertext) + "?iv=" +
+		base64.StdEncoding.EncodeToString(iv), nil
+}
+
+// nip04Decrypt decrypts a NIP-04 payload.
+func nip04Decrypt(sharedSecret []byte, payload string) (string, error) {
+	encodedCiphertext, encodedIV, found := strings.Cut(payload, "?iv=")
+	if !found {
+		return "", errors.New("invalid NIP-04 payload")
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(encodedCiphertext)
+	if err != nil {
+		return "", fmt.Errorf("invalid NIP-04 ciphertext: %w", err)
+	}
+
+	iv, err := base64.StdEncoding.DecodeString(encodedIV)
+	if err != nil {
+		return "", fmt.Errorf("invalid NIP-04 IV: %w", err)
+	}
+
+	if len(iv) != aes.BlockSize || len(ciphertext) == 0 ||
+		len(ciphertext)%aes.BlockSize != 0 {
+
+		return "", errors.New("invalid NIP-04 payload")
+	}
+
+	block, err := aes.NewCipher(sharedSecret)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ciphertext)
+
+	padding := int(plaintext[len(plaintext)-1])
+	if padding == 0 || padding > aes.BlockSize {
+		return "", errors.New("invalid NIP-04 padding")
+	}
+
+	return string(plaintext[:len(plaintext)-padding]), nil
+}
+
+// nip44ConversationKey derives the NIP-44 conversation key of the shared
+// secret.
+func nip44ConversationKey(sharedSecret []byte) []byte {
+	return hkdf.Extract(sha256.New, sharedSecret, []byte("nip44-v2"))
+}
+
+// nip44MessageKeys derives the ChaCha20 key and nonce and the HMAC key of a
+// message from its nonce.
+func nip44MessageKeys(conversationKey, nonce []byte) ([]byte, []byte, []byte,
+	error) {
+
+	keys := make([]byte, 76)
+	_, err := io.ReadFull(
+		hkdf.Expand(sha256.New, conversationKey, nonce), keys,
+	)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("unable to derive keys: %w", err)
+	}
+
+	return keys[:32], keys[32:44], keys[44:], nil
+}
+
+// nip44PaddedLen returns the size of the padded plaintext.
+func nip44PaddedLen(unpaddedLen int) int {
+	if unpaddedLen <= 32 {
+		return 32
+	}
+
+	nextPower := 1 << bits.Len(uint(unpaddedLen-1))
+	chunk := 32
+	if nextPower > 256 {
+		chunk = nextPower / 8
+	}
+
+	return chunk * ((unpaddedLen-1)/chunk + 1)
+}
+
+// nip44Encrypt encrypts the plaintext with the NIP-44 version 2 scheme.
+func nip44Encrypt(conversationKey []byte, plaintext string) (string, error) {
+	nonce := make([]byte, 32)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("unable to create nonce: %w", err)
+	}
+
+	return nip44EncryptWithNonce(conversationKey, nonce, plaintext)
+}
+
+// nip44EncryptWithNonce encrypts the plaintext with the given nonce.
+func nip44EncryptWithNonce(conversationKey, nonce []byte,
+	plaintext string) (string, error) {
+
+	if len(plaintext) == 0 || len(plaintext) > nip44MaxPlaintext {
+		return "", errors.New("invalid NIP-44 plaintext size")
+	}
+
+	chachaKey, chachaNonce, hmacKey, err := nip44MessageKeys(
+		conversationKey, nonce,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	padded := make([]byte, 2+nip44PaddedLen(len(plaintext)))
+	binary.BigEndian.PutUint16(padded, uint16(len(plaintext)))
+	copy(padded[2:], plaintext)
+
+	stream, err := chacha20.NewUnauthenticatedCipher(chachaKey, chachaNonce)
+	if err != nil {
+		return "", err
+	}
+	ciphertext := make([]byte, len(padded))
+	stream.XORKeyStream(ciphertext, padded)
+
+	payload := make([]byte, 0, 1+len(nonce)+len(ciphertext)+sha256.Size)
+	payload = append(payload, nip44Version)
+	payload = append(payload, nonce...)
+	payload = append(payload, ciphertext...)
+	payload = append(payload, nip44MAC(hmacKey, nonce, ciphertext)...)
+
+	return base64.StdEncoding.EncodeToString(payload), nil
+}
+
+// nip44Decrypt decrypts a NIP-44 version 2 payload.
+func nip44Decrypt(conversationKey []byte, encoded string) (string, error) {
+	payload, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("invalid NIP-44 payload: %w", err)
+	}
+
+	// The version, the nonce, at least 32 padded bytes plus the length
+	// and the MAC.
+	if len(payload) < 1+32+34+sha256.Size || payload[0] != nip44Version {
+		return "", errors.New("invalid NIP-44 payload")
+	}
+
+	nonce := payload[1:33]
+	ciphertext := payload[33 : len(payload)-sha256.Size]
+	mac := payload[len(payload)-sha256.Size:]
+
+	chachaKey, chachaNonce, hmacKey, err := nip44MessageKeys(
+		conversationKey, nonce,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	if !hmac.Equal(mac, nip44MAC(hmacKey, nonce, ciphertext)) {
+		return "", errors.New("invalid NIP-44 MAC")
+	}
+
+	stream, err := chacha20.NewUnauthenticatedCipher(chachaKey, chachaNonce)
+	if err != nil {
+		return "", err
+	}
+	padded := make([]byte, len(ciphertext))
+	stream.XORKeyStream(padded, ciphertext)
+
+	size := int(binary.BigEndian.Uint16(padded))
+	if size == 0 || len(padded) != 2+nip44PaddedLen(size) {
+		return "", errors.New("invalid NIP-44 padding")
+	}
+
+	return string(padded[2 : 2+size]), nil
+}
+
+// nip44MAC authenticates the ciphertext and its nonce.
+func nip44MAC(hmacKey, nonce, ciphertext []byte) []byte {
+	mac := hmac.New(sha256.New, hmacKey)
+	mac.Write(nonce)
+	mac.Write(ciphertext)
+
+	return mac.Sum(nil)
+}
@@ -0,0 +1,651 @@
+package wallets
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+)
+
+const (
+	// DefaultNWCTimeout is the time the NWC wallet is given to answer a
+	// request.
+	DefaultNWCTimeout = 60 * time.Second
+
+	// nwcURIScheme is the scheme of the NWC connection URIs.
+	nwcURIScheme = "nostr+walletconnect"
+
+	// The kinds of the NIP-47 events.
+	nwcKindInfo     = 13194
+	nwcKindRequest  = 23194
+	nwcKindResponse = 23195
+
+	// The encryption schemes of the NIP-47 requests.
+	nwcEncryptionNIP04 = "nip04"
+	nwcEncryptionNIP44 = "nip44_v2"
+)
+
+// The NIP-47 error codes.
+const (
+	nwcCodeRateLimited         = "RATE_LIMITED"
+	nwcCodeInsufficientBalance = "INSUFFICIENT_BALANCE"
+	nwcCodeQuotaExceeded       = "QUOTA_EXCEEDED"
+	nwcCodeRestricted          = "RESTRICTED"
+	nwcCodeUnauthorized        = "UNAUTHORIZED"
+	nwcCodePaymentFailed       = "PAYMENT_FAILED"
+	nwcCodeNotFound            = "NOT_FOUND"
+)
+
+// DeleteNWCWallet deletes the NWC wallet with the given ID.
+func DeleteNWCWallet(store Store, id uint64) error {
+	err := store.DeleteWalletToken(id)
+	if err != nil {
+		return fmt.Errorf("unable to delete wallet token: %w", err)
+	}
+
+	err = store.DeleteWallet(id)
+
+	return err
+}
+
+// NWCConnection holds the parameters of a nostr+walletconnect:// URI.
+type NWCConnection struct {
+	// WalletPubKey is the hex encoded public key of the wallet service.
+	WalletPubKey string
+
+	// Relays are the websocket URLs of the relays the wallet service
+	// listens on.
+	Relays []string
+
+	// Secret is the hex encoded secret key signing the requests.
+	Secret string
+}
+
+// ParseNWCURI parses a nostr+walletconnect:// connection URI.
+func ParseNWCURI(uri string) (*NWCConnection, error) {
+	u, err := url.Parse(strings.TrimSpace(uri))
+	if err != nil {
+		return nil, fmt.Errorf("invalid NWC URI: %w", err)
+	}
+
+	if u.Scheme != nwcURIScheme {
+		return nil, fmt.Errorf("invalid NWC URI: the scheme must be %s",
+			nwcURIScheme)
+	}
+
+	// The public key is the host of nostr+walletconnect://<pubkey>, or
+	// the opaque part of nostr+walletconnect:<pubkey>.
+	pubKey := u.Host
+	if pubKey == "" {
+		pubKey = strings.TrimPrefix(u.Opaque, "//")
+	}
+
+	conn := &NWCConnection{
+		WalletPubKey: strings.ToLower(pubKey),
+		Relays:       u.Query()["relay"],
+		Secret:       u.Query().Get("secret"),
+	}
+
+	if _, err := parseNostrPubKey(conn.WalletPubKey); err != nil {
+		return nil, fmt.Errorf("invalid NWC wallet public key: %w", err)
+	}
+
+	if len(conn.Relays) == 0 {
+		return nil, errors.New("invalid NWC URI: no relay")
+	}
+
+	if secret, err := hex.DecodeString(conn.Secret); err != nil ||
+		len(secret) != 32 {
+
+		return nil, errors.New("invalid NWC URI: the secret must be a " +
+			"hex encoded 32 bytes key")
+	}
+
+	return conn, nil
+}
+
+// NWCError is an error returned by an NWC wallet service. It matches the
+// typed wallet error of its cause with errors.Is.
+type NWCError struct {
+	// Code is the NIP-47 error code.
+	Code string `json:"code"`
+
+	// Message is the error message sent by the wallet service.
+	Message string `json:"message"`
+}
+
+// Error returns the error message.
+func (e *NWCError) Error() string {
+	return fmt.Sprintf("NWC error(%s): %s", e.Code, e.Message)
+}
+
+// Unwrap returns the typed wallet error of the NWC error, nil if there is
+// none.
+func (e *NWCError) Unwrap() error {
+	switch e.Code {
+	case nwcCodeRateLimited:
+		return ErrRateLimited
+
+	case nwcCodeUnauthorized, nwcCodeRestricted:
+		return ErrUnauthorized
+
+	case nwcCodeInsufficientBalance, nwcCodeQuotaExceeded:
+		return ErrInsufficientBalance
+
+	case nwcCodePaymentFailed:
+		return ErrPaymentFailed
+
+	case nwcCodeNotFound:
+		return ErrPaymentNotFound
+	}
+
+	return nil
+}
+
+// nwcRequest is the content of a NIP-47 request.
+type nwcRequest struct {
+	Method string `json:"method"`
+	Params any    `json:"params"`
+}
+
+// nwcResponse is the content of a NIP-47 response.
+type nwcResponse struct {
+	ResultType string          `json:"result_type"`
+	Error      *NWCError       `json:"error"`
+	Result     json.RawMessage `json:"result"`
+}
+
+// NWCClient is a client paying the invoices with a wallet service through
+// Nostr Wallet Connect (NIP-47).
+type NWCClient struct {
+	// Connection holds the parameters of the connection URI.
+	Connection NWCConnection
+
+	// Timeout is the time the wallet service is given to answer a
+	// request.
+	Timeout time.Duration
+
+	secret    *btcec.PrivateKey
+	walletKey *btcec.PublicKey
+
+	// encryption is the encryption scheme supported by the wallet
+	// service, looked up with the first request.
+	encryption   string
+	encryptionMu sync.Mutex
+}
+
+// NewNWCClient returns a new client for the wallet service of the
+// nostr+walletconnect:// URI.
+func NewNWCClient(uri string) (*NWCClient, error) {
+	conn, err := ParseNWCURI(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, _ := hex.DecodeString(conn.Secret)
+	key, _ := btcec.PrivKeyFromBytes(secret)
+
+	walletKey, err := parseNostrPubKey(conn.WalletPubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &NWCClient{
+		Connection: *conn,
+		Timeout:    DefaultNWCTimeout,
+		secret:     key,
+		walletKey:  walletKey,
+	}, nil
+}
+
+// GetPreimage returns the preimage for the given LN invoice.
+func (n *NWCClient) GetPreimage(invoice string) (string, error) {
+	return n.pay(context.Background(), invoice, 0)
+}
+
+// GetPreimageContext returns the preimage for the given LN invoice, giving up
+// when the context is done.
+func (n *NWCClient) GetPreimageContext(ctx context.Context,
+	invoice string) (string, error) {
+
+	return n.pay(ctx, invoice, 0)
+}
+
+// GetPreimageForAmount pays the amountless LN invoice with the given amount
+// and returns its preimage.
+func (n *NWCClient) GetPreimageForAmount(ctx context.Context, invoice string,
+	amountSats uint64) (string, error) {
+
+	return n.pay(ctx, invoice, amountSats)
+}
+
+// pay pays the LN invoice with the pay_invoice method, amountSats is only set
+// for amountless invoices.
+func (n *NWCClient) pay(ctx context.Context, invoice string,
+	amountSats uint64) (string, error) {
+
+	params := map[string]any{"invoice": invoice}
+	if amountSats != 0 {
+		params["amount"] = amountSats * 1000
+	}
+
+	var result struct {
+		Preimage string `json:"preimage"`
+	}
+	err := n.request(ctx, "pay_invoice", params, &result)
+	if err != nil {
+		return "", err
+	}
+
+	if result.Preimage == "" {
+		return "", errors.New("NWC payment without preimage")
+	}
+
+	return result.Preimage, nil
+}
+
+// LookupPayment returns the status of the payment of the invoice with the
+// given payment hash.
+func (n *NWCClient) LookupPayment(ctx context.Context,
+	paymentHash string) (*PaymentStatus, error) {
+
+	var result struct {
+		Type      string `json:"type"`
+		State     string `json:"state"`
+		Preimage  string `json:"preimage"`
+		SettledAt int64  `json:"settled_at"`
+	}
+	err := n.request(ctx, "lookup_invoice", map[string]any{
+		"payment_hash": paymentHash,
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case result.Type != "" && result.Type != "outgoing":
+		return nil, ErrPaymentNotFound
+
+	case result.State == "settled" ||
+		(result.State == "" && result.SettledAt != 0):
+
+		return &PaymentStatus{
+			State:    PaymentSucceeded,
+			Preimage: result.Preimage,
+		}, nil
+
+	case result.State == "failed" || result.State == "expired":
+		return &PaymentStatus{State: PaymentFailed}, nil
+	}
+
+	return &PaymentStatus{State: PaymentInFlight}, nil
+}
+
+// CheckAuth checks that the wallet service accepts the connection, and that
+// the connection is allowed to pay invoices.
+func (n *NWCClient) CheckAuth(ctx context.Context) error {
+	var info struct {
+		Alias   string   `json:"alias"`
+		Methods []string `json:"methods"`
+	}
+	err := n.request(ctx, "get_info", struct{}{}, &info)
+	if err != nil {
+		return err
+	}
+
+	for _, method := range info.Methods {
+		if method == "pay_invoice" {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("NWC connection not allowed to pay invoices: %w",
+		ErrUnauthorized)
+}
+
+// Balance returns the balance of the wallet, in sats.
+func (n *NWCClient) Balance(ctx context.Context) (uint64, error) {
+	var result struct {
+		// Balance is the balance in millisatoshis.
+		Balance uint64 `json:"balance"`
+	}
+	err := n.request(ctx, "get_balance", struct{}{}, &result)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.Balance / 1000, nil
+}
+
+// request sends a NIP-47 request to the wallet service and decodes the result
+// of its response into result. The relays are tried in order until one
+// accepts the request, it is never sent twice.
+func (n *NWCClient) request(ctx context.Context, method string, params,
+	result any) error {
+
+	timeout := n.Timeout
+	if timeout == 0 {
+		timeout = DefaultNWCTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var errs []error
+	for _, relayURL := range n.Connection.Relays {
+		published, err := n.requestRelay(
+			ctx, relayURL, method, params, result, timeout,
+		)
+		if err == nil || published || ctx.Err() != nil {
+			return err
+		}
+
+		slog.Debug("NWC relay failed.", "relay", relayURL, "error", err)
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// requestRelay sends the request through the relay. True is returned once the
+// request was published, so it is not sent through another relay.
+func (n *NWCClient) requestRelay(ctx context.Context, relayURL, method string,
+	params, result any, timeout time.Duration) (bool, error) {
+
+	relay, err := dialNostrRelay(ctx, relayURL)
+	if err != nil {
+		return false, err
+	}
+	defer relay.close()
+
+	encryption, err := n.walletEncryption(relay)
+	if err != nil {
+		return false, err
+	}
+
+	content, err := json.Marshal(&nwcRequest{Method: method, Params: params})
+	if err != nil {
+		return false, fmt.Errorf("unable to encode request: %w", err)
+	}
+
+	encrypted, err := n.encrypt(encryption, string(content))
+	if err != nil {
+		return false, err
+	}
+
+	// The wallet service ignores the requests it gets after we give up.
+	now := time.Now()
+	event := &nostrEvent{
+		CreatedAt: now.Unix(),
+		Kind:      nwcKindRequest,
+		Tags: [][]string{
+			{"p", n.Connection.WalletPubKey},
+			{"expiration", strconv.FormatInt(
+				now.Add(timeout).Unix(), 10,
+			)},
+		},
+		Content: encrypted,
+	}
+	if encryption == nwcEncryptionNIP44 {
+		event.Tags = append(event.Tags,
+			[]string{"encryption", nwcEncryptionNIP44})
+	}
+
+	err = event.sign(n.secret)
+	if err != nil {
+		return false, err
+	}
+
+	// The subscription to the response is opened before publishing the
+	// request, so the response can not be missed.
+	subID := randomSubscriptionID()
+	err = relay.send("REQ", subID, &nostrFilter{
+		Kinds:   []int{nwcKindResponse},
+		Authors: []string{n.Connection.WalletPubKey},
+		Events:  []string{event.ID},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	err = relay.send("EVENT", event)
+	if err != nil {
+		return false, err
+	}
+
+	for {
+		msgType, fields, err := relay.read()
+		if err != nil {
+			if ctx.Err() != nil {
+				return true, fmt.Errorf("no answer from the NWC wallet: "+
+					"%w", ctx.Err())
+			}
+
+			return true, err
+		}
+
+		switch msgType {
+		case "OK":
+			var (
+				id       string
+				accepted bool
+				message  string
+			)
+			if !decodeRelayFields(fields, &id, &accepted, &message) ||
+				id != event.ID || accepted {
+
+				continue
+			}
+
+			return false, fmt.Errorf("relay %s rejected the request: %s",
+				relayURL, message)
+
+		case "CLOSED":
+			var id, message string
+			if !decodeRelayFields(fields, &id, &message) || id != subID {
+				continue
+			}
+
+			return true, fmt.Errorf("relay %s closed the subscription: %s",
+				relayURL, message)
+
+		case "NOTICE":
+			slog.Debug("NWC relay notice.", "relay", relayURL,
+				"notice", string(fields[0]))
+
+		case "EVENT":
+			var (
+				id       string
+				response nostrEvent
+			)
+			if !decodeRelayFields(fields, &id, &response) ||
+				id != subID {
+
+				continue
+			}
+
+			err := n.checkResponse(&response, event.ID)
+			if err != nil {
+				slog.Debug("Ignoring NWC response.", "error", err)
+				continue
+			}
+
+			return true, n.decodeResponse(&response, method, result)
+		}
+	}
+}
+
+// checkResponse checks that the event is a response of the wallet service to
+// the request with the given ID.
+func (n *NWCClient) checkResponse(event *nostrEvent, requestID string) error {
+	switch {
+	case event.Kind != nwcKindResponse:
+		return fmt.Errorf("unexpected event kind %d", event.Kind)
+
+	case event.PubKey != n.Connection.WalletPubKey:
+		return errors.New("response not sent by the wallet service")
+
+	case event.tag("e") != requestID:
+		return errors.New("response to another request")
+	}
+
+	return event.verify()
+}
+
+// decodeResponse decrypts the response and decodes its result into result.
+func (n *NWCClient) decodeResponse(event *nostrEvent, method string,
+	result any) error {
+
+	// The response is encrypted like the request, the NIP-04 payloads are
+	// the only ones holding an IV.
+	encryption := nwcEncryptionNIP44
+	if strings.Contains(event.Content, "?iv=") {
+		encryption = nwcEncryptionNIP04
+	}
+
+	content, err := n.decrypt(encryption, event.Content)
+	if err != nil {
+		return fmt.Errorf("unable to decrypt NWC response: %w", err)
+	}
+
+	var response nwcResponse
+	err = json.Unmarshal([]byte(content), &response)
+	if err != nil {
+		return fmt.Errorf("unable to parse NWC response: %w", err)
+	}
+
+	switch {
+	case response.Error != nil:
+		return response.Error
+
+	case response.ResultType != method:
+		return fmt.Errorf("unexpected NWC result type %q",
+			response.ResultType)
+	}
+
+	err = json.Unmarshal(response.Result, result)
+	if err != nil {
+		return fmt.Errorf("unable to parse NWC result: %w", err)
+	}
+
+	return nil
+}
+
+// walletEncryption returns the encryption scheme of the wallet service. It is
+// read from its info event, NIP-04 is used if it does not support NIP-44.
+func (n *NWCClient) walletEncryption(relay *nostrRelay) (string, error) {
+	n.encryptionMu.Lock()
+	defer n.encryptionMu.Unlock()
+
+	if n.encryption != "" {
+		return n.encryption, nil
+	}
+
+	subID := randomSubscriptionID()
+	err := relay.send("REQ", subID, &nostrFilter{
+		Kinds:   []int{nwcKindInfo},
+		Authors: []string{n.Connection.WalletPubKey},
+		Limit:   1,
+	})
+	if err != nil {
+		return "", err
+	}
+	defer relay.send("CLOSE", subID)
+
+	encryption := nwcEncryptionNIP04
+	for {
+		msgType, fields, err := relay.read()
+		if err != nil {
+			return "", err
+		}
+
+		var id string
+		if len(fields) == 0 || json.Unmarshal(fields[0], &id) != nil ||
+			id != subID {
+
+			continue
+		}
+
+		if msgType == "EVENT" {
+			var info nostrEvent
+			if !decodeRelayFields(fields, &id, &info) ||
+				info.PubKey != n.Connection.WalletPubKey ||
+				info.verify() != nil {
+
+				continue
+			}
+
+			schemes := strings.Fields(info.tag("encryption"))
+			for _, scheme := range schemes {
+				if scheme == nwcEncryptionNIP44 {
+					encryption = nwcEncryptionNIP44
+				}
+			}
+		}
+
+		// The stored events are sent before the EOSE.
+		if msgType == "EOSE" || msgType == "CLOSED" {
+			break
+		}
+	}
+
+	n.encryption = encryption
+
+	return encryption, nil
+}
+
+// encrypt encrypts the content for the wallet service.
+func (n *NWCClient) encrypt(encryption, content string) (string, error) {
+	sharedSecret := nostrSharedSecret(n.secret, n.walletKey)
+
+	if encryption == nwcEncryptionNIP44 {
+		return nip44Encrypt(nip44ConversationKey(sharedSecret), content)
+	}
+
+	return nip04Encrypt(sharedSecret, content)
+}
+
+// decrypt decrypts the content sent by the wallet service.
+func (n *NWCClient) decrypt(encryption, content string) (string, error) {
+	sharedSecret := nostrSharedSecret(n.secret, n.walletKey)
+
+	if encryption == nwcEncryptionNIP44 {
+		return nip44Decrypt(nip44ConversationKey(sharedSecret), content)
+	}
+
+	return nip04Decrypt(sharedSecret, content)
+}
+
+// decodeRelayFields decodes the fields of a relay message into the given
+// values, false is returned if they do not match.
+func decodeRelayFields(fields []json.RawMessage, values ...any) bool {
+	if len(fields) < len(values) {
+		return false
+	}
+
+	for i, value := range values {
+		if json.Unmarshal(fields[i], value) != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// randomSubscriptionID returns a new random subscription ID.
+func randomSubscriptionID() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,462 @@
+package wallets
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/fewsats/fewsatscli/fewsatstest"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+// nwcStandIn is a local stand-in for a nostr relay and the NWC wallet service
+// listening on it.
+type nwcStandIn struct {
+	t *testing.T
+
+	// key is the key of the wallet service.
+	key *btcec.PrivateKey
+
+	// encryption is the encryption tag of the info event, no info event
+	// is sent if empty.
+	encryption string
+
+	// response is the answer to the requests, none is sent if empty.
+	response string
+
+	// rejected makes the relay refuse the requests.
+	rejected bool
+
+	// request and requestEncryption are the last request received.
+	request           nwcRequest
+	requestEncryption string
+}
+
+func newNWCStandIn(t *testing.T) *nwcStandIn {
+	key, err := btcec.NewPrivateKey()
+	require.NoError(t, err)
+
+	return &nwcStandIn{t: t, key: key}
+}
+
+// uri returns the connection URI of the wallet service on the relay at the
+// given URL, with a new client secret.
+func (n *nwcStandIn) uri(relayURL string) string {
+	secret, err := btcec.NewPrivateKey()
+	require.NoError(n.t, err)
+
+	return "nostr+walletconnect://" +
+		hex.EncodeToString(schnorr.SerializePubKey(n.key.PubKey())) +
+		"?relay=" + "ws" + strings.TrimPrefix(relayURL, "http") +
+		"&secret=" + hex.EncodeToString(secret.Serialize())
+}
+
+func (n *nwcStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+	require.NoError(n.t, err)
+	defer conn.Close()
+
+	var responseSubID string
+	for {
+		var msg []json.RawMessage
+		if conn.ReadJSON(&msg) != nil {
+			return
+		}
+
+		var msgType, subID string
+		require.NoError(n.t, json.Unmarshal(msg[0], &msgType))
+
+		switch msgType {
+		case "REQ":
+			var filter nostrFilter
+			require.NoError(n.t, json.Unmarshal(msg[1], &subID))
+			require.NoError(n.t, json.Unmarshal(msg[2], &filter))
+
+			if filter.Kinds[0] == nwcKindResponse {
+				responseSubID = subID
+				continue
+			}
+
+			if n.encryption != "" {
+				info := &nostrEvent{
+					CreatedAt: time.Now().Unix(),
+					Kind:      nwcKindInfo,
+					Tags: [][]string{
+						{"encryption", n.encryption},
+					},
+					Content: "pay_invoice lookup_invoice",
+				}
+				require.NoError(n.t, info.sign(n.key))
+				conn.WriteJSON([]any{"EVENT", subID, info})
+			}
+			conn.WriteJSON([]any{"EOSE", subID})
+
+		case "EVENT":
+			var event nostrEvent
+			require.NoError(n.t, json.Unmarshal(msg[1], &event))
+			require.NoError(n.t, event.verify())
+
+			if n.rejected {
+				conn.WriteJSON([]any{
+					"OK", event.ID, false, "blocked: not allowed",
+				})
+				continue
+			}
+			conn.WriteJSON([]any{"OK", event.ID, true, ""})
+
+			response := n.answer(&event)
+			if response != nil {
+				conn.WriteJSON([]any{"EVENT", responseSubID, response})
+			}
+		}
+	}
+}
+
+// answer decrypts the request and returns the response event, nil if there
+// is none.
+func (n *nwcStandIn) answer(request *nostrEvent) *nostrEvent {
+	clientKey, err := parseNostrPubKey(request.PubKey)
+	require.NoError(n.t, err)
+
+	sharedSecret := nostrSharedSecret(n.key, clientKey)
+	conversationKey := nip44ConversationKey(sharedSecret)
+
+	n.requestEncryption = request.tag("encryption")
+
+	var content string
+	if n.requestEncryption == nwcEncryptionNIP44 {
+		content, err = nip44Decrypt(conversationKey, request.Content)
+	} else {
+		content, err = nip04Decrypt(sharedSecret, request.Content)
+	}
+	require.NoError(n.t, err)
+	require.NoError(n.t, json.Unmarshal([]byte(content), &n.request))
+
+	if n.response == "" {
+		return nil
+	}
+
+	if n.requestEncryption == nwcEncryptionNIP44 {
+		content, err = nip44Encrypt(conversationKey, n.response)
+	} else {
+		content, err = nip04Encrypt(sharedSecret, n.response)
+	}
+	require.NoError(n.t, err)
+
+	response := &nostrEvent{
+		CreatedAt: time.Now().Unix(),
+		Kind:      nwcKindResponse,
+		Tags: [][]string{
+			{"p", request.PubKey},
+			{"e", request.ID},
+		},
+		Content: content,
+	}
+	require.NoError(n.t, response.sign(n.key))
+
+	return response
+}
+
+func TestNWCClient(t *testing.T) {
+	srv, err := fewsatstest.New(fewsatstest.Config{})
+	require.NoError(t, err)
+
+	invoice, preimage, err := srv.CreateInvoice(21, "nwc")
+	require.NoError(t, err)
+
+	paid := `{"result_type": "pay_invoice", ` +
+		`"result": {"preimage": "` + preimage + `"}}`
+
+	tests := []struct {
+		name           string
+		encryption     string
+		response       string
+		rejected       bool
+		wantEncryption string
+		wantError      error
+		wantText       string
+	}{
+		{
+			name:           "nip44",
+			encryption:     "nip44_v2 nip04",
+			response:       paid,
+			wantEncryption: nwcEncryptionNIP44,
+		},
+		{
+			name:       "nip04",
+			encryption: "nip04",
+			response:   paid,
+		},
+		{
+			name:     "no info event",
+			response: paid,
+		},
+		{
+			name: "insufficient balance",
+			response: `{"result_type": "pay_invoice", "error": ` +
+				`{"code": "INSUFFICIENT_BALANCE", ` +
+				`"message": "not enough funds"}}`,
+			wantError: ErrInsufficientBalance,
+		},
+		{
+			name: "restricted",
+			response: `{"result_type": "pay_invoice", "error": ` +
+				`{"code": "RESTRICTED", ` +
+				`"message": "pay_invoice not allowed"}}`,
+			wantError: ErrUnauthorized,
+		},
+		{
+			name: "payment failed",
+			response: `{"result_type": "pay_invoice", "error": ` +
+				`{"code": "PAYMENT_FAILED", "message": "no route"}}`,
+			wantError: ErrPaymentFailed,
+		},
+		{
+			name:     "rejected by the relay",
+			rejected: true,
+			wantText: "rejected the request",
+		},
+		{
+			name:     "no answer",
+			wantText: "no answer from the NWC wallet",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			standIn := newNWCStandIn(t)
+			standIn.encryption = tc.encryption
+			standIn.response = tc.response
+			standIn.rejected = tc.rejected
+
+			server := httptest.NewServer(standIn)
+			defer server.Close()
+
+			client, err := NewNWCClient(standIn.uri(server.URL))
+			require.NoError(t, err)
+			client.Timeout = time.Second
+
+			got, err := client.GetPreimage(invoice)
+			switch {
+			case tc.wantError != nil:
+				require.ErrorIs(t, err, tc.wantError)
+				return
+
+			case tc.wantText != "":
+				require.ErrorContains(t, err, tc.wantText)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, preimage, got)
+
+			require.Equal(t, "pay_invoice", standIn.request.Method)
+			require.Equal(t, map[string]any{"invoice": invoice},
+				standIn.request.Params)
+			require.Equal(t, tc.wantEncryption, standIn.requestEncryption)
+		})
+	}
+}
+
+func TestNWCClientLookupPayment(t *testing.T) {
+	tests := []struct {
+		name      string
+		response  string
+		wantState PaymentState
+		wantError error
+	}{
+		{
+			name: "settled",
+			response: `{"result_type": "lookup_invoice", "result": ` +
+				`{"type": "outgoing", "preimage": "00", ` +
+				`"settled_at": 1700000000}}`,
+			wantState: PaymentSucceeded,
+		},
+		{
+			name: "pending",
+			response: `{"result_type": "lookup_invoice", "result": ` +
+				`{"type": "outgoing", "state": "pending"}}`,
+			wantState: PaymentInFlight,
+		},
+		{
+			name: "failed",
+			response: `{"result_type": "lookup_invoice", "result": ` +
+				`{"type": "outgoing", "state": "failed"}}`,
+			wantState: PaymentFailed,
+		},
+		{
+			name: "incoming",
+			response: `{"result_type": "lookup_invoice", "result": ` +
+				`{"type": "incoming", "state": "settled"}}`,
+			wantError: ErrPaymentNotFound,
+		},
+		{
+			name: "not found",
+			response: `{"result_type": "lookup_invoice", "error": ` +
+				`{"code": "NOT_FOUND", "message": "invoice not found"}}`,
+			wantError: ErrPaymentNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			standIn := newNWCStandIn(t)
+			standIn.response = tc.response
+
+			server := httptest.NewServer(standIn)
+			defer server.Close()
+
+			client, err := NewNWCClient(standIn.uri(server.URL))
+			require.NoError(t, err)
+
+			status, err := client.LookupPayment(
+				context.Background(), "00",
+			)
+			if tc.wantError != nil {
+				require.ErrorIs(t, err, tc.wantError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.wantState, status.State)
+			require.Equal(t, map[string]any{"payment_hash": "00"},
+				standIn.request.Params)
+		})
+	}
+}
+
+func TestNWCClientCheckAuth(t *testing.T) {
+	tests := []struct {
+		name      string
+		response  string
+		wantError error
+	}{
+		{
+			name: "allowed to pay",
+			response: `{"result_type": "get_info", "result": ` +
+				`{"alias": "hub", "methods": ["get_balance", ` +
+				`"pay_invoice"]}}`,
+		},
+		{
+			name: "read only",
+			response: `{"result_type": "get_info", "result": ` +
+				`{"alias": "hub", "methods": ["get_balance"]}}`,
+			wantError: ErrUnauthorized,
+		},
+		{
+			name: "unknown connection",
+			response: `{"result_type": "get_info", "error": ` +
+				`{"code": "UNAUTHORIZED", "message": "no wallet"}}`,
+			wantError: ErrUnauthorized,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			standIn := newNWCStandIn(t)
+			standIn.response = tc.response
+
+			server := httptest.NewServer(standIn)
+			defer server.Close()
+
+			client, err := NewNWCClient(standIn.uri(server.URL))
+			require.NoError(t, err)
+
+			err = client.CheckAuth(context.Background())
+			if tc.wantError != nil {
+				require.ErrorIs(t, err, tc.wantError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, "get_info", standIn.request.Method)
+		})
+	}
+}
+
+func TestNWCClientBalance(t *testing.T) {
+	standIn := newNWCStandIn(t)
+	standIn.response = `{"result_type": "get_balance", ` +
+		`"result": {"balance": 1234999}}`
+
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	client, err := NewNWCClient(standIn.uri(server.URL))
+	require.NoError(t, err)
+
+	balance, err := client.Balance(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, uint64(1234), balance)
+	require.Equal(t, "get_balance", standIn.request.Method)
+}
+
+func TestParseNWCURI(t *testing.T) {
+	pubKey := "b889ff5b1513b641e2a139f661a661364979c5beee91842f8f0ef42ab558e9d4"
+	secret := "71a8c14c1407c113601079c4302dab36460f0ccd0ad506f1f2dc73b5100e4f3c"
+
+	conn, err := ParseNWCURI("nostr+walletconnect://" + pubKey +
+		"?relay=wss%3A%2F%2Frelay.damus.io&relay=wss://nos.lol" +
+		"&secret=" + secret)
+	require.NoError(t, err)
+	require.Equal(t, &NWCConnection{
+		WalletPubKey: pubKey,
+		Relays:       []string{"wss://relay.damus.io", "wss://nos.lol"},
+		Secret:       secret,
+	}, conn)
+
+	invalid := []string{
+		"https://" + pubKey + "?relay=wss://nos.lol&secret=" + secret,
+		"nostr+walletconnect://00?relay=wss://nos.lol&secret=" + secret,
+		"nostr+walletconnect://" + pubKey + "?secret=" + secret,
+		"nostr+walletconnect://" + pubKey + "?relay=wss://nos.lol",
+	}
+	for _, uri := range invalid {
+		_, err := ParseNWCURI(uri)
+		require.Error(t, err, uri)
+	}
+}
+
+func TestNIP44(t *testing.T) {
+	// The first vector of the NIP-44 specification.
+	sec1, _ := btcec.PrivKeyFromBytes(mustDecodeHex(t,
+		"0000000000000000000000000000000000000000000000000000000000000001"))
+	sec2, _ := btcec.PrivKeyFromBytes(mustDecodeHex(t,
+		"0000000000000000000000000000000000000000000000000000000000000002"))
+
+	conversationKey := nip44ConversationKey(
+		nostrSharedSecret(sec1, sec2.PubKey()),
+	)
+	require.Equal(t,
+		"c41c775356fd92eadc63ff5a0dc1da211b268cbea22316767095b2871ea1412d",
+		hex.EncodeToString(conversationKey))
+
+	nonce := mustDecodeHex(t,
+		"0000000000000000000000000000000000000000000000000000000000000001")
+	payload, err := nip44EncryptWithNonce(conversationKey, nonce, "a")
+	require.NoError(t, err)
+	require.Equal(t, "AgAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAABee0G5VSK0/9Y"+
+		"ypIObAtDKfYEAjD35uVkHyB0F4DwrcNaCXlCWZKaArsGrY6M9wnuTMxWfp1RTN9X"+
+		"ga8no+kF5Vsb", payload)
+
+	plaintext, err := nip44Decrypt(conversationKey, payload)
+	require.NoError(t, err)
+	require.Equal(t, "a", plaintext)
+
+	// The other party derives the same key.
+	require.Equal(t, conversationKey, nip44ConversationKey(
+		nostrSharedSecret(sec2, sec1.PubKey()),
+	))
+}
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	require.NoError(t, err)
+
+	return b
+}
@@ -0,0 +1,71 @@
+package wallets
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/urfave/cli/v2"
+)
+
+var removeCommand = &cli.Command{
+	Name:      "remove",
+	Usage:     "Remove the wallet with the given ID and its credentials.",
+	ArgsUsage: "<id>",
+	Action:    removeWallet,
+}
+
+// removeWallet removes the wallet with the given ID. If it was the default
+// wallet, the most recent remaining wallet becomes the default one.
+func removeWallet(c *cli.Context) error {
+	store, ok := c.App.Metadata["store"].(Store)
+	if !ok {
+		return errors.New("failed to get store from context")
+	}
+
+	if c.Args().Len() < 1 {
+		return cli.Exit("missing <id> argument", 1)
+	}
+
+	id, err := parseID(c.Args().Get(0))
+	if err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+
+	defaultID, err := store.GetDefaultWallet()
+	if err != nil && !errors.Is(err, ErrNoWalletFound) {
+		slog.Debug("Failed to get default wallet.", "error", err)
+		return cli.Exit("failed to remove wallet", 1)
+	}
+
+	err = DeleteWallet(store, id)
+	switch {
+	case errors.Is(err, ErrNoWalletFound):
+		return cli.Exit(fmt.Sprintf("wallet %d not found", id), 1)
+
+	case err != nil:
+		slog.Debug("Failed to delete wallet.", "error", err)
+		return cli.Exit("failed to remove wallet", 1)
+	}
+
+	fmt.Println("Wallet removed successfully.")
+
+	if id != defaultID {
+		return nil
+	}
+
+	// The store replaced the default wallet when deleting it.
+	newDefault, err := store.GetDefaultWallet()
+	switch {
+	case errors.Is(err, ErrNoWalletFound):
+		return nil
+
+	case err != nil:
+		slog.Debug("Failed to get default wallet.", "error", err)
+		return cli.Exit("failed to get default wallet", 1)
+	}
+
+	fmt.Printf("Wallet %d is now the default wallet.\n", newDefault)
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package wallets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/urfave/cli/v2"
+)
+
+var testCommand = &cli.Command{
+	Name: "test",
+	Usage: "Check that the backend of the wallet accepts its credentials, " +
+		"without paying anything. The default wallet is tested if no " +
+		"ID is given.",
+	ArgsUsage: "[id]",
+	Flags: []cli.Flag{
+		timeoutFlag,
+	},
+	Action: testWallet,
+}
+
+// testWallet checks the credentials of the wallet with the given ID.
+func testWallet(c *cli.Context) error {
+	id, wallet, err := walletFromArgs(c)
+	if err != nil {
+		return err
+	}
+
+	checker, ok := wallet.(AuthChecker)
+	if !ok {
+		return cli.Exit(fmt.Sprintf("wallet %d can not be tested", id), 1)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context, c.Duration("timeout"))
+	defer cancel()
+
+	err = checker.CheckAuth(ctx)
+	switch {
+	case errors.Is(err, ErrUnauthorized):
+		return cli.Exit(fmt.Sprintf("wallet %d credentials rejected: %v",
+			id, err), 1)
+
+	case err != nil:
+		slog.Debug("Failed to test wallet.", "error", err)
+		return cli.Exit(fmt.Sprintf("wallet %d test failed: %v", id, err),
+			1)
+	}
+
+	fmt.Printf("Wallet %d is working.\n", id)
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package wallets
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/urfave/cli/v2"
+)
+
+var useCommand = &cli.Command{
+	Name:      "use",
+	Usage:     "Make the wallet with the given ID the default one.",
+	ArgsUsage: "<id>",
+	Action:    useWallet,
+}
+
+// useWallet sets the wallet with the given ID as the default wallet.
+func useWallet(c *cli.Context) error {
+	store, ok := c.App.Metadata["store"].(Store)
+	if !ok {
+		return errors.New("failed to get store from context")
+	}
+
+	if c.Args().Len() < 1 {
+		return cli.Exit("missing <id> argument", 1)
+	}
+
+	id, err := parseID(c.Args().Get(0))
+	if err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+
+	_, err = store.GetWallet(id)
+	switch {
+	case errors.Is(err, ErrNoWalletFound):
+		return cli.Exit(fmt.Sprintf("wallet %d not found", id), 1)
+
+	case err != nil:
+		slog.Debug("Failed to get wallet.", "error", err)
+		return cli.Exit("failed to set default wallet", 1)
+	}
+
+	err = store.SetDefaultWallet(id)
+	if err != nil {
+		slog.Debug("Failed to set default wallet.", "error", err)
+		return cli.Exit("failed to set default wallet", 1)
+	}
+
+	fmt.Printf("Wallet %d is now the default wallet.\n", id)
+
+	return nil
+}
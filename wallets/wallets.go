@@ -1,7 +1,10 @@
 package wallets
 
 import (
+	"errors"
 	"fmt"
+	"log/slog"
+	"strconv"
 	"strings"
 	"time"
 
@@ -9,17 +12,47 @@ import (
 )
 
 const (
-	WalletTypeAlby = "alby"
-	WalletTypeZBD  = "zbd"
+	WalletTypeAlby   = "alby"
+	WalletTypeZBD    = "zbd"
+	WalletTypeDev    = "dev"
+	WalletTypeLND    = "lnd"
+	WalletTypeCLN    = "cln"
+	WalletTypeNWC    = "nwc"
+	WalletTypeLNbits = "lnbits"
 )
 
 var (
 	AllSupportedWallets = []string{
 		WalletTypeAlby,
 		WalletTypeZBD,
+		WalletTypeDev,
+		WalletTypeLND,
+		WalletTypeCLN,
+		WalletTypeNWC,
+		WalletTypeLNbits,
 	}
 
 	ErrNoWalletFound = fmt.Errorf("no wallet found")
+
+	// ErrPaymentNotFound is the error returned by PaymentLookup when the
+	// wallet never sent the payment.
+	ErrPaymentNotFound = fmt.Errorf("payment not found")
+
+	// ErrPaymentFailed is the error returned when the wallet backend
+	// refused or failed to pay an invoice, nothing was paid.
+	ErrPaymentFailed = fmt.Errorf("payment failed")
+
+	// ErrInsufficientBalance is the error returned when the wallet has not
+	// enough funds to pay an invoice.
+	ErrInsufficientBalance = fmt.Errorf("insufficient balance")
+
+	// ErrUnauthorized is the error returned when the wallet backend
+	// rejects the credentials of the wallet.
+	ErrUnauthorized = fmt.Errorf("wallet credentials rejected")
+
+	// ErrRateLimited is the error returned when the wallet backend limits
+	// the rate of the requests.
+	ErrRateLimited = fmt.Errorf("wallet rate limited")
 )
 
 // Wallet represents a connected wallet able to provide preimages for LN
@@ -41,13 +74,75 @@ type WalletToken struct {
 func Command() *cli.Command {
 	return &cli.Command{
 		Name:  "wallet",
-		Usage: "",
+		Usage: "Manage the wallets paying the L402 invoices.",
 		Subcommands: []*cli.Command{
 			ConnectWalletCommand,
+			listCommand,
+			useCommand,
+			removeCommand,
+			testCommand,
+			balanceCommand,
 		},
 	}
 }
 
+// timeoutFlag bounds the requests of the wallet commands sent to the wallet
+// backends.
+var timeoutFlag = &cli.DurationFlag{
+	Name:  "timeout",
+	Usage: "The time the wallet backend is given to answer",
+	Value: 30 * time.Second,
+}
+
+// parseID parses a wallet ID given as argument.
+func parseID(arg string) (uint64, error) {
+	id, err := strconv.ParseUint(arg, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid wallet id: %s", arg)
+	}
+
+	return id, nil
+}
+
+// walletFromArgs returns the wallet with the ID given as argument, or the
+// default wallet if there is none.
+func walletFromArgs(c *cli.Context) (uint64, PreimageProvider, error) {
+	store, ok := c.App.Metadata["store"].(Store)
+	if !ok {
+		return 0, nil, errors.New("failed to get store from context")
+	}
+
+	var (
+		id  uint64
+		err error
+	)
+	if c.Args().Len() > 0 {
+		id, err = parseID(c.Args().Get(0))
+	} else {
+		id, err = store.GetDefaultWallet()
+	}
+	switch {
+	case errors.Is(err, ErrNoWalletFound):
+		return 0, nil, cli.Exit("no wallet connected", 1)
+
+	case err != nil:
+		return 0, nil, cli.Exit(err.Error(), 1)
+	}
+
+	wallet, err := GetWallet(store, id)
+	switch {
+	case errors.Is(err, ErrNoWalletFound):
+		return 0, nil, cli.Exit(fmt.Sprintf("wallet %d not found", id), 1)
+
+	case err != nil:
+		slog.Debug("Failed to load wallet.", "error", err)
+		return 0, nil, cli.Exit(fmt.Sprintf("failed to load wallet %d: %v",
+			id, err), 1)
+	}
+
+	return id, wallet, nil
+}
+
 var ConnectWalletCommand = &cli.Command{
 	Name:  "connect",
 	Usage: "Connect a new wallet",
@@ -59,8 +154,73 @@ var ConnectWalletCommand = &cli.Command{
 			Required: true,
 		},
 		&cli.StringFlag{
-			Name:  "token",
-			Usage: "The token used to connect to the wallet",
+			Name: "token",
+			Usage: "The token used to connect to the wallet, the admin " +
+				"key of LNbits wallets",
+		},
+		&cli.StringFlag{
+			Name: "server-url",
+			Usage: "The URL of the LNbits instance, or of the stand-in " +
+				"server paid by dev wallets",
+		},
+		&cli.StringFlag{
+			Name:  "preimage-dir",
+			Usage: "The preimage directory read by dev wallets",
+		},
+		&cli.StringFlag{
+			Name: "mode",
+			Usage: fmt.Sprintf("The behaviour of dev wallets: {%s}",
+				strings.Join(AllDevModes, ", ")),
+			Value: DevModeSucceed,
+		},
+		&cli.DurationFlag{
+			Name:  "delay",
+			Usage: "The time every payment of dev wallets takes",
+		},
+		&cli.StringFlag{
+			Name:  "host",
+			Usage: "The address of the LND or CLN REST API",
+		},
+		&cli.StringFlag{
+			Name:  "macaroon-path",
+			Usage: "The path of the LND macaroon file",
+		},
+		&cli.StringFlag{
+			Name:  "macaroon-hex",
+			Usage: "The hex encoded LND macaroon",
+		},
+		&cli.StringFlag{
+			Name:  "tls-cert-path",
+			Usage: "The path of the LND or CLN TLS certificate",
+		},
+		&cli.Uint64Flag{
+			Name:  "fee-limit",
+			Usage: "The maximum routing fee in sats paid per invoice",
+			Value: DefaultLNDFeeLimitSats,
+		},
+		&cli.DurationFlag{
+			Name:  "payment-timeout",
+			Usage: "The time given to the node to pay an invoice",
+			Value: DefaultLNDPaymentTimeout,
+		},
+		&cli.StringFlag{
+			Name:  "rune",
+			Usage: "The CLN rune allowed to pay invoices",
+		},
+		&cli.Float64Flag{
+			Name:  "max-fee-percent",
+			Usage: "The maximum routing fee paid by CLN, in percent",
+			Value: DefaultCLNMaxFeePercent,
+		},
+		&cli.DurationFlag{
+			Name:  "retry-for",
+			Usage: "The time CLN keeps retrying a payment",
+			Value: DefaultCLNRetryFor,
+		},
+		&cli.StringFlag{
+			Name: "uri",
+			Usage: "The nostr+walletconnect:// connection URI of " +
+				"the NWC wallet",
 		},
 	},
 	Action: connectWallet,
@@ -103,7 +263,66 @@ func GetWallet(store Store, id uint64) (PreimageProvider, error) {
 			return nil, err
 		}
 
-		provider = NewZBDClient(token)
+		client := NewZBDClient(token)
+		if ids, ok := store.(PaymentIDStore); ok {
+			client.PaymentIDs = ids
+		}
+		provider = client
+
+	case WalletTypeDev:
+		token, err := store.GetWalletToken(id)
+		if err != nil {
+			return nil, err
+		}
+
+		provider, err = newDevWalletFromToken(token)
+		if err != nil {
+			return nil, err
+		}
+
+	case WalletTypeLND:
+		conn, err := store.GetLNDConnection(id)
+		if err != nil {
+			return nil, err
+		}
+
+		provider, err = NewLNDClient(conn)
+		if err != nil {
+			return nil, err
+		}
+
+	case WalletTypeCLN:
+		conn, err := store.GetCLNConnection(id)
+		if err != nil {
+			return nil, err
+		}
+
+		provider, err = NewCLNClient(conn)
+		if err != nil {
+			return nil, err
+		}
+
+	case WalletTypeNWC:
+		token, err := store.GetWalletToken(id)
+		if err != nil {
+			return nil, err
+		}
+
+		provider, err = NewNWCClient(token)
+		if err != nil {
+			return nil, err
+		}
+
+	case WalletTypeLNbits:
+		token, err := store.GetWalletToken(id)
+		if err != nil {
+			return nil, err
+		}
+
+		provider, err = newLNbitsClientFromToken(token)
+		if err != nil {
+			return nil, err
+		}
 
 	default:
 		return nil, fmt.Errorf("unsupported wallet type: %s", wallet.Type)
@@ -126,6 +345,21 @@ func DeleteWallet(store Store, id uint64) error {
 	case "zbd":
 		return DeleteZBDWallet(store, id)
 
+	case WalletTypeDev:
+		return DeleteDevWallet(store, id)
+
+	case WalletTypeLND:
+		return DeleteLNDWallet(store, id)
+
+	case WalletTypeCLN:
+		return DeleteCLNWallet(store, id)
+
+	case WalletTypeNWC:
+		return DeleteNWCWallet(store, id)
+
+	case WalletTypeLNbits:
+		return DeleteLNbitsWallet(store, id)
+
 	default:
 		return fmt.Errorf("delete wallet %s not implemented", wallet.Type)
 	}
@@ -1,7 +1,38 @@
 package wallets
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fewsats/fewsatscli/invoices"
+)
+
+const (
+	zbdURL = "https://api.zebedee.io"
+
+	// DefaultZBDPollInterval is the wait between two checks of a payment
+	// not settled yet.
+	DefaultZBDPollInterval = time.Second
+
+	// DefaultZBDPaymentTimeout bounds the payments made without a context.
+	DefaultZBDPaymentTimeout = 60 * time.Second
+)
+
+// The states of the ZBD payments.
+const (
+	zbdStatusCompleted = "completed"
+	zbdStatusFailed    = "failed"
+	zbdStatusError     = "error"
+	zbdStatusExpired   = "expired"
 )
 
 // DeleteZBDWallet deletes the ZBD wallet with the given ID.
@@ -16,16 +47,374 @@ func DeleteZBDWallet(store Store, id uint64) error {
 	return err
 }
 
+// ZBDClient is a client for the ZBD wallet API.
 type ZBDClient struct {
+	// APIKey is the API key of the ZBD project paying the invoices.
 	APIKey string
+
+	// BaseURL is the URL of the ZBD API. If empty, the public ZBD API is
+	// used.
+	BaseURL string
+
+	// HTTPClient sends the requests. If nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+
+	// PollInterval is the wait between two checks of a payment not settled
+	// yet. If zero, DefaultZBDPollInterval is used.
+	PollInterval time.Duration
+
+	// PaymentIDs persists the IDs of the ZBD payments so they can be looked
+	// up by another process. If nil, only the payments sent by this client
+	// can be looked up.
+	PaymentIDs PaymentIDStore
+
+	// paymentIDs maps the payment hashes of the invoices paid by the client
+	// to the IDs of their ZBD payments, used to look them up.
+	paymentIDs map[string]string
+	mu         sync.Mutex
 }
 
+// NewZBDClient returns a new client for the ZBD wallet API.
 func NewZBDClient(apiKey string) *ZBDClient {
 	return &ZBDClient{
 		APIKey: apiKey,
 	}
 }
 
-func (a *ZBDClient) GetPreimage(invoice string) (string, error) {
-	return "", fmt.Errorf("not implemented")
+// ZBDPaymentRequest is the request body for the ZBD payment endpoint.
+type ZBDPaymentRequest struct {
+	Invoice     string `json:"invoice"`
+	Description string `json:"description"`
+
+	// Amount is the amount to pay in millisatoshis.
+	Amount string `json:"amount"`
+
+	// InternalID is set to the payment hash of the invoice.
+	InternalID string `json:"internalId"`
+}
+
+// ZBDPayment is a payment of the ZBD API.
+type ZBDPayment struct {
+	ID          string `json:"id"`
+	Invoice     string `json:"invoice"`
+	Preimage    string `json:"preimage"`
+	Status      string `json:"status"`
+	Amount      string `json:"amount"`
+	Fee         string `json:"fee"`
+	InternalID  string `json:"internalId"`
+	Description string `json:"description"`
+}
+
+// ZBDResponse is the envelope of the ZBD API responses.
+type ZBDResponse struct {
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// ZBDError is an error returned by the ZBD API. It matches the typed wallet
+// error of its cause with errors.Is.
+type ZBDError struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+
+	// Message is the error message sent by ZBD.
+	Message string
+}
+
+// Error returns the error message.
+func (e *ZBDError) Error() string {
+	return fmt.Sprintf("ZBD error(%d): %s", e.StatusCode, e.Message)
+}
+
+// Unwrap returns the typed wallet error of the ZBD error, nil if there is
+// none.
+func (e *ZBDError) Unwrap() error {
+	message := strings.ToLower(e.Message)
+
+	switch {
+	case e.StatusCode == http.StatusUnauthorized ||
+		e.StatusCode == http.StatusForbidden:
+
+		return ErrUnauthorized
+
+	case e.StatusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+
+	case strings.Contains(message, "enough funds") ||
+		strings.Contains(message, "insufficient"):
+
+		return ErrInsufficientBalance
+
+	// Only the rejected requests are definitive failures, a payment not
+	// found or conflicting may still be in flight.
+	case e.StatusCode == http.StatusBadRequest ||
+		e.StatusCode == http.StatusUnprocessableEntity:
+
+		return ErrPaymentFailed
+	}
+
+	return nil
+}
+
+// GetPreimage returns the preimage for the given LN invoice.
+func (z *ZBDClient) GetPreimage(invoice string) (string, error) {
+	ctx, cancel := context.WithTimeout(
+		context.Background(), DefaultZBDPaymentTimeout,
+	)
+	defer cancel()
+
+	return z.pay(ctx, invoice, 0)
+}
+
+// GetPreimageContext returns the preimage for the given LN invoice, giving up
+// when the context is done.
+func (z *ZBDClient) GetPreimageContext(ctx context.Context,
+	invoice string) (string, error) {
+
+	return z.pay(ctx, invoice, 0)
+}
+
+// GetPreimageForAmount pays the amountless LN invoice with the given amount
+// and returns its preimage.
+func (z *ZBDClient) GetPreimageForAmount(ctx context.Context, invoice string,
+	amountSats uint64) (string, error) {
+
+	return z.pay(ctx, invoice, amountSats)
+}
+
+// pay sends the payment of the LN invoice and waits until it settles,
+// amountSats is only set for amountless invoices.
+func (z *ZBDClient) pay(ctx context.Context, invoice string,
+	amountSats uint64) (string, error) {
+
+	details, err := invoices.Parse(invoice)
+	if err != nil {
+		return "", fmt.Errorf("unable to decode invoice: %w", err)
+	}
+
+	amountMsat := details.AmountMsat
+	if details.Amountless {
+		amountMsat = amountSats * 1000
+	}
+
+	var payment ZBDPayment
+	err = z.call(ctx, http.MethodPost, "/v0/payments", &ZBDPaymentRequest{
+		Invoice:     invoice,
+		Description: details.Description,
+		Amount:      strconv.FormatUint(amountMsat, 10),
+		InternalID:  details.PaymentHash,
+	}, &payment)
+	if err != nil {
+		return "", err
+	}
+
+	z.mu.Lock()
+	if z.paymentIDs == nil {
+		z.paymentIDs = make(map[string]string)
+	}
+	z.paymentIDs[details.PaymentHash] = payment.ID
+	z.mu.Unlock()
+
+	if z.PaymentIDs != nil {
+		err = z.PaymentIDs.SetWalletPaymentID(
+			details.PaymentHash, payment.ID,
+		)
+		if err != nil {
+			slog.Debug("Failed to store ZBD payment ID.", "error", err,
+				"payment_hash", details.PaymentHash)
+		}
+	}
+
+	interval := z.PollInterval
+	if interval == 0 {
+		interval = DefaultZBDPollInterval
+	}
+
+	for {
+		switch strings.ToLower(payment.Status) {
+		case zbdStatusCompleted:
+			if payment.Preimage == "" {
+				return "", fmt.Errorf("ZBD payment %s completed "+
+					"without preimage", payment.ID)
+			}
+
+			return payment.Preimage, nil
+
+		case zbdStatusFailed, zbdStatusError, zbdStatusExpired:
+			return "", fmt.Errorf("ZBD payment %s %s: %w", payment.ID,
+				payment.Status, ErrPaymentFailed)
+		}
+
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return "", fmt.Errorf("ZBD payment %s not settled: %w",
+				payment.ID, ctx.Err())
+		}
+
+		id := payment.ID
+		err = z.call(ctx, http.MethodGet, "/v0/payments/"+id, nil, &payment)
+		if err != nil {
+			return "", fmt.Errorf("unable to check ZBD payment %s: %w",
+				id, err)
+		}
+	}
+}
+
+// LookupPayment returns the status of the payment of the invoice with the
+// given payment hash. ZBD looks up the payments by the ID it returned when
+// paying, so only the payments sent by this client or whose ID is in
+// PaymentIDs can be looked up.
+func (z *ZBDClient) LookupPayment(ctx context.Context,
+	paymentHash string) (*PaymentStatus, error) {
+
+	z.mu.Lock()
+	id := z.paymentIDs[paymentHash]
+	z.mu.Unlock()
+
+	if id == "" && z.PaymentIDs != nil {
+		var err error
+		id, err = z.PaymentIDs.GetWalletPaymentID(paymentHash)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get ZBD payment ID for "+
+				"payment hash %s: %w", paymentHash, err)
+		}
+	}
+
+	// The payment may have been sent without its ID reaching the client,
+	// so it is not reported as never sent.
+	if id == "" {
+		return nil, fmt.Errorf("no ZBD payment ID for payment hash %s",
+			paymentHash)
+	}
+
+	var payment ZBDPayment
+	err := z.call(ctx, http.MethodGet, "/v0/payments/"+id, nil, &payment)
+	if err != nil {
+		return nil, fmt.Errorf("unable to check ZBD payment %s: %w", id,
+			err)
+	}
+
+	switch strings.ToLower(payment.Status) {
+	case zbdStatusCompleted:
+		return &PaymentStatus{
+			State:    PaymentSucceeded,
+			Preimage: payment.Preimage,
+		}, nil
+
+	case zbdStatusFailed, zbdStatusError, zbdStatusExpired:
+		return &PaymentStatus{State: PaymentFailed}, nil
+	}
+
+	return &PaymentStatus{State: PaymentInFlight}, nil
+}
+
+// call sends a request to the ZBD API and decodes the data of the response
+// into out.
+func (z *ZBDClient) call(ctx context.Context, method, path string, in,
+	out any) error {
+
+	baseURL := z.BaseURL
+	if baseURL == "" {
+		baseURL = zbdURL
+	}
+
+	var body io.Reader
+	if in != nil {
+		reqBodyBytes, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("unable to encode request body: %w", err)
+		}
+		body = bytes.NewReader(reqBodyBytes)
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx, method, strings.TrimRight(baseURL, "/")+path, body,
+	)
+	if err != nil {
+		return fmt.Errorf("unable to create request: %w", err)
+	}
+
+	req.Header.Set("apikey", z.APIKey)
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	client := z.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("unable to read response body: %w", err)
+	}
+
+	var response ZBDResponse
+	err = json.Unmarshal(respBodyBytes, &response)
+	if err != nil && resp.StatusCode == http.StatusOK {
+		return fmt.Errorf("unable to parse response body: %w", err)
+	}
+
+	if resp.StatusCode >= 300 || !response.Success {
+		message := response.Message
+		if message == "" {
+			message = strings.TrimSpace(string(respBodyBytes))
+		}
+
+		status := resp.StatusCode
+		if status < 300 {
+			// A failure reported with a 2xx status is a rejected
+			// request.
+			status = http.StatusBadRequest
+		}
+
+		return &ZBDError{StatusCode: status, Message: message}
+	}
+
+	err = json.Unmarshal(response.Data, out)
+	if err != nil {
+		return fmt.Errorf("unable to parse response data: %w", err)
+	}
+
+	return nil
+}
+
+// ZBDWallet is the wallet of a ZBD project.
+type ZBDWallet struct {
+	// Balance is the balance in millisatoshis.
+	Balance string `json:"balance"`
+	Unit    string `json:"unit"`
+}
+
+// CheckAuth checks that ZBD accepts the API key by reading the wallet of the
+// project.
+func (z *ZBDClient) CheckAuth(ctx context.Context) error {
+	_, err := z.Balance(ctx)
+	return err
+}
+
+// Balance returns the balance of the wallet of the ZBD project, in sats.
+func (z *ZBDClient) Balance(ctx context.Context) (uint64, error) {
+	var wallet ZBDWallet
+	err := z.call(ctx, http.MethodGet, "/v0/wallet", nil, &wallet)
+	if err != nil {
+		return 0, err
+	}
+
+	balanceMsat, err := strconv.ParseUint(wallet.Balance, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ZBD balance %q: %w", wallet.Balance,
+			err)
+	}
+
+	return balanceMsat / 1000, nil
 }
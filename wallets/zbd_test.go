@@ -0,0 +1,355 @@
+package wallets
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fewsats/fewsatscli/fewsatstest"
+	"github.com/fewsats/fewsatscli/invoices"
+	"github.com/stretchr/testify/require"
+)
+
+// zbdStandIn is a local stand-in for the ZBD payments API.
+type zbdStandIn struct {
+	t *testing.T
+
+	// payStatus and payMessage are the status code and the message of the
+	// payment responses.
+	payStatus  int
+	payMessage string
+
+	// settleAfter is the number of checks the payments stay pending for,
+	// finalStatus their status afterwards.
+	settleAfter int32
+	finalStatus string
+
+	preimage string
+	request  ZBDPaymentRequest
+	checks   atomic.Int32
+}
+
+func (z *zbdStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	require.Equal(z.t, "zbd-key", r.Header.Get("apikey"))
+
+	write := func(status int, payment *ZBDPayment, message string) {
+		data, err := json.Marshal(payment)
+		require.NoError(z.t, err)
+
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(&ZBDResponse{
+			Success: status == http.StatusOK,
+			Message: message,
+			Data:    data,
+		})
+	}
+
+	payment := &ZBDPayment{ID: "payment-1", Status: "pending"}
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/v0/payments":
+		require.NoError(z.t, json.NewDecoder(r.Body).Decode(&z.request))
+
+		status := z.payStatus
+		if status == 0 {
+			status = http.StatusOK
+		}
+		write(status, payment, z.payMessage)
+
+	case r.Method == http.MethodGet && r.URL.Path == "/v0/wallet":
+		json.NewEncoder(w).Encode(&ZBDResponse{
+			Success: true,
+			Data:    json.RawMessage(`{"balance": "1234999", "unit": "msats"}`),
+		})
+
+	case r.Method == http.MethodGet &&
+		r.URL.Path == "/v0/payments/payment-1":
+
+		if z.checks.Add(1) > z.settleAfter {
+			payment.Status = z.finalStatus
+			if payment.Status == "completed" {
+				payment.Preimage = z.preimage
+			}
+		}
+		write(http.StatusOK, payment, "")
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestZBDClient(t *testing.T) {
+	srv, err := fewsatstest.New(fewsatstest.Config{})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		standIn    *zbdStandIn
+		timeout    time.Duration
+		wantError  error
+		wantChecks int32
+	}{
+		{
+			name: "settled",
+			standIn: &zbdStandIn{
+				settleAfter: 2,
+				finalStatus: "completed",
+			},
+			wantChecks: 3,
+		},
+		{
+			name: "failed",
+			standIn: &zbdStandIn{
+				finalStatus: "failed",
+			},
+			wantError:  ErrPaymentFailed,
+			wantChecks: 1,
+		},
+		{
+			name: "unauthorized",
+			standIn: &zbdStandIn{
+				payStatus:  http.StatusUnauthorized,
+				payMessage: "Invalid API key",
+			},
+			wantError: ErrUnauthorized,
+		},
+		{
+			name: "insufficient balance",
+			standIn: &zbdStandIn{
+				payStatus:  http.StatusBadRequest,
+				payMessage: "You do not have enough funds",
+			},
+			wantError: ErrInsufficientBalance,
+		},
+		{
+			name: "rate limited",
+			standIn: &zbdStandIn{
+				payStatus:  http.StatusTooManyRequests,
+				payMessage: "Too many requests",
+			},
+			wantError: ErrRateLimited,
+		},
+		{
+			name: "never settled",
+			standIn: &zbdStandIn{
+				settleAfter: 1000,
+			},
+			timeout:   50 * time.Millisecond,
+			wantError: context.DeadlineExceeded,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			invoice, preimage, err := srv.CreateInvoice(21, tc.name)
+			require.NoError(t, err)
+
+			standIn := tc.standIn
+			standIn.t = t
+			standIn.preimage = preimage
+
+			server := httptest.NewServer(standIn)
+			defer server.Close()
+
+			client := NewZBDClient("zbd-key")
+			client.BaseURL = server.URL
+			client.PollInterval = time.Millisecond
+
+			ctx := context.Background()
+			if tc.timeout != 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tc.timeout)
+				defer cancel()
+			}
+
+			got, err := client.GetPreimageContext(ctx, invoice)
+			if tc.wantError != nil {
+				require.ErrorIs(t, err, tc.wantError)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, preimage, got)
+			}
+
+			if tc.wantChecks != 0 {
+				require.Equal(t, tc.wantChecks, standIn.checks.Load())
+			}
+
+			// The amount is sent in millisatoshis and the payment is
+			// tagged with its payment hash.
+			details, err := invoices.Parse(invoice)
+			require.NoError(t, err)
+			require.Equal(t, "21000", standIn.request.Amount)
+			require.Equal(t, details.PaymentHash, standIn.request.InternalID)
+		})
+	}
+}
+
+func TestZBDClientLookupPayment(t *testing.T) {
+	srv, err := fewsatstest.New(fewsatstest.Config{})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		standIn      *zbdStandIn
+		wantState    PaymentState
+		wantPreimage bool
+	}{
+		{
+			name: "in flight",
+			standIn: &zbdStandIn{
+				settleAfter: 1000,
+			},
+			wantState: PaymentInFlight,
+		},
+		{
+			name: "settled",
+			standIn: &zbdStandIn{
+				finalStatus: "completed",
+			},
+			wantState:    PaymentSucceeded,
+			wantPreimage: true,
+		},
+		{
+			name: "expired",
+			standIn: &zbdStandIn{
+				finalStatus: "expired",
+			},
+			wantState: PaymentFailed,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			invoice, preimage, err := srv.CreateInvoice(21, tc.name)
+			require.NoError(t, err)
+			details, err := invoices.Parse(invoice)
+			require.NoError(t, err)
+
+			standIn := tc.standIn
+			standIn.t = t
+			standIn.preimage = preimage
+
+			server := httptest.NewServer(standIn)
+			defer server.Close()
+
+			client := NewZBDClient("zbd-key")
+			client.BaseURL = server.URL
+			client.PollInterval = time.Millisecond
+
+			// The payment is unknown until the client sends it.
+			_, err = client.LookupPayment(
+				context.Background(), details.PaymentHash,
+			)
+			require.Error(t, err)
+			require.NotErrorIs(t, err, ErrPaymentNotFound)
+
+			ctx, cancel := context.WithTimeout(
+				context.Background(), 20*time.Millisecond,
+			)
+			defer cancel()
+			_, _ = client.GetPreimageContext(ctx, invoice)
+
+			status, err := client.LookupPayment(
+				context.Background(), details.PaymentHash,
+			)
+			require.NoError(t, err)
+			require.Equal(t, tc.wantState, status.State)
+			if tc.wantPreimage {
+				require.Equal(t, preimage, status.Preimage)
+			}
+		})
+	}
+}
+
+// memPaymentIDs is an in-memory store of the wallet payment IDs.
+type memPaymentIDs map[string]string
+
+func (m memPaymentIDs) SetWalletPaymentID(paymentHash, id string) error {
+	m[paymentHash] = id
+	return nil
+}
+
+func (m memPaymentIDs) GetWalletPaymentID(paymentHash string) (string,
+	error) {
+
+	return m[paymentHash], nil
+}
+
+func TestZBDClientLookupStoredPayment(t *testing.T) {
+	srv, err := fewsatstest.New(fewsatstest.Config{})
+	require.NoError(t, err)
+
+	invoice, preimage, err := srv.CreateInvoice(21, "stored")
+	require.NoError(t, err)
+	details, err := invoices.Parse(invoice)
+	require.NoError(t, err)
+
+	ids := memPaymentIDs{}
+	newClient := func(standIn *zbdStandIn) *ZBDClient {
+		server := httptest.NewServer(standIn)
+		t.Cleanup(server.Close)
+
+		client := NewZBDClient("zbd-key")
+		client.BaseURL = server.URL
+		client.PollInterval = time.Millisecond
+		client.PaymentIDs = ids
+
+		return client
+	}
+
+	// The payment is interrupted before it settles.
+	ctx, cancel := context.WithTimeout(
+		context.Background(), 20*time.Millisecond,
+	)
+	defer cancel()
+	client := newClient(&zbdStandIn{t: t, settleAfter: 1000})
+	_, err = client.GetPreimageContext(ctx, invoice)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Equal(t, "payment-1", ids[details.PaymentHash])
+
+	// Another client looks it up by its stored ID once settled.
+	client = newClient(&zbdStandIn{
+		t:           t,
+		preimage:    preimage,
+		finalStatus: "completed",
+	})
+	status, err := client.LookupPayment(
+		context.Background(), details.PaymentHash,
+	)
+	require.NoError(t, err)
+	require.Equal(t, PaymentSucceeded, status.State)
+	require.Equal(t, preimage, status.Preimage)
+}
+
+func TestZBDError(t *testing.T) {
+	err := &ZBDError{StatusCode: http.StatusInternalServerError,
+		Message: "boom"}
+	require.Nil(t, err.Unwrap())
+	require.Equal(t, "ZBD error(500): boom", err.Error())
+
+	err = &ZBDError{StatusCode: http.StatusBadRequest,
+		Message: "Invoice expired"}
+	require.ErrorIs(t, err, ErrPaymentFailed)
+
+	// A payment not found may still be in flight.
+	err = &ZBDError{StatusCode: http.StatusNotFound,
+		Message: "Payment not found"}
+	require.Nil(t, err.Unwrap())
+}
+
+func TestZBDClientBalance(t *testing.T) {
+	server := httptest.NewServer(&zbdStandIn{t: t})
+	defer server.Close()
+
+	client := NewZBDClient("zbd-key")
+	client.BaseURL = server.URL
+
+	balance, err := client.Balance(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, uint64(1234), balance)
+	require.NoError(t, client.CheckAuth(context.Background()))
+}